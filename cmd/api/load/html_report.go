@@ -0,0 +1,285 @@
+package load
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	chartWidth   = 800
+	chartHeight  = 260
+	chartPadding = 45
+)
+
+type chartSeries struct {
+	Name   string
+	Color  string
+	Values []float64
+}
+
+type statusCodeRow struct {
+	Code    int
+	Count   int
+	Percent float64
+}
+
+type htmlReportData struct {
+	Result           TestResult
+	GeneratedAt      string
+	StartTime        string
+	Duration         string
+	SuccessRate      float64
+	MinResponseMs    float64
+	MaxResponseMs    float64
+	AvgResponseMs    float64
+	StatusCodes      []statusCodeRow
+	TopErrors        []ErrorCount
+	LatencyChart     template.HTML
+	ThroughputChart  template.HTML
+	HiddenErrorCount int
+}
+
+const htmlReportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Load Test Report - {{.Result.Config.URL}}</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 0; background: #f5f6f8; color: #222; }
+  header { background: #24292f; color: #fff; padding: 20px 32px; }
+  header h1 { margin: 0 0 4px 0; font-size: 22px; }
+  header p { margin: 0; color: #c9d1d9; font-size: 13px; }
+  main { padding: 24px 32px; max-width: 1100px; }
+  section { background: #fff; border-radius: 6px; padding: 16px 20px; margin-bottom: 20px; box-shadow: 0 1px 2px rgba(0,0,0,0.08); }
+  h2 { font-size: 16px; margin: 0 0 12px 0; }
+  table { border-collapse: collapse; width: 100%; font-size: 14px; }
+  th, td { text-align: left; padding: 6px 8px; border-bottom: 1px solid #eaecef; }
+  th { background: #f6f8fa; }
+  td.num { text-align: right; font-variant-numeric: tabular-nums; }
+  .cards { display: flex; flex-wrap: wrap; gap: 12px; }
+  .card { flex: 1 1 150px; background: #f6f8fa; border-radius: 6px; padding: 12px; }
+  .card .label { font-size: 12px; color: #57606a; text-transform: uppercase; }
+  .card .value { font-size: 22px; font-weight: 600; margin-top: 4px; }
+  .ok { color: #1a7f37; }
+  .fail { color: #cf222e; }
+  .muted { color: #57606a; font-size: 13px; }
+  code { word-break: break-all; }
+</style>
+</head>
+<body>
+<header>
+  <h1>Load Test Report</h1>
+  <p>{{.Result.Config.Method}} {{.Result.Config.URL}} &middot; started {{.StartTime}} &middot; generated {{.GeneratedAt}}</p>
+</header>
+<main>
+<section>
+  <h2>Test Configuration</h2>
+  <table>
+    <tr><th>URL</th><td><code>{{.Result.Config.URL}}</code></td></tr>
+    <tr><th>Method</th><td>{{.Result.Config.Method}}</td></tr>
+    <tr><th>Concurrent users</th><td>{{.Result.Config.Concurrency}}</td></tr>
+    <tr><th>Request limit</th><td>{{if .Result.Config.Requests}}{{.Result.Config.Requests}}{{else}}unlimited{{end}}</td></tr>
+    <tr><th>Duration limit</th><td>{{if .Result.Config.DurationSeconds}}{{.Result.Config.DurationSeconds}} s{{else}}until all requests complete{{end}}</td></tr>
+    <tr><th>Request timeout</th><td>{{.Result.Config.TimeoutSeconds}} s</td></tr>
+    <tr><th>Ramp-up</th><td>{{.Result.Config.RampUpSeconds}} s</td></tr>
+    <tr><th>Think time</th><td>{{.Result.Config.ThinkTimeMillis}} ms</td></tr>
+    <tr><th>Authentication</th><td>{{if .Result.Config.AuthType}}{{.Result.Config.AuthType}}{{else}}none{{end}}</td></tr>
+  </table>
+</section>
+<section>
+  <h2>Summary</h2>
+  <div class="cards">
+    <div class="card"><div class="label">Total requests</div><div class="value">{{.Result.TotalRequests}}</div></div>
+    <div class="card"><div class="label">Successful</div><div class="value ok">{{.Result.SuccessfulRequests}}</div></div>
+    <div class="card"><div class="label">Failed</div><div class="value{{if .Result.FailedRequests}} fail{{end}}">{{.Result.FailedRequests}}</div></div>
+    <div class="card"><div class="label">Success rate</div><div class="value">{{printf "%.2f" .SuccessRate}} %</div></div>
+    <div class="card"><div class="label">Requests / s</div><div class="value">{{printf "%.2f" .Result.RPS}}</div></div>
+    <div class="card"><div class="label">Duration</div><div class="value">{{.Duration}}</div></div>
+  </div>
+  <table style="margin-top: 12px">
+    <tr><th>Min response time</th><td class="num">{{printf "%.2f" .MinResponseMs}} ms</td></tr>
+    <tr><th>Average response time</th><td class="num">{{printf "%.2f" .AvgResponseMs}} ms</td></tr>
+    <tr><th>Max response time</th><td class="num">{{printf "%.2f" .MaxResponseMs}} ms</td></tr>
+  </table>
+</section>
+<section>
+  <h2>Latency over time</h2>
+  {{.LatencyChart}}
+</section>
+<section>
+  <h2>Throughput over time</h2>
+  {{.ThroughputChart}}
+</section>
+<section>
+  <h2>Status codes</h2>
+  {{if .StatusCodes}}
+  <table>
+    <tr><th>Status code</th><th>Count</th><th>Share</th></tr>
+    {{range .StatusCodes}}<tr><td class="{{if ge .Code 400}}fail{{else}}ok{{end}}">{{.Code}}</td><td class="num">{{.Count}}</td><td class="num">{{printf "%.2f" .Percent}} %</td></tr>
+    {{end}}
+  </table>
+  {{else}}<p class="muted">No HTTP responses received.</p>{{end}}
+</section>
+<section>
+  <h2>Top errors</h2>
+  {{if .TopErrors}}
+  <table>
+    <tr><th>Error</th><th>Count</th></tr>
+    {{range .TopErrors}}<tr><td><code>{{.Message}}</code></td><td class="num">{{.Count}}</td></tr>
+    {{end}}
+  </table>
+  {{if .HiddenErrorCount}}<p class="muted">... and {{.HiddenErrorCount}} more distinct errors</p>{{end}}
+  {{else}}<p class="muted">No errors recorded.</p>{{end}}
+</section>
+</main>
+</body>
+</html>
+`
+
+var htmlReport = template.Must(template.New("report").Parse(htmlReportTemplate))
+
+func renderHTMLReport(results TestResult) ([]byte, error) {
+	data := htmlReportData{
+		Result:        results,
+		GeneratedAt:   time.Now().Format(time.RFC3339),
+		StartTime:     results.StartTime.Format(time.RFC3339),
+		Duration:      results.TotalDuration.Round(time.Millisecond).String(),
+		MinResponseMs: durationMs(results.MinResponseTime),
+		MaxResponseMs: durationMs(results.MaxResponseTime),
+		AvgResponseMs: durationMs(results.AvgResponseTime),
+	}
+	if results.SuccessfulRequests == 0 {
+		data.MinResponseMs = 0
+	}
+	if results.TotalRequests > 0 {
+		data.SuccessRate = float64(results.SuccessfulRequests) / float64(results.TotalRequests) * 100
+	}
+
+	responses := 0
+	for _, count := range results.StatusCodes {
+		responses += count
+	}
+	for code, count := range results.StatusCodes {
+		data.StatusCodes = append(data.StatusCodes, statusCodeRow{
+			Code:    code,
+			Count:   count,
+			Percent: float64(count) / float64(responses) * 100,
+		})
+	}
+	sort.Slice(data.StatusCodes, func(i, j int) bool { return data.StatusCodes[i].Code < data.StatusCodes[j].Code })
+
+	allErrors := topErrors(results.Errors, 0)
+	data.TopErrors = topErrors(results.Errors, 20)
+	data.HiddenErrorCount = len(allErrors) - len(data.TopErrors)
+
+	avgLatency := make([]float64, len(results.Timeline))
+	maxLatency := make([]float64, len(results.Timeline))
+	requests := make([]float64, len(results.Timeline))
+	failed := make([]float64, len(results.Timeline))
+	for i, bucket := range results.Timeline {
+		avgLatency[i] = durationMs(bucket.AvgResponseTime)
+		maxLatency[i] = durationMs(bucket.MaxResponseTime)
+		requests[i] = float64(bucket.Requests)
+		failed[i] = float64(bucket.FailedRequests)
+	}
+
+	data.LatencyChart = renderLineChart("ms", []chartSeries{
+		{Name: "Average", Color: "#0969da", Values: avgLatency},
+		{Name: "Max", Color: "#bf8700", Values: maxLatency},
+	})
+	data.ThroughputChart = renderLineChart("req/s", []chartSeries{
+		{Name: "Requests", Color: "#1a7f37", Values: requests},
+		{Name: "Failed", Color: "#cf222e", Values: failed},
+	})
+
+	var buffer bytes.Buffer
+	if err := htmlReport.Execute(&buffer, data); err != nil {
+		return nil, fmt.Errorf("error rendering HTML report: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// renderLineChart draws series sharing one X axis (seconds since start) as an inline SVG
+func renderLineChart(unit string, series []chartSeries) template.HTML {
+	points := 0
+	maxValue := 0.0
+	for _, s := range series {
+		if len(s.Values) > points {
+			points = len(s.Values)
+		}
+		for _, v := range s.Values {
+			maxValue = math.Max(maxValue, v)
+		}
+	}
+	if points == 0 {
+		return template.HTML(`<p class="muted">No data collected.</p>`)
+	}
+	if maxValue == 0 {
+		maxValue = 1
+	}
+
+	plotWidth := float64(chartWidth - 2*chartPadding)
+	plotHeight := float64(chartHeight - 2*chartPadding)
+	x := func(i int) float64 {
+		if points == 1 {
+			return chartPadding + plotWidth/2
+		}
+		return chartPadding + float64(i)*plotWidth/float64(points-1)
+	}
+	y := func(v float64) float64 {
+		return chartPadding + plotHeight - v/maxValue*plotHeight
+	}
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="100%%" font-family="sans-serif" font-size="11">`, chartWidth, chartHeight)
+
+	// Horizontal grid with Y axis labels
+	for i := 0; i <= 4; i++ {
+		v := maxValue * float64(i) / 4
+		fmt.Fprintf(&svg, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#eaecef"/>`, chartPadding, y(v), chartWidth-chartPadding, y(v))
+		fmt.Fprintf(&svg, `<text x="%d" y="%.1f" text-anchor="end" fill="#57606a">%s</text>`, chartPadding-6, y(v)+4, formatChartValue(v))
+	}
+	fmt.Fprintf(&svg, `<text x="%d" y="%d" fill="#57606a">%s</text>`, 4, chartPadding-14, template.HTMLEscapeString(unit))
+
+	// X axis labels (seconds since start)
+	labelStep := int(math.Max(1, math.Ceil(float64(points)/10)))
+	for i := 0; i < points; i += labelStep {
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%d" text-anchor="middle" fill="#57606a">%ds</text>`, x(i), chartHeight-chartPadding+16, i)
+	}
+	fmt.Fprintf(&svg, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#8c959f"/>`, chartPadding, chartHeight-chartPadding, chartWidth-chartPadding, chartHeight-chartPadding)
+
+	for idx, s := range series {
+		var coords strings.Builder
+		for i, v := range s.Values {
+			fmt.Fprintf(&coords, "%.1f,%.1f ", x(i), y(v))
+		}
+		fmt.Fprintf(&svg, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, s.Color, strings.TrimSpace(coords.String()))
+		if len(s.Values) == 1 {
+			fmt.Fprintf(&svg, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"/>`, x(0), y(s.Values[0]), s.Color)
+		}
+
+		// Legend
+		legendX := chartPadding + idx*110
+		fmt.Fprintf(&svg, `<rect x="%d" y="%d" width="12" height="3" fill="%s"/>`, legendX, chartHeight-12, s.Color)
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" fill="#24292f">%s</text>`, legendX+16, chartHeight-8, template.HTMLEscapeString(s.Name))
+	}
+
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
+
+func formatChartValue(v float64) string {
+	if v >= 100 || v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -65,7 +66,11 @@ Allows defining the number of concurrent requests, total requests, or test durat
 
   # SOAP request with Basic authentication
   vpd api load-test --url https://api.example.com/soap --method POST --content-type "text/xml" \
-    --data-file request.xml --auth-type basic --username "user" --password "pass"`,
+    --data-file request.xml --auth-type basic --username "user" --password "pass"
+
+  # Self-contained HTML report with charts, status codes and top errors
+  vpd api load --url https://api.example.com/endpoint --concurrency 10 --duration 60 \
+    --output html --output-file report.html`,
 	Run: func(cmd *cobra.Command, args []string) {
 		runLoadTest()
 	},
@@ -104,30 +109,63 @@ func init() {
 	// Other options
 	Cmd.Flags().BoolVar(&FlagInsecure, "insecure", false, "Skip SSL certificate verification")
 	Cmd.Flags().BoolVarP(&FlagVerbose, "verbose", "v", false, "Show detailed output")
-	Cmd.Flags().StringVar(&FlagOutputFormat, "output", "text", "Output format (text, json, csv)")
+	Cmd.Flags().StringVar(&FlagOutputFormat, "output", "text", "Output format (text, json, csv, html)")
 	Cmd.Flags().StringVar(&FlagOutputFile, "output-file", "", "File to save results")
 }
 
+type TestConfig struct {
+	URL             string `json:"url"`
+	Method          string `json:"method"`
+	Concurrency     int    `json:"concurrency"`
+	Requests        int    `json:"requests"`
+	DurationSeconds int    `json:"durationSeconds"`
+	TimeoutSeconds  int    `json:"timeoutSeconds"`
+	RampUpSeconds   int    `json:"rampUpSeconds"`
+	ThinkTimeMillis int    `json:"thinkTimeMillis"`
+	AuthType        string `json:"authType"`
+}
+
 type TestResult struct {
-	TotalRequests      int           `json:"totalRequests"`
-	SuccessfulRequests int           `json:"successfulRequests"`
+	Config             TestConfig     `json:"config"`
+	StartTime          time.Time      `json:"startTime"`
+	TotalRequests      int            `json:"totalRequests"`
+	SuccessfulRequests int            `json:"successfulRequests"`
+	FailedRequests     int            `json:"failedRequests"`
+	TotalDuration      time.Duration  `json:"totalDuration"`
+	MinResponseTime    time.Duration  `json:"minResponseTime"`
+	MaxResponseTime    time.Duration  `json:"maxResponseTime"`
+	AvgResponseTime    time.Duration  `json:"avgResponseTime"`
+	RPS                float64        `json:"requestsPerSecond"`
+	StatusCodes        map[int]int    `json:"statusCodes"`
+	Errors             map[string]int `json:"errors"`
+	Timeline           []TimeBucket   `json:"timeline"`
+}
+
+// TimeBucket aggregates requests completed within one second of the test
+type TimeBucket struct {
+	Second             int           `json:"second"`
+	Requests           int           `json:"requests"`
 	FailedRequests     int           `json:"failedRequests"`
-	TotalDuration      time.Duration `json:"totalDuration"`
-	MinResponseTime    time.Duration `json:"minResponseTime"`
-	MaxResponseTime    time.Duration `json:"maxResponseTime"`
 	AvgResponseTime    time.Duration `json:"avgResponseTime"`
-	RPS                float64       `json:"requestsPerSecond"`
-	StatusCodes        map[int]int   `json:"statusCodes"`
-	Errors             []string      `json:"errors"`
+	MaxResponseTime    time.Duration `json:"maxResponseTime"`
+	successfulRequests int
+	totalResponseTime  time.Duration
 }
 
 type RequestResult struct {
+	StartTime   time.Time
 	Duration    time.Duration
 	StatusCode  int
 	Error       error
 	ContentSize int64
 }
 
+// ErrorCount is a single aggregated error message with its number of occurrences
+type ErrorCount struct {
+	Message string
+	Count   int
+}
+
 func runLoadTest() {
 	fmt.Println("Starting load test...")
 	fmt.Printf("URL: %s\n", FlagURL)
@@ -152,9 +190,20 @@ func runLoadTest() {
 
 	// Initialize results
 	results := TestResult{
+		Config: TestConfig{
+			URL:             FlagURL,
+			Method:          FlagMethod,
+			Concurrency:     FlagConcurrency,
+			Requests:        FlagRequests,
+			DurationSeconds: FlagDuration,
+			TimeoutSeconds:  FlagTimeoutSeconds,
+			RampUpSeconds:   FlagRampUpSeconds,
+			ThinkTimeMillis: FlagThinkTimeMillis,
+			AuthType:        FlagAuthType,
+		},
 		MinResponseTime: time.Hour, // High initial value to ensure any real response time is lower
 		StatusCodes:     make(map[int]int),
-		Errors:          make([]string, 0),
+		Errors:          make(map[string]int),
 	}
 
 	// Channel for results from workers
@@ -175,6 +224,7 @@ func runLoadTest() {
 	}()
 
 	startTime := time.Now()
+	results.StartTime = startTime
 
 	// Start worker goroutines
 	for i := 0; i < FlagConcurrency; i++ {
@@ -248,10 +298,13 @@ func runLoadTest() {
 		}
 
 		results.TotalRequests++
+		bucket := timelineBucket(&results, result, startTime)
+		bucket.Requests++
 
 		if result.Error != nil {
 			results.FailedRequests++
-			results.Errors = append(results.Errors, result.Error.Error())
+			bucket.FailedRequests++
+			results.Errors[result.Error.Error()]++
 			if FlagVerbose {
 				fmt.Printf("Error: %v\n", result.Error)
 			}
@@ -259,12 +312,18 @@ func runLoadTest() {
 			results.StatusCodes[result.StatusCode]++
 			if result.StatusCode >= 400 && result.StatusCode <= 599 {
 				results.FailedRequests++
-				results.Errors = append(results.Errors, fmt.Sprintf("HTTP %d", result.StatusCode))
+				bucket.FailedRequests++
+				results.Errors[fmt.Sprintf("HTTP %d", result.StatusCode)]++
 				if FlagVerbose {
 					fmt.Printf("Error: HTTP %d\n", result.StatusCode)
 				}
 			} else {
 				results.SuccessfulRequests++
+				bucket.successfulRequests++
+				bucket.totalResponseTime += result.Duration
+				if result.Duration > bucket.MaxResponseTime {
+					bucket.MaxResponseTime = result.Duration
+				}
 				if result.Duration < results.MinResponseTime {
 					results.MinResponseTime = result.Duration
 				}
@@ -283,6 +342,17 @@ func runLoadTest() {
 		results.AvgResponseTime = totalResponseTime / time.Duration(results.SuccessfulRequests)
 		results.RPS = float64(results.SuccessfulRequests) / results.TotalDuration.Seconds()
 	}
+	for i := range results.Timeline {
+		bucket := &results.Timeline[i]
+		if bucket.successfulRequests > 0 {
+			bucket.AvgResponseTime = bucket.totalResponseTime / time.Duration(bucket.successfulRequests)
+		}
+	}
+
+	// HTML report needs a target file, fall back to a default name
+	if FlagOutputFormat == "html" && FlagOutputFile == "" {
+		FlagOutputFile = "load-test-report.html"
+	}
 
 	// Print results
 	printResults(results)
@@ -293,6 +363,40 @@ func runLoadTest() {
 	}
 }
 
+// timelineBucket returns the per-second bucket for the moment the request completed,
+// growing the timeline as needed
+func timelineBucket(results *TestResult, result RequestResult, startTime time.Time) *TimeBucket {
+	second := 0
+	if !result.StartTime.IsZero() {
+		second = int(result.StartTime.Add(result.Duration).Sub(startTime) / time.Second)
+	}
+	if second < 0 {
+		second = 0
+	}
+	for len(results.Timeline) <= second {
+		results.Timeline = append(results.Timeline, TimeBucket{Second: len(results.Timeline)})
+	}
+	return &results.Timeline[second]
+}
+
+// topErrors returns aggregated errors sorted by count (descending), limited to n entries (0 = all)
+func topErrors(errs map[string]int, n int) []ErrorCount {
+	sorted := make([]ErrorCount, 0, len(errs))
+	for message, count := range errs {
+		sorted = append(sorted, ErrorCount{Message: message, Count: count})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].Message < sorted[j].Message
+	})
+	if n > 0 && len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
 func createHTTPClient() *http.Client {
 	client := &http.Client{
 		Timeout: time.Duration(FlagTimeoutSeconds) * time.Second,
@@ -369,8 +473,9 @@ func executeRequest(client *http.Client, requestBody []byte, done <-chan struct{
 
 	if err != nil {
 		return RequestResult{
-			Duration: duration,
-			Error:    err,
+			StartTime: startTime,
+			Duration:  duration,
+			Error:     err,
 		}
 	}
 	defer resp.Body.Close()
@@ -379,6 +484,7 @@ func executeRequest(client *http.Client, requestBody []byte, done <-chan struct{
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return RequestResult{
+			StartTime:  startTime,
 			Duration:   duration,
 			StatusCode: resp.StatusCode,
 			Error:      err,
@@ -386,6 +492,7 @@ func executeRequest(client *http.Client, requestBody []byte, done <-chan struct{
 	}
 
 	return RequestResult{
+		StartTime:   startTime,
 		Duration:    duration,
 		StatusCode:  resp.StatusCode,
 		ContentSize: int64(len(body)),
@@ -412,13 +519,14 @@ func printResults(results TestResult) {
 	}
 
 	if len(results.Errors) > 0 {
-		fmt.Println("\nSample errors:")
-		for i, err := range results.Errors {
+		fmt.Println("\nTop errors:")
+		errs := topErrors(results.Errors, 0)
+		for i, e := range errs {
 			if i >= 5 {
-				fmt.Printf("... and %d more errors\n", len(results.Errors)-5)
+				fmt.Printf("... and %d more distinct errors\n", len(errs)-5)
 				break
 			}
-			fmt.Printf("  - %s\n", err)
+			fmt.Printf("  - %s (%dx)\n", e.Message, e.Count)
 		}
 	}
 }
@@ -448,7 +556,16 @@ func saveResults(results TestResult) {
 			buffer.WriteString(fmt.Sprintf("%d,%d\n", code, count))
 		}
 
+		if len(results.Errors) > 0 {
+			buffer.WriteString("\nErrors\n")
+			for _, e := range topErrors(results.Errors, 0) {
+				buffer.WriteString(fmt.Sprintf("%q,%d\n", e.Message, e.Count))
+			}
+		}
+
 		data = buffer.Bytes()
+	case "html":
+		data, err = renderHTMLReport(results)
 	default:
 		var buffer bytes.Buffer
 		buffer.WriteString("--- Load Test Results ---\n")
@@ -0,0 +1,16 @@
+package broker
+
+import (
+	"github.com/VojtechPastyrik/vpd/cmd/root"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "broker",
+	Short: "Message broker CLI Utils",
+	Long:  "Broker-agnostic CLI Utils, which work the same way with RabbitMQ, NATS, JetStream and Kafka-compatible brokers.",
+}
+
+func init() {
+	root.RootCmd.AddCommand(Cmd)
+}
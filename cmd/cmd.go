@@ -7,6 +7,8 @@ import (
 	_ "github.com/VojtechPastyrik/vpd/cmd/azure/subscription"
 	_ "github.com/VojtechPastyrik/vpd/cmd/base64"
 	_ "github.com/VojtechPastyrik/vpd/cmd/base64/interactive_clipboard"
+	_ "github.com/VojtechPastyrik/vpd/cmd/broker"
+	_ "github.com/VojtechPastyrik/vpd/cmd/broker/load"
 	_ "github.com/VojtechPastyrik/vpd/cmd/cert"
 	_ "github.com/VojtechPastyrik/vpd/cmd/cert/check"
 	_ "github.com/VojtechPastyrik/vpd/cmd/cheat"
@@ -60,8 +62,21 @@ import (
 	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq"
 	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/function"
 	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/load"
+	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/management"
+	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/management/delete_queue"
+	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/management/export_definitions"
+	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/management/import_definitions"
+	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/management/list"
+	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/management/purge_queue"
+	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/move"
+	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/profile"
+	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/profile/add"
+	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/profile/list"
+	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/profile/remove"
+	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/profile/test_connection"
 	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/put_message"
 	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/read_queue"
+	_ "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/replay"
 	_ "github.com/VojtechPastyrik/vpd/cmd/release"
 	"github.com/VojtechPastyrik/vpd/cmd/root"
 	_ "github.com/VojtechPastyrik/vpd/cmd/ssh"
@@ -76,8 +91,28 @@ import (
 	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino"
 	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/create"
 	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/decrypt"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/diff"
 	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/edit"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/env"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/exec"
 	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/get"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/git"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/git/setup"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/grep"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/info"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/keygen"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/keys"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/migrate"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/recipients"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/recipients/add"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/recipients/list"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/recipients/remove"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/rekey"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/render"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/set"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/textconv"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/unset"
+	_ "github.com/VojtechPastyrik/vpd/cmd/vaultino/verify"
 	_ "github.com/VojtechPastyrik/vpd/cmd/youtrack"
 	_ "github.com/VojtechPastyrik/vpd/cmd/youtrack/track_time"
 	"github.com/spf13/cobra"
@@ -0,0 +1,151 @@
+package rabbitmq
+
+import (
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	rabbitmqUtils "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/spf13/cobra"
+)
+
+// ConnectionFlags are the connection flags shared by all rabbitmq commands
+type ConnectionFlags struct {
+	Profile        string
+	URI            string
+	Host           string
+	Port           int
+	User           string
+	Password       string
+	VirtualHost    string
+	Ssl            bool
+	SslCA          string
+	SslServerName  string
+	Insecure       bool
+	SslCert        string
+	SslKey         string
+	SslP12         string
+	SslP12Password string
+	ExternalAuth   bool
+	// changed reports whether a flag was set explicitly
+	changed func(name string) bool
+}
+
+// AddConnectionFlags registers the connection flags on the command
+func AddConnectionFlags(cmd *cobra.Command) *ConnectionFlags {
+	f := addConnectionFlags(cmd.Flags())
+	f.changed = cmd.Flags().Changed
+	return f
+}
+
+// AddPersistentConnectionFlags registers the connection flags on a command group, so they
+// are shared by all its subcommands
+func AddPersistentConnectionFlags(cmd *cobra.Command) *ConnectionFlags {
+	f := addConnectionFlags(cmd.PersistentFlags())
+	f.changed = cmd.PersistentFlags().Changed
+	return f
+}
+
+// flagSet is the part of pflag.FlagSet used to register the connection flags
+type flagSet interface {
+	StringVar(p *string, name, value, usage string)
+	StringVarP(p *string, name, shorthand, value, usage string)
+	IntVarP(p *int, name, shorthand string, value int, usage string)
+	BoolVar(p *bool, name string, value bool, usage string)
+	BoolVarP(p *bool, name, shorthand string, value bool, usage string)
+}
+
+func addConnectionFlags(flags flagSet) *ConnectionFlags {
+	f := &ConnectionFlags{}
+	flags.StringVar(&f.Profile, "profile", "", "Connection profile from ~/.config/vpd/rabbitmq.yaml (flags override profile values)")
+	flags.StringVar(&f.URI, "uri", "", "AMQP URI, e.g. amqps://user@host:5671/vhost")
+	flags.StringVarP(&f.Host, "host", "H", "localhost", "RabbitMQ host")
+	flags.IntVarP(&f.Port, "port", "P", 5672, "RabbitMQ port")
+	flags.StringVarP(&f.User, "user", "u", "guest", "RabbitMQ user")
+	flags.StringVarP(&f.Password, "password", "p", "guest", "RabbitMQ password")
+	flags.StringVarP(&f.VirtualHost, "vhost", "v", "/", "RabbitMQ virtual host")
+	flags.BoolVarP(&f.Ssl, "ssl", "s", false, "Use SSL for RabbitMQ connection")
+	flags.StringVar(&f.SslCA, "ssl-ca", "", "PEM bundle of CA certificates trusted in addition to the system roots")
+	flags.StringVar(&f.SslServerName, "ssl-server-name", "", "Server name to verify the broker certificate against (default host)")
+	flags.BoolVar(&f.Insecure, "insecure", false, "Skip verification of the broker certificate")
+	flags.StringVarP(&f.SslCert, "ssl-cert", "c", "", "Path to PEM client certificate file")
+	flags.StringVarP(&f.SslKey, "ssl-key", "k", "", "Path to PEM client key file")
+	flags.StringVar(&f.SslP12, "ssl-p12", "", "Path to PKCS#12 file with the client certificate and key")
+	flags.StringVar(&f.SslP12Password, "ssl-p12-password", "", "Password of the PKCS#12 file")
+	flags.BoolVar(&f.ExternalAuth, "external-auth", false, "Authenticate with the client certificate (SASL EXTERNAL)")
+	return f
+}
+
+// Options resolves the connection profile and applies the flags on top of it. Without a
+// profile or URI the flag defaults are used, otherwise only explicitly set flags.
+func (f *ConnectionFlags) Options() (rabbitmqUtils.ConnectionOptions, error) {
+	var options rabbitmqUtils.ConnectionOptions
+	if f.Profile != "" {
+		profile, err := rabbitmqUtils.GetProfile(f.Profile)
+		if err != nil {
+			return options, err
+		}
+		options, err = profile.Options()
+		if err != nil {
+			return options, err
+		}
+	}
+
+	useDefaults := f.Profile == "" && f.URI == ""
+	set := func(name string) bool {
+		return useDefaults || f.changed(name)
+	}
+	if f.URI != "" {
+		options.URI = f.URI
+	}
+	if set("host") {
+		options.Host = f.Host
+	}
+	if set("port") {
+		options.Port = f.Port
+	}
+	if set("user") {
+		options.User = f.User
+	}
+	if set("password") {
+		options.Password = f.Password
+	}
+	if set("vhost") {
+		options.VirtualHost = f.VirtualHost
+	}
+	if f.changed("ssl") {
+		options.SSL = f.Ssl
+	}
+	if f.SslCA != "" {
+		options.TLS.CACertPath = f.SslCA
+	}
+	if f.SslServerName != "" {
+		options.TLS.ServerName = f.SslServerName
+	}
+	if f.changed("insecure") {
+		options.TLS.Insecure = f.Insecure
+	}
+	if f.SslCert != "" || f.SslKey != "" {
+		options.TLS.CertPath, options.TLS.KeyPath, options.TLS.P12Path = f.SslCert, f.SslKey, ""
+	}
+	if f.SslP12 != "" {
+		options.TLS.P12Path, options.TLS.CertPath, options.TLS.KeyPath = f.SslP12, "", ""
+	}
+	if f.SslP12Password != "" {
+		options.TLS.P12Password = f.SslP12Password
+	}
+	if f.changed("external-auth") {
+		options.ExternalAuth = f.ExternalAuth
+	}
+	if options.TLS.Insecure {
+		logger.Warn("TLS certificate verification is disabled")
+	}
+	return options, nil
+}
+
+// Connect resolves the connection options and connects to RabbitMQ
+func (f *ConnectionFlags) Connect() (*amqp091.Connection, *amqp091.Channel, error) {
+	options, err := f.Options()
+	if err != nil {
+		return nil, nil, err
+	}
+	return rabbitmqUtils.Connect(options)
+}
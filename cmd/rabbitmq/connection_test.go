@@ -0,0 +1,79 @@
+package rabbitmq
+
+import (
+	"reflect"
+	"testing"
+
+	rabbitmqUtils "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/spf13/cobra"
+)
+
+// parseConnectionFlags registers the connection flags on a new command and parses the args
+func parseConnectionFlags(t *testing.T, args ...string) *ConnectionFlags {
+	t.Helper()
+	cmd := &cobra.Command{Use: "test"}
+	flags := AddConnectionFlags(cmd)
+	if err := cmd.ParseFlags(args); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return flags
+}
+
+func TestConnectionFlags_Options(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("VPD_TEST_RABBIT_PASSWORD", "from-profile")
+	err := rabbitmqUtils.SaveProfiles(map[string]rabbitmqUtils.Profile{
+		"prod": {Host: "rabbit", Port: 5671, User: "app", PasswordEnv: "VPD_TEST_RABBIT_PASSWORD", VirtualHost: "orders", SSL: true, Insecure: true, SSLCert: "client.pem", SSLKey: "client.key"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	profile := rabbitmqUtils.ConnectionOptions{
+		Host: "rabbit", Port: 5671, User: "app", Password: "from-profile", VirtualHost: "orders", SSL: true,
+		TLS: rabbitmqUtils.TLSOptions{Insecure: true, CertPath: "client.pem", KeyPath: "client.key"},
+	}
+	defaults := rabbitmqUtils.ConnectionOptions{Host: "localhost", Port: 5672, User: "guest", Password: "guest", VirtualHost: "/"}
+
+	tests := []struct {
+		name   string
+		args   []string
+		modify func(o *rabbitmqUtils.ConnectionOptions)
+		base   rabbitmqUtils.ConnectionOptions
+	}{
+		{"defaults without a profile", nil, nil, defaults},
+		{"flags without a profile", []string{"-H", "rabbit", "--ssl"}, func(o *rabbitmqUtils.ConnectionOptions) { o.Host, o.SSL = "rabbit", true }, defaults},
+		{"profile keeps its values over unchanged defaults", []string{"--profile", "prod"}, nil, profile},
+		{"changed flags override the profile", []string{"--profile", "prod", "--port", "5672", "-u", "admin", "--ssl=false", "--insecure=false"},
+			func(o *rabbitmqUtils.ConnectionOptions) {
+				o.Port, o.User, o.SSL, o.TLS.Insecure = 5672, "admin", false, false
+			}, profile},
+		{"flag set to its default overrides the profile", []string{"--profile", "prod", "--vhost", "/"}, func(o *rabbitmqUtils.ConnectionOptions) { o.VirtualHost = "/" }, profile},
+		{"PKCS#12 flag replaces the profile certificate", []string{"--profile", "prod", "--ssl-p12", "client.p12"},
+			func(o *rabbitmqUtils.ConnectionOptions) {
+				o.TLS.CertPath, o.TLS.KeyPath, o.TLS.P12Path = "", "", "client.p12"
+			}, profile},
+		{"URI does not use the flag defaults", []string{"--uri", "amqp://localhost/"}, nil, rabbitmqUtils.ConnectionOptions{URI: "amqp://localhost/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := parseConnectionFlags(t, tt.args...).Options()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected := tt.base
+			if tt.modify != nil {
+				tt.modify(&expected)
+			}
+			if !reflect.DeepEqual(options, expected) {
+				t.Errorf("expected %+v, got %+v", expected, options)
+			}
+		})
+	}
+}
+
+func TestConnectionFlags_UnknownProfile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if _, err := parseConnectionFlags(t, "--profile", "missing").Options(); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
@@ -0,0 +1,449 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	rabbitmqUtils "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// check is a single functional test of the suite
+type check struct {
+	name        string
+	description string
+	run         func(s *suite) error
+}
+
+var checks = []check{
+	{"round-trip", "Publish a message and consume it back", checkRoundTrip},
+	{"publisher-confirms", "Broker acknowledges a published message", checkPublisherConfirms},
+	{"mandatory-return", "Unroutable mandatory message is returned", checkMandatoryReturn},
+	{"ttl-expiry", "Message with expiration is removed from the queue", checkTTLExpiry},
+	{"dead-lettering", "Rejected message is dead-lettered with x-death", checkDeadLettering},
+	{"quorum-queue", "Quorum queue can be declared and used", checkQuorumQueue},
+	{"consumer-cancel", "Consumer is notified when its queue is deleted", checkConsumerCancel},
+	{"connection-recovery", "Client reconnects after the connection is closed abruptly", checkConnectionRecovery},
+}
+
+func checkNames() []string {
+	names := make([]string, len(checks))
+	for i, c := range checks {
+		names[i] = c.name
+	}
+	return names
+}
+
+// suite holds the shared connection and the resources to clean up
+type suite struct {
+	options rabbitmqUtils.ConnectionOptions
+	con     *amqp091.Connection
+	timeout time.Duration
+	// prefix makes the names of the test resources unique
+	prefix string
+	// exchange, queue and routingKey are used by the round trip, either given or created
+	exchange   string
+	queue      string
+	routingKey string
+	userGiven  bool
+
+	queues    []string
+	exchanges []string
+}
+
+func (s *suite) name(suffix string) string {
+	return s.prefix + "-" + suffix
+}
+
+// channel opens a new channel, a failed check closes only its own channel
+func (s *suite) channel() (*amqp091.Channel, error) {
+	ch, err := s.con.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a channel: %w", err)
+	}
+	return ch, nil
+}
+
+// declareQueue declares a temporary queue and remembers it for the cleanup
+func (s *suite) declareQueue(ch *amqp091.Channel, name string, durable bool, args amqp091.Table) error {
+	if _, err := ch.QueueDeclare(name, durable, false, false, false, args); err != nil {
+		return fmt.Errorf("failed to declare queue '%s': %w", name, err)
+	}
+	s.queues = append(s.queues, name)
+	return nil
+}
+
+func (s *suite) declareExchange(ch *amqp091.Channel, name, kind string) error {
+	if err := ch.ExchangeDeclare(name, kind, false, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare exchange '%s': %w", name, err)
+	}
+	s.exchanges = append(s.exchanges, name)
+	return nil
+}
+
+// cleanup deletes all resources declared by the checks
+func (s *suite) cleanup() {
+	if s.con == nil || s.con.IsClosed() {
+		return
+	}
+	ch, err := s.channel()
+	if err != nil {
+		logger.Errorf("cleanup failed: %v", err)
+		return
+	}
+	defer ch.Close()
+	for _, queue := range s.queues {
+		if _, err := ch.QueueDelete(queue, false, false, false); err != nil {
+			logger.Errorf("failed to delete queue '%s': %v", queue, err)
+			if ch, err = s.channel(); err != nil {
+				return
+			}
+		}
+	}
+	for _, exchange := range s.exchanges {
+		if err := ch.ExchangeDelete(exchange, false, false); err != nil {
+			logger.Errorf("failed to delete exchange '%s': %v", exchange, err)
+			if ch, err = s.channel(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// publishConfirmed publishes on a confirm channel and waits for the broker ack
+func (s *suite) publishConfirmed(ch *amqp091.Channel, exchange, routingKey string, mandatory bool, publishing amqp091.Publishing) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, mandatory, false, publishing)
+	if err != nil {
+		return fmt.Errorf("failed to publish: %w", err)
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("no confirm within %s", s.timeout)
+	}
+	if !acked {
+		return fmt.Errorf("message was nacked by the broker")
+	}
+	return nil
+}
+
+// receive waits for a delivery or the timeout
+func (s *suite) receive(deliveries <-chan amqp091.Delivery) (amqp091.Delivery, error) {
+	select {
+	case d, ok := <-deliveries:
+		if !ok {
+			return d, fmt.Errorf("consumer was closed")
+		}
+		return d, nil
+	case <-time.After(s.timeout):
+		return amqp091.Delivery{}, fmt.Errorf("no message received within %s", s.timeout)
+	}
+}
+
+func testMessage(check string) amqp091.Publishing {
+	return amqp091.Publishing{
+		ContentType: "application/json",
+		Body:        []byte(fmt.Sprintf(`{"message": "This is a test message", "check": "%s"}`, check)),
+	}
+}
+
+func checkRoundTrip(s *suite) error {
+	ch, err := s.channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	if !s.userGiven {
+		if err := s.declareExchange(ch, s.exchange, "direct"); err != nil {
+			return err
+		}
+		if err := s.declareQueue(ch, s.queue, false, nil); err != nil {
+			return err
+		}
+		if err := ch.QueueBind(s.queue, s.routingKey, s.exchange, false, nil); err != nil {
+			return fmt.Errorf("failed to bind queue to exchange: %w", err)
+		}
+	}
+
+	if err := ch.Publish(s.exchange, s.routingKey, false, false, testMessage("round-trip")); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	msgs, err := ch.Consume(s.queue, "", true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume messages: %w", err)
+	}
+	_, err = s.receive(msgs)
+	return err
+}
+
+func checkPublisherConfirms(s *suite) error {
+	ch, err := s.channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	if err := ch.Confirm(false); err != nil {
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	queue := s.name("confirms")
+	if err := s.declareQueue(ch, queue, false, nil); err != nil {
+		return err
+	}
+	return s.publishConfirmed(ch, "", queue, false, testMessage("publisher-confirms"))
+}
+
+func checkMandatoryReturn(s *suite) error {
+	ch, err := s.channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	if err := ch.Confirm(false); err != nil {
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	returns := ch.NotifyReturn(make(chan amqp091.Return, 1))
+
+	exchange := s.name("unroutable")
+	if err := s.declareExchange(ch, exchange, "direct"); err != nil {
+		return err
+	}
+	if err := s.publishConfirmed(ch, exchange, "no-binding", true, testMessage("mandatory-return")); err != nil {
+		return err
+	}
+	// The return is sent before the confirm of the same message
+	select {
+	case r := <-returns:
+		if r.ReplyCode != amqp091.NoRoute {
+			return fmt.Errorf("expected reply code %d (NO_ROUTE), got %d %s", amqp091.NoRoute, r.ReplyCode, r.ReplyText)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unroutable message was confirmed without basic.return")
+	}
+}
+
+func checkTTLExpiry(s *suite) error {
+	ch, err := s.channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	queue := s.name("ttl")
+	if err := s.declareQueue(ch, queue, false, nil); err != nil {
+		return err
+	}
+	publishing := testMessage("ttl-expiry")
+	publishing.Expiration = "100"
+	if err := ch.Publish("", queue, false, false, publishing); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	deadline := time.Now().Add(s.timeout)
+	for {
+		time.Sleep(200 * time.Millisecond)
+		// A passive declare reports the ready messages, expired ones are not counted
+		q, err := ch.QueueDeclarePassive(queue, false, false, false, false, nil)
+		if err != nil {
+			return fmt.Errorf("failed to inspect queue: %w", err)
+		}
+		if q.Messages == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("message with 100ms expiration still in the queue after %s", s.timeout)
+		}
+	}
+}
+
+func checkDeadLettering(s *suite) error {
+	ch, err := s.channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	dlx, dlq, queue := s.name("dlx"), s.name("dlq"), s.name("dead-letter-source")
+	if err := s.declareExchange(ch, dlx, "fanout"); err != nil {
+		return err
+	}
+	if err := s.declareQueue(ch, dlq, false, nil); err != nil {
+		return err
+	}
+	if err := ch.QueueBind(dlq, "", dlx, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead letter queue: %w", err)
+	}
+	if err := s.declareQueue(ch, queue, false, amqp091.Table{"x-dead-letter-exchange": dlx}); err != nil {
+		return err
+	}
+
+	if err := ch.Publish("", queue, false, false, testMessage("dead-lettering")); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	msgs, err := ch.Consume(queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume messages: %w", err)
+	}
+	msg, err := s.receive(msgs)
+	if err != nil {
+		return err
+	}
+	if err := msg.Reject(false); err != nil {
+		return fmt.Errorf("failed to reject message: %w", err)
+	}
+
+	deadLetters, err := ch.Consume(dlq, "", true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume dead letters: %w", err)
+	}
+	deadLetter, err := s.receive(deadLetters)
+	if err != nil {
+		return fmt.Errorf("rejected message not dead-lettered: %w", err)
+	}
+	deaths, ok := deadLetter.Headers["x-death"].([]any)
+	if !ok || len(deaths) == 0 {
+		return fmt.Errorf("dead letter has no x-death header")
+	}
+	if death, ok := deaths[0].(amqp091.Table); !ok || death["reason"] != "rejected" {
+		return fmt.Errorf("unexpected x-death header: %v", deaths[0])
+	}
+	return nil
+}
+
+func checkQuorumQueue(s *suite) error {
+	ch, err := s.channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	queue := s.name("quorum")
+	if err := s.declareQueue(ch, queue, true, amqp091.Table{"x-queue-type": "quorum"}); err != nil {
+		return err
+	}
+	if err := ch.Publish("", queue, false, false, testMessage("quorum-queue")); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	msgs, err := ch.Consume(queue, "", true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume messages: %w", err)
+	}
+	_, err = s.receive(msgs)
+	return err
+}
+
+func checkConsumerCancel(s *suite) error {
+	ch, err := s.channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	cancels := ch.NotifyCancel(make(chan string, 1))
+
+	queue := s.name("cancel")
+	if err := s.declareQueue(ch, queue, false, nil); err != nil {
+		return err
+	}
+	consumerTag := s.name("consumer")
+	if _, err := ch.Consume(queue, consumerTag, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to consume messages: %w", err)
+	}
+
+	// Delete the queue from another channel, the broker cancels the consumer
+	deleteCh, err := s.channel()
+	if err != nil {
+		return err
+	}
+	defer deleteCh.Close()
+	if _, err := deleteCh.QueueDelete(queue, false, false, false); err != nil {
+		return fmt.Errorf("failed to delete queue: %w", err)
+	}
+
+	select {
+	case tag := <-cancels:
+		if tag != consumerTag {
+			return fmt.Errorf("expected cancel of consumer '%s', got '%s'", consumerTag, tag)
+		}
+		return nil
+	case <-time.After(s.timeout):
+		return fmt.Errorf("no basic.cancel received within %s", s.timeout)
+	}
+}
+
+// recordingDialer keeps the last TCP connection, so it can be closed abruptly
+type recordingDialer struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (d *recordingDialer) dial(network, addr string) (net.Conn, error) {
+	conn, err := amqp091.DefaultDial(30*time.Second)(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.conn = conn
+	d.mu.Unlock()
+	return conn, nil
+}
+
+func (d *recordingDialer) kill() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn != nil {
+		d.conn.Close()
+	}
+}
+
+func checkConnectionRecovery(s *suite) error {
+	dialer := &recordingDialer{}
+	options := s.options
+	options.Dial = dialer.dial
+
+	con, ch, err := rabbitmqUtils.Connect(options)
+	if err != nil {
+		return err
+	}
+	closed := con.NotifyClose(make(chan *amqp091.Error, 1))
+	ch.Close()
+
+	dialer.kill()
+	select {
+	case err := <-closed:
+		if err == nil {
+			return fmt.Errorf("connection was closed gracefully instead of failing")
+		}
+	case <-time.After(s.timeout):
+		con.Close()
+		return fmt.Errorf("connection loss not detected within %s", s.timeout)
+	}
+
+	// Recover: reconnect, redeclare the topology and do a round trip
+	con, ch, err = rabbitmqUtils.Connect(options)
+	if err != nil {
+		return fmt.Errorf("reconnect failed: %w", err)
+	}
+	defer con.Close()
+	defer ch.Close()
+
+	queue := s.name("recovery")
+	if err := s.declareQueue(ch, queue, false, nil); err != nil {
+		return err
+	}
+	if err := ch.Publish("", queue, false, false, testMessage("connection-recovery")); err != nil {
+		return fmt.Errorf("failed to publish after reconnect: %w", err)
+	}
+	msgs, err := ch.Consume(queue, "", true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume after reconnect: %w", err)
+	}
+	_, err = s.receive(msgs)
+	return err
+}
@@ -1,184 +1,194 @@
 package function
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"os"
+	"slices"
+	"strings"
 	"time"
 
 	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq"
-	rabbitmqUtisl "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
 	"github.com/pterm/pterm"
-	"github.com/rabbitmq/amqp091-go"
 	"github.com/spf13/cobra"
 )
 
 var (
-	FlagHost        string
-	FlagPort        int
-	FlagUser        string
-	FlagPassword    string
-	FlagVirtualHost string
-	FlagExchange    string
-	FlagQueue       string
-	FlagRoutingKey  string
-	FlagSsl         bool
-	FlagSslCert     string
-	FlagSslKey      string
+	FlagConnection *parent_cmd.ConnectionFlags
+	FlagExchange   string
+	FlagQueue      string
+	FlagRoutingKey string
+	FlagTimeout    time.Duration
+	FlagSkip       []string
+	FlagOnly       []string
+	FlagOutput     string
+	FlagOutputFile string
 )
 
 var Cmd = &cobra.Command{
 	Use:     "func-test",
 	Aliases: []string{"ft"},
 	Short:   "Run functional test on RabbitMQ server",
-	Long:    "Run functional test on RabbitMQ server. It will connect to the server, create exchange, queue and binding, send a message, read it back and clean up after the test.",
+	Long: `Run functional test suite on RabbitMQ server. It connects to the server and checks:
+` + checkList() + `
+All test objects get a unique name and are deleted after the test. The command exits with status 1 when a check fails, so it can be used as a post-deploy smoke check.`,
+	Example: `  vpd rabbitmq func-test --profile prod
+  vpd rabbitmq func-test --profile stage --skip quorum-queue --output junit --output-file rabbitmq-junit.xml
+
+  # Round trip through an existing direct exchange and queue
+  vpd rabbitmq func-test --profile prod --exchange orders --queue orders.health --routing-key health --only round-trip`,
 	Run: func(cmd *cobra.Command, args []string) {
-		runFunctionalTest(FlagHost, FlagPort, FlagUser, FlagPassword, FlagVirtualHost, FlagExchange, FlagQueue, FlagRoutingKey, FlagSsl, FlagSslCert, FlagSslKey)
+		runFunctionalTest(FlagConnection, FlagExchange, FlagQueue, FlagRoutingKey)
 	},
 }
 
 func init() {
 	parent_cmd.Cmd.AddCommand(Cmd)
-	Cmd.Flags().StringVarP(&FlagHost, "host", "H", "localhost", "RabbitMQ host")
-	Cmd.MarkFlagRequired("host")
-	Cmd.Flags().IntVarP(&FlagPort, "port", "P", 5672, "RabbitMQ port")
-	Cmd.MarkFlagRequired("port")
-	Cmd.Flags().StringVarP(&FlagUser, "user", "u", "guest", "RabbitMQ user")
-	Cmd.MarkFlagRequired("user")
-	Cmd.Flags().StringVarP(&FlagPassword, "password", "p", "guest", "RabbitMQ password")
-	Cmd.MarkFlagRequired("password")
-	Cmd.Flags().StringVarP(&FlagVirtualHost, "vhost", "v", "/", "RabbitMQ virtual host")
-	Cmd.Flags().StringVarP(&FlagExchange, "exchange", "e", "", "RabbitMQ exchange name to use for testing")
-	Cmd.Flags().StringVarP(&FlagQueue, "queue", "q", "", "RabbitMQ queue name to use for testing")
-	Cmd.Flags().StringVarP(&FlagRoutingKey, "routing-key", "r", "", "RabbitMQ routing key to use for testing")
-	Cmd.Flags().BoolVarP(&FlagSsl, "ssl", "s", false, "Use SSL for RabbitMQ connection")
-	Cmd.Flags().StringVarP(&FlagSslCert, "ssl-cert", "c", "", "Path to SSL certificate file")
-	Cmd.Flags().StringVarP(&FlagSslKey, "ssl-key", "k", "", "Path to SSL key file")
+	FlagConnection = parent_cmd.AddConnectionFlags(Cmd)
+	Cmd.Flags().StringVarP(&FlagExchange, "exchange", "e", "", "RabbitMQ exchange name to use for the round trip")
+	Cmd.Flags().StringVarP(&FlagQueue, "queue", "q", "", "RabbitMQ queue name to use for the round trip")
+	Cmd.Flags().StringVarP(&FlagRoutingKey, "routing-key", "r", "", "RabbitMQ routing key to use for the round trip")
+	Cmd.Flags().DurationVar(&FlagTimeout, "timeout", 5*time.Second, "How long each check waits for the broker")
+	Cmd.Flags().StringSliceVar(&FlagSkip, "skip", nil, "Checks to skip: "+strings.Join(checkNames(), ", "))
+	Cmd.Flags().StringSliceVar(&FlagOnly, "only", nil, "Run only these checks")
+	Cmd.Flags().StringVarP(&FlagOutput, "output", "o", "", "Write machine-readable results: json, junit")
+	Cmd.Flags().StringVar(&FlagOutputFile, "output-file", "", "File for the results (default stdout)")
 }
 
-func runFunctionalTest(host string, port int, user, password, virtualHost, exchange, queue, routingKey string, ssl bool, sslCert, sslKey string) {
-	if exchange == "" && (queue != "" || routingKey != "") {
+func checkList() string {
+	var lines []string
+	for _, c := range checks {
+		lines = append(lines, fmt.Sprintf("  %-20s %s", c.name, c.description))
+	}
+	return strings.Join(lines, "\n")
+}
 
+func runFunctionalTest(connection *parent_cmd.ConnectionFlags, exchange, queue, routingKey string) {
+	if exchange == "" && (queue != "" || routingKey != "") {
 		pterm.Warning.Println("If one of 'exchange', 'queue', or 'routingKey' is set, all must be set. Only direct exchange can be used for functional testing.")
 		return
 	}
-	exchangeUsed := exchange
-	if exchangeUsed == "" {
-		exchangeUsed = "rabbitmq-function-test-exchange"
+	if FlagOutput != "" && FlagOutput != "json" && FlagOutput != "junit" {
+		logger.Fatalf("unsupported output format '%s', use json or junit", FlagOutput)
+	}
+	for _, name := range append(slices.Clone(FlagSkip), FlagOnly...) {
+		if !slices.Contains(checkNames(), name) {
+			logger.Fatalf("unknown check '%s', available checks: %s", name, strings.Join(checkNames(), ", "))
+		}
+	}
+	// Spinners and the table would mix with machine-readable results on stdout
+	interactive := FlagOutput == "" || FlagOutputFile != ""
+
+	options, err := connection.Options()
+	if err != nil {
+		logger.Fatalf("%v", err)
 	}
-	queueUsed := queue
-	if queueUsed == "" {
-		queueUsed = "rabbitmq-function-test-queue"
+	s := &suite{
+		options:    options,
+		timeout:    FlagTimeout,
+		prefix:     "vpd-func-test-" + randomSuffix(),
+		exchange:   exchange,
+		queue:      queue,
+		routingKey: routingKey,
+		userGiven:  exchange != "",
 	}
-	routingKeyUsed := routingKey
-	if routingKeyUsed == "" {
-		routingKeyUsed = "rabbitmq-function-test-routing-key"
+	if !s.userGiven {
+		s.exchange, s.queue, s.routingKey = s.name("exchange"), s.name("queue"), s.name("routing-key")
 	}
 
+	report := &SuiteReport{Target: options.Redacted(), StartTime: time.Now()}
+
 	// Task: Connect to RabbitMQ
-	spinnerConnect, _ := pterm.DefaultSpinner.Start("Running Connect to RabbitMQ...")
-	con, ch, err := rabbitmqUtisl.ConnectToRabbitMQ(ssl, user, password, host, port, virtualHost, sslCert, sslKey)
-	if err != nil {
-		spinnerConnect.Fail(fmt.Sprintf("Connection to RabbitMQ failed: %s", err.Error()))
-		return
+	var spinner *pterm.SpinnerPrinter
+	if interactive {
+		spinner, _ = pterm.DefaultSpinner.Start("Running Connect to RabbitMQ...")
 	}
-	spinnerConnect.Success("Connected to RabbitMQ")
-	defer func() {
-		if err := con.Close(); err != nil {
-			pterm.Error.Printf("Error closing RabbitMQ connection: %s\n", err)
-		}
-	}()
-	defer func() {
-		if err := ch.Close(); err != nil {
-			pterm.Error.Printf("Error closing RabbitMQ channel: %s\n", err)
+	con, ch, connectErr := connection.Connect()
+	if connectErr == nil {
+		ch.Close()
+		s.con = con
+		defer con.Close()
+	}
+	if spinner != nil {
+		if connectErr != nil {
+			spinner.Fail(fmt.Sprintf("Connection to RabbitMQ failed: %s", connectErr.Error()))
+		} else {
+			spinner.Success("Connected to RabbitMQ")
 		}
-	}()
-
-	// Task: Create exchange, binding and queue
-	spinnerCreate, _ := pterm.DefaultSpinner.Start("Create exchange, queue and binding...")
-	if exchange == "" {
-		err := ch.ExchangeDeclare(exchangeUsed, "direct", true, false, false, false, nil)
-		if err != nil {
-			spinnerCreate.Fail(fmt.Sprintf("Failed to create exchange: %s", err.Error()))
-			return
+	}
+
+	for _, c := range checks {
+		result := CheckResult{Name: c.name, Description: c.description}
+		switch {
+		case slices.Contains(FlagSkip, c.name) || (len(FlagOnly) > 0 && !slices.Contains(FlagOnly, c.name)):
+			result.Status, result.Error = statusSkipped, "skipped by flag"
+		case connectErr != nil:
+			result.Status, result.Error = statusFailed, "not connected: "+connectErr.Error()
+		default:
+			result = runCheck(s, c, interactive)
 		}
-		_, err = ch.QueueDeclare(queueUsed, true, false, false, false, nil)
-		if err != nil {
-			spinnerCreate.Fail(fmt.Sprintf("Failed to create queue: %s", err.Error()))
-			return
+		report.Checks = append(report.Checks, result)
+	}
+
+	// Task: Clean after the test
+	if s.con != nil {
+		if interactive {
+			spinner, _ = pterm.DefaultSpinner.Start("Cleaning up after the test...")
 		}
-		err = ch.QueueBind(queueUsed, routingKeyUsed, exchangeUsed, false, nil)
-		if err != nil {
-			spinnerCreate.Fail(fmt.Sprintf("Failed to bind queue to exchange: %s", err.Error()))
-			return
+		s.cleanup()
+		if spinner != nil {
+			spinner.Success("Cleaned up after the test")
 		}
-		spinnerCreate.Success("All objects created successfully")
-	} else {
-		spinnerCreate.Info("All parameters are specified, skipping objects creation")
-	}
-
-	// Task: Send message
-	spinnerSend, _ := pterm.DefaultSpinner.Start("Sending message to RabbitMQ...")
-	sendErr := ch.Publish(
-		exchangeUsed,
-		routingKeyUsed,
-		false,
-		false,
-		amqp091.Publishing{
-			ContentType: "application/json",
-			Body:        []byte(`{"message": "This is a test message"}`),
-		},
-	)
-	if sendErr != nil {
-		spinnerSend.Fail(fmt.Sprintf("Failed to publish message: %s", sendErr.Error()))
-		return
 	}
-	spinnerSend.Success("Message sent successfully")
-
-	// Task: Read message
-	spinnerRead, _ := pterm.DefaultSpinner.Start("Reading message from RabbitMQ...")
-	msgs, err := ch.Consume(
-		queueUsed,
-		"",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		spinnerRead.Fail(fmt.Sprintf("Failed to consume messages: %s", err.Error()))
-		return
+
+	report.Duration = time.Since(report.StartTime).Seconds()
+	report.Passed = report.count(statusFailed) == 0
+	if interactive {
+		printTable(report)
 	}
-	select {
-	case _ = <-msgs:
-		// IMPORTANT: Complete the spinner BEFORE logging the message
-		spinnerRead.Success("Message received successfully")
-	case <-time.After(5 * time.Second):
-		spinnerRead.Fail("No message received within 5 seconds timeout")
+	if FlagOutput != "" {
+		if err := writeReport(report, FlagOutput, FlagOutputFile); err != nil {
+			logger.Errorf("%v", err)
+		} else if FlagOutputFile != "" {
+			logger.Successf("results saved to %s", FlagOutputFile)
+		}
 	}
-
-	// Task: Clean after the test
-	spinnerCleanup, _ := pterm.DefaultSpinner.Start("Cleaning up after the test...")
-	if exchange == "" {
-		if err := ch.QueueUnbind(queueUsed, routingKeyUsed, exchangeUsed, nil); err != nil {
-			pterm.Error.Printf("Failed to unbind queue: %s\n", err)
-		} else {
-			pterm.Info.Print("Successfully unbound queue\n")
+	if !report.Passed {
+		if s.con != nil {
+			s.con.Close()
 		}
+		os.Exit(1)
+	}
+}
 
-		if _, err := ch.QueueDelete(queueUsed, false, false, false); err != nil {
-			pterm.Error.Printf("Failed to delete queue: %s\n", err)
-
-		} else {
-			pterm.Info.Print("Successfully deleted queue\n")
-		}
+func runCheck(s *suite, c check, interactive bool) CheckResult {
+	result := CheckResult{Name: c.name, Description: c.description}
+	var spinner *pterm.SpinnerPrinter
+	if interactive {
+		spinner, _ = pterm.DefaultSpinner.Start(fmt.Sprintf("Running %s...", c.name))
+	}
 
-		if err := ch.ExchangeDelete(exchangeUsed, false, false); err != nil {
-			pterm.Error.Printf("Failed to delete exchange: %s\n", err)
-		} else {
-			pterm.Info.Print("Successfully deleted exchange\n")
+	start := time.Now()
+	err := c.run(s)
+	result.Duration = time.Since(start).Seconds()
+	if err != nil {
+		result.Status, result.Error = statusFailed, err.Error()
+		if spinner != nil {
+			spinner.Fail(fmt.Sprintf("%s: %s", c.name, err.Error()))
 		}
-		spinnerCleanup.Success("Cleaned up after the test")
-	} else {
-		spinnerCleanup.Info("Parameters were specified, skipping object deletion.")
+		return result
+	}
+	result.Status = statusPassed
+	if spinner != nil {
+		spinner.Success(c.description)
 	}
+	return result
+}
+
+func randomSuffix() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
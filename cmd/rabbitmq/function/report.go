@@ -0,0 +1,149 @@
+package function
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+const (
+	statusPassed  = "passed"
+	statusFailed  = "failed"
+	statusSkipped = "skipped"
+)
+
+// CheckResult is the outcome of a single check
+type CheckResult struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Status      string  `json:"status"`
+	Duration    float64 `json:"durationSeconds"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// SuiteReport is the machine-readable result of the functional test suite
+type SuiteReport struct {
+	Target    string        `json:"target"`
+	StartTime time.Time     `json:"startTime"`
+	Duration  float64       `json:"durationSeconds"`
+	Passed    bool          `json:"passed"`
+	Checks    []CheckResult `json:"checks"`
+}
+
+func (r *SuiteReport) count(status string) int {
+	n := 0
+	for _, c := range r.Checks {
+		if c.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// printTable prints the pass/fail table of all checks
+func printTable(r *SuiteReport) {
+	data := pterm.TableData{{"CHECK", "STATUS", "DURATION", "DETAILS"}}
+	for _, c := range r.Checks {
+		status := pterm.Green("PASS")
+		switch c.Status {
+		case statusFailed:
+			status = pterm.Red("FAIL")
+		case statusSkipped:
+			status = pterm.Yellow("SKIP")
+		}
+		details := c.Description
+		if c.Error != "" {
+			details = c.Error
+		}
+		data = append(data, []string{c.Name, status, fmt.Sprintf("%.2fs", c.Duration), details})
+	}
+	pterm.Println()
+	pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+	pterm.Printfln("%d passed, %d failed, %d skipped in %.2fs", r.count(statusPassed), r.count(statusFailed), r.count(statusSkipped), r.Duration)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+func junitReport(r *SuiteReport) junitTestSuites {
+	suite := junitSuite{
+		Name:      r.Target,
+		Tests:     len(r.Checks),
+		Failures:  r.count(statusFailed),
+		Skipped:   r.count(statusSkipped),
+		Time:      fmt.Sprintf("%.3f", r.Duration),
+		Timestamp: r.StartTime.Format(time.RFC3339),
+	}
+	for _, c := range r.Checks {
+		testCase := junitCase{Name: c.Name, ClassName: "rabbitmq.func-test", Time: fmt.Sprintf("%.3f", c.Duration)}
+		switch c.Status {
+		case statusFailed:
+			testCase.Failure = &junitFailure{Message: c.Error, Text: c.Description}
+		case statusSkipped:
+			testCase.Skipped = &junitSkipped{Message: c.Error}
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	return junitTestSuites{Suites: []junitSuite{suite}}
+}
+
+// writeReport writes the report as json or junit to the file, or stdout without a path
+func writeReport(r *SuiteReport, format, path string) error {
+	var data []byte
+	var err error
+	switch format {
+	case "json":
+		data, err = json.MarshalIndent(r, "", "  ")
+	case "junit":
+		data, err = xml.MarshalIndent(junitReport(r), "", "  ")
+		data = append([]byte(xml.Header), data...)
+	default:
+		return fmt.Errorf("unsupported output format '%s', use json or junit", format)
+	}
+	if err != nil {
+		return fmt.Errorf("error serializing results: %w", err)
+	}
+	data = append(data, '\n')
+
+	if path == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing results file: %w", err)
+	}
+	return nil
+}
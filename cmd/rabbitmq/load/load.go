@@ -1,91 +1,66 @@
 package load
 
 import (
-	"context"
-	"fmt"
-	"math/rand"
 	"os"
 	"os/signal"
 	"strings"
-	"sync"
-	"sync/atomic"
 	"syscall"
 	"time"
 
 	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq"
 	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/VojtechPastyrik/vpd/utils/loadtest"
 	rabbitmqUtisl "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
-	"github.com/rabbitmq/amqp091-go"
 	"github.com/spf13/cobra"
 )
 
-type LoadProfile struct {
-	Duration        string
-	QueueCount      int
-	ExchangeCount   int
-	RoutingKeyCount int
-	MessageSize     int
-	ParallelClients int
-	Description     string
-}
-
-var loadProfiles = map[string]LoadProfile{
-	"light": {
-		Duration:        "30s",
-		QueueCount:      5,
-		ExchangeCount:   2,
-		RoutingKeyCount: 3,
-		MessageSize:     1024,
-		ParallelClients: 2,
-		Description:     "Light load - sanity check (2.5K-5K msgs/sec expected)",
-	},
-	"medium": {
-		Duration:        "2m",
-		QueueCount:      20,
-		ExchangeCount:   5,
-		RoutingKeyCount: 10,
-		MessageSize:     4096,
-		ParallelClients: 10,
-		Description:     "Medium load - typical workload (20K-50K msgs/sec expected)",
-	},
-	"heavy": {
-		Duration:        "3m",
-		QueueCount:      50,
-		ExchangeCount:   20,
-		RoutingKeyCount: 30,
-		MessageSize:     8192,
-		ParallelClients: 20,
-		Description:     "Heavy load - stress test (100K+ msgs/sec expected)",
-	},
-	"sustained": {
-		Duration:        "10m",
-		QueueCount:      30,
-		ExchangeCount:   10,
-		RoutingKeyCount: 15,
-		MessageSize:     2048,
-		ParallelClients: 15,
-		Description:     "Sustained load - stability test (long-running)",
-	},
-}
-
 var (
-	FlagHost              string
-	FlagPort              int
-	FlagUser              string
-	FlagPassword          string
-	FlagVirtualHost       string
-	FlagSsl               bool
-	FlagSslCert           string
-	FlagSslKey            string
-	FlagDuration          string
-	FlagQueueCount        int
-	FlagExchangeCount     int
-	FlagRoutingKeyCount   int
-	FlagMessageSize       int
-	FlagParallelClients   int
-	FlagLoadProfile       string
-	sentMessagesCount     int32
-	receivedMessagesCount int32
+	FlagConnection           *parent_cmd.ConnectionFlags
+	FlagProtocol             *parent_cmd.ProtocolFlags
+	FlagDuration             string
+	FlagQueueCount           int
+	FlagExchangeCount        int
+	FlagRoutingKeyCount      int
+	FlagMessageSize          int
+	FlagParallelClients      int
+	FlagLoadProfile          string
+	FlagTopologyFile         string
+	FlagExchangeType         string
+	FlagQueueType            string
+	FlagLazy                 bool
+	FlagMaxLength            int
+	FlagMaxLengthBytes       int
+	FlagMessageTTL           int
+	FlagOverflow             string
+	FlagDeadLetterExchange   string
+	FlagDeadLetterRoutingKey string
+	FlagUseExisting          bool
+	FlagKeepTopology         bool
+	FlagRate                 float64
+	FlagRateStart            float64
+	FlagRateScope            string
+	FlagRateProfile          string
+	FlagRateSteps            int
+	FlagSizeDistribution     string
+	FlagMinMessageSize       int
+	FlagMaxMessageSize       int
+	FlagMessageSizeStdDev    int
+	FlagPersistent           bool
+	FlagPriority             int
+	FlagExpiration           int
+	FlagManualAck            bool
+	FlagAckDelay             time.Duration
+	FlagNackPercent          float64
+	FlagRequeue              bool
+	FlagPrefetch             int
+	FlagProfileFile          string
+	FlagOutput               string
+	FlagOutputFile           string
+	FlagSampleInterval       time.Duration
+	FlagMinSendRate          float64
+	FlagMinReceiveRate       float64
+	FlagMaxLatencyP99        string
+	FlagZeroLoss             bool
 )
 
 func init() {
@@ -96,37 +71,134 @@ var Cmd = &cobra.Command{
 	Use:     "load-test",
 	Aliases: []string{"lt"},
 	Short:   "Run RabbitMQ load test",
-	Long:    "Run RabbitMQ load test using the specified configuration. This command is useful for performance testing and benchmarking RabbitMQ servers.",
+	Long:    "Run RabbitMQ load test using the specified configuration. This command is useful for performance testing and benchmarking RabbitMQ servers.\nEvery message carries a sequence number and send timestamp, so the statistics include publisher confirms, end-to-end latency percentiles and lost or duplicated messages. Producers publish mandatory messages to random publish targets and keep publishing while confirms are in flight, streams are consumed from the next offset.\nWith --protocol mqtt, mqtt5 or stomp the test publishes to one topic per queue count through the protocol plugin and every topic has one subscriber, the topology flags do not apply. Publishes wait for the PUBACK or receipt, MQTT 3.1.1 carries the trace data in front of the body.\nThe load profile flags were renamed to --load-profile and --load-profile-file (--profile-file is still accepted), --profile selects the connection profile like in the other rabbitmq commands.",
+	Example: `  # Classic queues behind topic exchanges, capped at 10k messages with a dead letter exchange
+  vpd rabbitmq load-test --load-profile light --exchange-type topic --queue-type classic \
+    --max-length 10000 --dead-letter-exchange dlx
+
+  # Production-like topology from a file, declared before and deleted after the test
+  vpd rabbitmq load-test --profile prod --load-profile medium --topology-file topology.yaml
+
+  # Topology file format
+  exchanges:
+    - name: orders
+      type: topic
+  queues:
+    - name: orders.created
+      type: quorum
+      arguments:
+        x-max-length: 10000
+  bindings:
+    - exchange: orders
+      queue: orders.created
+      routingKey: "order.created.#"
+  publish:            # optional, derived from bindings when omitted
+    - exchange: orders
+      routingKey: order.created.eu
+
+  # MQTT 5 pub/sub through the MQTT plugin on 10 topics
+  vpd rabbitmq load-test --profile stage --load-profile light --protocol mqtt5 --queue-count 10
+
+  # CI gate: custom profile, JSON results and a non-zero exit code when thresholds fail
+  vpd rabbitmq load-test --load-profile ci --output json --output-file results.json \
+    --min-send-rate 5000 --max-latency-p99 50ms --zero-loss
+
+  # Profile file format (~/.config/vpd/rabbitmq-profiles.yaml or --load-profile-file)
+  profiles:
+    ci:
+      duration: 1m
+      queueCount: 10
+      exchangeCount: 2
+      routingKeyCount: 5
+      messageSize: 2048
+      parallelClients: 4
+      description: CI smoke test
+      thresholds:
+        minSendRate: 5000
+        minReceiveRate: 5000
+        maxLatencyP99: 50ms
+        zeroLoss: true`,
 	Run: func(cmd *cobra.Command, args []string) {
-		runLoadTestWithProfile(FlagHost, FlagPort, FlagUser, FlagPassword, FlagVirtualHost, FlagSsl, FlagSslCert, FlagSslKey, FlagLoadProfile, FlagDuration, FlagQueueCount, FlagExchangeCount, FlagRoutingKeyCount, FlagMessageSize, FlagParallelClients)
+		runLoadTestWithProfile(FlagConnection, FlagLoadProfile, FlagDuration, FlagQueueCount, FlagExchangeCount, FlagRoutingKeyCount, FlagMessageSize, FlagParallelClients)
 	},
 }
 
 func init() {
 	parent_cmd.Cmd.AddCommand(Cmd)
-	Cmd.Flags().StringVarP(&FlagHost, "host", "H", "localhost", "RabbitMQ host")
-	Cmd.Flags().IntVarP(&FlagPort, "port", "P", 5672, "RabbitMQ port")
-	Cmd.Flags().StringVarP(&FlagUser, "user", "u", "guest", "RabbitMQ username")
-	Cmd.Flags().StringVarP(&FlagPassword, "password", "p", "guest", "RabbitMQ password")
-	Cmd.Flags().StringVarP(&FlagVirtualHost, "vhost", "v", "/", "RabbitMQ virtual host")
-	Cmd.Flags().BoolVarP(&FlagSsl, "ssl", "s", false, "Enable SSL")
-	Cmd.Flags().StringVarP(&FlagSslCert, "ssl-cert", "c", "", "Path to SSL certificate")
-	Cmd.Flags().StringVarP(&FlagSslKey, "ssl-key", "k", "", "Path to SSL key")
-	Cmd.Flags().StringVarP(&FlagLoadProfile, "profile", "L", "medium", "Load profile: light, medium, heavy, sustained (overrides individual settings)")
-	Cmd.Flags().StringVarP(&FlagDuration, "duration", "d", "", "Duration of the load test (overrides profile)")
-	Cmd.Flags().IntVarP(&FlagQueueCount, "queue-count", "q", 0, "Number of queues to create (overrides profile)")
-	Cmd.Flags().IntVarP(&FlagExchangeCount, "exchange-count", "e", 0, "Number of exchanges to create (overrides profile)")
-	Cmd.Flags().IntVarP(&FlagRoutingKeyCount, "routing-keys", "r", 0, "Number of routing keys to use (overrides profile)")
-	Cmd.Flags().IntVarP(&FlagMessageSize, "message-size", "m", 0, "Size of each message in bytes (overrides profile)")
-	Cmd.Flags().IntVarP(&FlagParallelClients, "parallel-clients", "C", 0, "Number of parallel clients (overrides profile)")
+	FlagConnection = parent_cmd.AddConnectionFlags(Cmd)
+	FlagProtocol = parent_cmd.AddProtocolFlags(Cmd, "")
+	Cmd.Flags().StringVarP(&FlagLoadProfile, "load-profile", "L", "medium", "Load profile: light, medium, heavy, sustained or a custom profile from the load profile file")
+	Cmd.Flags().StringVar(&FlagProfileFile, "load-profile-file", "", "YAML file with custom load profiles (default ~/.config/vpd/rabbitmq-profiles.yaml)")
+	// --profile-file named the load profile file before --profile became the connection profile
+	Cmd.Flags().StringVar(&FlagProfileFile, "profile-file", "", "YAML file with custom load profiles")
+	Cmd.Flags().MarkDeprecated("profile-file", "use --load-profile-file instead")
+	Cmd.Flags().StringVarP(&FlagDuration, "duration", "d", "", "Duration of the load test (overrides load profile)")
+	Cmd.Flags().IntVarP(&FlagQueueCount, "queue-count", "q", 0, "Number of queues to create (overrides load profile)")
+	Cmd.Flags().IntVarP(&FlagExchangeCount, "exchange-count", "e", 0, "Number of exchanges to create (overrides load profile)")
+	Cmd.Flags().IntVarP(&FlagRoutingKeyCount, "routing-keys", "r", 0, "Number of routing keys to use (overrides load profile)")
+	Cmd.Flags().IntVarP(&FlagMessageSize, "message-size", "m", 0, "Size of each message in bytes (overrides load profile)")
+	Cmd.Flags().IntVarP(&FlagParallelClients, "parallel-clients", "C", 0, "Number of parallel clients (overrides load profile)")
+
+	// Topology
+	Cmd.Flags().StringVarP(&FlagTopologyFile, "topology-file", "t", "", "YAML file describing exchanges, queues, bindings and publish targets (replaces generated topology)")
+	Cmd.Flags().StringVar(&FlagExchangeType, "exchange-type", "direct", "Type of generated exchanges: direct, topic, fanout, headers")
+	Cmd.Flags().StringVar(&FlagQueueType, "queue-type", "quorum", "Type of generated queues: classic, quorum, stream")
+	Cmd.Flags().BoolVar(&FlagLazy, "lazy", false, "Declare generated classic queues in lazy mode")
+	Cmd.Flags().IntVar(&FlagMaxLength, "max-length", 0, "Maximum number of messages in generated queues (x-max-length)")
+	Cmd.Flags().IntVar(&FlagMaxLengthBytes, "max-length-bytes", 0, "Maximum size of generated queues in bytes (x-max-length-bytes)")
+	Cmd.Flags().IntVar(&FlagMessageTTL, "message-ttl", 0, "Message TTL of generated queues in milliseconds (x-message-ttl)")
+	Cmd.Flags().StringVar(&FlagOverflow, "overflow", "", "Overflow behaviour of generated queues: drop-head, reject-publish, reject-publish-dlx")
+	Cmd.Flags().StringVar(&FlagDeadLetterExchange, "dead-letter-exchange", "", "Dead letter exchange of generated queues (x-dead-letter-exchange)")
+	Cmd.Flags().StringVar(&FlagDeadLetterRoutingKey, "dead-letter-routing-key", "", "Dead letter routing key of generated queues (x-dead-letter-routing-key)")
+	Cmd.Flags().BoolVar(&FlagUseExisting, "use-existing", false, "Use existing exchanges and queues, do not declare or delete anything")
+	Cmd.Flags().BoolVar(&FlagKeepTopology, "keep-topology", false, "Declare the topology but do not delete it after the test")
+
+	// Publishing
+	Cmd.Flags().Float64Var(&FlagRate, "rate", 0, "Target publish rate in msgs/sec (0 = as fast as possible)")
+	Cmd.Flags().Float64Var(&FlagRateStart, "rate-start", 0, "Initial publish rate for ramp and step profiles")
+	Cmd.Flags().StringVar(&FlagRateScope, "rate-scope", "global", "Whether the rate applies to all producers together or to each producer: global, producer")
+	Cmd.Flags().StringVar(&FlagRateProfile, "rate-profile", "constant", "Rate profile over the test duration: constant, ramp (linear from --rate-start to --rate), step")
+	Cmd.Flags().IntVar(&FlagRateSteps, "rate-steps", 5, "Number of equally long steps from --rate-start to --rate for the step profile")
+	Cmd.Flags().StringVar(&FlagSizeDistribution, "size-distribution", "fixed", "Message size distribution: fixed, uniform, normal, exponential (mean is --message-size)")
+	Cmd.Flags().IntVar(&FlagMinMessageSize, "min-message-size", 0, "Minimum message size in bytes for non-fixed distributions")
+	Cmd.Flags().IntVar(&FlagMaxMessageSize, "max-message-size", 0, "Maximum message size in bytes for non-fixed distributions (default 2x --message-size for uniform, 4x otherwise)")
+	Cmd.Flags().IntVar(&FlagMessageSizeStdDev, "message-size-stddev", 0, "Standard deviation of the normal distribution in bytes (default 1/4 of --message-size)")
+	Cmd.Flags().BoolVar(&FlagPersistent, "persistent", false, "Publish persistent messages (delivery mode 2)")
+	Cmd.Flags().IntVar(&FlagPriority, "priority", 0, "Message priority (queues need x-max-priority)")
+	Cmd.Flags().IntVar(&FlagExpiration, "expiration", 0, "Per-message TTL in milliseconds")
+
+	// Consuming
+	Cmd.Flags().BoolVar(&FlagManualAck, "manual-ack", false, "Acknowledge messages manually instead of auto-ack")
+	Cmd.Flags().DurationVar(&FlagAckDelay, "ack-delay", 0, "Delay before acknowledging each message, simulates slow consumers (implies --manual-ack)")
+	Cmd.Flags().Float64Var(&FlagNackPercent, "nack-percent", 0, "Percentage of messages to reject (implies --manual-ack)")
+	Cmd.Flags().BoolVar(&FlagRequeue, "requeue", false, "Requeue rejected messages instead of dropping or dead-lettering them")
+	Cmd.Flags().IntVar(&FlagPrefetch, "prefetch", 100, "Consumer prefetch count")
+
+	// Results and thresholds
+	Cmd.Flags().StringVarP(&FlagOutput, "output", "o", "", "Write machine-readable results: json, csv")
+	Cmd.Flags().StringVar(&FlagOutputFile, "output-file", "", "File for the results (default stdout)")
+	Cmd.Flags().DurationVar(&FlagSampleInterval, "sample-interval", time.Second, "Interval of throughput samples in the results")
+	Cmd.Flags().Float64Var(&FlagMinSendRate, "min-send-rate", 0, "Fail if the average send rate in msgs/sec is lower (overrides load profile)")
+	Cmd.Flags().Float64Var(&FlagMinReceiveRate, "min-receive-rate", 0, "Fail if the average receive rate in msgs/sec is lower (overrides load profile)")
+	Cmd.Flags().StringVar(&FlagMaxLatencyP99, "max-latency-p99", "", "Fail if the p99 end-to-end latency is higher, e.g. 50ms (overrides load profile)")
+	Cmd.Flags().BoolVar(&FlagZeroLoss, "zero-loss", false, "Fail if any message was lost, nacked or left unconfirmed")
 }
 
-func runLoadTestWithProfile(host string, port int, user, password, virtualHost string, ssl bool, sslCert, sslKey, profile, duration string, queueCount, exchangeCount, routingKeyCount, messageSize, parallelClients int) {
+func runLoadTestWithProfile(connection *parent_cmd.ConnectionFlags, profile, duration string, queueCount, exchangeCount, routingKeyCount, messageSize, parallelClients int) {
 	// Load profile settings
-	loadProfile, exists := loadProfiles[profile]
+	profiles, err := loadtest.LoadProfiles(FlagProfileFile, loadtest.DefaultProfileFile("rabbitmq-profiles.yaml"))
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if _, isLoadProfile := profiles[connection.Profile]; isLoadProfile {
+		// --profile selected the load profile before it became the connection profile
+		if _, err := rabbitmqUtisl.GetProfile(connection.Profile); err != nil {
+			logger.Fatalf("'%s' is a load profile, use --load-profile %s (--profile selects the connection profile since the load profile flag was renamed)", connection.Profile, connection.Profile)
+		}
+	}
+	loadProfile, exists := profiles[profile]
 	if !exists {
-		logger.Errorf("invalid profile '%s'. Available profiles: light, medium, heavy, sustained", profile)
-		return
+		logger.Fatalf("invalid profile '%s'. Available profiles: %s", profile, strings.Join(loadtest.ProfileNames(profiles), ", "))
 	}
 
 	// Apply profile defaults, then override with explicit flags
@@ -160,411 +232,193 @@ func runLoadTestWithProfile(host string, port int, user, password, virtualHost s
 		finalParallelClients = loadProfile.ParallelClients
 	}
 
-	logger.Infof("using load profile: %s", profile)
-	logger.Infof("%s", loadProfile.Description)
-
-	runLoadTest(host, port, user, password, virtualHost, ssl, sslCert, sslKey, finalDuration, finalQueueCount, finalExchangeCount, finalRoutingKeyCount, finalMessageSize, finalParallelClients)
-}
-
-func runLoadTest(host string, port int, user, password, virtualHost string, ssl bool, sslCert, sslKey, duration string, queueCount, exchangeCount, routingKeyCount, messageSize, parallelClients int) {
-	startTime := time.Now()
-
-	con, ch, err := rabbitmqUtisl.ConnectToRabbitMQ(ssl, user, password, host, port, virtualHost, sslCert, sslKey)
-	if err != nil {
-		logger.Errorf("connection to rabbitmq failed: %v", err)
-		return
+	thresholds := loadProfile.Thresholds
+	if FlagMinSendRate > 0 {
+		thresholds.MinSendRate = FlagMinSendRate
 	}
-	defer con.Close()
-
-	exchangeList, queueList, exchangeRoutingKeys := createResources(ch, exchangeCount, queueCount, routingKeyCount)
-
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	durationParsed, err := time.ParseDuration(duration)
-	if err != nil {
-		logger.Errorf("invalid duration format: %v", err)
-		return
+	if FlagMinReceiveRate > 0 {
+		thresholds.MinReceiveRate = FlagMinReceiveRate
 	}
-
-	// Run test in a goroutine so we can handle signals
-	done := make(chan struct{})
-	go func() {
-		startConsumersAndProducers(con, queueList, exchangeList, exchangeRoutingKeys, parallelClients, durationParsed, messageSize)
-		close(done)
-	}()
-
-	// Wait for either test to complete or signal
-	select {
-	case <-done:
-		logger.Info("load test completed normally")
-	case sig := <-sigChan:
-		logger.Infof("signal received (%v), stopping test...", sig)
+	if FlagMaxLatencyP99 != "" {
+		thresholds.MaxLatencyP99 = FlagMaxLatencyP99
 	}
-
-	endTime := time.Now()
-	printStatistics(startTime, endTime, sentMessagesCount, receivedMessagesCount, queueCount, exchangeCount, routingKeyCount, messageSize, parallelClients, durationParsed)
-
-	logger.Info("starting cleanup...")
-	cleanupResources(ch, exchangeList, queueList)
-}
-
-func createResources(ch *amqp091.Channel, exchangeCount, queueCount, routingKeyCount int) ([]string, []string, map[string][]string) {
-	rand.Seed(time.Now().UnixNano())
-
-	exchangeList := make([]string, exchangeCount)
-	queueList := make([]string, queueCount)
-	routingKeys := make([]string, routingKeyCount)
-	exchangeRoutingKeys := make(map[string][]string)
-
-	for i := 0; i < exchangeCount; i++ {
-		exchangeName := fmt.Sprintf("test-exchange-%d", i)
-		err := ch.ExchangeDeclare(exchangeName, "direct", true, false, false, false, nil)
-		if err != nil {
-			logger.Errorf("failed to declare exchange %s: %v", exchangeName, err)
-		}
-		exchangeList[i] = exchangeName
+	if FlagZeroLoss {
+		thresholds.ZeroLoss = true
 	}
-
-	for i := 0; i < queueCount; i++ {
-		queueName := fmt.Sprintf("test-queue-%d", i)
-		// Create quorum queue by setting x-queue-type to "quorum"
-		args := amqp091.Table{
-			"x-queue-type": "quorum",
-		}
-		_, err := ch.QueueDeclare(queueName, true, false, false, false, args)
-		if err != nil {
-			logger.Errorf("failed to declare queue %s: %v", queueName, err)
-		}
-		queueList[i] = queueName
+	if err := thresholds.Validate(); err != nil {
+		logger.Fatalf("%v", err)
 	}
-
-	for i := 0; i < routingKeyCount; i++ {
-		routingKeys[i] = fmt.Sprintf("test-routing-key-%d", i)
+	if err := loadtest.ValidateOutput(FlagOutput); err != nil {
+		logger.Fatalf("%v", err)
 	}
 
-	// Bind each queue to each exchange with all routing keys for maximum message duplication
-	for _, exchange := range exchangeList {
-		for _, routingKey := range routingKeys {
-			for _, queue := range queueList {
-				err := ch.QueueBind(queue, routingKey, exchange, false, nil)
-				if err != nil {
-					logger.Errorf("failed to bind queue %s to exchange %s with routing key %s: %v", queue, exchange, routingKey, err)
-				}
-			}
-			if !contains(exchangeRoutingKeys[exchange], routingKey) {
-				exchangeRoutingKeys[exchange] = append(exchangeRoutingKeys[exchange], routingKey)
-			}
-		}
+	logger.Infof("using load profile: %s", profile)
+	if loadProfile.Description != "" {
+		logger.Infof("%s", loadProfile.Description)
 	}
 
-	return exchangeList, queueList, exchangeRoutingKeys
-}
+	topology, err := buildTopology(finalExchangeCount, finalQueueCount, finalRoutingKeyCount)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
 
-func cleanupResources(ch *amqp091.Channel, exchangeList, queueList []string) {
-	// First, purge all queues to remove remaining messages
-	for _, queue := range queueList {
-		purgedCount, err := ch.QueuePurge(queue, false)
-		if err != nil {
-			logger.Errorf("failed to purge queue %s: %v", queue, err)
-		} else {
-			logger.Infof("purged %d messages from queue %s", purgedCount, queue)
-		}
+	publishOptions := PublishOptions{
+		Rate: loadtest.RateOptions{
+			Rate:      FlagRate,
+			StartRate: FlagRateStart,
+			Scope:     FlagRateScope,
+			Profile:   FlagRateProfile,
+			Steps:     FlagRateSteps,
+		},
+		MessageSize:      finalMessageSize,
+		SizeDistribution: FlagSizeDistribution,
+		MinMessageSize:   FlagMinMessageSize,
+		MaxMessageSize:   FlagMaxMessageSize,
+		SizeStdDev:       FlagMessageSizeStdDev,
+		Persistent:       FlagPersistent,
+		Priority:         FlagPriority,
+		Expiration:       FlagExpiration,
+	}
+	if err := publishOptions.validate(); err != nil {
+		logger.Fatalf("%v", err)
 	}
 
-	// Unbind queues from exchanges
-	for _, exchange := range exchangeList {
-		for _, queue := range queueList {
-			err := ch.QueueUnbind(queue, "", exchange, nil)
-			if err != nil {
-				logger.Debugf("failed to unbind queue %s from exchange %s: %v", queue, exchange, err)
-				// Continue even if unbind fails - queue might not be bound with this routing key
-			}
-		}
+	consumeOptions := ConsumeOptions{
+		ManualAck:   FlagManualAck,
+		AckDelay:    FlagAckDelay,
+		NackPercent: FlagNackPercent,
+		Requeue:     FlagRequeue,
+		Prefetch:    FlagPrefetch,
+	}
+	if err := consumeOptions.validate(); err != nil {
+		logger.Fatalf("%v", err)
 	}
 
-	// Delete exchanges
-	for _, exchange := range exchangeList {
-		err := ch.ExchangeDelete(exchange, false, false)
-		if err != nil {
-			logger.Errorf("failed to delete exchange %s: %v", exchange, err)
-		} else {
-			logger.Infof("deleted exchange %s", exchange)
-		}
+	connectionOptions, err := connection.Options()
+	if err != nil {
+		logger.Fatalf("%v", err)
 	}
 
-	// Delete queues
-	for _, queue := range queueList {
-		deletedCount, err := ch.QueueDelete(queue, false, false, false)
-		if err != nil {
-			logger.Errorf("failed to delete queue %s: %v", queue, err)
-		} else {
-			logger.Infof("deleted queue %s (%d messages remaining)", queue, deletedCount)
+	if err := FlagProtocol.Validate(); err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if !FlagProtocol.AMQP() {
+		if consumeOptions.ack().Enabled() {
+			logger.Fatalf("manual acknowledgements are only supported with the amqp protocol")
 		}
+		runProtocolLoadTest(connectionOptions, finalDuration, finalQueueCount, publishOptions, consumeOptions, finalParallelClients, profile, thresholds)
+		return
 	}
 
-	logger.Info("cleanup completed")
+	runLoadTest(connectionOptions, finalDuration, topology, publishOptions, consumeOptions, finalParallelClients, profile, thresholds)
 }
 
-func startConsumersAndProducers(conn *amqp091.Connection, queueList, exchangeList []string, exchangeRoutingKeys map[string][]string, parallelClients int, duration time.Duration, messageSize int) {
-	var wg sync.WaitGroup
-	doneChan := make(chan struct{})
-
-	logger.Info("starting consumers...")
-	assignedQueues := assignQueuesToConsumers(queueList, parallelClients)
-	for i := 0; i < parallelClients; i++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			logger.Infof("consumer %d started", id)
-			ch, err := conn.Channel()
-			if err != nil {
-				logger.Errorf("failed to create channel for consumer %d: %v", id, err)
-				return
-			}
-			defer ch.Close()
-
-			logger.Infof("consumer %d assigned queues: %v", id, assignedQueues[id])
-			consumeMessages(ch, assignedQueues[id], duration, doneChan)
-			logger.Infof("consumer %d finished", id)
-		}(i)
-	}
-
-	logger.Info("starting producers...")
-	// Assign exchanges to producers to ensure all exchanges are used
-	assignedExchanges := assignExchangesToProducers(exchangeList, parallelClients)
-	for i := 0; i < parallelClients; i++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			logger.Infof("producer %d started", id)
-			ch, err := conn.Channel()
-			if err != nil {
-				logger.Errorf("failed to create channel for producer %d: %v", id, err)
-				return
-			}
-			defer ch.Close()
-
-			producerExchanges := assignedExchanges[id]
-			logger.Infof("producer %d assigned exchanges: %v", id, producerExchanges)
-			produceMessages(ch, producerExchanges, exchangeRoutingKeys, messageSize, duration, doneChan)
-			logger.Infof("producer %d finished", id)
-		}(i)
+// buildTopology loads the topology file if given, otherwise generates the topology from counts
+func buildTopology(exchangeCount, queueCount, routingKeyCount int) (*Topology, error) {
+	if FlagTopologyFile != "" {
+		logger.Infof("using topology file: %s", FlagTopologyFile)
+		return loadTopologyFile(FlagTopologyFile)
 	}
-
-	go func() {
-		time.Sleep(duration)
-		logger.Info("duration expired, stopping load test...")
-		close(doneChan)
-	}()
-
-	logger.Info("waiting for all goroutines to finish...")
-	wg.Wait()
-	logger.Info("all producers and consumers stopped")
+	return generateTopology(exchangeCount, queueCount, routingKeyCount, TopologyOptions{
+		ExchangeType:         FlagExchangeType,
+		QueueType:            FlagQueueType,
+		Lazy:                 FlagLazy,
+		MaxLength:            FlagMaxLength,
+		MaxLengthBytes:       FlagMaxLengthBytes,
+		MessageTTL:           FlagMessageTTL,
+		Overflow:             FlagOverflow,
+		DeadLetterExchange:   FlagDeadLetterExchange,
+		DeadLetterRoutingKey: FlagDeadLetterRoutingKey,
+	})
 }
 
-func assignQueuesToConsumers(queueList []string, parallelClients int) [][]string {
-	assigned := make([][]string, parallelClients)
-	for i, queue := range queueList {
-		assigned[i%parallelClients] = append(assigned[i%parallelClients], queue)
+func runLoadTest(connectionOptions rabbitmqUtisl.ConnectionOptions, duration string, topology *Topology, publishOptions PublishOptions, consumeOptions ConsumeOptions, parallelClients int, profile string, thresholds loadtest.Thresholds) {
+	durationParsed, err := time.ParseDuration(duration)
+	if err != nil {
+		logger.Fatalf("invalid duration format: %v", err)
 	}
-	return assigned
-}
 
-func assignExchangesToProducers(exchangeList []string, parallelClients int) [][]string {
-	assigned := make([][]string, parallelClients)
-	for i, exchange := range exchangeList {
-		assigned[i%parallelClients] = append(assigned[i%parallelClients], exchange)
+	con, ch, err := rabbitmqUtisl.Connect(connectionOptions)
+	if err != nil {
+		logger.Fatalf("connection to rabbitmq failed: %v", err)
 	}
-	return assigned
-}
+	defer con.Close()
 
-func contains(slice []string, str string) bool {
-	for _, item := range slice {
-		if item == str {
-			return true
-		}
+	if FlagUseExisting {
+		logger.Info("using existing topology, nothing will be declared or deleted")
+	} else {
+		declareTopology(ch, topology)
 	}
-	return false
-}
-
-func consumeMessages(ch *amqp091.Channel, queues []string, duration time.Duration, doneChan <-chan struct{}) {
-	var wg sync.WaitGroup
-	timeout := time.After(duration)
-
-	for _, queue := range queues {
-		wg.Add(1)
-		go func(queue string) {
-			defer wg.Done()
-
-			// Set QoS to prefetch messages for better performance
-			err := ch.Qos(100, 0, false)
-			if err != nil {
-				logger.Errorf("failed to set QoS for queue %s: %v", queue, err)
-				return
-			}
-
-			// Use Consume instead of Get for better throughput
-			msgs, err := ch.Consume(queue, "", true, false, false, false, nil)
-			if err != nil {
-				logger.Errorf("failed to consume from queue %s: %v", queue, err)
-				return
-			}
-
-			for {
-				select {
-				case <-doneChan:
-					logger.Infof("consumer received stop signal for queue %s, exiting...", queue)
-					drainQueues(ch, []string{queue})
-					return
-				case <-timeout:
-					logger.Infof("consumer timeout reached for queue %s, exiting...", queue)
-					drainQueues(ch, []string{queue})
-					return
-				case delivery, ok := <-msgs:
-					if !ok {
-						logger.Infof("message channel closed for queue %s", queue)
-						return
-					}
-					atomic.AddInt32(&receivedMessagesCount, 1)
-					_ = delivery // Use delivery to avoid unused variable warning
-				}
-			}
-		}(queue)
-	}
-
-	wg.Wait()
-}
 
-func drainQueues(ch *amqp091.Channel, queues []string) {
-	for _, queue := range queues {
-		// Set high QoS for fast draining
-		err := ch.Qos(500, 0, false)
-		if err != nil {
-			logger.Errorf("failed to set QoS for draining queue %s: %v", queue, err)
-			continue
-		}
+	targets, driverTargets := topology.driverTargets()
+	driver := &loadtest.RabbitMQDriver{
+		Options:    connectionOptions,
+		Streams:    topology.streamQueues(),
+		Targets:    driverTargets,
+		Persistent: publishOptions.Persistent,
+		Priority:   uint8(publishOptions.Priority),
+		Expiration: publishOptions.Expiration,
+		Prefetch:   consumeOptions.Prefetch,
+		Ack:        consumeOptions.ack(),
+	}
 
-		// Use Consume for faster draining
-		msgs, err := ch.Consume(queue, "", true, false, false, false, nil)
-		if err != nil {
-			logger.Errorf("failed to consume from queue %s for draining: %v", queue, err)
-			continue
-		}
+	stop, stopSignals := stopOnSignal()
+	defer stopSignals()
 
-		// Drain queue until empty with idle timeout
-		idleTimer := time.NewTimer(500 * time.Millisecond)
-		drainCount := 0
-		for {
-			select {
-			case delivery, ok := <-msgs:
-				if !ok {
-					logger.Infof("queue %s drained successfully (%d messages)", queue, drainCount)
-					return
-				}
-				atomic.AddInt32(&receivedMessagesCount, 1)
-				drainCount++
-				_ = delivery
-				idleTimer.Reset(500 * time.Millisecond) // Reset idle timer on each message
-			case <-idleTimer.C:
-				logger.Infof("queue %s drain completed (no messages for 500ms, drained %d messages)", queue, drainCount)
-				return
-			}
-		}
+	stats := loadtest.NewStats()
+	startTime := time.Now()
+	err = loadtest.Run(driver, loadtest.Options{
+		Destinations:   topology.queueNames(),
+		Targets:        targets,
+		Clients:        parallelClients,
+		Duration:       durationParsed,
+		Rate:           publishOptions.Rate,
+		Size:           publishOptions.sizeOptions(),
+		SampleInterval: FlagSampleInterval,
+	}, stats, stop)
+	// Only a completed run that meets its thresholds passes
+	passed := false
+	if err != nil {
+		logger.Errorf("%v", err)
+	} else {
+		endTime := time.Now()
+		logger.Info("load test completed")
+
+		report := buildReport(profile, rabbitmqUtisl.ProtocolAMQP, startTime, endTime, stats, topology, publishOptions, consumeOptions, parallelClients, durationParsed)
+		report.Finish(thresholds, FlagOutput, FlagOutputFile)
+		passed = report.Passed
 	}
-}
 
-func produceMessages(ch *amqp091.Channel, exchanges []string, exchangeRoutingKeys map[string][]string, messageSize int, duration time.Duration, doneChan <-chan struct{}) {
-	if len(exchanges) == 0 {
-		logger.Warnf("no exchanges provided, producer exiting")
-		return
+	if FlagUseExisting || FlagKeepTopology {
+		logger.Info("keeping topology, skipping cleanup")
+	} else {
+		logger.Info("starting cleanup...")
+		deleteTopology(ch, topology)
 	}
 
-	timeout := time.After(duration)
-	message := make([]byte, messageSize)
-	rand.Read(message)
-
-	// Enable publisher confirms for better performance tracking
-	err := ch.Confirm(false)
-	if err != nil {
-		logger.Warnf("failed to enable publisher confirms: %v", err)
-	}
-
-	for {
-		select {
-		case <-doneChan:
-			logger.Info("producer received stop signal, finishing...")
-			return
-		case <-timeout:
-			logger.Info("producer timeout reached, stopping...")
-			return
-		default:
-			if len(exchanges) == 0 {
-				continue
-			}
-			exchange := exchanges[rand.Intn(len(exchanges))]
-			routingKeys := exchangeRoutingKeys[exchange]
-			if len(routingKeys) == 0 {
-				continue
-			}
-			routingKey := routingKeys[rand.Intn(len(routingKeys))]
-			err := ch.PublishWithContext(context.Background(), exchange, routingKey, false, false, amqp091.Publishing{
-				Body: message,
-			})
-			if err != nil {
-				logger.Errorf("failed to publish message to exchange %s with routing key %s: %v", exchange, routingKey, err)
-			} else {
-				atomic.AddInt32(&sentMessagesCount, 1)
-			}
+	if !passed {
+		if err == nil {
+			logger.Errorf("load test failed its thresholds")
 		}
+		con.Close()
+		os.Exit(1)
 	}
 }
 
-func printStatistics(startTime, endTime time.Time, sentMessages, receivedMessages int32, queueCount, exchangeCount, routingKeyCount, messageSize, parallelClients int, expectedDuration time.Duration) {
-	actualDuration := endTime.Sub(startTime)
-	durationSeconds := actualDuration.Seconds()
-	expectedSeconds := expectedDuration.Seconds()
-
-	sentMsgsPerSecond := float64(sentMessages) / durationSeconds
-	receivedMsgsPerSecond := float64(receivedMessages) / durationSeconds
-
-	totalDataSent := int64(sentMessages) * int64(messageSize)
-	totalDataReceived := int64(receivedMessages) * int64(messageSize)
-
-	dataSentMBps := float64(totalDataSent) / (1024 * 1024 * durationSeconds)
-	dataReceivedMBps := float64(totalDataReceived) / (1024 * 1024 * durationSeconds)
-
-	duplicationFactor := float64(receivedMessages) / float64(sentMessages)
-	timeMultiplier := durationSeconds / expectedSeconds
-
-	separator := strings.Repeat("=", 70)
-	fmt.Println("\n" + separator)
-	fmt.Println("                    LOAD TEST STATISTICS                         ")
-	fmt.Println(separator)
-	fmt.Printf("\nTest Configuration:\n")
-	fmt.Printf("  - Expected Duration:     %.2f seconds\n", expectedSeconds)
-	fmt.Printf("  - Actual Duration:       %.2f seconds\n", durationSeconds)
-	fmt.Printf("  - Time Multiplier:       %.2f x\n", timeMultiplier)
-	fmt.Printf("  - Queues:                %d (type: quorum)\n", queueCount)
-	fmt.Printf("  - Exchanges:             %d\n", exchangeCount)
-	fmt.Printf("  - Routing Keys:          %d\n", routingKeyCount)
-	fmt.Printf("  - Message Size:          %d bytes\n", messageSize)
-	fmt.Printf("  - Parallel Clients:      %d (consumers + producers)\n", parallelClients)
-
-	fmt.Printf("\nMessage Statistics:\n")
-	fmt.Printf("  - Messages Sent:         %d\n", sentMessages)
-	fmt.Printf("  - Messages Received:     %d\n", receivedMessages)
-	fmt.Printf("  - Duplication Factor:    %.2f x\n", duplicationFactor)
-
-	fmt.Printf("\nThroughput:\n")
-	fmt.Printf("  - Send Rate:             %.2f msgs/sec\n", sentMsgsPerSecond)
-	fmt.Printf("  - Receive Rate:          %.2f msgs/sec\n", receivedMsgsPerSecond)
-
-	fmt.Printf("\nData Transfer:\n")
-	fmt.Printf("  - Total Data Sent:       %.2f MB\n", float64(totalDataSent)/(1024*1024))
-	fmt.Printf("  - Total Data Received:   %.2f MB\n", float64(totalDataReceived)/(1024*1024))
-	fmt.Printf("  - Send Rate:             %.2f MB/s\n", dataSentMBps)
-	fmt.Printf("  - Receive Rate:          %.2f MB/s\n", dataReceivedMBps)
-
-	fmt.Println("\n" + separator)
+// stopOnSignal returns a channel closed on SIGINT or SIGTERM and a function releasing the
+// signal handler
+func stopOnSignal() (<-chan struct{}, func()) {
+	stop := make(chan struct{})
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		if sig, ok := <-sigChan; ok {
+			logger.Infof("signal received (%v), stopping test...", sig)
+			close(stop)
+		}
+	}()
+	return stop, func() {
+		signal.Stop(sigChan)
+		close(sigChan)
+	}
 }
@@ -0,0 +1,160 @@
+package load
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/VojtechPastyrik/vpd/utils/loadtest"
+	rabbitmqUtisl "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+)
+
+// protocolDestinations returns one topic per queue of the profile. A random run id keeps
+// concurrent test runs apart, both plugins route them through amq.topic as vpd-load.<id>.<n>.
+func protocolDestinations(protocol string, count int) []string {
+	id := make([]byte, 4)
+	rand.Read(id)
+	run := hex.EncodeToString(id)
+
+	destinations := make([]string, count)
+	for i := range destinations {
+		if protocol == rabbitmqUtisl.ProtocolSTOMP {
+			destinations[i] = fmt.Sprintf("/topic/vpd-load.%s.%d", run, i+1)
+		} else {
+			destinations[i] = fmt.Sprintf("vpd-load/%s/%d", run, i+1)
+		}
+	}
+	return destinations
+}
+
+// protocolTopology describes the destinations as queues and publish targets, so the report
+// is built the same way as for AMQP
+func protocolTopology(protocol string, destinations []string) *Topology {
+	topology := &Topology{}
+	for _, destination := range destinations {
+		topology.Queues = append(topology.Queues, QueueSpec{Name: destination, Type: protocol + " subscription"})
+		topology.Publish = append(topology.Publish, PublishTarget{RoutingKey: destination})
+	}
+	return topology
+}
+
+// protocolDriver runs the broker-agnostic load engine through the MQTT or STOMP plugin
+type protocolDriver struct {
+	options    rabbitmqUtisl.ConnectionOptions
+	protocol   string
+	port       int
+	persistent bool
+}
+
+func (d *protocolDriver) Name() string {
+	return fmt.Sprintf("RabbitMQ %s plugin", d.protocol)
+}
+
+func (d *protocolDriver) Connect() (loadtest.Conn, error) {
+	client, err := rabbitmqUtisl.DialProtocol(d.options, d.protocol, d.port)
+	if err != nil {
+		return nil, err
+	}
+	return &protocolConn{client: client, persistent: d.persistent && d.protocol == rabbitmqUtisl.ProtocolSTOMP}, nil
+}
+
+// protocolConn adapts a protocol client to the load engine. Topics exist implicitly, there
+// is nothing to declare.
+type protocolConn struct {
+	client     rabbitmqUtisl.ProtocolClient
+	persistent bool
+}
+
+func (c *protocolConn) Declare(destinations []string) error {
+	return nil
+}
+
+func (c *protocolConn) Delete(destinations []string) error {
+	return nil
+}
+
+// Publish waits for the PUBACK or receipt of the plugin
+func (c *protocolConn) Publish(destination string, msg loadtest.Message) error {
+	headers := msg.Headers
+	if c.persistent {
+		headers = make(map[string]string, len(msg.Headers)+1)
+		for name, value := range msg.Headers {
+			headers[name] = value
+		}
+		headers["persistent"] = "true"
+	}
+	return c.client.Publish(destination, rabbitmqUtisl.ProtocolMessage{Headers: headers, Body: msg.Body})
+}
+
+func (c *protocolConn) Consume(destination string) (<-chan loadtest.Message, error) {
+	source, err := c.client.Subscribe(destination)
+	if err != nil {
+		return nil, err
+	}
+	messages := make(chan loadtest.Message, cap(source))
+	go func() {
+		defer close(messages)
+		for msg := range source {
+			messages <- loadtest.Message{Destination: msg.Destination, Body: msg.Body, Headers: msg.Headers}
+		}
+	}()
+	return messages, nil
+}
+
+// Headers is false for MQTT 3.1.1, which carries the trace data in front of the body
+func (c *protocolConn) Headers() bool {
+	return c.client.SupportsHeaders()
+}
+
+func (c *protocolConn) Close() error {
+	return c.client.Close()
+}
+
+func runProtocolLoadTest(connectionOptions rabbitmqUtisl.ConnectionOptions, duration string, destinationCount int, publishOptions PublishOptions, consumeOptions ConsumeOptions, parallelClients int, profile string, thresholds loadtest.Thresholds) {
+	protocol := FlagProtocol.Protocol
+	durationParsed, err := time.ParseDuration(duration)
+	if err != nil {
+		logger.Fatalf("invalid duration format: %v", err)
+	}
+	if FlagTopologyFile != "" || FlagUseExisting {
+		logger.Warnf("the %s protocol publishes to generated topics, the topology options are ignored", protocol)
+	}
+
+	destinations := protocolDestinations(protocol, destinationCount)
+	driver := &protocolDriver{
+		options:    connectionOptions,
+		protocol:   protocol,
+		port:       FlagProtocol.Port,
+		persistent: publishOptions.Persistent,
+	}
+
+	stop, stopSignals := stopOnSignal()
+	defer stopSignals()
+
+	stats := loadtest.NewStats()
+	startTime := time.Now()
+	err = loadtest.Run(driver, loadtest.Options{
+		Destinations:   destinations,
+		Clients:        parallelClients,
+		Duration:       durationParsed,
+		Rate:           publishOptions.Rate,
+		Size:           publishOptions.sizeOptions(),
+		SampleInterval: FlagSampleInterval,
+	}, stats, stop)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	endTime := time.Now()
+	logger.Info("load test completed")
+
+	topology := protocolTopology(protocol, destinations)
+	report := buildReport(profile, protocol, startTime, endTime, stats, topology, publishOptions, consumeOptions, parallelClients, durationParsed)
+	report.Finish(thresholds, FlagOutput, FlagOutputFile)
+	if !report.Passed {
+		logger.Errorf("load test failed its thresholds")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,71 @@
+package load
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/VojtechPastyrik/vpd/utils/loadtest"
+)
+
+// ReportConfig is the configuration written to the results
+type ReportConfig struct {
+	Protocol         string  `json:"protocol"`
+	Queues           int     `json:"queues"`
+	Exchanges        int     `json:"exchanges"`
+	Bindings         int     `json:"bindings"`
+	PublishTargets   int     `json:"publishTargets"`
+	MessageSize      int     `json:"messageSize"`
+	SizeDistribution string  `json:"sizeDistribution"`
+	TargetRate       float64 `json:"targetRate"`
+	Persistent       bool    `json:"persistent"`
+	Prefetch         int     `json:"prefetch"`
+	ManualAck        bool    `json:"manualAck"`
+	ParallelClients  int     `json:"parallelClients"`
+}
+
+func buildReport(profile, protocol string, startTime, endTime time.Time, stats *loadtest.Stats, topology *Topology, publishOptions PublishOptions, consumeOptions ConsumeOptions, parallelClients int, expectedDuration time.Duration) *loadtest.Report {
+	publishTargets := len(topology.publishTargets())
+	queueTypesSummary, exchangeTypesSummary := topology.describe()
+	ack := consumeOptions.ack()
+
+	report := loadtest.NewReport("rabbitmq", profile, startTime, endTime, expectedDuration, stats)
+	report.Configuration = ReportConfig{
+		Protocol:         protocol,
+		Queues:           len(topology.Queues),
+		Exchanges:        len(topology.Exchanges),
+		Bindings:         len(topology.Bindings),
+		PublishTargets:   publishTargets,
+		MessageSize:      publishOptions.MessageSize,
+		SizeDistribution: publishOptions.SizeDistribution,
+		TargetRate:       publishOptions.Rate.Rate,
+		Persistent:       publishOptions.Persistent,
+		Prefetch:         consumeOptions.Prefetch,
+		ManualAck:        ack.Enabled(),
+		ParallelClients:  parallelClients,
+	}
+
+	settings := []loadtest.Setting{
+		{Name: "Protocol", Value: protocol},
+		{Name: "Queues", Value: fmt.Sprintf("%d (%s)", len(topology.Queues), queueTypesSummary)},
+	}
+	if len(topology.Exchanges) > 0 {
+		settings = append(settings,
+			loadtest.Setting{Name: "Exchanges", Value: fmt.Sprintf("%d (%s)", len(topology.Exchanges), exchangeTypesSummary)},
+			loadtest.Setting{Name: "Bindings", Value: fmt.Sprintf("%d", len(topology.Bindings))})
+	}
+	settings = append(settings, loadtest.Setting{Name: "Publish Targets", Value: fmt.Sprintf("%d", publishTargets)})
+	deliveryMode := "transient"
+	if publishOptions.Persistent {
+		deliveryMode = "persistent"
+	}
+	settings = append(settings,
+		loadtest.Setting{Name: "Delivery Mode", Value: deliveryMode},
+		loadtest.Setting{Name: "Prefetch", Value: fmt.Sprintf("%d", consumeOptions.Prefetch)})
+	if ack.Enabled() {
+		settings = append(settings, loadtest.Setting{Name: "Acknowledgements", Value: fmt.Sprintf("manual (delay %s, nack %.1f%%, requeue %t)", ack.Delay, ack.NackPercent, ack.Requeue)})
+	} else {
+		settings = append(settings, loadtest.Setting{Name: "Acknowledgements", Value: "auto"})
+	}
+	report.Settings = append(settings, loadtest.WorkloadSettings(publishOptions.sizeOptions(), publishOptions.Rate, parallelClients)...)
+	return report
+}
@@ -0,0 +1,73 @@
+package load
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/VojtechPastyrik/vpd/utils/loadtest"
+)
+
+// PublishOptions configures how producers shape their messages
+type PublishOptions struct {
+	Rate             loadtest.RateOptions
+	MessageSize      int
+	SizeDistribution string
+	MinMessageSize   int
+	MaxMessageSize   int
+	SizeStdDev       int
+	Persistent       bool
+	Priority         int
+	Expiration       int
+}
+
+// ConsumeOptions configures consumer behaviour
+type ConsumeOptions struct {
+	ManualAck   bool
+	AckDelay    time.Duration
+	NackPercent float64
+	Requeue     bool
+	Prefetch    int
+}
+
+func (o PublishOptions) validate() error {
+	if err := o.Rate.Validate(); err != nil {
+		return err
+	}
+	if err := o.sizeOptions().Validate(); err != nil {
+		return err
+	}
+	if o.Priority < 0 || o.Priority > 255 {
+		return fmt.Errorf("message priority must be between 0 and 255")
+	}
+	return nil
+}
+
+func (o PublishOptions) sizeOptions() loadtest.SizeOptions {
+	return loadtest.SizeOptions{
+		MessageSize:  o.MessageSize,
+		Distribution: o.SizeDistribution,
+		MinSize:      o.MinMessageSize,
+		MaxSize:      o.MaxMessageSize,
+		StdDev:       o.SizeStdDev,
+	}
+}
+
+func (o ConsumeOptions) validate() error {
+	if o.NackPercent < 0 || o.NackPercent > 100 {
+		return fmt.Errorf("nack percentage must be between 0 and 100")
+	}
+	if o.Prefetch < 0 {
+		return fmt.Errorf("prefetch count must not be negative")
+	}
+	return nil
+}
+
+// ack returns the acknowledgement options of the driver
+func (o ConsumeOptions) ack() loadtest.AckOptions {
+	return loadtest.AckOptions{
+		Manual:      o.ManualAck,
+		Delay:       o.AckDelay,
+		NackPercent: o.NackPercent,
+		Requeue:     o.Requeue,
+	}
+}
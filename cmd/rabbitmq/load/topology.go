@@ -0,0 +1,455 @@
+package load
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/VojtechPastyrik/vpd/utils/loadtest"
+	"github.com/rabbitmq/amqp091-go"
+	"gopkg.in/yaml.v3"
+)
+
+// Topology describes exchanges, queues and bindings used by the load test. It is either
+// generated from the profile counts or loaded from a user supplied YAML file.
+type Topology struct {
+	Exchanges []ExchangeSpec  `yaml:"exchanges"`
+	Queues    []QueueSpec     `yaml:"queues"`
+	Bindings  []BindingSpec   `yaml:"bindings"`
+	Publish   []PublishTarget `yaml:"publish"`
+}
+
+type ExchangeSpec struct {
+	Name       string                 `yaml:"name"`
+	Type       string                 `yaml:"type"`
+	Durable    *bool                  `yaml:"durable"`
+	AutoDelete bool                   `yaml:"autoDelete"`
+	Internal   bool                   `yaml:"internal"`
+	Arguments  map[string]interface{} `yaml:"arguments"`
+}
+
+type QueueSpec struct {
+	Name       string                 `yaml:"name"`
+	Type       string                 `yaml:"type"`
+	Durable    *bool                  `yaml:"durable"`
+	AutoDelete bool                   `yaml:"autoDelete"`
+	Exclusive  bool                   `yaml:"exclusive"`
+	Arguments  map[string]interface{} `yaml:"arguments"`
+}
+
+type BindingSpec struct {
+	Exchange   string                 `yaml:"exchange"`
+	Queue      string                 `yaml:"queue"`
+	RoutingKey string                 `yaml:"routingKey"`
+	Arguments  map[string]interface{} `yaml:"arguments"`
+}
+
+// PublishTarget is a single exchange/routing key/headers combination producers publish to
+type PublishTarget struct {
+	Exchange   string                 `yaml:"exchange"`
+	RoutingKey string                 `yaml:"routingKey"`
+	Headers    map[string]interface{} `yaml:"headers"`
+}
+
+// TopologyOptions configures the generated topology
+type TopologyOptions struct {
+	ExchangeType         string
+	QueueType            string
+	Lazy                 bool
+	MaxLength            int
+	MaxLengthBytes       int
+	MessageTTL           int
+	Overflow             string
+	DeadLetterExchange   string
+	DeadLetterRoutingKey string
+}
+
+var (
+	exchangeTypes = []string{"direct", "topic", "fanout", "headers"}
+	queueTypes    = []string{"classic", "quorum", "stream"}
+)
+
+// routingKeyHeader carries the routing key for headers exchanges in the generated topology
+const routingKeyHeader = "x-vpd-routing-key"
+
+func loadTopologyFile(path string) (*Topology, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading topology file: %w", err)
+	}
+
+	var topology Topology
+	if err := yaml.Unmarshal(data, &topology); err != nil {
+		return nil, fmt.Errorf("error parsing topology file: %w", err)
+	}
+	if err := topology.validate(); err != nil {
+		return nil, fmt.Errorf("invalid topology file %s: %w", path, err)
+	}
+	return &topology, nil
+}
+
+// generateTopology builds the classic N×M×K mesh: every queue is bound to every exchange
+// with every routing key, so each message is delivered to all queues
+func generateTopology(exchangeCount, queueCount, routingKeyCount int, opts TopologyOptions) (*Topology, error) {
+	if !contains(exchangeTypes, opts.ExchangeType) {
+		return nil, fmt.Errorf("invalid exchange type '%s'. Available types: %s", opts.ExchangeType, strings.Join(exchangeTypes, ", "))
+	}
+	if !contains(queueTypes, opts.QueueType) {
+		return nil, fmt.Errorf("invalid queue type '%s'. Available types: %s", opts.QueueType, strings.Join(queueTypes, ", "))
+	}
+	if opts.Lazy && opts.QueueType != "classic" {
+		logger.Warnf("lazy mode only applies to classic queues, ignoring it for %s queues", opts.QueueType)
+	}
+
+	topology := &Topology{}
+	for i := 0; i < exchangeCount; i++ {
+		topology.Exchanges = append(topology.Exchanges, ExchangeSpec{
+			Name: fmt.Sprintf("test-exchange-%d", i),
+			Type: opts.ExchangeType,
+		})
+	}
+
+	queueArgs := map[string]interface{}{}
+	if opts.Lazy && opts.QueueType == "classic" {
+		queueArgs["x-queue-mode"] = "lazy"
+	}
+	if opts.MaxLength > 0 {
+		queueArgs["x-max-length"] = opts.MaxLength
+	}
+	if opts.MaxLengthBytes > 0 {
+		queueArgs["x-max-length-bytes"] = opts.MaxLengthBytes
+	}
+	if opts.MessageTTL > 0 {
+		queueArgs["x-message-ttl"] = opts.MessageTTL
+	}
+	if opts.Overflow != "" {
+		queueArgs["x-overflow"] = opts.Overflow
+	}
+	if opts.DeadLetterExchange != "" {
+		queueArgs["x-dead-letter-exchange"] = opts.DeadLetterExchange
+	}
+	if opts.DeadLetterRoutingKey != "" {
+		queueArgs["x-dead-letter-routing-key"] = opts.DeadLetterRoutingKey
+	}
+	for i := 0; i < queueCount; i++ {
+		args := make(map[string]interface{}, len(queueArgs))
+		for k, v := range queueArgs {
+			args[k] = v
+		}
+		topology.Queues = append(topology.Queues, QueueSpec{
+			Name:      fmt.Sprintf("test-queue-%d", i),
+			Type:      opts.QueueType,
+			Arguments: args,
+		})
+	}
+
+	for _, exchange := range topology.Exchanges {
+		for i := 0; i < routingKeyCount; i++ {
+			routingKey := fmt.Sprintf("test-routing-key-%d", i)
+			target := PublishTarget{Exchange: exchange.Name, RoutingKey: routingKey}
+			if opts.ExchangeType == "headers" {
+				target.Headers = map[string]interface{}{routingKeyHeader: routingKey}
+			}
+			topology.Publish = append(topology.Publish, target)
+
+			// A fanout exchange ignores routing keys, one binding per queue is enough
+			if opts.ExchangeType == "fanout" && i > 0 {
+				continue
+			}
+			for _, queue := range topology.Queues {
+				binding := BindingSpec{Exchange: exchange.Name, Queue: queue.Name, RoutingKey: routingKey}
+				if opts.ExchangeType == "headers" {
+					binding.Arguments = map[string]interface{}{"x-match": "all", routingKeyHeader: routingKey}
+				}
+				topology.Bindings = append(topology.Bindings, binding)
+			}
+		}
+	}
+
+	return topology, nil
+}
+
+func (t *Topology) validate() error {
+	if len(t.Queues) == 0 {
+		return fmt.Errorf("at least one queue is required")
+	}
+	exchanges := make(map[string]bool)
+	for _, exchange := range t.Exchanges {
+		if exchange.Name == "" {
+			return fmt.Errorf("exchange name is required")
+		}
+		if exchange.Type == "" {
+			return fmt.Errorf("exchange %s: type is required", exchange.Name)
+		}
+		exchanges[exchange.Name] = true
+	}
+	for _, queue := range t.Queues {
+		if queue.Name == "" {
+			return fmt.Errorf("queue name is required")
+		}
+		if queue.Type != "" && !contains(queueTypes, queue.Type) {
+			return fmt.Errorf("queue %s: invalid type '%s'", queue.Name, queue.Type)
+		}
+	}
+	for _, binding := range t.Bindings {
+		if binding.Exchange == "" || binding.Queue == "" {
+			return fmt.Errorf("binding requires both exchange and queue")
+		}
+	}
+	if len(t.Publish) == 0 && len(t.Bindings) == 0 {
+		return fmt.Errorf("either bindings or publish targets are required")
+	}
+	return nil
+}
+
+// publishTargets returns the publish targets of the topology. Without explicit publish
+// targets, one target is derived per binding (topic wildcards are replaced by a word, the
+// binding arguments of headers exchanges become message headers).
+func (t *Topology) publishTargets() []PublishTarget {
+	if len(t.Publish) > 0 {
+		return t.Publish
+	}
+	exchangeTypesByName := make(map[string]string)
+	for _, exchange := range t.Exchanges {
+		exchangeTypesByName[exchange.Name] = exchange.Type
+	}
+	var targets []PublishTarget
+	seen := make(map[string]bool)
+	for _, binding := range t.Bindings {
+		target := PublishTarget{Exchange: binding.Exchange, RoutingKey: binding.RoutingKey}
+		switch exchangeTypesByName[binding.Exchange] {
+		case "topic":
+			target.RoutingKey = concreteTopicKey(binding.RoutingKey)
+		case "headers":
+			target.Headers = make(map[string]interface{})
+			for k, v := range binding.Arguments {
+				if k != "x-match" {
+					target.Headers[k] = v
+				}
+			}
+		}
+		if key := target.key(); !seen[key] {
+			seen[key] = true
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// driverTargets maps the publish targets to the targets of the load engine by their key
+func (t *Topology) driverTargets() ([]string, map[string]loadtest.RabbitMQTarget) {
+	targets := t.publishTargets()
+	keys := make([]string, 0, len(targets))
+	driverTargets := make(map[string]loadtest.RabbitMQTarget, len(targets))
+	for _, target := range targets {
+		key := target.key()
+		keys = append(keys, key)
+		driverTargets[key] = loadtest.RabbitMQTarget{
+			Exchange:   target.Exchange,
+			RoutingKey: target.RoutingKey,
+			Headers:    toTable(target.Headers),
+		}
+	}
+	return keys, driverTargets
+}
+
+// key uniquely identifies the target, so messages of one stream are routed identically
+func (p PublishTarget) key() string {
+	if len(p.Headers) == 0 {
+		return p.Exchange + "|" + p.RoutingKey
+	}
+	headers := make([]string, 0, len(p.Headers))
+	for k, v := range p.Headers {
+		headers = append(headers, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(headers)
+	return p.Exchange + "|" + p.RoutingKey + "|" + strings.Join(headers, ",")
+}
+
+func concreteTopicKey(pattern string) string {
+	words := strings.Split(pattern, ".")
+	for i, word := range words {
+		if word == "*" || word == "#" {
+			words[i] = "load"
+		}
+	}
+	return strings.Join(words, ".")
+}
+
+func (t *Topology) queueNames() []string {
+	names := make([]string, 0, len(t.Queues))
+	for _, queue := range t.Queues {
+		names = append(names, queue.Name)
+	}
+	return names
+}
+
+// streamQueues returns queues that are consumed from the next offset with manual
+// acknowledgements
+func (t *Topology) streamQueues() map[string]bool {
+	streams := make(map[string]bool)
+	for _, queue := range t.Queues {
+		if queue.queueType() == "stream" {
+			streams[queue.Name] = true
+		}
+	}
+	return streams
+}
+
+// describe returns a short summary of queue and exchange types for the statistics
+func (t *Topology) describe() (queues string, exchanges string) {
+	count := func(values []string) string {
+		counts := make(map[string]int)
+		for _, v := range values {
+			counts[v]++
+		}
+		parts := make([]string, 0, len(counts))
+		for v, c := range counts {
+			parts = append(parts, fmt.Sprintf("%d %s", c, v))
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, ", ")
+	}
+
+	queueTypes := make([]string, 0, len(t.Queues))
+	for _, queue := range t.Queues {
+		queueTypes = append(queueTypes, queue.queueType())
+	}
+	exchangeTypes := make([]string, 0, len(t.Exchanges))
+	for _, exchange := range t.Exchanges {
+		exchangeTypes = append(exchangeTypes, exchange.Type)
+	}
+	return count(queueTypes), count(exchangeTypes)
+}
+
+func (q QueueSpec) queueType() string {
+	if q.Type != "" {
+		return q.Type
+	}
+	if queueType, ok := q.Arguments["x-queue-type"].(string); ok {
+		return queueType
+	}
+	return "classic"
+}
+
+func (q QueueSpec) arguments() amqp091.Table {
+	args := toTable(q.Arguments)
+	if q.Type != "" {
+		args["x-queue-type"] = q.Type
+	}
+	return args
+}
+
+func declareTopology(ch *amqp091.Channel, topology *Topology) {
+	for _, exchange := range topology.Exchanges {
+		err := ch.ExchangeDeclare(exchange.Name, exchange.Type, boolOrDefault(exchange.Durable, true), exchange.AutoDelete, exchange.Internal, false, toTable(exchange.Arguments))
+		if err != nil {
+			logger.Errorf("failed to declare exchange %s: %v", exchange.Name, err)
+		}
+	}
+
+	for _, queue := range topology.Queues {
+		_, err := ch.QueueDeclare(queue.Name, boolOrDefault(queue.Durable, true), queue.AutoDelete, queue.Exclusive, false, queue.arguments())
+		if err != nil {
+			logger.Errorf("failed to declare queue %s: %v", queue.Name, err)
+		}
+	}
+
+	for _, binding := range topology.Bindings {
+		err := ch.QueueBind(binding.Queue, binding.RoutingKey, binding.Exchange, false, toTable(binding.Arguments))
+		if err != nil {
+			logger.Errorf("failed to bind queue %s to exchange %s with routing key %s: %v", binding.Queue, binding.Exchange, binding.RoutingKey, err)
+		}
+	}
+}
+
+func deleteTopology(ch *amqp091.Channel, topology *Topology) {
+	streams := topology.streamQueues()
+
+	// First, purge all queues to remove remaining messages (streams cannot be purged)
+	for _, queue := range topology.queueNames() {
+		if streams[queue] {
+			continue
+		}
+		purgedCount, err := ch.QueuePurge(queue, false)
+		if err != nil {
+			logger.Errorf("failed to purge queue %s: %v", queue, err)
+		} else {
+			logger.Infof("purged %d messages from queue %s", purgedCount, queue)
+		}
+	}
+
+	// Unbind queues from exchanges
+	for _, binding := range topology.Bindings {
+		err := ch.QueueUnbind(binding.Queue, binding.RoutingKey, binding.Exchange, toTable(binding.Arguments))
+		if err != nil {
+			logger.Debugf("failed to unbind queue %s from exchange %s: %v", binding.Queue, binding.Exchange, err)
+		}
+	}
+
+	// Delete exchanges
+	for _, exchange := range topology.Exchanges {
+		err := ch.ExchangeDelete(exchange.Name, false, false)
+		if err != nil {
+			logger.Errorf("failed to delete exchange %s: %v", exchange.Name, err)
+		} else {
+			logger.Infof("deleted exchange %s", exchange.Name)
+		}
+	}
+
+	// Delete queues
+	for _, queue := range topology.queueNames() {
+		deletedCount, err := ch.QueueDelete(queue, false, false, false)
+		if err != nil {
+			logger.Errorf("failed to delete queue %s: %v", queue, err)
+		} else {
+			logger.Infof("deleted queue %s (%d messages remaining)", queue, deletedCount)
+		}
+	}
+
+	logger.Info("cleanup completed")
+}
+
+// toTable converts YAML decoded values to types supported by AMQP tables
+func toTable(values map[string]interface{}) amqp091.Table {
+	table := amqp091.Table{}
+	for k, v := range values {
+		table[k] = toTableValue(v)
+	}
+	return table
+}
+
+func toTableValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return toTable(value)
+	case []interface{}:
+		converted := make([]interface{}, len(value))
+		for i, item := range value {
+			converted[i] = toTableValue(item)
+		}
+		return converted
+	case int:
+		return int64(value)
+	default:
+		return value
+	}
+}
+
+func contains(slice []string, str string) bool {
+	for _, item := range slice {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
+
+func boolOrDefault(value *bool, def bool) bool {
+	if value == nil {
+		return def
+	}
+	return *value
+}
@@ -0,0 +1,40 @@
+package delete_queue
+
+import (
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/management"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+var (
+	FlagYes      bool
+	FlagIfEmpty  bool
+	FlagIfUnused bool
+)
+
+var Cmd = &cobra.Command{
+	Use:   "delete-queue <queue>",
+	Short: "Delete a queue",
+	Args:  cobra.ExactArgs(1),
+	Example: `  # Delete the queue only if it has no messages and no consumers
+  vpd rabbitmq management delete-queue --profile staging orders.tmp --if-empty --if-unused`,
+	Run: func(cmd *cobra.Command, args []string) {
+		queue := args[0]
+		client := parent_cmd.NewClient()
+		if !parent_cmd.Confirm(FlagYes, "Delete queue '%s' in vhost '%s'?", queue, client.VirtualHost) {
+			logger.Info("aborted")
+			return
+		}
+		if err := client.DeleteQueue(client.VirtualHost, queue, FlagIfEmpty, FlagIfUnused); err != nil {
+			logger.Fatalf("%v", err)
+		}
+		logger.Successf("queue '%s' deleted", queue)
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().BoolVarP(&FlagYes, "yes", "y", false, "Do not ask for confirmation")
+	Cmd.Flags().BoolVar(&FlagIfEmpty, "if-empty", false, "Only delete the queue when it has no messages")
+	Cmd.Flags().BoolVar(&FlagIfUnused, "if-unused", false, "Only delete the queue when it has no consumers")
+}
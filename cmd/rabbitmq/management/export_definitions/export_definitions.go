@@ -0,0 +1,54 @@
+package export_definitions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/management"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+var FlagAllVhosts bool
+
+var Cmd = &cobra.Command{
+	Use:   "export-definitions [file]",
+	Short: "Export RabbitMQ definitions",
+	Long:  "Export the definitions (queues, exchanges, bindings, policies) of the virtual host as JSON, or of the whole broker including users and permissions with --all-vhosts. Without a file the definitions are written to stdout.",
+	Args:  cobra.MaximumNArgs(1),
+	Example: `  vpd rabbitmq management export-definitions --profile prod prod-definitions.json
+  vpd rabbitmq mgmt export-definitions --profile prod --all-vhosts > broker.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		client := parent_cmd.NewClient()
+		vhost := client.VirtualHost
+		if FlagAllVhosts {
+			vhost = ""
+		}
+		data, err := client.ExportDefinitions(vhost)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+
+		var out bytes.Buffer
+		if err := json.Indent(&out, data, "", "  "); err != nil {
+			logger.Fatalf("error formatting definitions: %v", err)
+		}
+		out.WriteByte('\n')
+
+		if len(args) == 0 {
+			fmt.Print(out.String())
+			return
+		}
+		if err := os.WriteFile(args[0], out.Bytes(), 0600); err != nil {
+			logger.Fatalf("error writing definitions: %v", err)
+		}
+		logger.Successf("definitions exported to %s", args[0])
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().BoolVarP(&FlagAllVhosts, "all-vhosts", "A", false, "Export the definitions of the whole broker")
+}
@@ -0,0 +1,40 @@
+package import_definitions
+
+import (
+	"os"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/management"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+var FlagAllVhosts bool
+
+var Cmd = &cobra.Command{
+	Use:     "import-definitions <file>",
+	Short:   "Import RabbitMQ definitions",
+	Long:    "Import definitions exported by export-definitions into the virtual host, or into the whole broker with --all-vhosts. Existing resources are kept, definitions are merged.",
+	Args:    cobra.ExactArgs(1),
+	Example: `  vpd rabbitmq management import-definitions --profile staging prod-definitions.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			logger.Fatalf("error reading definitions: %v", err)
+		}
+
+		client := parent_cmd.NewClient()
+		vhost := client.VirtualHost
+		if FlagAllVhosts {
+			vhost = ""
+		}
+		if err := client.ImportDefinitions(vhost, data); err != nil {
+			logger.Fatalf("%v", err)
+		}
+		logger.Successf("definitions from %s imported", args[0])
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().BoolVarP(&FlagAllVhosts, "all-vhosts", "A", false, "Import broker wide definitions containing several virtual hosts")
+}
@@ -0,0 +1,108 @@
+package list
+
+import (
+	"fmt"
+	"strconv"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/management"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+var FlagAllVhosts bool
+
+var Cmd = &cobra.Command{
+	Use:       "list <queues|exchanges|bindings|connections|channels>",
+	Aliases:   []string{"ls"},
+	Short:     "List RabbitMQ resources",
+	Long:      "List queues, exchanges, bindings, connections or channels of the virtual host, or of all virtual hosts with --all-vhosts. Queues are listed with message counts, consumers and rates.",
+	ValidArgs: []string{"queues", "exchanges", "bindings", "connections", "channels"},
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	Example: `  vpd rabbitmq management list queues --profile prod
+  vpd rabbitmq mgmt ls connections --profile prod --all-vhosts -o json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		list(args[0])
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().BoolVarP(&FlagAllVhosts, "all-vhosts", "A", false, "List resources of all virtual hosts")
+}
+
+func list(resource string) {
+	client := parent_cmd.NewClient()
+	vhost := client.VirtualHost
+	if FlagAllVhosts {
+		vhost = ""
+	}
+
+	switch resource {
+	case "queues":
+		queues, err := client.ListQueues(vhost)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		var rows [][]string
+		for _, q := range queues {
+			rows = append(rows, []string{
+				q.Vhost, q.Name, q.Type, q.State,
+				strconv.Itoa(q.Messages), strconv.Itoa(q.MessagesReady), strconv.Itoa(q.MessagesUnacknowledged), strconv.Itoa(q.Consumers),
+				rate(q.MessageStats.PublishDetails.Rate), rate(q.MessageStats.DeliverGetDetails.Rate), rate(q.MessageStats.AckDetails.Rate),
+			})
+		}
+		parent_cmd.PrintResult(queues, []string{"VHOST", "NAME", "TYPE", "STATE", "MESSAGES", "READY", "UNACKED", "CONSUMERS", "PUBLISH/S", "DELIVER/S", "ACK/S"}, rows)
+	case "exchanges":
+		exchanges, err := client.ListExchanges(vhost)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		var rows [][]string
+		for _, e := range exchanges {
+			name := e.Name
+			if name == "" {
+				name = "(default)"
+			}
+			rows = append(rows, []string{e.Vhost, name, e.Type, strconv.FormatBool(e.Durable), strconv.FormatBool(e.AutoDelete), strconv.FormatBool(e.Internal)})
+		}
+		parent_cmd.PrintResult(exchanges, []string{"VHOST", "NAME", "TYPE", "DURABLE", "AUTO DELETE", "INTERNAL"}, rows)
+	case "bindings":
+		bindings, err := client.ListBindings(vhost)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		var rows [][]string
+		for _, b := range bindings {
+			source := b.Source
+			if source == "" {
+				source = "(default)"
+			}
+			rows = append(rows, []string{b.Vhost, source, b.Destination, b.DestinationType, b.RoutingKey})
+		}
+		parent_cmd.PrintResult(bindings, []string{"VHOST", "SOURCE", "DESTINATION", "TYPE", "ROUTING KEY"}, rows)
+	case "connections":
+		connections, err := client.ListConnections(vhost)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		var rows [][]string
+		for _, c := range connections {
+			rows = append(rows, []string{c.Vhost, c.Name, c.User, c.Protocol, strconv.FormatBool(c.SSL), c.State, strconv.Itoa(c.Channels)})
+		}
+		parent_cmd.PrintResult(connections, []string{"VHOST", "NAME", "USER", "PROTOCOL", "TLS", "STATE", "CHANNELS"}, rows)
+	case "channels":
+		channels, err := client.ListChannels(vhost)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		var rows [][]string
+		for _, c := range channels {
+			rows = append(rows, []string{c.Vhost, c.Name, c.User, c.State, strconv.Itoa(c.Consumers), strconv.Itoa(c.Prefetch), strconv.Itoa(c.MessagesUnacknowledged), strconv.FormatBool(c.Confirm)})
+		}
+		parent_cmd.PrintResult(channels, []string{"VHOST", "NAME", "USER", "STATE", "CONSUMERS", "PREFETCH", "UNACKED", "CONFIRM"}, rows)
+	}
+}
+
+func rate(value float64) string {
+	return fmt.Sprintf("%.1f", value)
+}
@@ -0,0 +1,78 @@
+package management
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	rabbitmqUtils "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	FlagConnection    *parent_cmd.ConnectionFlags
+	FlagManagementURL string
+	FlagOutput        string
+)
+
+var Cmd = &cobra.Command{
+	Use:     "management",
+	Aliases: []string{"mgmt"},
+	Short:   "Inspect and manage RabbitMQ through the management HTTP API",
+	Long:    "Inspect and manage RabbitMQ through the management HTTP API. The API URL is derived from the connection (port 15672, or 15671 with TLS) unless --management-url is set, and the connection credentials and TLS settings are reused.",
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	FlagConnection = parent_cmd.AddPersistentConnectionFlags(Cmd)
+	Cmd.PersistentFlags().StringVar(&FlagManagementURL, "management-url", "", "Management API URL, e.g. https://rabbit.example.com:15671 (default derived from the connection)")
+	Cmd.PersistentFlags().StringVarP(&FlagOutput, "output", "o", "table", "Output format: table or json")
+}
+
+// NewClient creates a management API client from the connection flags
+func NewClient() *rabbitmqUtils.ManagementClient {
+	options, err := FlagConnection.Options()
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	client, err := rabbitmqUtils.NewManagementClient(options, FlagManagementURL)
+	if err != nil {
+		logger.Fatalf("failed to create management API client: %v", err)
+	}
+	return client
+}
+
+// PrintResult prints the rows as a table, or the value as JSON with --output json
+func PrintResult(value any, header []string, rows [][]string) {
+	switch FlagOutput {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(value); err != nil {
+			logger.Fatalf("error encoding JSON: %v", err)
+		}
+	case "table":
+		if len(rows) == 0 {
+			fmt.Println("No resources found")
+			return
+		}
+		data := append(pterm.TableData{header}, rows...)
+		if err := pterm.DefaultTable.WithHasHeader().WithData(data).Render(); err != nil {
+			logger.Fatalf("error rendering table: %v", err)
+		}
+	default:
+		logger.Fatalf("unsupported output format '%s', use table or json", FlagOutput)
+	}
+}
+
+// Confirm asks the user to confirm a destructive action unless skip is set
+func Confirm(skip bool, format string, args ...any) bool {
+	if skip {
+		return true
+	}
+	confirmed, _ := pterm.DefaultInteractiveConfirm.Show(fmt.Sprintf(format, args...))
+	return confirmed
+}
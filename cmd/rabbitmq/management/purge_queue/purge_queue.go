@@ -0,0 +1,33 @@
+package purge_queue
+
+import (
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/management"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+var FlagYes bool
+
+var Cmd = &cobra.Command{
+	Use:     "purge-queue <queue>",
+	Short:   "Remove all ready messages from a queue",
+	Args:    cobra.ExactArgs(1),
+	Example: `  vpd rabbitmq management purge-queue --profile staging orders.dlq --yes`,
+	Run: func(cmd *cobra.Command, args []string) {
+		queue := args[0]
+		client := parent_cmd.NewClient()
+		if !parent_cmd.Confirm(FlagYes, "Purge all messages from queue '%s' in vhost '%s'?", queue, client.VirtualHost) {
+			logger.Info("aborted")
+			return
+		}
+		if err := client.PurgeQueue(client.VirtualHost, queue); err != nil {
+			logger.Fatalf("%v", err)
+		}
+		logger.Successf("queue '%s' purged", queue)
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().BoolVarP(&FlagYes, "yes", "y", false, "Do not ask for confirmation")
+}
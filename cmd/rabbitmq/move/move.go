@@ -0,0 +1,177 @@
+package move
+
+import (
+	"context"
+	"fmt"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	rabbitmqUtils "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/spf13/cobra"
+)
+
+var (
+	FlagConnection       *parent_cmd.ConnectionFlags
+	FlagFrom             string
+	FlagToExchange       string
+	FlagRoutingKey       string
+	FlagCount            int
+	FlagFilterHeaders    []string
+	FlagFilterRoutingKey string
+	FlagFilterJSON       []string
+	FlagDryRun           bool
+	FlagKeepXDeath       bool
+	FlagKeepUserId       bool
+)
+
+var Cmd = &cobra.Command{
+	Use:     "move",
+	Aliases: []string{"mv"},
+	Short:   "Move messages from a queue to an exchange",
+	Long:    "Move messages from a queue, typically a dead letter queue, to an exchange. Each message is republished and only acknowledged in the source queue after the broker confirmed it, so a message is never lost. Messages that do not match the filter or could not be moved stay in the source queue.",
+	Example: `  # Move everything from the dead letter queue back to the orders exchange
+  vpd rabbitmq move --profile prod --from orders.dlq --to-exchange orders
+
+  # Preview which failed EU orders would be moved with a new routing key
+  vpd rabbitmq move --profile prod --from orders.dlq --to-exchange orders --routing-key order.retry \
+    --filter-routing-key 'order.eu.*' --filter-json status=failed --count 100 --dry-run`,
+	Run: func(cmd *cobra.Command, args []string) {
+		moveMessages(FlagConnection, FlagFrom, FlagToExchange, FlagRoutingKey)
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	FlagConnection = parent_cmd.AddConnectionFlags(Cmd)
+	Cmd.Flags().StringVarP(&FlagFrom, "from", "f", "", "Source queue")
+	Cmd.MarkFlagRequired("from")
+	Cmd.Flags().StringVarP(&FlagToExchange, "to-exchange", "t", "", "Target exchange (\"\" is the default exchange, routing by queue name)")
+	Cmd.Flags().StringVarP(&FlagRoutingKey, "routing-key", "r", "", "Routing key for the moved messages (default original routing key)")
+	Cmd.Flags().IntVarP(&FlagCount, "count", "n", 0, "Maximum number of messages to move (0 = all)")
+	Cmd.Flags().StringArrayVar(&FlagFilterHeaders, "filter-header", nil, "Only move messages with the header, as name or name=value (repeatable)")
+	Cmd.Flags().StringVar(&FlagFilterRoutingKey, "filter-routing-key", "", "Only move messages whose routing key matches the glob pattern, e.g. 'order.*'")
+	Cmd.Flags().StringArrayVar(&FlagFilterJSON, "filter-json", nil, "Only move messages with a JSON body value, as path or path=value in gjson syntax (repeatable)")
+	Cmd.Flags().BoolVar(&FlagDryRun, "dry-run", false, "Only show which messages would be moved, the source queue stays untouched")
+	Cmd.Flags().BoolVar(&FlagKeepXDeath, "keep-x-death", false, "Keep the x-death and x-first/last-death headers (stripped by default)")
+	Cmd.Flags().BoolVar(&FlagKeepUserId, "keep-user-id", false, "Keep the user id property, RabbitMQ rejects messages whose user id is not the connection user (dropped by default)")
+}
+
+func moveMessages(connection *parent_cmd.ConnectionFlags, from, toExchange, routingKey string) {
+	filter, err := rabbitmqUtils.ParseMessageFilter(FlagFilterHeaders, FlagFilterRoutingKey, FlagFilterJSON)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	con, ch, err := connection.Connect()
+	if err != nil {
+		logger.Fatalf("connection to RabbitMQ failed: %v", err)
+	}
+	defer con.Close()
+	defer ch.Close()
+
+	// Publish on a separate channel, so confirms do not interfere with the deliveries
+	publishCh, err := con.Channel()
+	if err != nil {
+		logger.Fatalf("failed to open a channel: %v", err)
+	}
+	defer publishCh.Close()
+	if err := publishCh.Confirm(false); err != nil {
+		logger.Fatalf("failed to enable publisher confirms: %v", err)
+	}
+	returns := publishCh.NotifyReturn(make(chan amqp091.Return, 1))
+
+	moved, skipped, err := moveLoop(ch, publishCh, returns, from, toExchange, routingKey, filter)
+	if err != nil {
+		logger.Errorf("%v", err)
+	}
+
+	if FlagDryRun {
+		logger.Infof("dry run: %d messages would be moved, %d do not match, nothing was changed", moved, skipped)
+	} else {
+		logger.Successf("moved %d messages from '%s' to exchange '%s', %d left in the queue", moved, from, toExchange, skipped)
+	}
+	if err != nil {
+		ch.Close()
+		con.Close()
+		logger.Fatalf("move stopped early, remaining messages stay in '%s'", from)
+	}
+}
+
+// moveLoop fetches messages one by one. Moved messages are acked after their confirm,
+// everything else stays unacked and is requeued at the end, so it is not fetched twice.
+func moveLoop(ch, publishCh *amqp091.Channel, returns <-chan amqp091.Return, from, toExchange, routingKey string, filter rabbitmqUtils.MessageFilter) (int, int, error) {
+	var moved, skipped int
+	// lastUnacked is the newest delivery tag not acked, a multiple nack of it requeues all older unacked ones
+	var lastUnacked uint64
+	defer func() {
+		if lastUnacked > 0 {
+			if err := ch.Nack(lastUnacked, true, true); err != nil {
+				logger.Errorf("failed to requeue skipped messages: %v", err)
+			}
+		}
+	}()
+
+	for FlagCount == 0 || moved < FlagCount {
+		msg, ok, err := ch.Get(from, false)
+		if err != nil {
+			return moved, skipped, fmt.Errorf("failed to get message: %w", err)
+		}
+		if !ok {
+			break
+		}
+		previousUnacked := lastUnacked
+		lastUnacked = msg.DeliveryTag
+
+		if !filter.Matches(msg) {
+			skipped++
+			continue
+		}
+
+		targetKey := routingKey
+		if targetKey == "" {
+			targetKey = msg.RoutingKey
+		}
+		if FlagDryRun {
+			moved++
+			logger.Infof("would move message %s (routing key '%s') to exchange '%s' with routing key '%s': %s",
+				msg.MessageId, msg.RoutingKey, toExchange, targetKey, truncate(msg.Body, 200))
+			continue
+		}
+
+		publishing := rabbitmqUtils.PublishingFromDelivery(msg, FlagKeepUserId)
+		if !FlagKeepXDeath {
+			rabbitmqUtils.StripDeathHeaders(publishing.Headers)
+		}
+		confirmation, err := publishCh.PublishWithDeferredConfirmWithContext(context.Background(), toExchange, targetKey, true, false, publishing)
+		if err != nil {
+			skipped++
+			return moved, skipped, fmt.Errorf("failed to publish message: %w", err)
+		}
+		if !confirmation.Wait() {
+			skipped++
+			return moved, skipped, fmt.Errorf("broker rejected message %s", msg.MessageId)
+		}
+		// A return is delivered before the confirm of the same message
+		select {
+		case r := <-returns:
+			skipped++
+			return moved, skipped, fmt.Errorf("message %s is unroutable: exchange '%s' has no binding for routing key '%s' (%s)", msg.MessageId, r.Exchange, r.RoutingKey, r.ReplyText)
+		default:
+		}
+
+		if err := msg.Ack(false); err != nil {
+			return moved, skipped, fmt.Errorf("failed to acknowledge moved message, it may be duplicated: %w", err)
+		}
+		lastUnacked = previousUnacked
+		moved++
+	}
+	return moved, skipped, nil
+}
+
+func truncate(body []byte, max int) string {
+	if len(body) <= max {
+		return string(body)
+	}
+	return string(body[:max]) + "..."
+}
@@ -0,0 +1,136 @@
+package add
+
+import (
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/profile"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	rabbitmqUtils "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/spf13/cobra"
+)
+
+var (
+	FlagURI              string
+	FlagHost             string
+	FlagPort             int
+	FlagUser             string
+	FlagPassword         string
+	FlagPasswordEnv      string
+	FlagVaultFile        string
+	FlagVaultKey         string
+	FlagVaultPasswordEnv string
+	FlagVirtualHost      string
+	FlagSsl              bool
+	FlagSslCA            string
+	FlagSslServerName    string
+	FlagInsecure         bool
+	FlagSslCert          string
+	FlagSslKey           string
+	FlagSslP12           string
+	FlagSslP12PassEnv    string
+	FlagExternalAuth     bool
+	FlagForce            bool
+)
+
+var Cmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a RabbitMQ connection profile",
+	Long:  "Add a named RabbitMQ connection profile. The password can be read from an environment variable or a vaultino encrypted file instead of being stored in plain text.",
+	Example: `  vpd rabbitmq profile add local --host localhost --user guest --password guest
+  vpd rabbitmq profile add prod --uri amqps://app@rabbit.example.com:5671/prod --password-env RABBITMQ_PROD_PASSWORD
+  vpd rabbitmq profile add mtls --host rabbit.example.com --port 5671 --ssl --ssl-ca ca.pem --ssl-p12 client.p12 --ssl-p12-password-env CLIENT_P12_PASSWORD --external-auth
+  vpd rabbitmq profile add stage --host rabbit.stage --user app --vault-file secrets.vault --vault-key rabbitmq.password`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		addProfile(args[0])
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVar(&FlagURI, "uri", "", "AMQP URI, e.g. amqps://user@host:5671/vhost")
+	Cmd.Flags().StringVarP(&FlagHost, "host", "H", "", "RabbitMQ host")
+	Cmd.Flags().IntVarP(&FlagPort, "port", "P", 0, "RabbitMQ port")
+	Cmd.Flags().StringVarP(&FlagUser, "user", "u", "", "RabbitMQ user")
+	Cmd.Flags().StringVarP(&FlagPassword, "password", "p", "", "RabbitMQ password (stored in plain text)")
+	Cmd.Flags().StringVar(&FlagPasswordEnv, "password-env", "", "Environment variable with the RabbitMQ password")
+	Cmd.Flags().StringVar(&FlagVaultFile, "vault-file", "", "Vaultino file with the RabbitMQ password")
+	Cmd.Flags().StringVar(&FlagVaultKey, "vault-key", "", "Key of the RabbitMQ password in the vaultino file")
+	Cmd.Flags().StringVar(&FlagVaultPasswordEnv, "vault-password-env", "", "Environment variable with the vaultino password (prompts if not set)")
+	Cmd.Flags().StringVarP(&FlagVirtualHost, "vhost", "v", "", "RabbitMQ virtual host")
+	Cmd.Flags().BoolVarP(&FlagSsl, "ssl", "s", false, "Use SSL for RabbitMQ connection")
+	Cmd.Flags().StringVar(&FlagSslCA, "ssl-ca", "", "PEM bundle of CA certificates trusted in addition to the system roots")
+	Cmd.Flags().StringVar(&FlagSslServerName, "ssl-server-name", "", "Server name to verify the broker certificate against (default host)")
+	Cmd.Flags().BoolVar(&FlagInsecure, "insecure", false, "Skip verification of the broker certificate")
+	Cmd.Flags().StringVarP(&FlagSslCert, "ssl-cert", "c", "", "Path to PEM client certificate file")
+	Cmd.Flags().StringVarP(&FlagSslKey, "ssl-key", "k", "", "Path to PEM client key file")
+	Cmd.Flags().StringVar(&FlagSslP12, "ssl-p12", "", "Path to PKCS#12 file with the client certificate and key")
+	Cmd.Flags().StringVar(&FlagSslP12PassEnv, "ssl-p12-password-env", "", "Environment variable with the PKCS#12 password")
+	Cmd.Flags().BoolVar(&FlagExternalAuth, "external-auth", false, "Authenticate with the client certificate (SASL EXTERNAL)")
+	Cmd.Flags().BoolVarP(&FlagForce, "force", "f", false, "Overwrite an existing profile")
+}
+
+func addProfile(name string) {
+	sources := 0
+	for _, set := range []bool{FlagPassword != "", FlagPasswordEnv != "", FlagVaultFile != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		logger.Fatalf("use only one of --password, --password-env and --vault-file")
+	}
+	if (FlagVaultFile == "") != (FlagVaultKey == "") {
+		logger.Fatalf("--vault-file and --vault-key must be used together")
+	}
+	if FlagExternalAuth && FlagSslCert == "" && FlagSslP12 == "" {
+		logger.Fatalf("--external-auth requires --ssl-cert/--ssl-key or --ssl-p12")
+	}
+	if FlagURI == "" && FlagHost == "" {
+		logger.Fatalf("either --uri or --host is required")
+	}
+
+	profile := rabbitmqUtils.Profile{
+		URI:               FlagURI,
+		Host:              FlagHost,
+		Port:              FlagPort,
+		User:              FlagUser,
+		Password:          FlagPassword,
+		PasswordEnv:       FlagPasswordEnv,
+		VirtualHost:       FlagVirtualHost,
+		SSL:               FlagSsl,
+		SSLCA:             FlagSslCA,
+		SSLServerName:     FlagSslServerName,
+		Insecure:          FlagInsecure,
+		SSLCert:           FlagSslCert,
+		SSLKey:            FlagSslKey,
+		SSLP12:            FlagSslP12,
+		SSLP12PasswordEnv: FlagSslP12PassEnv,
+		ExternalAuth:      FlagExternalAuth,
+	}
+	if FlagVaultFile != "" {
+		profile.PasswordVault = &rabbitmqUtils.VaultSecret{
+			File:        FlagVaultFile,
+			Key:         FlagVaultKey,
+			PasswordEnv: FlagVaultPasswordEnv,
+		}
+	}
+	if err := (rabbitmqUtils.ConnectionOptions{URI: profile.URI}).Validate(); err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	profiles, err := rabbitmqUtils.LoadProfiles()
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if _, exists := profiles[name]; exists && !FlagForce {
+		logger.Fatalf("profile '%s' already exists, use --force to overwrite it", name)
+	}
+	profiles[name] = profile
+	if err := rabbitmqUtils.SaveProfiles(profiles); err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	if FlagPassword != "" {
+		logger.Warnf("the password of profile '%s' is stored in plain text, consider --password-env or --vault-file", name)
+	}
+	logger.Successf("profile '%s' saved", name)
+}
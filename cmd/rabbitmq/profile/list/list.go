@@ -0,0 +1,47 @@
+package list
+
+import (
+	"fmt"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/profile"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	rabbitmqUtils "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List RabbitMQ connection profiles",
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles, err := rabbitmqUtils.LoadProfiles()
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		if len(profiles) == 0 {
+			fmt.Println("No connection profiles found, add one with 'vpd rabbitmq profile add'")
+			return
+		}
+
+		fmt.Printf("%-20s %-55s %s\n", "NAME", "URL", "PASSWORD")
+		fmt.Printf("%-20s %-55s %s\n", "----", "---", "--------")
+		for _, name := range rabbitmqUtils.ProfileNames(profiles) {
+			profile := profiles[name]
+			// Do not resolve the password, listing must not prompt or read secrets
+			options := rabbitmqUtils.ConnectionOptions{
+				URI:         profile.URI,
+				Host:        profile.Host,
+				Port:        profile.Port,
+				User:        profile.User,
+				Password:    profile.Password,
+				VirtualHost: profile.VirtualHost,
+				SSL:         profile.SSL,
+			}
+			fmt.Printf("%-20s %-55s %s\n", name, options.Redacted(), profile.PasswordSource())
+		}
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+}
@@ -0,0 +1,16 @@
+package profile
+
+import (
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage RabbitMQ connection profiles",
+	Long:  "Manage named RabbitMQ connection profiles stored in ~/.config/vpd/rabbitmq.yaml. Every rabbitmq command accepts --profile <name> instead of the individual connection flags.",
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+}
@@ -0,0 +1,34 @@
+package remove
+
+import (
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/profile"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	rabbitmqUtils "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:     "remove <name>",
+	Aliases: []string{"rm"},
+	Short:   "Remove a RabbitMQ connection profile",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		profiles, err := rabbitmqUtils.LoadProfiles()
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		if _, exists := profiles[name]; !exists {
+			logger.Fatalf("profile '%s' not found", name)
+		}
+		delete(profiles, name)
+		if err := rabbitmqUtils.SaveProfiles(profiles); err != nil {
+			logger.Fatalf("%v", err)
+		}
+		logger.Successf("profile '%s' removed", name)
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+}
@@ -0,0 +1,46 @@
+package test_connection
+
+import (
+	"fmt"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq/profile"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	rabbitmqUtils "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "test <name>",
+	Short: "Test a RabbitMQ connection profile",
+	Long:  "Connect to RabbitMQ using the profile, open a channel and print the server version.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		profile, err := rabbitmqUtils.GetProfile(name)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		options, err := profile.Options()
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+
+		if options.TLS.Insecure {
+			logger.Warn("TLS certificate verification is disabled")
+		}
+		logger.Infof("connecting to %s", options.Redacted())
+		con, ch, err := rabbitmqUtils.Connect(options)
+		if err != nil {
+			logger.Fatalf("connection to RabbitMQ failed: %v", err)
+		}
+		defer con.Close()
+		defer ch.Close()
+
+		product, version := con.Properties["product"], con.Properties["version"]
+		logger.Successf("profile '%s' works, connected to %s %s", name, fmt.Sprint(product), fmt.Sprint(version))
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+}
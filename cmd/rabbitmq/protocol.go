@@ -0,0 +1,52 @@
+package rabbitmq
+
+import (
+	"strings"
+
+	rabbitmqUtils "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/spf13/cobra"
+)
+
+// ProtocolFlags select the protocol plugin used instead of AMQP 0-9-1
+type ProtocolFlags struct {
+	Protocol string
+	Port     int
+	// Destination is the raw MQTT topic or STOMP destination, only registered when requested
+	Destination string
+}
+
+// AddProtocolFlags registers --protocol and --protocol-port on the command, and --destination
+// with the usage when it is not empty
+func AddProtocolFlags(cmd *cobra.Command, destinationUsage string) *ProtocolFlags {
+	f := &ProtocolFlags{}
+	cmd.Flags().StringVar(&f.Protocol, "protocol", rabbitmqUtils.ProtocolAMQP, "Protocol to connect with: "+strings.Join(rabbitmqUtils.Protocols, ", ")+" (AMQP 1.0 is not supported)")
+	cmd.Flags().IntVar(&f.Port, "protocol-port", 0, "Port of the MQTT or STOMP plugin (default 1883/8883 for MQTT, 61613/61614 for STOMP)")
+	if destinationUsage != "" {
+		cmd.Flags().StringVar(&f.Destination, "destination", "", destinationUsage)
+	}
+	return f
+}
+
+// AMQP reports whether the command talks AMQP 0-9-1
+func (f *ProtocolFlags) AMQP() bool {
+	return f.Protocol == rabbitmqUtils.ProtocolAMQP
+}
+
+// MQTT reports whether one of the MQTT versions is selected
+func (f *ProtocolFlags) MQTT() bool {
+	return f.Protocol == rabbitmqUtils.ProtocolMQTT || f.Protocol == rabbitmqUtils.ProtocolMQTT5
+}
+
+// Validate checks the protocol name
+func (f *ProtocolFlags) Validate() error {
+	return rabbitmqUtils.ValidateProtocol(f.Protocol)
+}
+
+// Dial connects to the protocol plugin with the resolved connection flags
+func (f *ProtocolFlags) Dial(connection *ConnectionFlags) (rabbitmqUtils.ProtocolClient, error) {
+	options, err := connection.Options()
+	if err != nil {
+		return nil, err
+	}
+	return rabbitmqUtils.DialProtocol(options, f.Protocol, f.Port)
+}
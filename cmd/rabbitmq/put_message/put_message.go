@@ -1,80 +1,537 @@
 package put_message
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
 	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq"
 	"github.com/VojtechPastyrik/vpd/pkg/logger"
-	rabbitmqUtisl "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	rabbitmqUtils "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/google/uuid"
 	"github.com/rabbitmq/amqp091-go"
 	"github.com/spf13/cobra"
 )
 
+// directReplyTo is the pseudo queue for RabbitMQ direct reply-to, no reply queue has to be declared
+const directReplyTo = "amq.rabbitmq.reply-to"
+
+const defaultMessage = `{"message": "Hello RabbitMQ! This is default test message"}`
+
 var (
-	FlagHost        string
-	FlagPort        int
-	FlagUser        string
-	FlagPassword    string
-	FlagVirtualHost string
-	FlagExchange    string
-	FlagRoutingKey  string
-	FlagSsl         bool
-	FlagSslCert     string
-	FlagSslKey      string
-	FlagMessage     string
+	FlagConnection      *parent_cmd.ConnectionFlags
+	FlagProtocol        *parent_cmd.ProtocolFlags
+	FlagExchange        string
+	FlagRoutingKey      string
+	FlagMessage         string
+	FlagFile            string
+	FlagCount           int
+	FlagTemplate        bool
+	FlagHeaders         []string
+	FlagContentType     string
+	FlagContentEncoding string
+	FlagCorrelationId   string
+	FlagReplyTo         string
+	FlagMessageId       string
+	FlagExpiration      string
+	FlagPriority        uint8
+	FlagDeliveryMode    string
+	FlagType            string
+	FlagAppId           string
+	FlagConfirm         bool
+	FlagMandatory       bool
+	FlagRpc             bool
+	FlagRpcTimeout      time.Duration
 )
 
 var Cmd = &cobra.Command{
 	Use:     "put-message",
 	Aliases: []string{"pm"},
 	Short:   "Put a message to RabbitMQ server",
-	Long:    "Put a message to RabbitMQ server. It will connect to the server and send a message to the specified exchange and routing key",
-	Example: "vpd rabbitmq put-message --host localhost --port 5672 --user guest --password guest --vhost / --exchange my_exchange --routing-key my_routing_key --message '{\"message\": \"Hello RabbitMQ! This is default test message\"}'",
+	Long: `Put a message to RabbitMQ server. It will connect to the server and send a message to the specified exchange and routing key.
+The body is taken from --message, a file or stdin. With --template the body, header values, message id and correlation id are Go templates rendered for every message with {{.Counter}} (1..count), {{.UUID}}, {{.Timestamp}} (RFC 3339) and {{.Unix}}.
+By default every message waits for the publisher confirm and is published as mandatory, unroutable messages are reported and the command exits with status 1.
+With --rpc the message is sent with a reply-to address and the command waits for the response and prints its body to stdout.
+With --protocol mqtt, mqtt5 or stomp the messages are sent through the protocol plugin and every message waits for the PUBACK or receipt. STOMP sends to /exchange/<exchange>/<routing key> (/amq/queue/<routing key> for the default exchange) with the properties as headers, MQTT publishes to the routing key with dots replaced by slashes. MQTT 3.1.1 carries no headers and MQTT 5 only the content type and headers as user properties.`,
+	Example: `  vpd rabbitmq put-message --host localhost --port 5672 --user guest --password guest --vhost / --exchange my_exchange --routing-key my_routing_key --message '{"message": "Hello RabbitMQ! This is default test message"}'
+  vpd rabbitmq put-message --profile prod --exchange my_exchange --routing-key my_routing_key
+
+  # Send the body of a file as a persistent message with headers
+  vpd rabbitmq put-message --profile prod -e orders -r order.created --file order.json \
+    --delivery-mode persistent --header tenant=eu --header source=vpd
+
+  # Send 100 messages with unique ids
+  vpd rabbitmq put-message --profile stage -e orders -r order.created --count 100 --template \
+    --message-id '{{.UUID}}' --message '{"orderId": {{.Counter}}, "createdAt": "{{.Timestamp}}"}'
+
+  # Call a request/reply service
+  echo '{"sku": "A-1"}' | vpd rabbitmq put-message --profile stage -e "" -r price.quote --file - --rpc
+
+  # Publish to the MQTT topic sensors/eu/temperature through the MQTT plugin
+  vpd rabbitmq put-message --profile stage --protocol mqtt5 -r sensors.eu.temperature --message '{"value": 21.5}'
+
+  # Send to a queue over STOMP
+  vpd rabbitmq put-message --profile stage --protocol stomp --destination /amq/queue/orders --file order.json`,
 	Run: func(cmd *cobra.Command, args []string) {
-		putMessage(FlagHost, FlagPort, FlagUser, FlagPassword, FlagVirtualHost, FlagExchange, FlagRoutingKey, FlagSsl, FlagSslCert, FlagSslKey)
+		if FlagProtocol.AMQP() && !cmd.Flags().Changed("exchange") {
+			logger.Fatal(`required flag "exchange" not set`)
+		}
+		putMessage(FlagConnection, FlagExchange, FlagRoutingKey)
 	},
 }
 
 func init() {
 	parent_cmd.Cmd.AddCommand(Cmd)
-	Cmd.Flags().StringVarP(&FlagHost, "host", "H", "localhost", "RabbitMQ server host")
-	Cmd.MarkFlagRequired("host")
-	Cmd.Flags().IntVarP(&FlagPort, "port", "P", 5672, "RabbitMQ server port")
-	Cmd.Flags().StringVarP(&FlagUser, "user", "u", "guest", "RabbitMQ user")
-	Cmd.Flags().StringVarP(&FlagPassword, "password", "p", "guest", "RabbitMQ password")
-	Cmd.Flags().StringVarP(&FlagVirtualHost, "vhost", "v", "/", "RabbitMQ virtual host")
-	Cmd.Flags().StringVarP(&FlagExchange, "exchange", "e", "", "RabbitMQ exchange name to send the message to")
-	Cmd.MarkFlagRequired("exchange")
+	FlagConnection = parent_cmd.AddConnectionFlags(Cmd)
+	FlagProtocol = parent_cmd.AddProtocolFlags(Cmd, "MQTT topic or STOMP destination to send to instead of the one derived from --exchange and --routing-key")
+	Cmd.Flags().StringVarP(&FlagExchange, "exchange", "e", "", "RabbitMQ exchange name to send the message to (required with the amqp protocol)")
 	Cmd.Flags().StringVarP(&FlagRoutingKey, "routing-key", "r", "", "RabbitMQ routing key")
-	Cmd.Flags().BoolVarP(&FlagSsl, "ssl", "s", false, "Use SSL for RabbitMQ connection")
-	Cmd.Flags().StringVarP(&FlagSslCert, "ssl-cert", "c", "", "Path to SSL certificate file")
-	Cmd.Flags().StringVarP(&FlagSslKey, "ssl-key", "k", "", "Path to SSL key file")
 	Cmd.Flags().StringVarP(&FlagMessage, "message", "m", "", "Message to send to RabbitMQ")
+	Cmd.Flags().StringVarP(&FlagFile, "file", "f", "", "Read the message body from the file (- for stdin)")
+	Cmd.MarkFlagsMutuallyExclusive("message", "file")
+	Cmd.Flags().IntVarP(&FlagCount, "count", "n", 1, "Number of messages to send")
+	Cmd.Flags().BoolVarP(&FlagTemplate, "template", "t", false, "Render the body, header values, message id and correlation id as Go templates for every message")
+	Cmd.Flags().StringArrayVar(&FlagHeaders, "header", nil, "Message header as name=value (repeatable)")
+	Cmd.Flags().StringVar(&FlagContentType, "content-type", "application/json", "Content type of the message")
+	Cmd.Flags().StringVar(&FlagContentEncoding, "content-encoding", "", "Content encoding of the message")
+	Cmd.Flags().StringVar(&FlagCorrelationId, "correlation-id", "", "Correlation id (--rpc generates one when not set)")
+	Cmd.Flags().StringVar(&FlagReplyTo, "reply-to", "", "Reply-to address of the message")
+	Cmd.Flags().StringVar(&FlagMessageId, "message-id", "", "Message id")
+	Cmd.Flags().StringVar(&FlagExpiration, "expiration", "", "Per-message TTL as milliseconds or duration, e.g. 60000 or 1m")
+	Cmd.Flags().Uint8Var(&FlagPriority, "priority", 0, "Message priority (0-255, queues support up to x-max-priority)")
+	Cmd.Flags().StringVar(&FlagDeliveryMode, "delivery-mode", "transient", "Delivery mode: transient (1) or persistent (2)")
+	Cmd.Flags().StringVar(&FlagType, "type", "", "Message type property")
+	Cmd.Flags().StringVar(&FlagAppId, "app-id", "", "Application id property")
+	Cmd.Flags().BoolVar(&FlagConfirm, "confirm", true, "Wait for the publisher confirm of every message")
+	Cmd.Flags().BoolVar(&FlagMandatory, "mandatory", true, "Publish as mandatory and report messages returned as unroutable")
+	Cmd.Flags().BoolVar(&FlagRpc, "rpc", false, "Wait for a response sent to the reply-to address and print its body")
+	Cmd.Flags().DurationVar(&FlagRpcTimeout, "rpc-timeout", 30*time.Second, "How long to wait for each response in --rpc mode")
+}
+
+// templateData are the values available in message templates
+type templateData struct {
+	Counter   int
+	UUID      string
+	Timestamp string
+	Unix      int64
 }
 
-func putMessage(host string, port int, user, password, virtualHost, exchange, routingKey string, ssl bool, sslCert, sslKey string) {
-	con, ch, err := rabbitmqUtisl.ConnectToRabbitMQ(ssl, user, password, host, port, virtualHost, sslCert, sslKey)
+// messageTemplate builds the publishings, rendering the templated fields when enabled
+type messageTemplate struct {
+	body          string
+	bodyTemplate  *template.Template
+	headers       map[string]string
+	headerTmpls   map[string]*template.Template
+	messageId     *template.Template
+	correlationId *template.Template
+	publishing    amqp091.Publishing
+}
+
+func newMessageTemplate(body string) (*messageTemplate, error) {
+	deliveryMode, err := parseDeliveryMode(FlagDeliveryMode)
 	if err != nil {
-		logger.Fatalf("connection to RabbitMQ failed: %s", err.Error())
+		return nil, err
+	}
+	expiration, err := parseExpiration(FlagExpiration)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &messageTemplate{
+		body:        body,
+		headers:     make(map[string]string, len(FlagHeaders)),
+		headerTmpls: make(map[string]*template.Template, len(FlagHeaders)),
+		publishing: amqp091.Publishing{
+			ContentType:     FlagContentType,
+			ContentEncoding: FlagContentEncoding,
+			DeliveryMode:    deliveryMode,
+			Priority:        FlagPriority,
+			MessageId:       FlagMessageId,
+			CorrelationId:   FlagCorrelationId,
+			ReplyTo:         FlagReplyTo,
+			Expiration:      expiration,
+			Type:            FlagType,
+			AppId:           FlagAppId,
+		},
+	}
+	if t.bodyTemplate, err = parseTemplate("body", body); err != nil {
+		return nil, err
+	}
+	if t.messageId, err = parseTemplate("message-id", FlagMessageId); err != nil {
+		return nil, err
+	}
+	if t.correlationId, err = parseTemplate("correlation-id", FlagCorrelationId); err != nil {
+		return nil, err
+	}
+	for _, header := range FlagHeaders {
+		name, value, found := strings.Cut(header, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid header '%s', use name=value", header)
+		}
+		t.headers[name] = value
+		if t.headerTmpls[name], err = parseTemplate("header "+name, value); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// parseTemplate parses the text as a template, without --template the text is used as is
+func parseTemplate(name, text string) (*template.Template, error) {
+	if !FlagTemplate {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s template: %w", name, err)
 	}
+	return tmpl, nil
+}
+
+// render renders the template, or returns the literal text when templating is disabled
+func render(tmpl *template.Template, text string, data templateData) (string, error) {
+	if tmpl == nil {
+		return text, nil
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("error rendering %s template: %w", tmpl.Name(), err)
+	}
+	return out.String(), nil
+}
+
+// build renders the publishing for the message with the counter
+func (t *messageTemplate) build(counter int) (amqp091.Publishing, error) {
+	now := time.Now()
+	data := templateData{Counter: counter, UUID: uuid.NewString(), Timestamp: now.Format(time.RFC3339), Unix: now.Unix()}
+
+	publishing := t.publishing
+	body, err := render(t.bodyTemplate, t.body, data)
+	if err != nil {
+		return publishing, err
+	}
+	publishing.Body = []byte(body)
+	if publishing.MessageId, err = render(t.messageId, publishing.MessageId, data); err != nil {
+		return publishing, err
+	}
+	if publishing.CorrelationId, err = render(t.correlationId, publishing.CorrelationId, data); err != nil {
+		return publishing, err
+	}
+	if len(t.headers) > 0 {
+		publishing.Headers = make(amqp091.Table, len(t.headers))
+		for name, text := range t.headers {
+			value, err := render(t.headerTmpls[name], text, data)
+			if err != nil {
+				return publishing, err
+			}
+			publishing.Headers[name] = value
+		}
+	}
+	return publishing, nil
+}
 
-	var messageUsed string
-	if FlagMessage != "" {
-		messageUsed = FlagMessage
-	} else {
-		messageUsed = `{"message": "Hello RabbitMQ! This is default test message"}`
+func parseDeliveryMode(mode string) (uint8, error) {
+	switch strings.ToLower(mode) {
+	case "transient", "1":
+		return amqp091.Transient, nil
+	case "persistent", "2":
+		return amqp091.Persistent, nil
+	default:
+		return 0, fmt.Errorf("invalid delivery mode '%s', use transient or persistent", mode)
 	}
+}
 
+// parseExpiration converts the TTL into the milliseconds string expected by the broker
+func parseExpiration(expiration string) (string, error) {
+	if expiration == "" {
+		return "", nil
+	}
+	if ms, err := strconv.ParseUint(expiration, 10, 64); err == nil {
+		return strconv.FormatUint(ms, 10), nil
+	}
+	ttl, err := time.ParseDuration(expiration)
+	if err != nil || ttl < 0 {
+		return "", fmt.Errorf("invalid expiration '%s', use milliseconds or a duration like 30s", expiration)
+	}
+	return strconv.FormatInt(ttl.Milliseconds(), 10), nil
+}
+
+func readBody() (string, error) {
+	switch FlagFile {
+	case "":
+		if FlagMessage != "" {
+			return FlagMessage, nil
+		}
+		return defaultMessage, nil
+	case "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("error reading message from stdin: %w", err)
+		}
+		return string(data), nil
+	default:
+		data, err := os.ReadFile(FlagFile)
+		if err != nil {
+			return "", fmt.Errorf("error reading message file: %w", err)
+		}
+		return string(data), nil
+	}
+}
+
+func putMessage(connection *parent_cmd.ConnectionFlags, exchange, routingKey string) {
+	if FlagCount < 1 {
+		logger.Fatal("count must be at least 1")
+	}
+	if FlagRpc && FlagReplyTo != "" {
+		logger.Fatal("--reply-to cannot be used with --rpc, the response is received with direct reply-to")
+	}
+	if err := FlagProtocol.Validate(); err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if FlagRpc && !FlagProtocol.AMQP() {
+		logger.Fatal("--rpc is only supported with the amqp protocol")
+	}
+	body, err := readBody()
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	messages, err := newMessageTemplate(body)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if !FlagProtocol.AMQP() {
+		putProtocolMessages(connection, messages, exchange, routingKey)
+		return
+	}
+
+	con, ch, err := connection.Connect()
+	if err != nil {
+		logger.Fatalf("connection to RabbitMQ failed: %s", err.Error())
+	}
 	defer con.Close()
 	defer ch.Close()
-	if err := ch.Publish(exchange,
-		routingKey,
-		false,
-		false,
-		amqp091.Publishing{
-			ContentType: "application/json",
-			Body:        []byte(messageUsed),
-		},
-	); err != nil {
-		logger.Fatalf("failed to put message: %s", err.Error())
+
+	if FlagConfirm {
+		if err := ch.Confirm(false); err != nil {
+			logger.Fatalf("failed to enable publisher confirms: %v", err)
+		}
+	}
+	var returned atomic.Int64
+	returns := ch.NotifyReturn(make(chan amqp091.Return, 1))
+	go func() {
+		for r := range returns {
+			returned.Add(1)
+			logger.Errorf("message %s returned as unroutable: %s (exchange '%s', routing key '%s')", r.MessageId, r.ReplyText, r.Exchange, r.RoutingKey)
+		}
+	}()
+
+	var replies <-chan amqp091.Delivery
+	if FlagRpc {
+		// Direct reply-to requires consuming in no-ack mode before publishing on the same channel
+		replies, err = ch.Consume(directReplyTo, "", true, false, false, false, nil)
+		if err != nil {
+			logger.Fatalf("failed to consume from %s: %v", directReplyTo, err)
+		}
+	}
+
+	var published, nacked, timedOut int
+	for counter := 1; counter <= FlagCount; counter++ {
+		publishing, err := messages.build(counter)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		if FlagRpc {
+			publishing.ReplyTo = directReplyTo
+			if publishing.CorrelationId == "" {
+				publishing.CorrelationId = uuid.NewString()
+			}
+		}
+
+		start := time.Now()
+		if FlagConfirm {
+			confirmation, err := ch.PublishWithDeferredConfirmWithContext(context.Background(), exchange, routingKey, FlagMandatory, false, publishing)
+			if err != nil {
+				logger.Fatalf("failed to put message: %s", err.Error())
+			}
+			if !confirmation.Wait() {
+				nacked++
+				logger.Errorf("message %d was rejected by the broker", counter)
+				continue
+			}
+		} else if err := ch.PublishWithContext(context.Background(), exchange, routingKey, FlagMandatory, false, publishing); err != nil {
+			logger.Fatalf("failed to put message: %s", err.Error())
+		}
+		published++
+
+		if FlagRpc {
+			reply, err := waitForReply(replies, publishing.CorrelationId, FlagRpcTimeout)
+			if err != nil {
+				timedOut++
+				logger.Errorf("message %d: %v", counter, err)
+				continue
+			}
+			logger.Infof("response to message %d received in %s", counter, time.Since(start).Round(time.Millisecond))
+			fmt.Println(string(reply.Body))
+		}
+	}
+
+	if FlagMandatory {
+		// Returns arrive asynchronously, give the listener a moment to report them
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if FlagCount == 1 && nacked == 0 && returned.Load() == 0 && timedOut == 0 {
+		logger.Infof("message sent to exchange '%s' with routing key '%s'", exchange, routingKey)
+		return
+	}
+	summary := fmt.Sprintf("%d of %d messages sent to exchange '%s' with routing key '%s', %d rejected by the broker, %d returned as unroutable",
+		published, FlagCount, exchange, routingKey, nacked, returned.Load())
+	if FlagRpc {
+		summary += fmt.Sprintf(", %d without response", timedOut)
+	}
+	if nacked > 0 || returned.Load() > 0 || timedOut > 0 {
+		logger.Error(summary)
+		ch.Close()
+		con.Close()
+		os.Exit(1)
+	}
+	logger.Info(summary)
+}
+
+// putProtocolMessages sends the messages through the MQTT or STOMP plugin, every publish waits
+// for the PUBACK or receipt, which act as publisher confirms
+func putProtocolMessages(connection *parent_cmd.ConnectionFlags, messages *messageTemplate, exchange, routingKey string) {
+	destination, err := protocolDestination(exchange, routingKey)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	client, err := FlagProtocol.Dial(connection)
+	if err != nil {
+		logger.Fatalf("connection to RabbitMQ %s plugin failed: %v", FlagProtocol.Protocol, err)
+	}
+	defer client.Close()
+
+	if FlagProtocol.MQTT() {
+		if !client.SupportsHeaders() && len(FlagHeaders) > 0 {
+			logger.Warn("MQTT 3.1.1 has no message headers, --header values are dropped")
+		}
+		if FlagContentEncoding != "" || FlagCorrelationId != "" || FlagReplyTo != "" || FlagMessageId != "" ||
+			FlagExpiration != "" || FlagPriority > 0 || FlagType != "" || FlagAppId != "" {
+			logger.Warn("MQTT does not carry AMQP message properties, they are dropped")
+		}
+	}
+
+	var published, failed int
+	for counter := 1; counter <= FlagCount; counter++ {
+		publishing, err := messages.build(counter)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		if err := client.Publish(destination, protocolMessage(publishing)); err != nil {
+			failed++
+			logger.Errorf("message %d was not accepted by the broker: %v", counter, err)
+			continue
+		}
+		published++
+	}
+
+	if FlagCount == 1 && failed == 0 {
+		logger.Infof("message sent to %s destination '%s'", FlagProtocol.Protocol, destination)
+		return
+	}
+	summary := fmt.Sprintf("%d of %d messages sent to %s destination '%s', %d not accepted by the broker", published, FlagCount, FlagProtocol.Protocol, destination, failed)
+	if failed > 0 {
+		logger.Error(summary)
+		client.Close()
+		os.Exit(1)
+	}
+	logger.Info(summary)
+}
+
+// protocolDestination returns --destination or derives the destination from the exchange and
+// routing key the way the protocol plugins map them to AMQP
+func protocolDestination(exchange, routingKey string) (string, error) {
+	if FlagProtocol.Destination != "" {
+		return FlagProtocol.Destination, nil
+	}
+	if FlagProtocol.MQTT() {
+		if routingKey == "" {
+			return "", fmt.Errorf("MQTT requires --routing-key or --destination as the topic")
+		}
+		if exchange != "" && exchange != "amq.topic" {
+			logger.Warnf("MQTT messages are published to the exchange of the MQTT plugin (amq.topic by default), exchange '%s' is ignored", exchange)
+		}
+		return strings.ReplaceAll(routingKey, ".", "/"), nil
+	}
+	if exchange == "" {
+		if routingKey == "" {
+			return "", fmt.Errorf("STOMP requires --exchange, --routing-key or --destination")
+		}
+		return "/amq/queue/" + routingKey, nil
+	}
+	if routingKey == "" {
+		return "/exchange/" + exchange, nil
+	}
+	return "/exchange/" + exchange + "/" + routingKey, nil
+}
+
+// protocolMessage converts the publishing. STOMP carries the AMQP properties as the headers the
+// plugin maps back to properties, MQTT 5 only the content type and headers as user properties.
+func protocolMessage(p amqp091.Publishing) rabbitmqUtils.ProtocolMessage {
+	msg := rabbitmqUtils.ProtocolMessage{Body: p.Body, ContentType: p.ContentType, Headers: make(map[string]string, len(p.Headers))}
+	for name, value := range p.Headers {
+		msg.Headers[name] = fmt.Sprint(value)
+	}
+	if FlagProtocol.Protocol != rabbitmqUtils.ProtocolSTOMP {
+		return msg
+	}
+
+	set := func(name, value string) {
+		if value != "" {
+			msg.Headers[name] = value
+		}
+	}
+	set("content-encoding", p.ContentEncoding)
+	set("correlation-id", p.CorrelationId)
+	set("reply-to", p.ReplyTo)
+	set("amqp-message-id", p.MessageId)
+	set("expiration", p.Expiration)
+	set("type", p.Type)
+	set("app-id", p.AppId)
+	if p.Priority > 0 {
+		set("priority", strconv.Itoa(int(p.Priority)))
+	}
+	if p.DeliveryMode == amqp091.Persistent {
+		set("persistent", "true")
+	}
+	return msg
+}
+
+// waitForReply waits for the response with the correlation id, responses to earlier timed out
+// requests are skipped
+func waitForReply(replies <-chan amqp091.Delivery, correlationId string, timeout time.Duration) (amqp091.Delivery, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case reply, ok := <-replies:
+			if !ok {
+				return reply, fmt.Errorf("reply consumer was closed")
+			}
+			if reply.CorrelationId == correlationId {
+				return reply, nil
+			}
+			logger.Warnf("ignoring response with unexpected correlation id '%s'", reply.CorrelationId)
+		case <-timer.C:
+			return amqp091.Delivery{}, fmt.Errorf("no response within %s", timeout)
+		}
 	}
-	logger.Infof("message sent to exchange '%s' with routing key '%s'", exchange, routingKey)
 }
@@ -1,79 +1,322 @@
 package read_queue
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
 
 	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq"
 	"github.com/VojtechPastyrik/vpd/pkg/logger"
-	rabbitmqUtisl "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	rabbitmqUtils "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/rabbitmq/amqp091-go"
 	"github.com/spf13/cobra"
 )
 
 var (
-	FlagHost        string
-	FlagPort        int
-	FlagUser        string
-	FlagPassword    string
-	FlagVirtualHost string
-	FlagQueue       string
-	FlagSsl         bool
-	FlagSslCert     string
-	FlagSslKey      string
+	FlagConnection       *parent_cmd.ConnectionFlags
+	FlagProtocol         *parent_cmd.ProtocolFlags
+	FlagQueue            string
+	FlagPeek             bool
+	FlagCount            int
+	FlagFilterHeaders    []string
+	FlagFilterRoutingKey string
+	FlagFilterJSON       []string
+	FlagExport           string
 )
 
 var Cmd = &cobra.Command{
 	Use:     "read-queue",
 	Aliases: []string{"rq"},
 	Short:   "Read messages from RabbitMQ queue",
-	Long:    "Read messages from RabbitMQ queue. It will connect to the server and read messages from the specified queue",
-	Example: "vpd rabbitmq read-queue -host localhost --port 5672 --user guest --password guest --vhost / --queue my_queue",
+	Long:    "Read messages from RabbitMQ queue. It will connect to the server and read messages from the specified queue.\nBy default messages are consumed and removed from the queue. With --peek messages are fetched and requeued, so the queue stays untouched (requeued messages are marked as redelivered). With filters only the matching messages are consumed, the others are requeued and the command stops once the queue has been read through.\nWith --protocol stomp the queue is consumed from /amq/queue/<queue>, with --protocol mqtt or mqtt5 --destination is the topic filter to subscribe to. Protocol subscriptions cannot peek.",
+	Example: `  vpd rabbitmq read-queue --host localhost --port 5672 --user guest --password guest --vhost / --queue my_queue
+  vpd rabbitmq read-queue --profile prod --queue my_queue
+
+  # Browse the first 10 failed orders in a dead letter queue without removing them
+  vpd rabbitmq read-queue --profile prod --queue orders.dlq --peek --count 10 \
+    --filter-routing-key 'order.*' --filter-json status=failed
+
+  # Export all messages including properties and headers to JSONL
+  vpd rabbitmq read-queue --profile prod --queue orders.dlq --peek --export orders-dlq.jsonl
+
+  # Watch MQTT messages of all sensors in the eu region
+  vpd rabbitmq read-queue --profile stage --protocol mqtt5 --destination 'sensors/eu/#'`,
 	Run: func(cmd *cobra.Command, args []string) {
-		readQueue(FlagHost, FlagPort, FlagUser, FlagPassword, FlagVirtualHost, FlagQueue, FlagSsl, FlagSslCert, FlagSslKey)
+		if err := FlagProtocol.Validate(); err != nil {
+			logger.Fatalf("%v", err)
+		}
+		switch {
+		case FlagProtocol.MQTT() && FlagProtocol.Destination == "":
+			logger.Fatal(`required flag "destination" not set, MQTT subscribes to a topic filter`)
+		case FlagQueue == "" && (FlagProtocol.AMQP() || FlagProtocol.Destination == ""):
+			logger.Fatal(`required flag "queue" not set`)
+		}
+		if FlagProtocol.AMQP() {
+			readQueue(FlagConnection, FlagQueue)
+		} else {
+			readProtocol(FlagConnection, FlagQueue)
+		}
 	},
 }
 
 func init() {
 	parent_cmd.Cmd.AddCommand(Cmd)
-	Cmd.Flags().StringVarP(&FlagHost, "host", "H", "localhost", "RabbitMQ server host")
-	Cmd.MarkFlagRequired("host")
-	Cmd.Flags().IntVarP(&FlagPort, "port", "P", 5672, "RabbitMQ server port")
-	Cmd.Flags().StringVarP(&FlagUser, "user", "u", "guest", "RabbitMQ user")
-	Cmd.Flags().StringVarP(&FlagPassword, "password", "p", "guest", "RabbitMQ password")
-	Cmd.Flags().StringVarP(&FlagVirtualHost, "vhost", "v", "/", "RabbitMQ virtual host")
+	FlagConnection = parent_cmd.AddConnectionFlags(Cmd)
+	FlagProtocol = parent_cmd.AddProtocolFlags(Cmd, "MQTT topic filter or STOMP destination to subscribe to instead of the queue")
 	Cmd.Flags().StringVarP(&FlagQueue, "queue", "q", "", "RabbitMQ queue name to read messages from")
-	Cmd.MarkFlagRequired("queue")
-	Cmd.Flags().BoolVarP(&FlagSsl, "ssl", "s", false, "Use SSL for RabbitMQ connection")
-	Cmd.Flags().StringVarP(&FlagSslCert, "ssl-cert", "c", "", "Path to SSL certificate file")
-	Cmd.Flags().StringVarP(&FlagSslKey, "ssl-key", "k", "", "Path to SSL key file")
+	Cmd.Flags().BoolVar(&FlagPeek, "peek", false, "Browse messages without removing them from the queue")
+	Cmd.Flags().IntVarP(&FlagCount, "count", "n", 0, "Stop after this many matching messages (0 = all; consume mode without filters keeps waiting for new messages)")
+	Cmd.Flags().StringArrayVar(&FlagFilterHeaders, "filter-header", nil, "Only show messages with the header, as name or name=value (repeatable)")
+	Cmd.Flags().StringVar(&FlagFilterRoutingKey, "filter-routing-key", "", "Only show messages whose routing key matches the glob pattern, e.g. 'order.*'")
+	Cmd.Flags().StringArrayVar(&FlagFilterJSON, "filter-json", nil, "Only show messages with a JSON body value, as path or path=value in gjson syntax (repeatable)")
+	Cmd.Flags().StringVarP(&FlagExport, "export", "o", "", "Write matching messages with properties and headers as JSON lines to the file (- for stdout)")
+}
+
+// messageWriter prints or exports the matching messages
+type messageWriter struct {
+	queue   string
+	out     io.Writer
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+func newMessageWriter(queue, export string) (*messageWriter, error) {
+	writer := &messageWriter{queue: queue}
+	switch export {
+	case "":
+		return writer, nil
+	case "-":
+		writer.out = os.Stdout
+	default:
+		file, err := os.Create(export)
+		if err != nil {
+			return nil, fmt.Errorf("error creating export file: %w", err)
+		}
+		writer.out, writer.closer = file, file
+	}
+	writer.encoder = json.NewEncoder(writer.out)
+	return writer, nil
+}
+
+func (w *messageWriter) write(msg amqp091.Delivery) error {
+	if w.encoder == nil {
+		logger.Infof("message from the queue: %s headers: %s routingKey: %s", string(msg.Body), msg.Headers, msg.RoutingKey)
+		return nil
+	}
+	if err := w.encoder.Encode(rabbitmqUtils.MessageFromDelivery(w.queue, msg)); err != nil {
+		return fmt.Errorf("error exporting message: %w", err)
+	}
+	return nil
+}
+
+func (w *messageWriter) close() error {
+	if w.closer != nil {
+		return w.closer.Close()
+	}
+	return nil
 }
 
-func readQueue(host string, port int, user, password, virtualHost, queue string, ssl bool, sslCert, sslKey string) {
-	con, ch, err := rabbitmqUtisl.ConnectToRabbitMQ(ssl, user, password, host, port, virtualHost, sslCert, sslKey)
+func readQueue(connection *parent_cmd.ConnectionFlags, queue string) {
+	filter, err := rabbitmqUtils.ParseMessageFilter(FlagFilterHeaders, FlagFilterRoutingKey, FlagFilterJSON)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	writer, err := newMessageWriter(queue, FlagExport)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	con, ch, err := connection.Connect()
 	if err != nil {
 		logger.Fatalf("connection to RabbitMQ failed: %s", err.Error())
 	}
 	defer con.Close()
 	defer ch.Close()
 
-	msgs, err := ch.Consume(queue, "", true, false, false, false, nil)
+	var matched, scanned int
+	switch {
+	case FlagPeek:
+		matched, scanned, err = scanQueue(ch, queue, filter, writer, false)
+	case !filter.Empty():
+		matched, scanned, err = scanQueue(ch, queue, filter, writer, true)
+	default:
+		matched, scanned, err = consumeQueue(ch, queue, filter, writer)
+	}
+	if closeErr := writer.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	printSummary(matched, scanned)
+}
+
+func printSummary(matched, scanned int) {
+	if FlagExport != "" && FlagExport != "-" {
+		logger.Successf("exported %d of %d messages to %s", matched, scanned, FlagExport)
+	} else {
+		logger.Infof("%d of %d messages matched", matched, scanned)
+	}
+}
+
+// readProtocol subscribes through the MQTT or STOMP plugin until interrupted or the count is
+// reached. The subscription acknowledges automatically, messages are converted to deliveries so
+// filters and the export work the same as with AMQP.
+func readProtocol(connection *parent_cmd.ConnectionFlags, queue string) {
+	if FlagPeek {
+		logger.Fatalf("--peek is not supported with the %s protocol", FlagProtocol.Protocol)
+	}
+	source := FlagProtocol.Destination
+	if source == "" {
+		source = "/amq/queue/" + queue
+	}
+
+	filter, err := rabbitmqUtils.ParseMessageFilter(FlagFilterHeaders, FlagFilterRoutingKey, FlagFilterJSON)
 	if err != nil {
-		logger.Fatalf("failed to register consumer: %s", err.Error())
+		logger.Fatalf("%v", err)
+	}
+	writer, err := newMessageWriter(source, FlagExport)
+	if err != nil {
+		logger.Fatalf("%v", err)
 	}
 
+	client, err := FlagProtocol.Dial(connection)
+	if err != nil {
+		logger.Fatalf("connection to RabbitMQ %s plugin failed: %v", FlagProtocol.Protocol, err)
+	}
+	defer client.Close()
+	if len(FlagFilterHeaders) > 0 && !client.SupportsHeaders() {
+		logger.Warn("MQTT 3.1.1 has no message headers, header filters never match")
+	}
+
+	msgs, err := client.Subscribe(source)
+	if err != nil {
+		logger.Fatalf("failed to subscribe to %s: %v", source, err)
+	}
+	logger.Infof("subscribed to %s over %s", source, FlagProtocol.Protocol)
+
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
-	go func() {
-		<-sigs
-		logger.Info("shutting down...")
-		con.Close()
-		ch.Close()
-		os.Exit(0)
+	var matched, scanned int
+	err = func() error {
+		for {
+			select {
+			case <-sigs:
+				logger.Info("shutting down...")
+				return nil
+			case msg, ok := <-msgs:
+				if !ok {
+					return fmt.Errorf("subscription was closed by the broker")
+				}
+				scanned++
+				delivery := msg.Delivery()
+				if !filter.Matches(delivery) {
+					continue
+				}
+				matched++
+				if err := writer.write(delivery); err != nil {
+					return err
+				}
+				if FlagCount > 0 && matched >= FlagCount {
+					return nil
+				}
+			}
+		}
+	}()
+	if closeErr := writer.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	printSummary(matched, scanned)
+}
+
+// scanQueue fetches every message once and acknowledges the matching ones when consume is
+// set, all other messages are requeued at the end. Unacknowledged messages are not redelivered
+// on the same channel, so the scan stops once the queue has been read through.
+func scanQueue(ch *amqp091.Channel, queue string, filter rabbitmqUtils.MessageFilter, writer *messageWriter, consume bool) (int, int, error) {
+	var matched, scanned int
+	var lastTag uint64
+	defer func() {
+		if lastTag > 0 {
+			if err := ch.Nack(lastTag, true, true); err != nil {
+				logger.Errorf("failed to requeue messages: %v", err)
+			}
+		}
 	}()
 
-	for msg := range msgs {
-		logger.Infof("message from the queue: %s headers: %s routingKey: %s", string(msg.Body), msg.Headers, msg.RoutingKey)
+	for FlagCount == 0 || matched < FlagCount {
+		msg, ok, err := ch.Get(queue, false)
+		if err != nil {
+			return matched, scanned, fmt.Errorf("failed to get message: %w", err)
+		}
+		if !ok {
+			break
+		}
+		scanned++
+		if !filter.Matches(msg) {
+			lastTag = msg.DeliveryTag
+			continue
+		}
+		matched++
+		if err := writer.write(msg); err != nil {
+			lastTag = msg.DeliveryTag
+			return matched, scanned, err
+		}
+		if !consume {
+			lastTag = msg.DeliveryTag
+			continue
+		}
+		if err := msg.Ack(false); err != nil {
+			return matched, scanned, fmt.Errorf("failed to acknowledge message: %w", err)
+		}
+	}
+	return matched, scanned, nil
+}
+
+// consumeQueue consumes messages until interrupted or the count is reached. Messages are
+// acknowledged one by one, so prefetched messages beyond the count go back to the queue.
+func consumeQueue(ch *amqp091.Channel, queue string, filter rabbitmqUtils.MessageFilter, writer *messageWriter) (int, int, error) {
+	if err := ch.Qos(100, 0, false); err != nil {
+		return 0, 0, fmt.Errorf("failed to set prefetch: %w", err)
+	}
+	msgs, err := ch.Consume(queue, "", false, false, false, false, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to register consumer: %w", err)
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	var matched, scanned int
+	for {
+		select {
+		case <-sigs:
+			logger.Info("shutting down...")
+			return matched, scanned, nil
+		case msg, ok := <-msgs:
+			if !ok {
+				return matched, scanned, fmt.Errorf("consumer was cancelled or the connection was closed")
+			}
+			scanned++
+			if filter.Matches(msg) {
+				matched++
+				if err := writer.write(msg); err != nil {
+					return matched, scanned, err
+				}
+			}
+			if err := msg.Ack(false); err != nil {
+				return matched, scanned, fmt.Errorf("failed to acknowledge message: %w", err)
+			}
+			if FlagCount > 0 && matched >= FlagCount {
+				return matched, scanned, nil
+			}
+		}
 	}
 }
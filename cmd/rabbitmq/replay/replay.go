@@ -0,0 +1,161 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/rabbitmq"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	rabbitmqUtils "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/spf13/cobra"
+)
+
+var (
+	FlagConnection    *parent_cmd.ConnectionFlags
+	FlagExchange      string
+	FlagRoutingKey    string
+	FlagRate          float64
+	FlagSetHeaders    []string
+	FlagRemoveHeaders []string
+	FlagKeepUserId    bool
+)
+
+var Cmd = &cobra.Command{
+	Use:   "replay <file.jsonl>",
+	Short: "Republish messages exported by read-queue",
+	Long:  "Republish messages from a JSONL file exported by 'vpd rabbitmq read-queue --export'. Messages keep their properties and headers, except the user id unless --keep-user-id is set, and are sent to their original exchange and routing key unless overridden. Every message is confirmed by the broker before the next one is sent.",
+	Example: `  vpd rabbitmq replay orders-dlq.jsonl --profile prod
+  vpd rabbitmq replay orders-dlq.jsonl --profile prod --exchange orders --rate 50 \
+    --remove-header x-death --set-header x-replayed=true
+  cat orders-dlq.jsonl | vpd rabbitmq replay - --profile stage --routing-key order.retry`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		replay(FlagConnection, args[0], cmd.Flags().Changed("exchange"))
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	FlagConnection = parent_cmd.AddConnectionFlags(Cmd)
+	Cmd.Flags().StringVarP(&FlagExchange, "exchange", "e", "", "Publish to this exchange instead of the original one (\"\" is the default exchange)")
+	Cmd.Flags().StringVarP(&FlagRoutingKey, "routing-key", "r", "", "Publish with this routing key instead of the original one")
+	Cmd.Flags().Float64Var(&FlagRate, "rate", 0, "Maximum publish rate in msgs/sec (0 = unlimited)")
+	Cmd.Flags().StringArrayVar(&FlagSetHeaders, "set-header", nil, "Set a header as name=value (repeatable)")
+	Cmd.Flags().StringArrayVar(&FlagRemoveHeaders, "remove-header", nil, "Remove a header (repeatable)")
+	Cmd.Flags().BoolVar(&FlagKeepUserId, "keep-user-id", false, "Keep the user id property, RabbitMQ rejects messages whose user id is not the connection user (dropped by default)")
+}
+
+func replay(connection *parent_cmd.ConnectionFlags, path string, overrideExchange bool) {
+	setHeaders := make(map[string]string, len(FlagSetHeaders))
+	for _, header := range FlagSetHeaders {
+		name, value, found := strings.Cut(header, "=")
+		if !found || name == "" {
+			logger.Fatalf("invalid header '%s', use name=value", header)
+		}
+		setHeaders[name] = value
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			logger.Fatalf("error opening file: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	con, ch, err := connection.Connect()
+	if err != nil {
+		logger.Fatalf("connection to RabbitMQ failed: %v", err)
+	}
+	defer con.Close()
+	defer ch.Close()
+
+	if err := ch.Confirm(false); err != nil {
+		logger.Fatalf("failed to enable publisher confirms: %v", err)
+	}
+	var returned atomic.Int64
+	returns := ch.NotifyReturn(make(chan amqp091.Return, 1))
+	go func() {
+		for r := range returns {
+			returned.Add(1)
+			logger.Warnf("message %s returned as unroutable: %s (exchange '%s', routing key '%s')", r.MessageId, r.ReplyText, r.Exchange, r.RoutingKey)
+		}
+	}()
+
+	var interval time.Duration
+	if FlagRate > 0 {
+		interval = time.Duration(float64(time.Second) / FlagRate)
+	}
+
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 512*1024*1024)
+	line, published, nacked := 0, 0, 0
+	next := time.Now()
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var msg rabbitmqUtils.Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			logger.Fatalf("invalid message on line %d: %v", line, err)
+		}
+
+		publishing, err := msg.Publishing(FlagKeepUserId)
+		if err != nil {
+			logger.Fatalf("invalid message on line %d: %v", line, err)
+		}
+		if len(setHeaders) > 0 && publishing.Headers == nil {
+			publishing.Headers = amqp091.Table{}
+		}
+		for _, name := range FlagRemoveHeaders {
+			delete(publishing.Headers, name)
+		}
+		for name, value := range setHeaders {
+			publishing.Headers[name] = value
+		}
+
+		exchange, routingKey := msg.Exchange, msg.RoutingKey
+		if overrideExchange {
+			exchange = FlagExchange
+		}
+		if FlagRoutingKey != "" {
+			routingKey = FlagRoutingKey
+		}
+
+		if interval > 0 {
+			time.Sleep(time.Until(next))
+			next = next.Add(interval)
+		}
+
+		confirmation, err := ch.PublishWithDeferredConfirmWithContext(context.Background(), exchange, routingKey, true, false, publishing)
+		if err != nil {
+			logger.Fatalf("failed to publish message from line %d: %v (%d published so far)", line, err, published)
+		}
+		if !confirmation.Wait() {
+			nacked++
+			logger.Errorf("message from line %d was rejected by the broker", line)
+			continue
+		}
+		published++
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Fatalf("error reading file: %v", err)
+	}
+
+	// Returns are delivered before the confirm, give the listener a moment to count them
+	time.Sleep(100 * time.Millisecond)
+	logger.Successf("replayed %d messages, %d rejected by the broker, %d returned as unroutable", published, nacked, returned.Load())
+	if nacked > 0 || returned.Load() > 0 {
+		os.Exit(1)
+	}
+}
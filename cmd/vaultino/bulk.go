@@ -0,0 +1,73 @@
+package vaultino
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	vaultinoUtils "github.com/VojtechPastyrik/vpd/utils/vaultino"
+	"github.com/spf13/cobra"
+)
+
+// BulkFlags select the vaults of bulk commands and the report format
+type BulkFlags struct {
+	Dirs    []string
+	Workers int
+	Output  string
+}
+
+// AddBulkFlags registers the flags of commands working on many vaults
+func AddBulkFlags(cmd *cobra.Command, flags *BulkFlags) {
+	cmd.Flags().StringArrayVarP(&flags.Dirs, "dir", "d", nil, "Directory searched recursively for *.vault files (repeatable, default . when no files are given)")
+	cmd.Flags().IntVarP(&flags.Workers, "workers", "w", vaultinoUtils.DefaultWorkers(), "Number of vaults processed concurrently")
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "text", "Output format: text or json")
+}
+
+// Options returns the bulk options for the files given as arguments, the output format is
+// checked before any vault is touched
+func (f BulkFlags) Options(files []string) (vaultinoUtils.BulkOptions, error) {
+	if f.Output != "text" && f.Output != "json" {
+		return vaultinoUtils.BulkOptions{}, fmt.Errorf("unsupported output format '%s', use text or json", f.Output)
+	}
+	dirs := f.Dirs
+	if len(dirs) == 0 && len(files) == 0 {
+		dirs = []string{"."}
+	}
+	return vaultinoUtils.BulkOptions{Files: files, Dirs: dirs, Workers: f.Workers}, nil
+}
+
+// PrintBulkReport prints the results and the summary, it exits with 1 when a vault failed
+func PrintBulkReport(report *vaultinoUtils.BulkReport, output string, printResult func(result vaultinoUtils.BulkResult)) {
+	switch output {
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			logger.Fatalf("failed to serialize report: %v", err)
+		}
+		fmt.Println(string(data))
+	case "text":
+		for _, result := range report.Results {
+			printResult(result)
+		}
+		fmt.Printf("\n%s: %d ok, %d failed, %d skipped of %d vaults in %.2fs\n",
+			report.Operation, report.OK, report.Failed, report.Skipped, len(report.Results), report.DurationSeconds)
+	default:
+		logger.Fatalf("unsupported output format '%s', use text or json", output)
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// PrintBulkResult prints the status line of the result
+func PrintBulkResult(result vaultinoUtils.BulkResult) {
+	switch result.Status {
+	case vaultinoUtils.StatusFailed:
+		fmt.Printf("FAIL  %s: %s\n", result.File, result.Error)
+	case vaultinoUtils.StatusSkipped:
+		fmt.Printf("SKIP  %s: %s\n", result.File, result.Detail)
+	default:
+		fmt.Printf("OK    %s: %s\n", result.File, result.Detail)
+	}
+}
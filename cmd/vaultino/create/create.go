@@ -8,20 +8,47 @@ import (
 )
 
 var (
-	FlagFile string
+	FlagFile           string
+	FlagRecipients     []string
+	FlagRecipientFiles []string
+	FlagFormat         parent_cmd.FormatFlags
+	FlagBinary         bool
 )
 
 var Cmd = &cobra.Command{
 	Use:     "create",
 	Aliases: []string{"crt"},
 	Short:   "Create new Vaultino encrypted file",
-	Long:    "Create new Vaultino encrypted file. It will prompt for a password and create an encrypted file.",
-	Example: "vpd vaultino create <name> --file <path/to/encrypted_file>",
+	Long:    "Create new Vaultino encrypted file. It will prompt for a password twice and create an encrypted file, the password can also come from a file, a command, the keyring or VAULTINO_PASSWORD.\nWith --recipient or --recipients-file the file is encrypted with a random data key instead, wrapped for every recipient's age X25519 (age1...) or SSH ed25519 public key. Recipients decrypt with their identity file, no password is shared.",
+	Example: `vpd vaultino create <name> --file <path/to/encrypted_file>
+vpd vaultino create prod --file prod.yaml -r age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p -r "$(cat ~/.ssh/id_ed25519.pub)"
+vpd vaultino create prod --file prod.yaml --recipients-file team.txt
+VAULTINO_PASSWORD=secret vpd vaultino create prod --file prod.yaml
+vpd vaultino create prod --file prod.yaml --cipher xchacha20-poly1305 --kdf-memory 256
+vpd vaultino create prod --file prod.yaml --password-command "op read op://dev/vaultino/password"
+vpd vaultino create keystore --file keystore.p12 --binary`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if args == nil || len(args) < 1 {
 			logger.Fatalf("name of the encrypted file is required as the first argument")
 		}
-		err := vaultinoUtils.CreateVault(args[0], FlagFile)
+		format, err := FlagFormat.Format()
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		recipients, err := parent_cmd.ParseRecipients(FlagRecipients, FlagRecipientFiles)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		passwords, err := parent_cmd.Passwords("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		err = vaultinoUtils.CreateVault(args[0], FlagFile, vaultinoUtils.CreateOptions{
+			Recipients: recipients,
+			Passwords:  passwords,
+			Format:     format,
+			Binary:     FlagBinary,
+		})
 		if err != nil {
 			logger.Fatalf("failed to create vault: %v", err)
 		}
@@ -32,4 +59,8 @@ func init() {
 	parent_cmd.Cmd.AddCommand(Cmd)
 	Cmd.Flags().StringVarP(&FlagFile, "file", "f", "", "Path to source file to encrypt")
 	Cmd.MarkFlagRequired("file")
+	Cmd.Flags().StringArrayVarP(&FlagRecipients, "recipient", "r", nil, "Encrypt for the age X25519 or SSH ed25519 public key instead of a password (repeatable)")
+	Cmd.Flags().StringArrayVarP(&FlagRecipientFiles, "recipients-file", "R", nil, "File with one recipient per line (repeatable)")
+	Cmd.Flags().BoolVar(&FlagBinary, "binary", false, "Store the file as opaque bytes with its original name, files that are not UTF-8 text always are")
+	parent_cmd.AddFormatFlags(Cmd, &FlagFormat, false)
 }
@@ -7,17 +7,25 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var FlagOutput string
+
 var Cmd = &cobra.Command{
 	Use:     "decrypt",
 	Aliases: []string{"crt"},
 	Short:   "Decrypt Vaultino encrypted file",
-	Long:    "Decrypt Vaultino encrypted file. It will prompt for a password and create a decrypted file.",
-	Example: "vpd vaultino decrypt <path_tp_file>",
+	Long:    "Decrypt Vaultino encrypted file. It will prompt for a password and write the exact original bytes to a file named like the original file, to the path given with --output or to stdout with --output -.",
+	Example: `vpd vaultino decrypt <path_tp_file>
+vpd vaultino decrypt keystore.vault -o /etc/app/
+vpd vaultino decrypt prod.vault -o - | kubectl create secret generic app --from-file=config=/dev/stdin`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if args == nil || len(args) < 1 {
 			logger.Fatalf("path to the encrypted file is required as the first argument")
 		}
-		err := vaultinoUtils.DecryptVaultToFile(args[0])
+		keys, err := parent_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		_, err = vaultinoUtils.DecryptVaultToFile(args[0], keys, vaultinoUtils.DecryptOptions{Output: FlagOutput})
 		if err != nil {
 			logger.Fatalf("failed to decrypt vault: %v", err)
 		}
@@ -26,4 +34,5 @@ var Cmd = &cobra.Command{
 
 func init() {
 	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVarP(&FlagOutput, "output", "o", "", "File or existing directory to write to, - for stdout (default the original file name)")
 }
@@ -0,0 +1,60 @@
+package diff
+
+import (
+	"fmt"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	vaultinoUtils "github.com/VojtechPastyrik/vpd/utils/vaultino"
+	"github.com/spf13/cobra"
+)
+
+const maskedValue = "********"
+
+var FlagShowValues bool
+
+var Cmd = &cobra.Command{
+	Use:   "diff <old_vault> <new_vault>",
+	Short: "Show the changed keys between two Vaultino encrypted files",
+	Long:  "Decrypt two Vaultino encrypted files in memory and show the added (+), removed (-) and changed (~) keys. Values are masked unless --show-values is given. Both vaults are opened with the same password or identities.",
+	Example: `vpd vaultino diff staging.vault prod.vault
+vpd vaultino diff old/app.vault app.vault --show-values`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		keys, err := parent_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		changes, err := vaultinoUtils.DiffVaults(args[0], args[1], keys)
+		if err != nil {
+			logger.Fatalf("failed to diff vaults: %v", err)
+		}
+		if len(changes) == 0 {
+			logger.Info("no differences")
+			return
+		}
+		for _, change := range changes {
+			fmt.Println(formatChange(change))
+		}
+	},
+}
+
+func formatChange(change vaultinoUtils.KeyChange) string {
+	oldValue, newValue := maskedValue, maskedValue
+	if FlagShowValues {
+		oldValue, newValue = change.Old, change.New
+	}
+	switch change.Kind {
+	case vaultinoUtils.KeyAdded:
+		return fmt.Sprintf("+ %s = %s", change.Key, newValue)
+	case vaultinoUtils.KeyRemoved:
+		return fmt.Sprintf("- %s = %s", change.Key, oldValue)
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", change.Key, oldValue, newValue)
+	}
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().BoolVar(&FlagShowValues, "show-values", false, "Show the values instead of masking them")
+}
@@ -8,25 +8,30 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var FlagChangePassword bool
+var (
+	FlagChangePassword bool
+	FlagBackup         bool
+)
 
 var Cmd = &cobra.Command{
 	Use:     "edit",
 	Aliases: []string{"e"},
 	Short:   "Edit Vaultino encrypted file",
-	Long:    "Edit Vaultino encrypted file. It will prompt for a password, decrypt the file, open it in the default editor, and re-encrypt it upon saving.",
-	Example: "vpd vaultino edit <path_tp_file>\nvpd vaultino edit --change-password <path_tp_file>",
+	Long:    "Edit Vaultino encrypted file. It will prompt for a password, decrypt the file into a private temporary directory (tmpfs when available), open it in the default editor, and re-encrypt it upon saving.\nUnchanged content is not rewritten, invalid YAML or JSON can be edited again, the changed keys are listed without their values and the vault is replaced atomically. The temporary file is overwritten before it is deleted.",
+	Example: "vpd vaultino edit <path_tp_file>\nvpd vaultino edit --change-password <path_tp_file>\nvpd vaultino edit --backup <path_tp_file>\nVAULTINO_NEW_PASSWORD=new vpd vaultino edit -p --password-file old.txt <path_tp_file>",
 	Run: func(cmd *cobra.Command, args []string) {
 		if args == nil || len(args) < 1 {
 			logger.Fatalf("path to the encrypted file is required as the first argument")
 		}
 
-		var err error
-		if FlagChangePassword {
-			err = vaultinoUtils.EditVaultWithPasswordChange(args[0])
-		} else {
-			err = vaultinoUtils.EditVault(args[0])
+		keys, err := parent_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
 		}
+		err = vaultinoUtils.EditVault(args[0], keys, vaultinoUtils.EditOptions{
+			ChangePassword: FlagChangePassword,
+			Backup:         FlagBackup,
+		})
 
 		if err != nil {
 			logger.Fatalf("failed to edit vault: %v", err)
@@ -37,4 +42,5 @@ var Cmd = &cobra.Command{
 func init() {
 	parent_cmd.Cmd.AddCommand(Cmd)
 	Cmd.Flags().BoolVarP(&FlagChangePassword, "change-password", "p", false, "Change the vault password after editing")
+	Cmd.Flags().BoolVar(&FlagBackup, "backup", false, "Keep the previous vault as <vault>.bak")
 }
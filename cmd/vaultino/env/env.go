@@ -0,0 +1,37 @@
+package env
+
+import (
+	"fmt"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	vaultinoUtils "github.com/VojtechPastyrik/vpd/utils/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var FlagEnv vaultinoUtils.EnvOptions
+
+var Cmd = &cobra.Command{
+	Use:   "env <vault>",
+	Short: "Print the secrets of the vault as export lines",
+	Long:  "Print the secrets of the vault as export lines for eval in a shell. The vault is decrypted in memory only, nothing is written to the filesystem. Variable names are built the same way as with vaultino exec.",
+	Example: `eval "$(vpd vaultino env app.vault)"
+vpd vaultino env app.vault --prefix APP_ --separator __`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		keys, err := parent_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		secrets, err := vaultinoUtils.VaultEnv(args[0], keys, FlagEnv)
+		if err != nil {
+			logger.Fatalf("failed to read secrets: %v", err)
+		}
+		fmt.Print(vaultinoUtils.FormatExports(secrets))
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	parent_cmd.AddEnvFlags(Cmd, &FlagEnv)
+}
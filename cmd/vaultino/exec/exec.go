@@ -0,0 +1,45 @@
+package exec
+
+import (
+	"os"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	vaultinoUtils "github.com/VojtechPastyrik/vpd/utils/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var FlagEnv vaultinoUtils.EnvOptions
+
+var Cmd = &cobra.Command{
+	Use:   "exec <vault> -- <command> [args...]",
+	Short: "Run a command with the secrets of the vault as environment variables",
+	Long:  "Run a command with the secrets of the vault as environment variables. The vault is decrypted in memory only, nothing is written to the filesystem. Nested YAML and JSON keys are joined with the separator and upper cased, e.g. db.password becomes DB_PASSWORD. The exit code of the command is returned.",
+	Example: `vpd vaultino exec app.vault -- ./myapp
+vpd vaultino exec app.vault --prefix APP_ -- env
+VAULTINO_PASSWORD=secret vpd vaultino exec app.vault --separator __ -- docker compose up`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if dash := cmd.ArgsLenAtDash(); dash != -1 && dash != 1 {
+			logger.Fatalf("exactly one vault is expected before --")
+		}
+		keys, err := parent_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		secrets, err := vaultinoUtils.VaultEnv(args[0], keys, FlagEnv)
+		if err != nil {
+			logger.Fatalf("failed to read secrets: %v", err)
+		}
+		code, err := vaultinoUtils.ExecWithSecrets(args[1:], secrets)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		os.Exit(code)
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	parent_cmd.AddEnvFlags(Cmd, &FlagEnv)
+}
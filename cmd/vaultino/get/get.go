@@ -12,19 +12,24 @@ import (
 var (
 	Flagkey      string
 	FlagPassword string
+	FlagType     string
 )
 
 var Cmd = &cobra.Command{
 	Use:     "get",
 	Aliases: []string{"g"},
 	Short:   "Get value from Vaultino encrypted file",
-	Long:    "Get value from Vaultino encrypted file for the provided key. It will prompt for a password and retrieve the value. Supported file formats are YAML and JSON.",
+	Long:    "Get value from Vaultino encrypted file for the provided key. It will prompt for a password and retrieve the value. Supported file formats are YAML, JSON, env, TOML, INI and Java properties, nested keys are joined with dots.",
 	Example: "vpd vaultino get <path_to_file> -k <key>",
 	Run: func(cmd *cobra.Command, args []string) {
 		if args == nil || len(args) < 1 {
 			logger.Fatalf("path to the encrypted file is required as the first argument")
 		}
-		value, err := vaultinoUtils.GetSecretFromVault(args[0], Flagkey, FlagPassword)
+		keys, err := parent_cmd.Keys(FlagPassword)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		value, err := vaultinoUtils.GetSecretFromVaultAs(args[0], Flagkey, FlagType, keys)
 		if err != nil {
 			logger.Fatalf("failed to get secret: %v", err)
 		}
@@ -36,5 +41,6 @@ func init() {
 	parent_cmd.Cmd.AddCommand(Cmd)
 	Cmd.Flags().StringVarP(&Flagkey, "key", "k", "", "Key to get value for")
 	Cmd.MarkFlagRequired("key")
+	Cmd.Flags().StringVarP(&FlagType, "type", "t", "", "Parse the content as yaml, json, env, toml, ini or properties (default the type of the original file)")
 	Cmd.Flags().StringVarP(&FlagPassword, "password", "p", "", "Password to decrypt vault (if not provided, will prompt interactively)")
 }
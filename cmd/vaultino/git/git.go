@@ -0,0 +1,16 @@
+package git
+
+import (
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "git",
+	Short: "Git integration of Vaultino encrypted files",
+	Long:  "Git integration of Vaultino encrypted files, so git diff shows key level changes of vaults instead of base64 blobs.",
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+}
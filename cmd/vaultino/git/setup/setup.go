@@ -0,0 +1,47 @@
+package setup
+
+import (
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino/git"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	vaultinoUtils "github.com/VojtechPastyrik/vpd/utils/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var (
+	FlagPattern    string
+	FlagCommand    string
+	FlagMaskValues bool
+)
+
+var Cmd = &cobra.Command{
+	Use:   "setup [repository_dir]",
+	Short: "Configure git diff for Vaultino encrypted files",
+	Long:  "Configure the git repository so git diff renders decrypted key level changes of Vaultino encrypted files locally. The pattern is added to .gitattributes with the vaultino diff driver, which is committed and shared, and the textconv command is set in the local git config, which every clone has to set up. The textconv cache is disabled, so no plaintext ends up in git notes.",
+	Example: `vpd vaultino git setup
+vpd vaultino git setup --mask-values
+vpd vaultino git setup --pattern "secrets/**/*.vault" --command "vpd vaultino textconv"`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		root, err := vaultinoUtils.SetupGit(dir, vaultinoUtils.GitSetupOptions{
+			Pattern: FlagPattern,
+			Command: FlagCommand,
+			Mask:    FlagMaskValues,
+		})
+		if err != nil {
+			logger.Fatalf("failed to set up git: %v", err)
+		}
+		logger.Successf("vaultino diff driver configured in %s", root)
+		logger.Info("commit .gitattributes, other clones only need to run vpd vaultino git setup")
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVar(&FlagPattern, "pattern", "*.vault", "Pattern of the vault files in .gitattributes")
+	Cmd.Flags().StringVar(&FlagCommand, "command", "", "Textconv command (default: this vpd executable with vaultino textconv)")
+	Cmd.Flags().BoolVar(&FlagMaskValues, "mask-values", false, "Show value fingerprints instead of values in git diff")
+}
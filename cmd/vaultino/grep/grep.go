@@ -0,0 +1,68 @@
+package grep
+
+import (
+	"fmt"
+	"regexp"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	vaultinoUtils "github.com/VojtechPastyrik/vpd/utils/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var (
+	FlagBulk       parent_cmd.BulkFlags
+	FlagIgnoreCase bool
+)
+
+var Cmd = &cobra.Command{
+	Use:   "grep <key_pattern> [vault]...",
+	Short: "Search keys in many Vaultino encrypted files",
+	Long:  "Search the key paths of all Vaultino encrypted files given as arguments or found recursively in the directories with a regular expression. Nested keys are joined with dots, values are never printed.",
+	Example: `vpd vaultino grep password
+vpd vaultino grep --ignore-case '^db\.' --dir ./secrets`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		options, err := FlagBulk.Options(args[1:])
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		expression := args[0]
+		if FlagIgnoreCase {
+			expression = "(?i)" + expression
+		}
+		pattern, err := regexp.Compile(expression)
+		if err != nil {
+			logger.Fatalf("invalid key pattern: %v", err)
+		}
+		keys, err := parent_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		report, err := vaultinoUtils.GrepVaults(options, keys, pattern)
+		if err != nil {
+			logger.Fatalf("failed to search vaults: %v", err)
+		}
+
+		matches := 0
+		parent_cmd.PrintBulkReport(report, FlagBulk.Output, func(result vaultinoUtils.BulkResult) {
+			if result.Status == vaultinoUtils.StatusFailed {
+				parent_cmd.PrintBulkResult(result)
+				return
+			}
+			for _, match := range result.Matches {
+				fmt.Printf("%s: %s\n", result.File, match)
+				matches++
+			}
+		})
+		if FlagBulk.Output == "text" && matches == 0 {
+			logger.Info("no matching keys")
+		}
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	parent_cmd.AddBulkFlags(Cmd, &FlagBulk)
+	Cmd.Flags().BoolVar(&FlagIgnoreCase, "ignore-case", false, "Match the pattern case insensitively")
+}
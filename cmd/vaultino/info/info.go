@@ -0,0 +1,77 @@
+package info
+
+import (
+	"encoding/json"
+	"fmt"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var FlagOutput string
+
+var Cmd = &cobra.Command{
+	Use:   "info <vault>...",
+	Short: "Print the metadata of Vaultino encrypted files",
+	Long:  "Print the metadata of Vaultino encrypted files: format version, cipher, key mode, KDF parameters, original name and type, author, recipients and payload size. No password or identity is needed.",
+	Example: `vpd vaultino info prod.vault
+vpd vaultino info *.vault -o json`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var infos []*vaultino.Info
+		for _, vaultFile := range args {
+			info, err := vaultino.ReadInfo(vaultFile)
+			if err != nil {
+				logger.Fatalf("failed to read %s: %v", vaultFile, err)
+			}
+			infos = append(infos, info)
+		}
+
+		switch FlagOutput {
+		case "json":
+			data, err := json.MarshalIndent(infos, "", "  ")
+			if err != nil {
+				logger.Fatalf("failed to serialize info: %v", err)
+			}
+			fmt.Println(string(data))
+		case "text":
+			for i, info := range infos {
+				if i > 0 {
+					fmt.Println()
+				}
+				printInfo(info)
+			}
+		default:
+			logger.Fatalf("unsupported output format '%s', use text or json", FlagOutput)
+		}
+	},
+}
+
+func printInfo(info *vaultino.Info) {
+	fmt.Printf("File:             %s\n", info.File)
+	fmt.Printf("Version:          %s\n", info.Version)
+	fmt.Printf("Cipher:           %s\n", info.Cipher)
+	fmt.Printf("Header bound:     %t\n", info.AuthenticatedHeader)
+	if info.KDF != nil {
+		fmt.Printf("Key:              password, %s\n", info.KDF)
+	} else {
+		fmt.Printf("Key:              %d recipient(s)\n", len(info.Recipients))
+		for _, recipient := range info.Recipients {
+			fmt.Printf("  - %s\n", recipient)
+		}
+	}
+	fmt.Printf("Original file:    %s (%s)\n", info.OriginalFile, info.Type)
+	user := info.User
+	if user == "" {
+		user = "unknown user"
+	}
+	fmt.Printf("Written by:       %s at %s\n", user, info.Time)
+	fmt.Printf("Payload:          %d bytes\n", info.PayloadBytes)
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVarP(&FlagOutput, "output", "o", "text", "Output format: text or json")
+}
@@ -0,0 +1,49 @@
+package keygen
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var FlagOutput string
+
+var Cmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate an age X25519 identity for Vaultino recipients",
+	Long:  "Generate an X25519 identity in the age key file format. The identity file unlocks vaults encrypted for its recipient, which is printed to share with vault owners. Keys generated by age-keygen work the same way.",
+	Example: `vpd vaultino keygen -o ~/.config/vpd/vaultino-identity.txt
+vpd vaultino keygen > key.txt`,
+	Run: func(cmd *cobra.Command, args []string) {
+		identity, recipient, err := vaultino.GenerateIdentity()
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n", time.Now().Format(time.RFC3339), recipient, identity)
+
+		if FlagOutput == "" {
+			fmt.Print(content)
+			return
+		}
+		file, err := os.OpenFile(FlagOutput, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			logger.Fatalf("error creating identity file: %v", err)
+		}
+		defer file.Close()
+		if _, err := file.WriteString(content); err != nil {
+			logger.Fatalf("error writing identity file: %v", err)
+		}
+		logger.Successf("identity saved to %s", FlagOutput)
+		fmt.Printf("Public key: %s\n", recipient)
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVarP(&FlagOutput, "output", "o", "", "Write the identity to the file instead of stdout, an existing file is not overwritten")
+}
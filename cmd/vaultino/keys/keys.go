@@ -0,0 +1,39 @@
+package keys
+
+import (
+	"fmt"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:     "keys <vault>",
+	Short:   "List the keys of Vaultino encrypted file",
+	Long:    "List the paths of all values in Vaultino encrypted file, nested keys joined with dots as accepted by get, set and unset. Values are not printed.",
+	Example: "vpd vaultino keys prod.vault",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		keys, err := parent_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		vault, err := vaultino.Open(args[0], keys.Provider())
+		if err != nil {
+			logger.Fatalf("failed to open vault: %v", err)
+		}
+		paths, err := vault.Keys()
+		if err != nil {
+			logger.Fatalf("failed to list keys: %v", err)
+		}
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+}
@@ -0,0 +1,50 @@
+package migrate
+
+import (
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var FlagFormat parent_cmd.FormatFlags
+
+var Cmd = &cobra.Command{
+	Use:   "migrate <vault>...",
+	Short: "Migrate Vaultino encrypted files to the current format",
+	Long:  "Migrate Vaultino encrypted files to format 2.0, which authenticates the header and records the KDF parameters. The cipher and KDF parameters are kept unless changed with the flags. Vaults already in the current format with the requested parameters are left untouched.",
+	Example: `vpd vaultino migrate prod.vault
+vpd vaultino migrate prod.vault --cipher xchacha20-poly1305 --kdf-memory 256`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format, err := FlagFormat.Format()
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		keys, err := parent_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		failed := false
+		for _, vaultFile := range args {
+			migrated, err := vaultino.Migrate(vaultFile, keys.Provider(), format)
+			switch {
+			case err != nil:
+				logger.Errorf("failed to migrate %s: %v", vaultFile, err)
+				failed = true
+			case migrated:
+				logger.Successf("migrated %s", vaultFile)
+			default:
+				logger.Infof("%s is up to date", vaultFile)
+			}
+		}
+		if failed {
+			logger.Fatalf("some vaults were not migrated")
+		}
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	parent_cmd.AddFormatFlags(Cmd, &FlagFormat, true)
+}
@@ -0,0 +1,51 @@
+package add
+
+import (
+	vaultino_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino/recipients"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var (
+	FlagRecipients     []string
+	FlagRecipientFiles []string
+)
+
+var Cmd = &cobra.Command{
+	Use:   "add <vault_file>",
+	Short: "Add recipients to a Vaultino encrypted file",
+	Long:  "Add recipients to a vault. The data key is unwrapped with your identity and wrapped for the new recipients, the encrypted payload stays unchanged.",
+	Example: `vpd vaultino recipients add prod.vault -r age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+vpd vaultino recipients add prod.vault -r "$(cat alice_ed25519.pub)" -i ~/.ssh/id_ed25519`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		recipients, err := vaultino_cmd.ParseRecipients(FlagRecipients, FlagRecipientFiles)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		if len(recipients) == 0 {
+			logger.Fatalf("at least one --recipient or --recipients-file is required")
+		}
+		keys, err := vaultino_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		added, err := vaultino.AddRecipients(args[0], recipients, keys.Provider())
+		if err != nil {
+			logger.Fatalf("failed to add recipients: %v", err)
+		}
+		if added == 0 {
+			logger.Info("all recipients already have access")
+			return
+		}
+		logger.Successf("added %d recipient(s) to %s", added, args[0])
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringArrayVarP(&FlagRecipients, "recipient", "r", nil, "age X25519 or SSH ed25519 public key to add (repeatable)")
+	Cmd.Flags().StringArrayVarP(&FlagRecipientFiles, "recipients-file", "R", nil, "File with one recipient per line (repeatable)")
+}
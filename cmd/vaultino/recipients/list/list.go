@@ -0,0 +1,30 @@
+package list
+
+import (
+	"fmt"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino/recipients"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:     "list <vault_file>",
+	Aliases: []string{"ls"},
+	Short:   "List recipients of a Vaultino encrypted file",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		recipients, err := vaultino.ListRecipients(args[0])
+		if err != nil {
+			logger.Fatalf("failed to list recipients: %v", err)
+		}
+		for _, recipient := range recipients {
+			fmt.Println(recipient)
+		}
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+}
@@ -0,0 +1,17 @@
+package recipients
+
+import (
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:     "recipients",
+	Aliases: []string{"rcp"},
+	Short:   "Manage recipients of a Vaultino encrypted file",
+	Long:    "Manage the recipients of a vault created with --recipient. Adding and removing recipients rewraps the data key in the vault header, the encrypted payload is not touched.",
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+}
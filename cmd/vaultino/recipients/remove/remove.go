@@ -0,0 +1,39 @@
+package remove
+
+import (
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino/recipients"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var FlagRecipients []string
+
+var Cmd = &cobra.Command{
+	Use:     "remove <vault_file>",
+	Aliases: []string{"rm"},
+	Short:   "Remove recipients from a Vaultino encrypted file",
+	Long:    "Remove recipients from a vault by their public key or SSH fingerprint. Only the wrapped data key is dropped: a removed recipient who kept the data key could still decrypt the current content, the next vaultino edit encrypts it with a new data key.",
+	Example: `vpd vaultino recipients remove prod.vault -r age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+vpd vaultino recipients remove prod.vault -r SHA256:Wf3oVQ6cfgsJmD4Q6k1yB8d3l0sSRCoCPpHKr5wNQfI`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(FlagRecipients) == 0 {
+			logger.Fatalf("at least one --recipient is required")
+		}
+		removed, err := vaultino.RemoveRecipients(args[0], FlagRecipients)
+		if err != nil {
+			logger.Fatalf("failed to remove recipients: %v", err)
+		}
+		if removed == 0 {
+			logger.Warn("no matching recipient found")
+			return
+		}
+		logger.Successf("removed %d recipient(s) from %s", removed, args[0])
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringArrayVarP(&FlagRecipients, "recipient", "r", nil, "Public key or SSH fingerprint (SHA256:...) of the recipient to remove (repeatable)")
+}
@@ -0,0 +1,39 @@
+package rekey
+
+import (
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	vaultinoUtils "github.com/VojtechPastyrik/vpd/utils/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var FlagBulk parent_cmd.BulkFlags
+
+var Cmd = &cobra.Command{
+	Use:   "rekey [vault]...",
+	Short: "Change the password of many Vaultino encrypted files",
+	Long:  "Change the password of all Vaultino encrypted files given as arguments or found recursively in the directories. With --keyring the current password of each vault is read from its keyring entry and the new one is stored for each vault, otherwise the current password is asked once. The new password is asked twice and the vaults are re-encrypted concurrently. Vaults encrypted for recipients are skipped, manage them with vaultino recipients.",
+	Example: `vpd vaultino rekey --dir ./secrets
+VAULTINO_PASSWORD=old VAULTINO_NEW_PASSWORD=new vpd vaultino rekey --dir ./secrets -o json
+vpd vaultino rekey a.vault b.vault --new-password-command "op read op://dev/vaultino/new"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		options, err := FlagBulk.Options(args)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		keys, err := parent_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		report, err := vaultinoUtils.RekeyVaults(options, keys)
+		if err != nil {
+			logger.Fatalf("failed to rekey vaults: %v", err)
+		}
+		parent_cmd.PrintBulkReport(report, FlagBulk.Output, parent_cmd.PrintBulkResult)
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	parent_cmd.AddBulkFlags(Cmd, &FlagBulk)
+}
@@ -0,0 +1,81 @@
+package render
+
+import (
+	"bytes"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	vaultinoUtils "github.com/VojtechPastyrik/vpd/utils/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var (
+	FlagVault      string
+	FlagOutput     string
+	FlagK8sSecret  string
+	FlagNamespace  string
+	FlagSecretType string
+	FlagSecretKey  string
+)
+
+var Cmd = &cobra.Command{
+	Use:   "render [template]",
+	Short: "Render a template or a Kubernetes Secret from a Vaultino encrypted file",
+	Long: `Render a Go template with the secrets of a Vaultino encrypted file. The vault is decrypted in memory and the template can use:
+
+  secret "db.password"   value of the key, nested maps and lists work with toYaml and toJson
+  hasSecret "key"        whether the key exists
+  secrets                all secrets, also available as the template data, e.g. {{ .db.user }}
+  b64enc, b64dec         base64 encoding
+  toYaml, toJson         serialize a value
+  quote                  double quote a value
+  indent n, nindent n    indent lines, nindent starts with a newline
+
+Missing keys fail the rendering. With --k8s-secret a ready-to-apply v1.Secret is printed instead, holding every key of the vault, or the rendered template under a single key when a template is given.`,
+	Example: `vpd vaultino render app.conf.tmpl --vault prod.vault -o app.conf
+vpd vaultino render --vault prod.vault --k8s-secret app-secrets -n prod | kubectl apply -f -
+vpd vaultino render app.conf.tmpl --vault prod.vault --k8s-secret app-config --secret-key app.conf`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		templateFile := ""
+		if len(args) == 1 {
+			templateFile = args[0]
+		}
+		if templateFile == "" && FlagK8sSecret == "" {
+			logger.Fatalf("a template or --k8s-secret is required")
+		}
+		keys, err := parent_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+
+		var out bytes.Buffer
+		if FlagK8sSecret != "" {
+			err = vaultinoUtils.RenderK8sSecret(templateFile, FlagVault, keys, vaultinoUtils.K8sSecretOptions{
+				Name:      FlagK8sSecret,
+				Namespace: FlagNamespace,
+				Type:      FlagSecretType,
+				Key:       FlagSecretKey,
+			}, &out)
+		} else {
+			err = vaultinoUtils.RenderTemplate(templateFile, FlagVault, keys, &out)
+		}
+		if err != nil {
+			logger.Fatalf("failed to render: %v", err)
+		}
+		if err := vaultinoUtils.WriteOutput(FlagOutput, out.Bytes()); err != nil {
+			logger.Fatalf("failed to write output: %v", err)
+		}
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVar(&FlagVault, "vault", "", "Vault file with the secrets")
+	Cmd.MarkFlagRequired("vault")
+	Cmd.Flags().StringVarP(&FlagOutput, "output", "o", "", "Write to the file with mode 0600 instead of stdout")
+	Cmd.Flags().StringVar(&FlagK8sSecret, "k8s-secret", "", "Print a Kubernetes v1.Secret with the name")
+	Cmd.Flags().StringVarP(&FlagNamespace, "namespace", "n", "", "Namespace of the Kubernetes Secret")
+	Cmd.Flags().StringVar(&FlagSecretType, "secret-type", "", "Type of the Kubernetes Secret (default Opaque)")
+	Cmd.Flags().StringVar(&FlagSecretKey, "secret-key", "", "Key of the rendered template in the Kubernetes Secret (default the template name without .tmpl)")
+}
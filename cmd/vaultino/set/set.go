@@ -0,0 +1,74 @@
+package set
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var (
+	FlagKey   string
+	FlagValue string
+	FlagStdin bool
+	FlagJSON  bool
+)
+
+var Cmd = &cobra.Command{
+	Use:   "set <vault>",
+	Short: "Set a value in Vaultino encrypted file",
+	Long:  "Set the value of a key in Vaultino encrypted file without opening an editor. Nested YAML and JSON keys are separated by dots and missing parents are created, env files have flat keys. Comments, key order and formatting are kept where possible.\nThe value is a string unless it replaces a number, boolean or null and parses as one, use --json to set any JSON value.",
+	Example: `vpd vaultino set prod.vault -k db.password -v s3cret
+openssl rand -hex 32 | vpd vaultino set prod.vault -k api.token --stdin
+vpd vaultino set prod.vault -k db.hosts --json -v '["db1", "db2"]'`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		value := FlagValue
+		if FlagStdin {
+			if cmd.Flags().Changed("value") {
+				logger.Fatalf("use either --value or --stdin")
+			}
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				logger.Fatalf("failed to read value from stdin: %v", err)
+			}
+			value = strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+		} else if !cmd.Flags().Changed("value") {
+			logger.Fatalf("--value or --stdin is required")
+		}
+
+		keys, err := parent_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		vault, err := vaultino.Open(args[0], keys.Provider())
+		if err != nil {
+			logger.Fatalf("failed to open vault: %v", err)
+		}
+		if FlagJSON {
+			err = vault.SetJSON(FlagKey, value)
+		} else {
+			err = vault.Set(FlagKey, value)
+		}
+		if err != nil {
+			logger.Fatalf("failed to set key: %v", err)
+		}
+		if err := vault.Save(); err != nil {
+			logger.Fatalf("failed to save vault: %v", err)
+		}
+		logger.Successf("key %s set in %s", FlagKey, args[0])
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVarP(&FlagKey, "key", "k", "", "Key to set, nested keys separated by dots")
+	Cmd.MarkFlagRequired("key")
+	Cmd.Flags().StringVarP(&FlagValue, "value", "v", "", "Value to set")
+	Cmd.Flags().BoolVar(&FlagStdin, "stdin", false, "Read the value from stdin, a trailing newline is removed")
+	Cmd.Flags().BoolVar(&FlagJSON, "json", false, "Parse the value as JSON")
+}
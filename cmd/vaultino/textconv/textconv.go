@@ -0,0 +1,35 @@
+package textconv
+
+import (
+	"os"
+
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	vaultinoUtils "github.com/VojtechPastyrik/vpd/utils/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var FlagMaskValues bool
+
+var Cmd = &cobra.Command{
+	Use:   "textconv <vault>",
+	Short: "Print a Vaultino encrypted file as sorted key lines for git diff",
+	Long:  "Print the decrypted payload as sorted \"key = value\" lines, used by git as textconv of the vaultino diff driver, see vaultino git setup. With --mask-values the values are replaced by HMAC fingerprints keyed by the vault password or the identity that opens the vault, so short secrets cannot be guessed from the diff. Git runs textconv without a terminal, so the password has to come from VAULTINO_PASSWORD or a password command, or the vaults are encrypted for recipients. The keyring does not apply, git passes the old side of a diff as a temporary file and keyring entries belong to vault paths. Vaults that cannot be decrypted are printed as they are.",
+	Example: `vpd vaultino textconv prod.vault
+git config diff.vaultino.textconv "vpd vaultino textconv"`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		keys, err := parent_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		if err := vaultinoUtils.Textconv(args[0], keys, FlagMaskValues, os.Stdout); err != nil {
+			logger.Fatalf("failed to convert vault: %v", err)
+		}
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().BoolVar(&FlagMaskValues, "mask-values", false, "Print value fingerprints instead of values")
+}
@@ -0,0 +1,41 @@
+package unset
+
+import (
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var FlagKey string
+
+var Cmd = &cobra.Command{
+	Use:     "unset <vault>",
+	Short:   "Remove a key from Vaultino encrypted file",
+	Long:    "Remove a key with its value from Vaultino encrypted file without opening an editor. Nested YAML and JSON keys are separated by dots, list items are addressed by index.",
+	Example: "vpd vaultino unset prod.vault -k db.password",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		keys, err := parent_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		vault, err := vaultino.Open(args[0], keys.Provider())
+		if err != nil {
+			logger.Fatalf("failed to open vault: %v", err)
+		}
+		if err := vault.Unset(FlagKey); err != nil {
+			logger.Fatalf("failed to unset key: %v", err)
+		}
+		if err := vault.Save(); err != nil {
+			logger.Fatalf("failed to save vault: %v", err)
+		}
+		logger.Successf("key %s removed from %s", FlagKey, args[0])
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	Cmd.Flags().StringVarP(&FlagKey, "key", "k", "", "Key to remove, nested keys separated by dots")
+	Cmd.MarkFlagRequired("key")
+}
@@ -1,18 +1,143 @@
 package vaultino
 
 import (
+	"fmt"
+
 	"github.com/VojtechPastyrik/vpd/cmd/root"
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+	vaultinoUtils "github.com/VojtechPastyrik/vpd/utils/vaultino"
 	"github.com/spf13/cobra"
 )
 
+var (
+	FlagIdentities         []string
+	FlagPasswordFile       string
+	FlagPasswordFD         int
+	FlagPasswordCommand    string
+	FlagKeyring            string
+	FlagNewPasswordFile    string
+	FlagNewPasswordCommand string
+)
+
 var Cmd = &cobra.Command{
 	Use:     "vaultino",
 	Aliases: []string{"vt"},
 	Short:   "Vaultino is custom implementation of Vault similar to Ansible Vault",
-	Long:    "Vaultino is custom implementation of Vault similar to Ansible Vault. It allows you to encrypt and decrypt files using a password.",
+	Long:    "Vaultino is custom implementation of Vault similar to Ansible Vault. It allows you to encrypt and decrypt files using a password, or for a list of recipients with age X25519 or SSH ed25519 keys.\nThe password is taken from the first of --password-file, --password-fd, --password-command, --keyring and the VAULTINO_PASSWORD environment variable, otherwise it is prompted for. New passwords are prompted for twice.",
 	Example: "vpd vaultino --help",
 }
 
 func init() {
 	root.RootCmd.AddCommand(Cmd)
+	Cmd.PersistentFlags().StringArrayVarP(&FlagIdentities, "identity", "i", nil, "Identity file for vaults encrypted for recipients: age key file or OpenSSH ed25519 private key (default $VAULTINO_IDENTITY, ~/.config/vpd/vaultino-identity.txt, ~/.ssh/id_ed25519)")
+	Cmd.PersistentFlags().StringVar(&FlagPasswordFile, "password-file", "", "Read the password from the first line of the file, - for stdin")
+	Cmd.PersistentFlags().IntVar(&FlagPasswordFD, "password-fd", 0, "Read the password from the inherited file descriptor")
+	Cmd.PersistentFlags().StringVar(&FlagPasswordCommand, "password-command", "", "Read the password from the output of the shell command, e.g. \"op read op://vault/item/password\"")
+	Cmd.PersistentFlags().StringVar(&FlagKeyring, "keyring", "", "Read the password from the OS keyring and store new passwords there (auto, secret-service, macos)")
+	Cmd.PersistentFlags().StringVar(&FlagNewPasswordFile, "new-password-file", "", "Read the new password from the first line of the file when changing the password")
+	Cmd.PersistentFlags().StringVar(&FlagNewPasswordCommand, "new-password-command", "", "Read the new password from the output of the shell command when changing the password")
+}
+
+// Passwords returns the password provider configured by the flags, the password has
+// precedence over all other sources
+func Passwords(password string) (vaultinoUtils.PasswordProvider, error) {
+	provider := vaultinoUtils.PasswordProvider{
+		Value:   password,
+		File:    FlagPasswordFile,
+		FD:      FlagPasswordFD,
+		Command: FlagPasswordCommand,
+	}
+	if FlagKeyring != "" {
+		keyring, err := vaultinoUtils.GetKeyring(FlagKeyring)
+		if err != nil {
+			return provider, err
+		}
+		provider.Keyring = keyring
+	}
+	return provider, nil
+}
+
+// Keys returns the keys to unlock a vault with the password sources and the identity files
+func Keys(password string) (vaultinoUtils.Keys, error) {
+	passwords, err := Passwords(password)
+	if err != nil {
+		return vaultinoUtils.Keys{}, err
+	}
+	identities := FlagIdentities
+	if len(identities) == 0 {
+		identities = vaultinoUtils.DefaultIdentityFiles()
+	}
+	return vaultinoUtils.Keys{
+		Password: passwords,
+		NewPassword: vaultinoUtils.PasswordProvider{
+			File:    FlagNewPasswordFile,
+			Command: FlagNewPasswordCommand,
+			Keyring: passwords.Keyring,
+		},
+		IdentityFiles: identities,
+	}, nil
+}
+
+// FormatFlags select the cipher and KDF parameters of written vaults
+type FormatFlags struct {
+	Cipher     string
+	KDFTime    uint32
+	KDFMemory  uint32
+	KDFThreads uint8
+}
+
+// AddFormatFlags registers the flags selecting the cipher and KDF parameters, unset flags
+// keep the current values of existing vaults
+func AddFormatFlags(cmd *cobra.Command, flags *FormatFlags, existing bool) {
+	defaults := []string{"aes256-gcm", "3", "64", "4"}
+	if existing {
+		defaults = []string{"keep", "keep", "keep", "keep"}
+	}
+	cmd.Flags().StringVar(&flags.Cipher, "cipher", "", "Payload cipher: aes256-gcm or xchacha20-poly1305 (default "+defaults[0]+")")
+	cmd.Flags().Uint32Var(&flags.KDFTime, "kdf-time", 0, "Argon2id iterations of password vaults (default "+defaults[1]+")")
+	cmd.Flags().Uint32Var(&flags.KDFMemory, "kdf-memory", 0, "Argon2id memory in MiB of password vaults (default "+defaults[2]+")")
+	cmd.Flags().Uint8Var(&flags.KDFThreads, "kdf-threads", 0, "Argon2id parallelism of password vaults (default "+defaults[3]+")")
+}
+
+// Format returns the format selected by the flags, unset flags are zero
+func (f FormatFlags) Format() (vaultino.Format, error) {
+	// Check the limit before converting to KiB, larger values overflow uint32
+	if f.KDFMemory > vaultino.MaxKDFMemoryMiB {
+		return vaultino.Format{}, fmt.Errorf("argon2id memory must be at most %d MiB", vaultino.MaxKDFMemoryMiB)
+	}
+	return vaultino.Format{
+		Cipher: f.Cipher,
+		KDF: vaultino.KDFParams{
+			Time:    f.KDFTime,
+			Memory:  f.KDFMemory * 1024,
+			Threads: f.KDFThreads,
+		},
+	}, nil
+}
+
+// AddEnvFlags registers the flags naming the environment variables of the secrets
+func AddEnvFlags(cmd *cobra.Command, opts *vaultinoUtils.EnvOptions) {
+	cmd.Flags().StringVar(&opts.Prefix, "prefix", "", "Prefix of the variable names")
+	cmd.Flags().StringVar(&opts.Separator, "separator", "_", "Separator joining nested YAML and JSON keys")
+	cmd.Flags().BoolVar(&opts.KeepCase, "keep-case", false, "Keep the case of the keys instead of upper casing the variable names")
+}
+
+// ParseRecipients parses recipients given as keys and as recipient files
+func ParseRecipients(keys []string, files []string) ([]vaultino.Recipient, error) {
+	var recipients []vaultino.Recipient
+	for _, key := range keys {
+		recipient, err := vaultino.ParseRecipient(key)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, recipient)
+	}
+	for _, file := range files {
+		fromFile, err := vaultino.ReadRecipientsFile(file)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, fromFile...)
+	}
+	return recipients, nil
 }
@@ -0,0 +1,38 @@
+package verify
+
+import (
+	parent_cmd "github.com/VojtechPastyrik/vpd/cmd/vaultino"
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	vaultinoUtils "github.com/VojtechPastyrik/vpd/utils/vaultino"
+	"github.com/spf13/cobra"
+)
+
+var FlagBulk parent_cmd.BulkFlags
+
+var Cmd = &cobra.Command{
+	Use:   "verify [vault]...",
+	Short: "Check that Vaultino encrypted files decrypt and parse",
+	Long:  "Check that all Vaultino encrypted files given as arguments or found recursively in the directories decrypt with the password or identities and that YAML and JSON payloads parse. Vaults are checked concurrently and the command exits with 1 when any vault fails.",
+	Example: `vpd vaultino verify
+vpd vaultino verify --dir ./secrets --dir ./ci -o json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		options, err := FlagBulk.Options(args)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		keys, err := parent_cmd.Keys("")
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		report, err := vaultinoUtils.VerifyVaults(options, keys)
+		if err != nil {
+			logger.Fatalf("failed to verify vaults: %v", err)
+		}
+		parent_cmd.PrintBulkReport(report, FlagBulk.Output, parent_cmd.PrintBulkResult)
+	},
+}
+
+func init() {
+	parent_cmd.Cmd.AddCommand(Cmd)
+	parent_cmd.AddBulkFlags(Cmd, &FlagBulk)
+}
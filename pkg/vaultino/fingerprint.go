@@ -0,0 +1,44 @@
+package vaultino
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// fingerprintLabel separates the fingerprint key from the keys sealing the payload
+const fingerprintLabel = "vaultino value fingerprint"
+
+// Fingerprint returns a short HMAC-SHA256 of the value keyed by the password of the vault,
+// or by the identity that opened a recipient vault. Fingerprints of a value stay the same
+// across saves but cannot be guessed without the key, unlike a plain hash of a short secret.
+func (v *Vault) Fingerprint(value []byte) (string, error) {
+	key, err := v.valueKey()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(value)
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8]), nil
+}
+
+// valueKey derives the fingerprint key once, password keys cost as much as opening the vault
+func (v *Vault) valueKey() ([]byte, error) {
+	if v.fingerprintKey != nil {
+		return v.fingerprintKey, nil
+	}
+	switch {
+	case v.identity != nil:
+		mac := hmac.New(sha256.New, v.identity.secret())
+		mac.Write([]byte(fingerprintLabel))
+		v.fingerprintKey = mac.Sum(nil)
+	case v.password != nil:
+		v.fingerprintKey = argon2.IDKey(v.password, []byte(fingerprintLabel), DefaultKDF.Time, DefaultKDF.Memory, DefaultKDF.Threads, 32)
+	default:
+		return nil, errors.New("vault was not opened with a password or an identity")
+	}
+	return v.fingerprintKey, nil
+}
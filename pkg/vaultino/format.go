@@ -0,0 +1,407 @@
+package vaultino
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Vault file format: a header line, the wrapped data keys of recipient vaults and the
+// base64 payload of salt (password vaults), nonce and ciphertext.
+//
+//	1.2  $VAULTINO;1.2;AES256-GCM;argon2id;name=..;type=..;user=..;time=..
+//	2.0  $VAULTINO;2.0;XCHACHA20-POLY1305;argon2id;t=3;m=65536;p=4;name=..;type=..;user=..;time=..
+//
+// Version 2.0 records the argon2id parameters and authenticates the header line as
+// additional data of the AEAD, version 1.2 is still read.
+const (
+	headerMagic = "$VAULTINO"
+	version1    = "1.2"
+	version2    = "2.0"
+)
+
+// Payload ciphers
+const (
+	CipherAESGCM            = "AES256-GCM"
+	CipherXChaCha20Poly1305 = "XCHACHA20-POLY1305"
+)
+
+// Key modes of the header: the payload key is derived from a password, or it is a random
+// data key wrapped for every recipient
+const (
+	ModePassword   = "argon2id"
+	ModeRecipients = "recipients"
+)
+
+// TypeBinary is the type of vaults holding opaque bytes, e.g. keystores
+const TypeBinary = "binary"
+
+const saltSize = 16
+
+// tagSize is the size of the authentication tag of both ciphers
+const tagSize = 16
+
+// Limits of the KDF parameters of headers read from files, a crafted header must not make
+// opening a vault take gigabytes of memory or minutes of CPU
+const (
+	// MaxKDFMemoryMiB is the argon2id memory limit of 1 GiB
+	MaxKDFMemoryMiB = 1024
+	// maxKDFMemory is the limit in KiB
+	maxKDFMemory = MaxKDFMemoryMiB * 1024
+	maxKDFTime   = 16
+)
+
+// KDFParams are the argon2id parameters deriving the key of password vaults
+type KDFParams struct {
+	Time uint32 `json:"time"`
+	// Memory in KiB
+	Memory  uint32 `json:"memoryKiB"`
+	Threads uint8  `json:"threads"`
+}
+
+// DefaultKDF are the parameters of new vaults and of all 1.2 vaults
+var DefaultKDF = KDFParams{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+func (p KDFParams) validate() error {
+	if p.Time < 1 || p.Time > maxKDFTime || p.Threads < 1 || p.Memory < 8*uint32(p.Threads) || p.Memory > maxKDFMemory {
+		return fmt.Errorf("invalid argon2id parameters t=%d m=%d p=%d", p.Time, p.Memory, p.Threads)
+	}
+	return nil
+}
+
+func (p KDFParams) String() string {
+	return fmt.Sprintf("argon2id t=%d m=%d KiB p=%d", p.Time, p.Memory, p.Threads)
+}
+
+// Format selects the cipher and KDF parameters of a vault, zero fields keep the current
+// ones or use the defaults
+type Format struct {
+	Cipher string
+	KDF    KDFParams
+}
+
+// apply returns the header with the format applied
+func (f Format) apply(h Header) (Header, error) {
+	if f.Cipher != "" {
+		cipherName, err := ParseCipher(f.Cipher)
+		if err != nil {
+			return h, err
+		}
+		h.Cipher = cipherName
+	}
+	if f.KDF.Time != 0 {
+		h.KDF.Time = f.KDF.Time
+	}
+	if f.KDF.Memory != 0 {
+		h.KDF.Memory = f.KDF.Memory
+	}
+	if f.KDF.Threads != 0 {
+		h.KDF.Threads = f.KDF.Threads
+	}
+	if h.Mode == ModePassword {
+		if err := h.KDF.validate(); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
+
+// ParseCipher returns the header name of the cipher, case insensitive
+func ParseCipher(name string) (string, error) {
+	switch strings.ToUpper(name) {
+	case CipherAESGCM, "AES-256-GCM", "AES":
+		return CipherAESGCM, nil
+	case CipherXChaCha20Poly1305, "XCHACHA20", "XCHACHA":
+		return CipherXChaCha20Poly1305, nil
+	default:
+		return "", fmt.Errorf("unsupported cipher '%s', use aes256-gcm or xchacha20-poly1305", name)
+	}
+}
+
+// Header is the metadata line of a vault
+type Header struct {
+	Version string
+	Cipher  string
+	Mode    string
+	// KDF of password vaults
+	KDF  KDFParams
+	Name string
+	Type string
+	// File is the original file name of binary vaults
+	File string
+	User string
+	Time string
+}
+
+// newHeader returns a 2.0 header for the original file name and type, written by the
+// current user now
+func newHeader(name, fileType, mode string) Header {
+	user := os.Getenv("USER")
+	if user == "" {
+		user = os.Getenv("USERNAME")
+	}
+	return Header{
+		Version: version2,
+		Cipher:  CipherAESGCM,
+		Mode:    mode,
+		KDF:     DefaultKDF,
+		Name:    headerValue(name),
+		Type:    fileType,
+		User:    headerValue(user),
+		Time:    time.Now().Format(time.RFC3339),
+	}
+}
+
+// headerValue replaces the field separator and control characters of a free-form header
+// value, a file or user name with ";" would otherwise add fields to the header
+func headerValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ';' || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+// renew returns the header for a new payload of the vault, written in the current format
+// by the current user now
+func (h Header) renew() Header {
+	renewed := newHeader(h.Name, h.Type, h.Mode)
+	renewed.Cipher, renewed.KDF, renewed.File = h.Cipher, h.KDF, h.File
+	return renewed
+}
+
+// FileName returns the base name of the original file. Path separators recorded in the
+// header are dropped, a vault must not choose where it is decrypted to.
+func (h Header) FileName() string {
+	name := h.File
+	if name == "" {
+		name = h.Name
+		if h.Type != "" {
+			name += "." + h.Type
+		}
+	}
+	name = filepath.Base(filepath.FromSlash(strings.ReplaceAll(name, "\\", "/")))
+	if name == "." || name == ".." || name == string(filepath.Separator) || name == "" {
+		return "vault.out"
+	}
+	return name
+}
+
+func (h Header) String() string {
+	fields := []string{headerMagic, h.Version, h.Cipher, h.Mode}
+	if h.Version != version1 && h.Mode == ModePassword {
+		fields = append(fields,
+			"t="+strconv.FormatUint(uint64(h.KDF.Time), 10),
+			"m="+strconv.FormatUint(uint64(h.KDF.Memory), 10),
+			"p="+strconv.FormatUint(uint64(h.KDF.Threads), 10))
+	}
+	fields = append(fields, "name="+headerValue(h.Name), "type="+h.Type)
+	if h.File != "" {
+		fields = append(fields, "file="+url.PathEscape(h.File))
+	}
+	fields = append(fields, "user="+headerValue(h.User), "time="+h.Time)
+	return strings.Join(fields, ";")
+}
+
+// Authenticated reports whether the header is bound to the payload
+func (h Header) Authenticated() bool {
+	return h.Version != version1
+}
+
+// parseHeader parses and checks the header line
+func parseHeader(line string) (Header, error) {
+	fields := strings.Split(strings.TrimRight(line, "\r"), ";")
+	if len(fields) < 4 || fields[0] != headerMagic {
+		return Header{}, errors.New("invalid vault format: missing $VAULTINO header")
+	}
+	h := Header{Version: fields[1], Cipher: fields[2], Mode: fields[3], KDF: DefaultKDF}
+
+	switch h.Version {
+	case version1:
+		if h.Cipher != CipherAESGCM {
+			return Header{}, fmt.Errorf("unsupported cipher %s in a %s vault", h.Cipher, version1)
+		}
+	case version2:
+		if _, err := ParseCipher(h.Cipher); err != nil || h.Cipher != strings.ToUpper(h.Cipher) {
+			return Header{}, fmt.Errorf("unsupported cipher %s", h.Cipher)
+		}
+	default:
+		return Header{}, fmt.Errorf("unsupported vault version %s, supported are %s and %s", h.Version, version1, version2)
+	}
+	if h.Mode != ModePassword && h.Mode != ModeRecipients {
+		return Header{}, fmt.Errorf("unsupported key mode %s", h.Mode)
+	}
+
+	kdfSeen := 0
+	for _, field := range fields[4:] {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "t", "m", "p":
+			bits := 32
+			if key == "p" {
+				bits = 8
+			}
+			n, err := strconv.ParseUint(value, 10, bits)
+			if err != nil {
+				return Header{}, fmt.Errorf("invalid KDF parameter %s: %w", field, err)
+			}
+			switch key {
+			case "t":
+				h.KDF.Time = uint32(n)
+			case "m":
+				h.KDF.Memory = uint32(n)
+			case "p":
+				h.KDF.Threads = uint8(n)
+			}
+			kdfSeen++
+		case "name":
+			h.Name = value
+		case "type":
+			h.Type = value
+		case "file":
+			file, err := url.PathUnescape(value)
+			if err != nil {
+				return Header{}, fmt.Errorf("invalid file name %s: %w", value, err)
+			}
+			h.File = file
+		case "user":
+			h.User = value
+		case "time":
+			h.Time = value
+		}
+	}
+	if h.Version == version2 && h.Mode == ModePassword {
+		if kdfSeen != 3 {
+			return Header{}, errors.New("invalid vault header: missing KDF parameters")
+		}
+		if err := h.KDF.validate(); err != nil {
+			return Header{}, err
+		}
+	}
+	return h, nil
+}
+
+// Info is the metadata of a vault, readable without its keys
+type Info struct {
+	File                string     `json:"file"`
+	Version             string     `json:"version"`
+	Cipher              string     `json:"cipher"`
+	Mode                string     `json:"mode"`
+	KDF                 *KDFParams `json:"kdf,omitempty"`
+	Name                string     `json:"name"`
+	Type                string     `json:"type"`
+	OriginalFile        string     `json:"originalFile"`
+	User                string     `json:"user"`
+	Time                string     `json:"time"`
+	AuthenticatedHeader bool       `json:"authenticatedHeader"`
+	Recipients          []string   `json:"recipients,omitempty"`
+	PayloadBytes        int        `json:"payloadBytes"`
+}
+
+// ReadInfo returns the metadata of the vault
+func ReadInfo(path string) (*Info, error) {
+	f, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{
+		File:                path,
+		Version:             f.hdr.Version,
+		Cipher:              f.hdr.Cipher,
+		Mode:                f.hdr.Mode,
+		Name:                f.hdr.Name,
+		Type:                f.hdr.Type,
+		OriginalFile:        f.hdr.FileName(),
+		User:                f.hdr.User,
+		Time:                f.hdr.Time,
+		AuthenticatedHeader: f.hdr.Authenticated(),
+		PayloadBytes:        len(f.ciphertext) - tagSize,
+	}
+	if f.hdr.Mode == ModePassword {
+		kdf := f.hdr.KDF
+		info.KDF = &kdf
+	}
+	for _, s := range f.stanzas {
+		info.Recipients = append(info.Recipients, describeStanza(s))
+	}
+	return info, nil
+}
+
+// ReadHeader returns the header of the vault
+func ReadHeader(path string) (Header, error) {
+	f, err := readFile(path)
+	if err != nil {
+		return Header{}, err
+	}
+	return f.hdr, nil
+}
+
+// Migrate seals the vault again in the current format with the format applied. It returns
+// false without touching the vault when it is current and the format changes nothing.
+func Migrate(path string, keys KeyProvider, format Format) (bool, error) {
+	f, err := readFile(path)
+	if err != nil {
+		return false, err
+	}
+	target, err := format.apply(f.hdr)
+	if err != nil {
+		return false, err
+	}
+	if f.hdr.Version == version2 && target.Cipher == f.hdr.Cipher && target.KDF == f.hdr.KDF {
+		return false, nil
+	}
+
+	v, err := Open(path, keys)
+	if err != nil {
+		return false, err
+	}
+	v.header = target
+	return true, v.Save()
+}
+
+// newAEAD returns the cipher of the header with the key
+func newAEAD(cipherName string, key []byte) (cipher.AEAD, error) {
+	switch cipherName {
+	case CipherXChaCha20Poly1305:
+		aead, err := chacha20poly1305.NewX(key)
+		if err != nil {
+			return nil, fmt.Errorf("error creating cipher: %w", err)
+		}
+		return aead, nil
+	case CipherAESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("error creating cipher: %w", err)
+		}
+		aesGCM, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("error creating GCM: %w", err)
+		}
+		return aesGCM, nil
+	default:
+		return nil, fmt.Errorf("unsupported cipher %s", cipherName)
+	}
+}
+
+func nonceSize(cipherName string) int {
+	if cipherName == CipherXChaCha20Poly1305 {
+		return chacha20poly1305.NonceSizeX
+	}
+	return 12
+}
+
+func deriveKey(password, salt []byte, kdf KDFParams) []byte {
+	return argon2.IDKey(password, salt, kdf.Time, kdf.Memory, kdf.Threads, 32)
+}
@@ -0,0 +1,140 @@
+package vaultino
+
+import (
+	"testing"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	tests := []Header{
+		{Version: version2, Cipher: CipherAESGCM, Mode: ModePassword, KDF: DefaultKDF, Name: "app", Type: "yaml", User: "alice", Time: "2024-01-01T00:00:00Z"},
+		{Version: version2, Cipher: CipherXChaCha20Poly1305, Mode: ModeRecipients, KDF: DefaultKDF, Name: "app", Type: "env", User: "bob", Time: "2024-01-01T00:00:00Z"},
+		{Version: version2, Cipher: CipherAESGCM, Mode: ModePassword, KDF: KDFParams{Time: 1, Memory: 64, Threads: 1}, Name: "keystore", Type: TypeBinary, File: "key store;1.p12", Time: "2024-01-01T00:00:00Z"},
+		{Version: version1, Cipher: CipherAESGCM, Mode: ModePassword, KDF: DefaultKDF, Name: "old", Type: "json", User: "carol", Time: "2023-01-01T00:00:00Z"},
+	}
+	for _, expected := range tests {
+		line := expected.String()
+		parsed, err := parseHeader(line)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", line, err)
+		}
+		if parsed != expected {
+			t.Errorf("expected %+v, got %+v", expected, parsed)
+		}
+	}
+}
+
+func TestHeaderString(t *testing.T) {
+	h := Header{Version: version2, Cipher: CipherAESGCM, Mode: ModePassword, KDF: DefaultKDF, Name: "app", Type: "yaml", User: "alice", Time: "now"}
+	expected := "$VAULTINO;2.0;AES256-GCM;argon2id;t=3;m=65536;p=4;name=app;type=yaml;user=alice;time=now"
+	if h.String() != expected {
+		t.Errorf("expected %s, got %s", expected, h.String())
+	}
+
+	h.Version = version1
+	expected = "$VAULTINO;1.2;AES256-GCM;argon2id;name=app;type=yaml;user=alice;time=now"
+	if h.String() != expected {
+		t.Errorf("expected %s, got %s", expected, h.String())
+	}
+}
+
+func TestHeaderSeparatorInValues(t *testing.T) {
+	t.Setenv("USER", "eve;time=never")
+	h := newHeader("a;b", "env", ModePassword)
+	if h.Name != "a_b" || h.User != "eve_time=never" {
+		t.Errorf("expected the separators to be replaced, got %s and %s", h.Name, h.User)
+	}
+
+	h.Name = "x;user=mallory\n"
+	parsed, err := parseHeader(h.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parsed.Name != "x_user=mallory_" || parsed.User != "eve_time=never" || parsed.Time != h.Time {
+		t.Errorf("expected the values to stay in their fields, got %+v", parsed)
+	}
+}
+
+func TestHeaderFileName(t *testing.T) {
+	tests := []struct {
+		header   Header
+		expected string
+	}{
+		{Header{Name: "app", Type: "yaml"}, "app.yaml"},
+		{Header{Name: "Dockerfile"}, "Dockerfile"},
+		{Header{Name: "keystore", Type: TypeBinary, File: "keystore.p12"}, "keystore.p12"},
+		{Header{Name: "x", Type: TypeBinary, File: "../../etc/passwd"}, "passwd"},
+		{Header{Name: "x", Type: TypeBinary, File: `..\..\boot.ini`}, "boot.ini"},
+		{Header{Name: "x", Type: TypeBinary, File: ".."}, "vault.out"},
+		{Header{}, "vault.out"},
+	}
+	for _, tt := range tests {
+		if name := tt.header.FileName(); name != tt.expected {
+			t.Errorf("%+v: expected %s, got %s", tt.header, tt.expected, name)
+		}
+	}
+}
+
+func TestParseCipher(t *testing.T) {
+	tests := map[string]string{
+		"aes256-gcm":         CipherAESGCM,
+		"AES":                CipherAESGCM,
+		"xchacha20-poly1305": CipherXChaCha20Poly1305,
+		"XChaCha20":          CipherXChaCha20Poly1305,
+	}
+	for name, expected := range tests {
+		cipherName, err := ParseCipher(name)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if cipherName != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, cipherName)
+		}
+	}
+	if _, err := ParseCipher("des"); err == nil {
+		t.Errorf("expected an error for an unsupported cipher")
+	}
+}
+
+func TestFormatApply(t *testing.T) {
+	h := newHeader("app", "env", ModePassword)
+	applied, err := Format{Cipher: "xchacha", KDF: KDFParams{Memory: 128 * 1024}}.apply(h)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if applied.Cipher != CipherXChaCha20Poly1305 {
+		t.Errorf("expected %s, got %s", CipherXChaCha20Poly1305, applied.Cipher)
+	}
+	expected := KDFParams{Time: DefaultKDF.Time, Memory: 128 * 1024, Threads: DefaultKDF.Threads}
+	if applied.KDF != expected {
+		t.Errorf("expected %v, got %v", expected, applied.KDF)
+	}
+
+	invalid := []Format{
+		{Cipher: "des"},
+		{KDF: KDFParams{Memory: 8}},
+		{KDF: KDFParams{Memory: maxKDFMemory + 1}},
+		{KDF: KDFParams{Time: maxKDFTime + 1}},
+	}
+	for _, format := range invalid {
+		if _, err := format.apply(h); err == nil {
+			t.Errorf("%+v: expected an error", format)
+		}
+	}
+
+	// Recipient vaults have no KDF to check
+	recipients := newHeader("app", "env", ModeRecipients)
+	if _, err := (Format{KDF: KDFParams{Memory: 8}}).apply(recipients); err != nil {
+		t.Errorf("unexpected error for a recipient vault: %v", err)
+	}
+}
+
+func TestRenewKeepsFormat(t *testing.T) {
+	h := Header{Version: version1, Cipher: CipherXChaCha20Poly1305, Mode: ModePassword, KDF: KDFParams{Time: 1, Memory: 64, Threads: 1}, Name: "ks", Type: TypeBinary, File: "ks.p12", User: "old", Time: "then"}
+	renewed := h.renew()
+	if renewed.Version != version2 || renewed.Time == "then" {
+		t.Errorf("expected a current header, got %+v", renewed)
+	}
+	if renewed.Cipher != h.Cipher || renewed.KDF != h.KDF || renewed.File != h.File || renewed.Name != h.Name || renewed.Type != h.Type {
+		t.Errorf("expected the cipher, KDF and names of %+v, got %+v", h, renewed)
+	}
+}
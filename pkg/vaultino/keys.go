@@ -0,0 +1,94 @@
+package vaultino
+
+import "errors"
+
+var (
+	// ErrNoPassword is returned by key providers without a password
+	ErrNoPassword = errors.New("no password available")
+	// ErrNoIdentity is returned when a vault encrypted for recipients is opened without identities
+	ErrNoIdentity = errors.New("the vault is encrypted for recipients, no identity given")
+	// ErrDecrypt is returned when the password does not open the vault
+	ErrDecrypt = errors.New("decryption failed: incorrect password or corrupted data")
+)
+
+// KeyProvider supplies the keys of vaults. Password vaults use the password, vaults
+// encrypted for recipients are opened with the first matching identity. New vaults are
+// encrypted for the recipients, or with the password when there are none.
+type KeyProvider interface {
+	// Password returns the password of the vault at the path, the path is empty for vaults
+	// in memory. Confirm is set when the password protects a new vault, e.g. to ask twice.
+	Password(path string, confirm bool) ([]byte, error)
+	// Identities return the private keys tried on vaults encrypted for recipients
+	Identities() ([]Identity, error)
+	// Recipients return the public keys new vaults are encrypted for
+	Recipients() ([]Recipient, error)
+}
+
+// Password is a KeyProvider with a fixed password
+type Password []byte
+
+func (p Password) Password(string, bool) ([]byte, error) {
+	if len(p) == 0 {
+		return nil, ErrNoPassword
+	}
+	return p, nil
+}
+
+func (p Password) Identities() ([]Identity, error) {
+	return nil, nil
+}
+
+func (p Password) Recipients() ([]Recipient, error) {
+	return nil, nil
+}
+
+// PasswordFunc is a KeyProvider asking for the password when it is needed, e.g. with a
+// prompt or from a secret manager
+type PasswordFunc func(path string, confirm bool) ([]byte, error)
+
+func (f PasswordFunc) Password(path string, confirm bool) ([]byte, error) {
+	return f(path, confirm)
+}
+
+func (f PasswordFunc) Identities() ([]Identity, error) {
+	return nil, nil
+}
+
+func (f PasswordFunc) Recipients() ([]Recipient, error) {
+	return nil, nil
+}
+
+// Identities is a KeyProvider opening vaults with the identities, new vaults are
+// encrypted for their recipients
+type Identities []Identity
+
+func (ids Identities) Password(string, bool) ([]byte, error) {
+	return nil, ErrNoPassword
+}
+
+func (ids Identities) Identities() ([]Identity, error) {
+	return ids, nil
+}
+
+func (ids Identities) Recipients() ([]Recipient, error) {
+	recipients := make([]Recipient, 0, len(ids))
+	for _, identity := range ids {
+		recipients = append(recipients, identity.Recipient())
+	}
+	return recipients, nil
+}
+
+// Recipients is a KeyProvider encrypting new vaults for the recipients, it cannot open vaults
+type Recipients []Recipient
+
+func (r Recipients) Password(string, bool) ([]byte, error) {
+	return nil, ErrNoPassword
+}
+
+func (r Recipients) Identities() ([]Identity, error) {
+	return nil, nil
+}
+
+func (r Recipients) Recipients() ([]Recipient, error) {
+	return r, nil
+}
@@ -0,0 +1,765 @@
+package vaultino
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+)
+
+// Get returns the value at the key path, nested keys are joined with dots and list items
+// are addressed by index. Maps and lists are returned as JSON.
+func (v *Vault) Get(key string) (string, error) {
+	return Extract(v.data, v.header.Type, key)
+}
+
+// Set sets the value at the key path, missing parents are created. The value is a
+// string, unless it replaces a number, boolean or null and parses as one. Formatting and
+// comments of the payload are kept.
+func (v *Vault) Set(key, value string) error {
+	return v.update(func(data []byte, fileType string) ([]byte, error) {
+		return setKey(data, fileType, key, value, false)
+	})
+}
+
+// SetJSON sets the value at the key path to the JSON document, so numbers, booleans,
+// lists and objects can be set
+func (v *Vault) SetJSON(key, document string) error {
+	return v.update(func(data []byte, fileType string) ([]byte, error) {
+		return setKey(data, fileType, key, document, true)
+	})
+}
+
+// Unset removes the key path
+func (v *Vault) Unset(key string) error {
+	return v.update(func(data []byte, fileType string) ([]byte, error) {
+		return unsetKey(data, fileType, key)
+	})
+}
+
+// Keys returns the paths of all values, env keys in the order of the file and other
+// keys sorted
+func (v *Vault) Keys() ([]string, error) {
+	return listKeys(v.data, v.header.Type)
+}
+
+// Values returns the values by key path
+func (v *Vault) Values() (map[string]string, error) {
+	return v.Flatten(".")
+}
+
+// Flatten returns the scalar values by their key path joined with the separator
+func (v *Vault) Flatten(separator string) (map[string]string, error) {
+	tree, err := parseTree(v.data, v.header.Type)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	flattenValue(values, "", tree, separator)
+	return values, nil
+}
+
+// Tree returns the payload parsed into maps, lists and scalars
+func (v *Vault) Tree() (interface{}, error) {
+	return parseTree(v.data, v.header.Type)
+}
+
+// Lookup returns the parsed value at the key path and whether it exists
+func (v *Vault) Lookup(key string) (interface{}, bool, error) {
+	tree, err := parseTree(v.data, v.header.Type)
+	if err != nil {
+		return nil, false, err
+	}
+	return lookupValue(tree, key)
+}
+
+func (v *Vault) update(update func(data []byte, fileType string) ([]byte, error)) error {
+	updated, err := update(v.data, v.header.Type)
+	if err != nil {
+		return err
+	}
+	v.data = updated
+	return nil
+}
+
+// Extract returns the value at the key path of a payload of the file type
+func Extract(data []byte, fileType, key string) (string, error) {
+	switch fileType {
+	case "yaml", "yml":
+		return getFromYAML(data, key)
+	case "json":
+		return getFromJSON(data, key)
+	case "env":
+		return getFromENV(data, key)
+	case "toml", "ini", "properties":
+		return getFromTree(data, key, fileType)
+	case TypeBinary:
+		return "", errors.New("binary vaults have no keys")
+	case "":
+		return "", errors.New("the original file had no extension, the type of the content is unknown")
+	default:
+		return "", fmt.Errorf("unsupported file type: %s", fileType)
+	}
+}
+
+func getFromYAML(data []byte, key string) (string, error) {
+	// Convert YAML to JSON first, then use gjson for consistent parsing
+	var yamlData interface{}
+	if err := yaml.Unmarshal(data, &yamlData); err != nil {
+		return "", fmt.Errorf("error parsing YAML: %w", err)
+	}
+
+	jsonData, err := json.Marshal(yamlData)
+	if err != nil {
+		return "", fmt.Errorf("error converting YAML to JSON: %w", err)
+	}
+
+	return getValueUsingGjson(string(jsonData), key)
+}
+
+func getFromJSON(data []byte, key string) (string, error) {
+	return getValueUsingGjson(string(data), key)
+}
+
+func getValueUsingGjson(jsonStr string, key string) (string, error) {
+	result := gjson.Get(jsonStr, key)
+	if !result.Exists() {
+		return "", fmt.Errorf("key %s not found", key)
+	}
+	return result.String(), nil
+}
+
+func getFromTree(data []byte, key, fileType string) (string, error) {
+	tree, err := parseTree(data, fileType)
+	if err != nil {
+		return "", err
+	}
+	value, ok, err := lookupValue(tree, key)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("key %s not found", key)
+	}
+	return formatValue(value)
+}
+
+func getFromENV(data []byte, key string) (string, error) {
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, key+"=") {
+			return strings.TrimPrefix(line, key+"="), nil
+		}
+	}
+	return "", fmt.Errorf("key %s not found", key)
+}
+
+// Validate checks that YAML, JSON, TOML and INI payloads parse, other types always pass
+func Validate(data []byte, fileType string) error {
+	switch fileType {
+	case "yaml", "yml":
+		var v interface{}
+		return yaml.Unmarshal(data, &v)
+	case "json":
+		var v interface{}
+		return json.Unmarshal(data, &v)
+	case "toml", "ini":
+		_, err := parseTree(data, fileType)
+		return err
+	default:
+		return nil
+	}
+}
+
+func setKey(data []byte, fileType, key, value string, asJSON bool) ([]byte, error) {
+	path, err := splitKeyPath(key)
+	if err != nil {
+		return nil, err
+	}
+	if asJSON && !json.Valid([]byte(value)) {
+		return nil, errors.New("the value is not valid JSON")
+	}
+	switch fileType {
+	case "yaml", "yml":
+		return setYAMLKey(data, path, value, asJSON)
+	case "json":
+		return setJSONKey(data, path, value, asJSON)
+	case "env":
+		if len(path) != 1 {
+			return nil, errors.New("env files have no nested keys")
+		}
+		return setEnvKey(data, path[0], value), nil
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", fileType)
+	}
+}
+
+func unsetKey(data []byte, fileType, key string) ([]byte, error) {
+	path, err := splitKeyPath(key)
+	if err != nil {
+		return nil, err
+	}
+	switch fileType {
+	case "yaml", "yml":
+		return unsetYAMLKey(data, path)
+	case "json":
+		return unsetJSONKey(data, path)
+	case "env":
+		return unsetEnvKey(data, key)
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", fileType)
+	}
+}
+
+func listKeys(data []byte, fileType string) ([]string, error) {
+	// Env keys are listed in the order of the file
+	if fileType == "env" {
+		var names []string
+		for _, pair := range parseEnvPairs(data) {
+			names = append(names, pair.name)
+		}
+		return names, nil
+	}
+
+	tree, err := parseTree(data, fileType)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	flattenValue(values, "", tree, ".")
+	paths := make([]string, 0, len(values))
+	for path := range values {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// splitKeyPath splits the key on dots like Get, "\." is a literal dot
+func splitKeyPath(key string) ([]string, error) {
+	var path []string
+	var current strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch {
+		case key[i] == '\\' && i+1 < len(key):
+			i++
+			current.WriteByte(key[i])
+		case key[i] == '.':
+			path = append(path, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(key[i])
+		}
+	}
+	path = append(path, current.String())
+	for _, segment := range path {
+		if segment == "" {
+			return nil, fmt.Errorf("invalid key '%s'", key)
+		}
+	}
+	return path, nil
+}
+
+// scalarKind returns the JSON kind of a scalar value: string, number, bool or null
+func scalarKind(value string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return "string"
+	}
+	switch v.(type) {
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case nil:
+		return "null"
+	default:
+		return "string"
+	}
+}
+
+// YAML payloads are edited on the node tree, which keeps comments, key order and styles
+
+func setYAMLKey(data []byte, path []string, value string, asJSON bool) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing YAML: %w", err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+
+	node := doc.Content[0]
+	for i, segment := range path {
+		switch node.Kind {
+		case yaml.MappingNode:
+			child := yamlMappingValue(node, segment)
+			if child == nil {
+				created, err := newYAMLValue(path[i+1:], value, asJSON)
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: segment}, created)
+				return encodeYAML(&doc, data)
+			}
+			node = child
+		case yaml.SequenceNode:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node.Content) {
+				return nil, fmt.Errorf("index '%s' out of range of the list at '%s'", segment, strings.Join(path[:i], "."))
+			}
+			node = node.Content[index]
+		default:
+			return nil, fmt.Errorf("'%s' is not a map or a list", strings.Join(path[:i], "."))
+		}
+	}
+
+	if asJSON || node.Kind != yaml.ScalarNode {
+		if !asJSON {
+			return nil, fmt.Errorf("'%s' is not a scalar value, set it with --json", strings.Join(path, "."))
+		}
+		replacement, err := newYAMLValue(nil, value, asJSON)
+		if err != nil {
+			return nil, err
+		}
+		replacement.HeadComment, replacement.LineComment, replacement.FootComment = node.HeadComment, node.LineComment, node.FootComment
+		*node = *replacement
+		return encodeYAML(&doc, data)
+	}
+
+	// The value keeps the type of a replaced number, boolean or null when it parses as one
+	existing := map[string]string{"!!int": "number", "!!float": "number", "!!bool": "bool", "!!null": "null"}[node.ShortTag()]
+	if existing != "" && scalarKind(value) == existing {
+		node.Tag = ""
+		node.Style = 0
+	} else {
+		node.Tag = "!!str"
+		if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 && !strings.Contains(value, "\n") {
+			node.Style = 0
+		}
+	}
+	node.Value = value
+	return encodeYAML(&doc, data)
+}
+
+func unsetYAMLKey(data []byte, path []string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing YAML: %w", err)
+	}
+	if doc.Kind == 0 {
+		return nil, fmt.Errorf("key %s not found", strings.Join(path, "."))
+	}
+
+	node := doc.Content[0]
+	for i, segment := range path {
+		last := i == len(path)-1
+		switch node.Kind {
+		case yaml.MappingNode:
+			index := -1
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if node.Content[j].Value == segment {
+					index = j
+					break
+				}
+			}
+			if index < 0 {
+				return nil, fmt.Errorf("key %s not found", strings.Join(path, "."))
+			}
+			if last {
+				node.Content = append(node.Content[:index], node.Content[index+2:]...)
+				return encodeYAML(&doc, data)
+			}
+			node = node.Content[index+1]
+		case yaml.SequenceNode:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node.Content) {
+				return nil, fmt.Errorf("key %s not found", strings.Join(path, "."))
+			}
+			if last {
+				node.Content = append(node.Content[:index], node.Content[index+1:]...)
+				return encodeYAML(&doc, data)
+			}
+			node = node.Content[index]
+		default:
+			return nil, fmt.Errorf("key %s not found", strings.Join(path, "."))
+		}
+	}
+	return nil, fmt.Errorf("key %s not found", strings.Join(path, "."))
+}
+
+func yamlMappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// newYAMLValue builds the value nested in maps for the remaining path
+func newYAMLValue(path []string, value string, asJSON bool) (*yaml.Node, error) {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	if asJSON {
+		var v interface{}
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return nil, fmt.Errorf("error parsing JSON value: %w", err)
+		}
+		node = &yaml.Node{}
+		if err := node.Encode(v); err != nil {
+			return nil, fmt.Errorf("error converting value to YAML: %w", err)
+		}
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[i]}, node,
+		}}
+	}
+	return node, nil
+}
+
+// encodeYAML writes the document with the indentation of the original payload
+func encodeYAML(doc *yaml.Node, original []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(yamlIndent(original))
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("error encoding YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("error encoding YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func yamlIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if indent := len(line) - len(trimmed); indent > 0 && trimmed != "" && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "- ") {
+			return indent
+		}
+	}
+	return 2
+}
+
+// JSON payloads are edited in place on the located byte ranges, so the rest of the
+// document keeps its formatting
+
+// jsonMember is a key and value of an object, or an item of an array without a key
+type jsonMember struct {
+	key        string
+	keyStart   int
+	valueStart int
+	valueEnd   int
+}
+
+// jsonContainer is an object or array with its members and the position of the brackets
+type jsonContainer struct {
+	object  bool
+	open    int
+	close   int
+	members []jsonMember
+}
+
+func setJSONKey(data []byte, path []string, value string, asJSON bool) ([]byte, error) {
+	container, member, missing, err := locateJSON(data, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if missing == nil {
+		// Replace the value of an existing key
+		raw := data[member.valueStart:member.valueEnd]
+		var encoded []byte
+		switch {
+		case asJSON:
+			encoded = compactJSON(value)
+		case raw[0] != '"' && raw[0] != '{' && raw[0] != '[' && scalarKind(string(raw)) == scalarKind(value):
+			encoded = []byte(value)
+		default:
+			encoded, _ = json.Marshal(value)
+		}
+		return spliceBytes(data, member.valueStart, member.valueEnd, encoded), nil
+	}
+
+	if !container.object {
+		return nil, fmt.Errorf("index '%s' out of range of the list", missing[0])
+	}
+	// Insert the first missing key into the deepest existing object
+	var nested interface{} = value
+	if asJSON {
+		nested = json.RawMessage(compactJSON(value))
+	}
+	for i := len(missing) - 1; i >= 1; i-- {
+		nested = map[string]interface{}{missing[i]: nested}
+	}
+	encodedKey, _ := json.Marshal(missing[0])
+	encodedValue, err := json.Marshal(nested)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding value: %w", err)
+	}
+	entry := string(encodedKey) + ": " + string(encodedValue)
+
+	if len(container.members) == 0 {
+		return spliceBytes(data, container.open+1, container.close, []byte(entry)), nil
+	}
+	last := container.members[len(container.members)-1]
+	separator := ", "
+	if lineStart := bytes.LastIndexByte(data[:last.keyStart], '\n'); lineStart >= 0 && lineStart > container.open {
+		separator = ",\n" + string(data[lineStart+1:last.keyStart])
+	}
+	return spliceBytes(data, last.valueEnd, last.valueEnd, []byte(separator+entry)), nil
+}
+
+func unsetJSONKey(data []byte, path []string) ([]byte, error) {
+	container, member, missing, err := locateJSON(data, path)
+	if err != nil {
+		return nil, err
+	}
+	if missing != nil {
+		return nil, fmt.Errorf("key %s not found", strings.Join(path, "."))
+	}
+
+	index := 0
+	for i, m := range container.members {
+		if m.valueStart == member.valueStart {
+			index = i
+		}
+	}
+	switch {
+	case len(container.members) == 1:
+		return spliceBytes(data, container.open+1, container.close, nil), nil
+	case index > 0:
+		// Remove from the end of the previous value, with the comma
+		return spliceBytes(data, container.members[index-1].valueEnd, member.valueEnd, nil), nil
+	default:
+		return spliceBytes(data, member.keyStart, container.members[1].keyStart, nil), nil
+	}
+}
+
+// locateJSON walks the path and returns the innermost existing container, the member
+// of the last key and the part of the path that does not exist
+func locateJSON(data []byte, path []string) (*jsonContainer, jsonMember, []string, error) {
+	start := skipJSONSpace(data, 0)
+	if start >= len(data) || data[start] != '{' && data[start] != '[' {
+		return nil, jsonMember{}, nil, errors.New("the JSON payload is not an object or array")
+	}
+	container, err := scanJSONContainer(data, start)
+	if err != nil {
+		return nil, jsonMember{}, nil, err
+	}
+
+	for i, segment := range path {
+		member, found := container.find(segment)
+		if !found {
+			return container, jsonMember{}, path[i:], nil
+		}
+		if i == len(path)-1 {
+			return container, member, nil, nil
+		}
+		if c := data[member.valueStart]; c != '{' && c != '[' {
+			return nil, jsonMember{}, nil, fmt.Errorf("'%s' is not an object or array", strings.Join(path[:i+1], "."))
+		}
+		if container, err = scanJSONContainer(data, member.valueStart); err != nil {
+			return nil, jsonMember{}, nil, err
+		}
+	}
+	return nil, jsonMember{}, nil, errors.New("empty key")
+}
+
+func (c *jsonContainer) find(segment string) (jsonMember, bool) {
+	if !c.object {
+		index, err := strconv.Atoi(segment)
+		if err != nil || index < 0 || index >= len(c.members) {
+			return jsonMember{}, false
+		}
+		return c.members[index], true
+	}
+	for _, m := range c.members {
+		if m.key == segment {
+			return m, true
+		}
+	}
+	return jsonMember{}, false
+}
+
+func scanJSONContainer(data []byte, open int) (*jsonContainer, error) {
+	c := &jsonContainer{object: data[open] == '{', open: open}
+	closing := byte(']')
+	if c.object {
+		closing = '}'
+	}
+
+	i := skipJSONSpace(data, open+1)
+	if i < len(data) && data[i] == closing {
+		c.close = i
+		return c, nil
+	}
+	for i < len(data) {
+		m := jsonMember{keyStart: i}
+		if c.object {
+			end, err := scanJSONValue(data, i)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(data[i:end], &m.key); err != nil {
+				return nil, fmt.Errorf("invalid JSON key at offset %d", i)
+			}
+			i = skipJSONSpace(data, end)
+			if i >= len(data) || data[i] != ':' {
+				return nil, fmt.Errorf("expected ':' at offset %d", i)
+			}
+			i = skipJSONSpace(data, i+1)
+		}
+		end, err := scanJSONValue(data, i)
+		if err != nil {
+			return nil, err
+		}
+		m.valueStart, m.valueEnd = i, end
+		c.members = append(c.members, m)
+
+		i = skipJSONSpace(data, end)
+		if i >= len(data) {
+			break
+		}
+		switch data[i] {
+		case ',':
+			i = skipJSONSpace(data, i+1)
+		case closing:
+			c.close = i
+			return c, nil
+		default:
+			return nil, fmt.Errorf("unexpected '%c' at offset %d", data[i], i)
+		}
+	}
+	return nil, errors.New("unexpected end of JSON")
+}
+
+// scanJSONValue returns the end offset of the value starting at i
+func scanJSONValue(data []byte, i int) (int, error) {
+	if i >= len(data) {
+		return 0, errors.New("unexpected end of JSON")
+	}
+	switch data[i] {
+	case '"':
+		for j := i + 1; j < len(data); j++ {
+			switch data[j] {
+			case '\\':
+				j++
+			case '"':
+				return j + 1, nil
+			}
+		}
+		return 0, errors.New("unterminated JSON string")
+	case '{', '[':
+		c, err := scanJSONContainer(data, i)
+		if err != nil {
+			return 0, err
+		}
+		return c.close + 1, nil
+	default:
+		j := i
+		for j < len(data) && !strings.ContainsRune(",}] \t\r\n", rune(data[j])) {
+			j++
+		}
+		if j == i || !json.Valid(data[i:j]) {
+			return 0, fmt.Errorf("invalid JSON value at offset %d", i)
+		}
+		return j, nil
+	}
+}
+
+func skipJSONSpace(data []byte, i int) int {
+	for i < len(data) && strings.ContainsRune(" \t\r\n", rune(data[i])) {
+		i++
+	}
+	return i
+}
+
+func compactJSON(value string) []byte {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(value)); err != nil {
+		return []byte(value)
+	}
+	return buf.Bytes()
+}
+
+func spliceBytes(data []byte, start, end int, insert []byte) []byte {
+	out := make([]byte, 0, len(data)-(end-start)+len(insert))
+	out = append(out, data[:start]...)
+	out = append(out, insert...)
+	return append(out, data[end:]...)
+}
+
+// Env payloads are edited line by line, other lines and comments stay untouched
+
+func setEnvKey(data []byte, key, value string) []byte {
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		name, quote, prefix, ok := envLine(line)
+		if ok && name == key {
+			lines[i] = prefix + key + "=" + formatEnvValue(value, quote)
+			return []byte(strings.Join(lines, "\n"))
+		}
+	}
+
+	entry := key + "=" + formatEnvValue(value, 0)
+	text := string(data)
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return []byte(text + entry + "\n")
+}
+
+func unsetEnvKey(data []byte, key string) ([]byte, error) {
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if name, _, _, ok := envLine(line); ok && name == key {
+			return []byte(strings.Join(append(lines[:i], lines[i+1:]...), "\n")), nil
+		}
+	}
+	return nil, fmt.Errorf("key %s not found", key)
+}
+
+// envLine returns the name of a KEY=VALUE line, the quote of the value and the text
+// before the name, like indentation and export
+func envLine(line string) (string, byte, string, bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return "", 0, "", false
+	}
+	prefix := line[:len(line)-len(trimmed)]
+	if strings.HasPrefix(trimmed, "export ") {
+		prefix += "export "
+		trimmed = strings.TrimPrefix(trimmed, "export ")
+	}
+	name, value, ok := strings.Cut(trimmed, "=")
+	if !ok {
+		return "", 0, "", false
+	}
+	var quote byte
+	if value = strings.TrimSpace(value); value != "" && (value[0] == '"' || value[0] == '\'') {
+		quote = value[0]
+	}
+	return strings.TrimSpace(name), quote, prefix, true
+}
+
+// formatEnvValue quotes the value when the original was quoted or when it needs quotes
+func formatEnvValue(value string, quote byte) string {
+	needsQuotes := value == "" || strings.ContainsAny(value, " \t\n\r#\"'\\$`")
+	switch {
+	case quote == '\'' && !strings.ContainsAny(value, "'\n"):
+		return "'" + value + "'"
+	case quote != 0 || needsQuotes && value != "":
+		return strconv.Quote(value)
+	default:
+		return value
+	}
+}
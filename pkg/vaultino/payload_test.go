@@ -0,0 +1,300 @@
+package vaultino
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileType string
+		data     string
+		key      string
+		expected string
+	}{
+		{"yaml nested", "yaml", "db:\n  password: s3cret\n", "db.password", "s3cret"},
+		{"yaml list item", "yml", "hosts:\n  - db1\n  - db2\n", "hosts.1", "db2"},
+		{"yaml map as json", "yaml", "db:\n  port: 5432\n", "db", `{"port":5432}`},
+		{"json nested", "json", `{"api":{"token":"abc"}}`, "api.token", "abc"},
+		{"json number", "json", `{"port":5432}`, "port", "5432"},
+		{"env", "env", "A=1\nTOKEN=abc\n", "TOKEN", "abc"},
+		{"toml table", "toml", "[db]\npassword = \"s3cret\"\n", "db.password", "s3cret"},
+		{"ini section", "ini", "[db]\npassword = s3cret\n", "db.password", "s3cret"},
+		{"properties dotted key", "properties", "db.password=s3cret\n", "db.password", "s3cret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := Extract([]byte(tt.data), tt.fileType, tt.key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if value != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileType string
+		data     string
+		key      string
+	}{
+		{"missing yaml key", "yaml", "a: 1\n", "b"},
+		{"missing env key", "env", "A=1\n", "B"},
+		{"missing toml key", "toml", "a = 1\n", "b"},
+		{"invalid yaml", "yaml", "a: [\n", "a"},
+		{"binary", TypeBinary, "\x00", "a"},
+		{"no type", "", "a=1", "a"},
+		{"unsupported type", "xml", "<a/>", "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Extract([]byte(tt.data), tt.fileType, tt.key); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestSetKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileType string
+		data     string
+		key      string
+		value    string
+		asJSON   bool
+		expected string
+	}{
+		{
+			name:     "yaml keeps comments",
+			fileType: "yaml",
+			data:     "# database\ndb:\n  user: admin # login\n",
+			key:      "db.password",
+			value:    "s3cret",
+			expected: "# database\ndb:\n  user: admin # login\n  password: s3cret\n",
+		},
+		{
+			name:     "yaml replaces number",
+			fileType: "yaml",
+			data:     "port: 5432\n",
+			key:      "port",
+			value:    "6543",
+			expected: "port: 6543\n",
+		},
+		{
+			name:     "yaml creates parents",
+			fileType: "yaml",
+			data:     "a: 1\n",
+			key:      "b.c",
+			value:    "x",
+			expected: "a: 1\nb:\n  c: x\n",
+		},
+		{
+			name:     "json keeps order",
+			fileType: "json",
+			data:     "{\n  \"b\": \"1\",\n  \"a\": \"2\"\n}\n",
+			key:      "b",
+			value:    "3",
+			expected: "{\n  \"b\": \"3\",\n  \"a\": \"2\"\n}\n",
+		},
+		{
+			name:     "json value",
+			fileType: "json",
+			data:     `{"a":"1"}`,
+			key:      "hosts",
+			value:    `["db1", "db2"]`,
+			asJSON:   true,
+			expected: `{"a":"1", "hosts": ["db1","db2"]}`,
+		},
+		{
+			name:     "env replaces value",
+			fileType: "env",
+			data:     "# comment\nA=1\nB=2\n",
+			key:      "A",
+			value:    "9",
+			expected: "# comment\nA=9\nB=2\n",
+		},
+		{
+			name:     "env appends key",
+			fileType: "env",
+			data:     "A=1\n",
+			key:      "B",
+			value:    "2",
+			expected: "A=1\nB=2\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, err := setKey([]byte(tt.data), tt.fileType, tt.key, tt.value, tt.asJSON)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(updated) != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, updated)
+			}
+		})
+	}
+}
+
+func TestSetKeyErrors(t *testing.T) {
+	if _, err := setKey([]byte("A=1\n"), "env", "a.b", "x", false); err == nil {
+		t.Errorf("expected an error for a nested env key")
+	}
+	if _, err := setKey([]byte("a: 1\n"), "yaml", "a", "{", true); err == nil {
+		t.Errorf("expected an error for invalid JSON")
+	}
+	if _, err := setKey([]byte("a = 1\n"), "toml", "a", "2", false); err == nil {
+		t.Errorf("expected an error for an unsupported file type")
+	}
+}
+
+func TestUnsetKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileType string
+		data     string
+		key      string
+		expected string
+	}{
+		{"yaml", "yaml", "db:\n  user: admin\n  password: s3cret\n", "db.password", "db:\n  user: admin\n"},
+		{"json", "json", `{"a":"1","b":"2"}`, "a", `{"b":"2"}`},
+		{"env", "env", "A=1\nB=2\n", "A", "B=2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, err := unsetKey([]byte(tt.data), tt.fileType, tt.key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(updated) != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, updated)
+			}
+			if _, err := unsetKey(updated, tt.fileType, tt.key); err == nil {
+				t.Errorf("expected an error removing a missing key")
+			}
+		})
+	}
+}
+
+func TestListKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileType string
+		data     string
+		expected []string
+	}{
+		{"yaml sorted", "yaml", "db:\n  user: a\n  hosts: [x, y]\napi: t\n", []string{"api", "db.hosts.0", "db.hosts.1", "db.user"}},
+		{"env in file order", "env", "B=1\n# c\nexport A=2\n", []string{"B", "A"}},
+		{"ini", "ini", "top=1\n[db]\nuser=a\n", []string{"db.user", "top"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := listKeys([]byte(tt.data), tt.fileType)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(keys, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, keys)
+			}
+		})
+	}
+}
+
+func TestSplitKeyPath(t *testing.T) {
+	tests := map[string][]string{
+		"a":        {"a"},
+		"a.b.c":    {"a", "b", "c"},
+		`a\.b.c`:   {"a.b", "c"},
+		"hosts.0":  {"hosts", "0"},
+		`tls\.crt`: {"tls.crt"},
+	}
+	for key, expected := range tests {
+		path, err := splitKeyPath(key)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", key, err)
+		}
+		if !reflect.DeepEqual(path, expected) {
+			t.Errorf("%s: expected %v, got %v", key, expected, path)
+		}
+	}
+	for _, key := range []string{"", "a..b", "a."} {
+		if _, err := splitKeyPath(key); err == nil {
+			t.Errorf("%q: expected an error", key)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		fileType string
+		data     string
+		valid    bool
+	}{
+		{"yaml", "a: 1\n", true},
+		{"yaml", "a: [\n", false},
+		{"json", `{"a":1}`, true},
+		{"json", `{"a":`, false},
+		{"toml", "a = 1\n", true},
+		{"toml", "a = \n", false},
+		{"ini", "[db]\nuser=a\n", true},
+		{"ini", "[db\n", false},
+		{"env", "anything", true},
+		{TypeBinary, "\x00", true},
+	}
+	for _, tt := range tests {
+		err := Validate([]byte(tt.data), tt.fileType)
+		if tt.valid && err != nil {
+			t.Errorf("%s %q: unexpected error: %v", tt.fileType, tt.data, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s %q: expected an error", tt.fileType, tt.data)
+		}
+	}
+}
+
+func TestVaultValues(t *testing.T) {
+	v := &Vault{header: Header{Type: "yaml"}, data: []byte("db:\n  user: admin\n  hosts: [a, b]\n")}
+
+	values, err := v.Values()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{"db.user": "admin", "db.hosts.0": "a", "db.hosts.1": "b"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+
+	flat, err := v.Flatten("_")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if flat["db_user"] != "admin" {
+		t.Errorf("expected db_user=admin, got %v", flat)
+	}
+
+	value, ok, err := v.Lookup("db.hosts")
+	if err != nil || !ok {
+		t.Fatalf("expected db.hosts to exist, got %v %v", ok, err)
+	}
+	if !reflect.DeepEqual(value, []interface{}{"a", "b"}) {
+		t.Errorf("expected [a b], got %v", value)
+	}
+	if _, ok, _ := v.Lookup("db.missing"); ok {
+		t.Errorf("expected db.missing not to exist")
+	}
+
+	env := &Vault{header: Header{Type: "env"}, data: []byte("spring.datasource.url=\"jdbc:x\"\n")}
+	value, ok, _ = env.Lookup("spring.datasource.url")
+	if !ok || value != "jdbc:x" {
+		t.Errorf("expected the dotted env key to be found unquoted, got %v %v", value, ok)
+	}
+	if keys, _ := env.Keys(); strings.Join(keys, ",") != "spring.datasource.url" {
+		t.Errorf("expected the env key, got %v", keys)
+	}
+}
@@ -0,0 +1,577 @@
+package vaultino
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/ssh"
+)
+
+// Stanza types of wrapped data keys
+const (
+	stanzaX25519     = "X25519"
+	stanzaSSHEd25519 = "ssh-ed25519"
+)
+
+// HKDF labels of the wrapping keys. X25519 stanzas are wrapped exactly as age does, ssh-ed25519
+// stanzas skip age's tweak of the shared secret, so they use a label of their own and never
+// pass for age stanzas.
+const (
+	labelX25519     = "age-encryption.org/v1/X25519"
+	labelSSHEd25519 = "vaultino/v1/ssh-ed25519"
+)
+
+const (
+	x25519RecipientHRP = "age"
+	x25519IdentityHRP  = "AGE-SECRET-KEY-"
+	dataKeySize        = 32
+)
+
+var b64 = base64.RawStdEncoding
+
+// stanza is the data key wrapped for one recipient, stored as a header line
+// "-> <type> <recipient> <ephemeral share> <wrapped key>"
+type stanza struct {
+	kind      string
+	recipient string
+	ephemeral []byte
+	wrapped   []byte
+}
+
+func (s stanza) String() string {
+	return fmt.Sprintf("-> %s %s %s %s", s.kind, s.recipient, b64.EncodeToString(s.ephemeral), b64.EncodeToString(s.wrapped))
+}
+
+func parseStanza(line string) (stanza, error) {
+	fields := strings.Fields(strings.TrimPrefix(line, "->"))
+	if len(fields) != 4 {
+		return stanza{}, fmt.Errorf("invalid recipient line %q", line)
+	}
+	ephemeral, err := b64.DecodeString(fields[2])
+	if err != nil {
+		return stanza{}, fmt.Errorf("invalid recipient line %q: %w", line, err)
+	}
+	wrapped, err := b64.DecodeString(fields[3])
+	if err != nil {
+		return stanza{}, fmt.Errorf("invalid recipient line %q: %w", line, err)
+	}
+	return stanza{kind: fields[0], recipient: fields[1], ephemeral: ephemeral, wrapped: wrapped}, nil
+}
+
+// Recipient is a public key the data key of a vault is wrapped for
+type Recipient interface {
+	// String returns the key as accepted by ParseRecipient
+	String() string
+	wrap(dataKey []byte) (stanza, error)
+}
+
+// Identity is a private key unwrapping the data key of the stanzas of its recipient
+type Identity interface {
+	Recipient() Recipient
+	unwrap(s stanza) ([]byte, error)
+	secret() []byte
+}
+
+type x25519Recipient struct {
+	key *ecdh.PublicKey
+}
+
+func (r *x25519Recipient) String() string {
+	encoded, _ := bech32Encode(x25519RecipientHRP, r.key.Bytes())
+	return encoded
+}
+
+func (r *x25519Recipient) wrap(dataKey []byte) (stanza, error) {
+	ephemeral, wrapped, err := wrapX25519(r.key, dataKey, labelX25519)
+	if err != nil {
+		return stanza{}, err
+	}
+	return stanza{kind: stanzaX25519, recipient: r.String(), ephemeral: ephemeral, wrapped: wrapped}, nil
+}
+
+type x25519Identity struct {
+	key *ecdh.PrivateKey
+}
+
+func (i *x25519Identity) Recipient() Recipient {
+	return &x25519Recipient{key: i.key.PublicKey()}
+}
+
+func (i *x25519Identity) String() string {
+	encoded, _ := bech32Encode(x25519IdentityHRP, i.key.Bytes())
+	return strings.ToUpper(encoded)
+}
+
+func (i *x25519Identity) secret() []byte {
+	return i.key.Bytes()
+}
+
+func (i *x25519Identity) unwrap(s stanza) ([]byte, error) {
+	return unwrapX25519(i.key, s, labelX25519)
+}
+
+// sshRecipient wraps for the X25519 equivalent of an ed25519 SSH key, the stanza keeps
+// the SSH key so the vault can be rewrapped for it
+type sshRecipient struct {
+	sshKey ssh.PublicKey
+	key    *ecdh.PublicKey
+}
+
+func (r *sshRecipient) String() string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(r.sshKey)))
+}
+
+func (r *sshRecipient) wrap(dataKey []byte) (stanza, error) {
+	ephemeral, wrapped, err := wrapX25519(r.key, dataKey, labelSSHEd25519)
+	if err != nil {
+		return stanza{}, err
+	}
+	return stanza{
+		kind:      stanzaSSHEd25519,
+		recipient: b64.EncodeToString(r.sshKey.Marshal()),
+		ephemeral: ephemeral,
+		wrapped:   wrapped,
+	}, nil
+}
+
+type sshIdentity struct {
+	recipient *sshRecipient
+	key       *ecdh.PrivateKey
+}
+
+func (i *sshIdentity) Recipient() Recipient {
+	return i.recipient
+}
+
+func (i *sshIdentity) secret() []byte {
+	return i.key.Bytes()
+}
+
+func (i *sshIdentity) unwrap(s stanza) ([]byte, error) {
+	return unwrapX25519(i.key, s, labelSSHEd25519)
+}
+
+// wrapX25519 encrypts the data key with a key agreed between a fresh ephemeral key and the
+// recipient, derived with HKDF-SHA256 from the shared secret as age does
+func wrapX25519(recipient *ecdh.PublicKey, dataKey []byte, label string) ([]byte, []byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generating ephemeral key: %w", err)
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error agreeing on wrapping key: %w", err)
+	}
+	share := ephemeral.PublicKey().Bytes()
+	wrapped, err := sealWrapKey(shared, share, recipient.Bytes(), label, dataKey)
+	return share, wrapped, err
+}
+
+func unwrapX25519(identity *ecdh.PrivateKey, s stanza, label string) ([]byte, error) {
+	share, err := ecdh.X25519().NewPublicKey(s.ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral share: %w", err)
+	}
+	shared, err := identity.ECDH(share)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := append(slices.Clone(s.ephemeral), identity.PublicKey().Bytes()...)
+	wrapKey, err := hkdf.Key(sha256.New, shared, salt, label, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), s.wrapped, nil)
+}
+
+func sealWrapKey(shared, share, recipient []byte, label string, dataKey []byte) ([]byte, error) {
+	salt := append(slices.Clone(share), recipient...)
+	wrapKey, err := hkdf.Key(sha256.New, shared, salt, label, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	// Every wrapping key is used once, so the zero nonce is safe
+	return aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), dataKey, nil), nil
+}
+
+// ParseRecipient parses an age X25519 recipient (age1...) or an ed25519 SSH public key
+// (ssh-ed25519 AAAA... [comment])
+func ParseRecipient(s string) (Recipient, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "ssh-") {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSH public key: %w", err)
+		}
+		return newSSHRecipient(key)
+	}
+
+	hrp, data, err := bech32Decode(s)
+	if err != nil || hrp != x25519RecipientHRP {
+		return nil, fmt.Errorf("invalid recipient %q, expected an age1... or ssh-ed25519 key", s)
+	}
+	key, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid X25519 recipient: %w", err)
+	}
+	return &x25519Recipient{key: key}, nil
+}
+
+func newSSHRecipient(key ssh.PublicKey) (*sshRecipient, error) {
+	if key.Type() != ssh.KeyAlgoED25519 {
+		return nil, fmt.Errorf("unsupported SSH key type %s, only ssh-ed25519 keys can be recipients", key.Type())
+	}
+	cryptoKey, ok := key.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, errors.New("unsupported SSH key")
+	}
+	edKey, ok := cryptoKey.CryptoPublicKey().(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("unsupported SSH key")
+	}
+	montgomery, err := ed25519ToX25519(edKey)
+	if err != nil {
+		return nil, err
+	}
+	x25519Key, err := ecdh.X25519().NewPublicKey(montgomery)
+	if err != nil {
+		return nil, err
+	}
+	return &sshRecipient{sshKey: key, key: x25519Key}, nil
+}
+
+// ed25519ToX25519 maps an Edwards point to the Montgomery u coordinate, u = (1+y)/(1-y)
+func ed25519ToX25519(key ed25519.PublicKey) ([]byte, error) {
+	p := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	littleEndian := slices.Clone([]byte(key))
+	littleEndian[31] &= 0x7f
+	slices.Reverse(littleEndian)
+	y := new(big.Int).SetBytes(littleEndian)
+
+	denominator := new(big.Int).Sub(big.NewInt(1), y)
+	denominator.Mod(denominator, p)
+	if denominator.Sign() == 0 {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+	u := new(big.Int).Add(big.NewInt(1), y)
+	u.Mul(u, denominator.ModInverse(denominator, p))
+	u.Mod(u, p)
+
+	out := make([]byte, 32)
+	u.FillBytes(out)
+	slices.Reverse(out)
+	return out, nil
+}
+
+// recipientFromStanza restores the recipient a stanza was wrapped for
+func recipientFromStanza(s stanza) (Recipient, error) {
+	switch s.kind {
+	case stanzaX25519:
+		return ParseRecipient(s.recipient)
+	case stanzaSSHEd25519:
+		wire, err := b64.DecodeString(s.recipient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSH recipient: %w", err)
+		}
+		key, err := ssh.ParsePublicKey(wire)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSH recipient: %w", err)
+		}
+		return newSSHRecipient(key)
+	}
+	return nil, fmt.Errorf("unsupported recipient type %s", s.kind)
+}
+
+// matchesRecipient reports whether the stanza belongs to the recipient given as key or as
+// SSH fingerprint
+func matchesRecipient(s stanza, recipient string) bool {
+	r, err := recipientFromStanza(s)
+	if err != nil {
+		return false
+	}
+	if sshKey, ok := r.(*sshRecipient); ok && ssh.FingerprintSHA256(sshKey.sshKey) == recipient {
+		return true
+	}
+	if parsed, err := ParseRecipient(recipient); err == nil {
+		return parsed.String() == r.String()
+	}
+	return false
+}
+
+// describeStanza returns the recipient type and key, SSH keys with their fingerprint
+func describeStanza(s stanza) string {
+	r, err := recipientFromStanza(s)
+	if err != nil {
+		return fmt.Sprintf("%s (invalid: %v)", s.kind, err)
+	}
+	if sshKey, ok := r.(*sshRecipient); ok {
+		return fmt.Sprintf("%s %s", ssh.FingerprintSHA256(sshKey.sshKey), r.String())
+	}
+	return r.String()
+}
+
+// ReadRecipientsFile reads one recipient per line, empty lines and # comments are skipped
+func ReadRecipientsFile(path string) ([]Recipient, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading recipients file: %w", err)
+	}
+	var recipients []Recipient
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		recipient, err := ParseRecipient(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// GenerateIdentity returns a new X25519 identity (AGE-SECRET-KEY-1...) and its recipient
+func GenerateIdentity() (string, string, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("error generating key: %w", err)
+	}
+	identity := &x25519Identity{key: key}
+	return identity.String(), identity.Recipient().String(), nil
+}
+
+// PassphraseFunc returns the passphrase of an encrypted SSH key
+type PassphraseFunc func() ([]byte, error)
+
+// LoadIdentityFile reads an age identity file or an OpenSSH ed25519 private key, see
+// ParseIdentities
+func LoadIdentityFile(path string, passphrase PassphraseFunc) ([]Identity, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading identity file: %w", err)
+	}
+	identities, err := ParseIdentities(content, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return identities, nil
+}
+
+// ParseIdentities parses age identities (AGE-SECRET-KEY-1... lines) or an OpenSSH ed25519
+// private key, the passphrase of encrypted SSH keys is asked for when passphrase is not nil
+func ParseIdentities(content []byte, passphrase PassphraseFunc) ([]Identity, error) {
+	if bytes.Contains(content, []byte("PRIVATE KEY-----")) {
+		identity, err := parseSSHIdentity(content, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return []Identity{identity}, nil
+	}
+
+	var identities []Identity
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hrp, data, err := bech32Decode(line)
+		if err != nil || hrp != strings.ToLower(x25519IdentityHRP) {
+			return nil, errors.New("invalid identity, expected AGE-SECRET-KEY-1... lines")
+		}
+		key, err := ecdh.X25519().NewPrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid X25519 identity: %w", err)
+		}
+		identities = append(identities, &x25519Identity{key: key})
+	}
+	return identities, nil
+}
+
+func parseSSHIdentity(content []byte, passphrase PassphraseFunc) (Identity, error) {
+	raw, err := ssh.ParseRawPrivateKey(content)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && passphrase != nil {
+		secret, readErr := passphrase()
+		if readErr != nil {
+			return nil, fmt.Errorf("error reading passphrase: %w", readErr)
+		}
+		raw, err = ssh.ParseRawPrivateKeyWithPassphrase(content, secret)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH private key: %w", err)
+	}
+
+	var edKey ed25519.PrivateKey
+	switch k := raw.(type) {
+	case ed25519.PrivateKey:
+		edKey = k
+	case *ed25519.PrivateKey:
+		edKey = *k
+	default:
+		return nil, fmt.Errorf("unsupported SSH key type %T, only ed25519 keys can be identities", raw)
+	}
+
+	publicKey, err := ssh.NewPublicKey(edKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	recipient, err := newSSHRecipient(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	// The X25519 scalar of an ed25519 key is the clamped first half of the hashed seed
+	digest := sha512.Sum512(edKey.Seed())
+	key, err := ecdh.X25519().NewPrivateKey(digest[:32])
+	if err != nil {
+		return nil, err
+	}
+	return &sshIdentity{recipient: recipient, key: key}, nil
+}
+
+// unwrapDataKey returns the data key from the first stanza an identity can open and the
+// identity that opened it
+func unwrapDataKey(stanzas []stanza, identities []Identity) ([]byte, Identity, error) {
+	if len(identities) == 0 {
+		return nil, nil, ErrNoIdentity
+	}
+	for _, identity := range identities {
+		recipient := identity.Recipient().String()
+		for _, s := range stanzas {
+			if !matchesRecipient(s, recipient) {
+				continue
+			}
+			if dataKey, err := identity.unwrap(s); err == nil && len(dataKey) == dataKeySize {
+				return dataKey, identity, nil
+			}
+		}
+	}
+	return nil, nil, errors.New("none of the identities is a recipient of the vault")
+}
+
+// wrapDataKey wraps the data key for every recipient, duplicates are skipped
+func wrapDataKey(dataKey []byte, recipients []Recipient) ([]stanza, error) {
+	var stanzas []stanza
+	seen := make(map[string]bool)
+	for _, recipient := range recipients {
+		if seen[recipient.String()] {
+			continue
+		}
+		seen[recipient.String()] = true
+		s, err := recipient.wrap(dataKey)
+		if err != nil {
+			return nil, err
+		}
+		stanzas = append(stanzas, s)
+	}
+	return stanzas, nil
+}
+
+// readRecipientVault reads a vault and checks that it is encrypted for recipients
+func readRecipientVault(path string) (*file, error) {
+	f, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if f.hdr.Mode != ModeRecipients {
+		return nil, errors.New("the vault is password protected, recreate it with recipients to use them")
+	}
+	return f, nil
+}
+
+// ListRecipients returns the recipients of the vault, SSH keys with their fingerprint
+func ListRecipients(path string) ([]string, error) {
+	f, err := readRecipientVault(path)
+	if err != nil {
+		return nil, err
+	}
+	recipients := make([]string, 0, len(f.stanzas))
+	for _, s := range f.stanzas {
+		recipients = append(recipients, describeStanza(s))
+	}
+	return recipients, nil
+}
+
+// AddRecipients unwraps the data key with one of the identities of the keys and wraps it
+// for the new recipients, the payload stays as it is. It returns the number of added
+// recipients.
+func AddRecipients(path string, recipients []Recipient, keys KeyProvider) (int, error) {
+	f, err := readRecipientVault(path)
+	if err != nil {
+		return 0, err
+	}
+	identities, err := keys.Identities()
+	if err != nil {
+		return 0, err
+	}
+	dataKey, _, err := unwrapDataKey(f.stanzas, identities)
+	if err != nil {
+		return 0, err
+	}
+
+	var added []Recipient
+	for _, recipient := range recipients {
+		exists := slices.ContainsFunc(f.stanzas, func(s stanza) bool { return matchesRecipient(s, recipient.String()) })
+		if !exists {
+			added = append(added, recipient)
+		}
+	}
+	stanzas, err := wrapDataKey(dataKey, added)
+	if err != nil {
+		return 0, err
+	}
+	if len(stanzas) == 0 {
+		return 0, nil
+	}
+	f.stanzas = append(f.stanzas, stanzas...)
+	return len(stanzas), f.write(path)
+}
+
+// RemoveRecipients drops the wrapped data keys of the recipients, given as key or SSH
+// fingerprint. Removed recipients who kept a copy of the data key can still decrypt the
+// current payload, the next save encrypts it with a new data key.
+func RemoveRecipients(path string, recipients []string) (int, error) {
+	f, err := readRecipientVault(path)
+	if err != nil {
+		return 0, err
+	}
+	remaining := slices.DeleteFunc(slices.Clone(f.stanzas), func(s stanza) bool {
+		return slices.ContainsFunc(recipients, func(r string) bool { return matchesRecipient(s, strings.TrimSpace(r)) })
+	})
+	removed := len(f.stanzas) - len(remaining)
+	if removed == 0 {
+		return 0, nil
+	}
+	if len(remaining) == 0 {
+		return 0, errors.New("refusing to remove the last recipient, nobody could decrypt the vault")
+	}
+	// The header line stays as it is because it describes the unchanged payload and is
+	// authenticated by it
+	f.stanzas = remaining
+	return removed, f.write(path)
+}
@@ -0,0 +1,254 @@
+package vaultino
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// newSSHKey returns an OpenSSH ed25519 private key, encrypted when the passphrase is set,
+// and its authorized_keys line
+func newSSHKey(t *testing.T, passphrase string) ([]byte, string) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(private, "test")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(private, "test", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatalf("failed to convert public key: %v", err)
+	}
+	return pem.EncodeToMemory(block), string(ssh.MarshalAuthorizedKey(sshPublic))
+}
+
+func TestGenerateIdentity(t *testing.T) {
+	secret, public, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	if !strings.HasPrefix(secret, "AGE-SECRET-KEY-1") || !strings.HasPrefix(public, "age1") {
+		t.Fatalf("unexpected identity %s... or recipient %s", secret[:16], public)
+	}
+
+	identities, err := ParseIdentities([]byte("# created: now\n# public key: "+public+"\n"+secret+"\n"), nil)
+	if err != nil {
+		t.Fatalf("failed to parse identity: %v", err)
+	}
+	if len(identities) != 1 || identities[0].Recipient().String() != public {
+		t.Errorf("expected the identity of %s, got %v", public, identities)
+	}
+
+	recipient, err := ParseRecipient(public)
+	if err != nil {
+		t.Fatalf("failed to parse recipient: %v", err)
+	}
+	if recipient.String() != public {
+		t.Errorf("expected %s, got %s", public, recipient)
+	}
+}
+
+func TestParseRecipientErrors(t *testing.T) {
+	rsa := "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAAgQDC6v3R test"
+	for _, invalid := range []string{"", "age1invalid", "AGE-SECRET-KEY-1QQQQ", "ssh-ed25519 !!!", rsa} {
+		if _, err := ParseRecipient(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
+
+func TestSSHRecipient(t *testing.T) {
+	private, authorizedKey := newSSHKey(t, "")
+	recipient, err := ParseRecipient(authorizedKey)
+	if err != nil {
+		t.Fatalf("failed to parse SSH recipient: %v", err)
+	}
+	identities, err := ParseIdentities(private, nil)
+	if err != nil {
+		t.Fatalf("failed to parse SSH identity: %v", err)
+	}
+	if identities[0].Recipient().String() != recipient.String() {
+		t.Errorf("expected recipient %s, got %s", recipient, identities[0].Recipient())
+	}
+
+	content, err := Encrypt(strings.NewReader("A=1\n"), Recipients{recipient})
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	if !bytes.Contains(content, []byte("-> ssh-ed25519 ")) {
+		t.Errorf("expected an ssh-ed25519 stanza, got:\n%s", content)
+	}
+	decrypted, err := Decrypt(bytes.NewReader(content), Identities(identities))
+	if err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+	if string(decrypted) != "A=1\n" {
+		t.Errorf("expected A=1, got %q", decrypted)
+	}
+}
+
+func TestSSHRecipient_NotAgeLabel(t *testing.T) {
+	private, _ := newSSHKey(t, "")
+	identities, err := ParseIdentities(private, nil)
+	if err != nil {
+		t.Fatalf("failed to parse SSH identity: %v", err)
+	}
+	identity := identities[0].(*sshIdentity)
+	dataKey := bytes.Repeat([]byte{1}, dataKeySize)
+	s, err := identity.recipient.wrap(dataKey)
+	if err != nil {
+		t.Fatalf("failed to wrap: %v", err)
+	}
+	if _, err := unwrapX25519(identity.key, s, "age-encryption.org/v1/ssh-ed25519"); err == nil {
+		t.Error("expected the stanza not to unwrap with the age label")
+	}
+	unwrapped, err := identity.unwrap(s)
+	if err != nil {
+		t.Fatalf("failed to unwrap: %v", err)
+	}
+	if !bytes.Equal(unwrapped, dataKey) {
+		t.Errorf("expected data key %x, got %x", dataKey, unwrapped)
+	}
+}
+
+func TestEncryptedSSHIdentity(t *testing.T) {
+	private, _ := newSSHKey(t, "secret")
+
+	if _, err := ParseIdentities(private, nil); err == nil {
+		t.Errorf("expected an error without a passphrase")
+	}
+	asked := 0
+	identities, err := ParseIdentities(private, func() ([]byte, error) {
+		asked++
+		return []byte("secret"), nil
+	})
+	if err != nil {
+		t.Fatalf("failed to parse encrypted SSH identity: %v", err)
+	}
+	if asked != 1 || len(identities) != 1 {
+		t.Errorf("expected 1 identity after asking once, got %d after %d", len(identities), asked)
+	}
+
+	readErr := errors.New("no terminal")
+	if _, err := ParseIdentities(private, func() ([]byte, error) { return nil, readErr }); !errors.Is(err, readErr) {
+		t.Errorf("expected the passphrase error, got %v", err)
+	}
+}
+
+func TestLoadIdentityFile(t *testing.T) {
+	dir := t.TempDir()
+	first, _, _ := GenerateIdentity()
+	second, _, _ := GenerateIdentity()
+	path := filepath.Join(dir, "identity.txt")
+	if err := os.WriteFile(path, []byte(first+"\n\n# second\n"+second+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write identity file: %v", err)
+	}
+	identities, err := LoadIdentityFile(path, nil)
+	if err != nil {
+		t.Fatalf("failed to load identities: %v", err)
+	}
+	if len(identities) != 2 {
+		t.Errorf("expected 2 identities, got %d", len(identities))
+	}
+
+	invalid := filepath.Join(dir, "invalid.txt")
+	if err := os.WriteFile(invalid, []byte("not a key\n"), 0600); err != nil {
+		t.Fatalf("failed to write identity file: %v", err)
+	}
+	if _, err := LoadIdentityFile(invalid, nil); err == nil || !strings.Contains(err.Error(), invalid) {
+		t.Errorf("expected an error naming the file, got %v", err)
+	}
+	if _, err := LoadIdentityFile(filepath.Join(dir, "missing.txt"), nil); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
+
+func TestReadRecipientsFile(t *testing.T) {
+	_, first, _ := GenerateIdentity()
+	_, authorizedKey := newSSHKey(t, "")
+	path := filepath.Join(t.TempDir(), "recipients.txt")
+	if err := os.WriteFile(path, []byte("# team\n"+first+"\n\n"+authorizedKey), 0600); err != nil {
+		t.Fatalf("failed to write recipients file: %v", err)
+	}
+	recipients, err := ReadRecipientsFile(path)
+	if err != nil {
+		t.Fatalf("failed to read recipients: %v", err)
+	}
+	if len(recipients) != 2 || recipients[0].String() != first || !strings.HasPrefix(recipients[1].String(), "ssh-ed25519 ") {
+		t.Errorf("unexpected recipients %v", recipients)
+	}
+}
+
+func TestRemoveRecipientByFingerprint(t *testing.T) {
+	alice := newTestIdentity(t)
+	_, authorizedKey := newSSHKey(t, "")
+	bob, err := ParseRecipient(authorizedKey)
+	if err != nil {
+		t.Fatalf("failed to parse SSH recipient: %v", err)
+	}
+	path := createVault(t, t.TempDir(), "app.env", "A=1\n", Recipients{alice.Recipient(), bob})
+
+	sshKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+	if err != nil {
+		t.Fatalf("failed to parse authorized key: %v", err)
+	}
+	fingerprint := ssh.FingerprintSHA256(sshKey)
+	recipients, err := ListRecipients(path)
+	if err != nil {
+		t.Fatalf("failed to list recipients: %v", err)
+	}
+	if !strings.HasPrefix(recipients[1], fingerprint+" ssh-ed25519 ") {
+		t.Errorf("expected the SSH recipient with its fingerprint, got %s", recipients[1])
+	}
+
+	removed, err := RemoveRecipients(path, []string{fingerprint})
+	if err != nil {
+		t.Fatalf("failed to remove recipient: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 removed recipient, got %d", removed)
+	}
+}
+
+func TestBech32RoundTrip(t *testing.T) {
+	data := []byte{0, 1, 2, 0xfe, 0xff}
+	encoded, err := bech32Encode("test", data)
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	hrp, decoded, err := bech32Decode(encoded)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if hrp != "test" || !bytes.Equal(decoded, data) {
+		t.Errorf("expected test %x, got %s %x", data, hrp, decoded)
+	}
+	corrupted := []byte(encoded)
+	if corrupted[len(corrupted)-1] == 'q' {
+		corrupted[len(corrupted)-1] = 'p'
+	} else {
+		corrupted[len(corrupted)-1] = 'q'
+	}
+	if _, _, err := bech32Decode(string(corrupted)); err == nil {
+		t.Errorf("expected a checksum error")
+	}
+	if _, _, err := bech32Decode(strings.ToUpper(encoded)); err != nil {
+		t.Errorf("expected an upper case string to decode, got %v", err)
+	}
+}
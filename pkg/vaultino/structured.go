@@ -0,0 +1,844 @@
+package vaultino
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseTree parses the payload into nested maps and lists. Env and Java properties files
+// are flat maps, INI sections are nested by their dotted names like TOML tables.
+func parseTree(data []byte, fileType string) (interface{}, error) {
+	switch fileType {
+	case "yaml", "yml":
+		var tree interface{}
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, fmt.Errorf("error parsing YAML: %w", err)
+		}
+		return tree, nil
+	case "json":
+		var tree interface{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&tree); err != nil {
+			return nil, fmt.Errorf("error parsing JSON: %w", err)
+		}
+		return tree, nil
+	case "env":
+		tree := map[string]interface{}{}
+		for _, pair := range parseEnvPairs(data) {
+			tree[pair.name] = pair.value
+		}
+		return tree, nil
+	case "toml":
+		tree, err := parseTOML(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing TOML: %w", err)
+		}
+		return tree, nil
+	case "ini":
+		tree, err := parseINI(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing INI: %w", err)
+		}
+		return tree, nil
+	case "properties":
+		return parseProperties(data), nil
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", fileType)
+	}
+}
+
+// formatValue returns scalars as text and maps and lists as JSON, like Get does for JSON
+// payloads
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case map[string]interface{}, []interface{}:
+		out, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("error serializing value: %w", err)
+		}
+		return string(out), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// lookupValue follows the key path like Get, list items are addressed by index
+func lookupValue(tree interface{}, key string) (interface{}, bool, error) {
+	// Env keys are never nested and may contain dots
+	if env, ok := tree.(map[string]interface{}); ok {
+		if value, ok := env[key]; ok {
+			return value, true, nil
+		}
+	}
+	path, err := splitKeyPath(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	current := tree
+	for _, segment := range path {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false, nil
+			}
+			current = value
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false, nil
+			}
+			current = node[i]
+		default:
+			return nil, false, nil
+		}
+	}
+	return current, true, nil
+}
+
+// flattenValue stores scalars under their key path, list items use their index
+func flattenValue(values map[string]string, path string, value interface{}, separator string) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + separator + key
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flattenValue(values, join(key), child, separator)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenValue(values, join(strconv.Itoa(i)), child, separator)
+		}
+	case nil:
+		if path != "" {
+			values[path] = ""
+		}
+	default:
+		if path != "" {
+			values[path] = fmt.Sprint(v)
+		}
+	}
+}
+
+type envPair struct {
+	name  string
+	value string
+}
+
+// parseEnvPairs parses KEY=VALUE lines, comments, blank lines and export prefixes are
+// skipped and quotes around the value are removed
+func parseEnvPairs(data []byte) []envPair {
+	var pairs []envPair
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		pairs = append(pairs, envPair{name: strings.TrimSpace(key), value: unquoteEnvValue(strings.TrimSpace(value))})
+	}
+	return pairs
+}
+
+func unquoteEnvValue(value string) string {
+	if len(value) >= 2 {
+		switch {
+		case value[0] == '"' && value[len(value)-1] == '"':
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				return unquoted
+			}
+			return value[1 : len(value)-1]
+		case value[0] == '\'' && value[len(value)-1] == '\'':
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
+
+// parseINI parses "key = value" lines in [section] blocks, ";" and "#" start comments
+func parseINI(data []byte) (map[string]interface{}, error) {
+	root := map[string]interface{}{}
+	current := root
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated section", n+1)
+			}
+			var err error
+			if current, err = iniSection(root, strings.TrimSpace(line[1:end])); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			continue
+		}
+
+		sep := strings.IndexAny(line, "=:")
+		if sep < 0 {
+			// A key without a value, e.g. a flag in my.cnf
+			current[line] = ""
+			continue
+		}
+		key := strings.TrimSpace(line[:sep])
+		if key == "" {
+			return nil, fmt.Errorf("line %d: missing key", n+1)
+		}
+		current[key] = iniValue(strings.TrimSpace(line[sep+1:]))
+	}
+	return root, nil
+}
+
+func iniSection(root map[string]interface{}, name string) (map[string]interface{}, error) {
+	if name == "" {
+		return nil, errors.New("empty section name")
+	}
+	current := root
+	for _, part := range strings.Split(name, ".") {
+		part = strings.TrimSpace(part)
+		child, ok := current[part].(map[string]interface{})
+		if !ok {
+			if _, exists := current[part]; exists {
+				return nil, fmt.Errorf("section %s conflicts with the key %s", name, part)
+			}
+			child = map[string]interface{}{}
+			current[part] = child
+		}
+		current = child
+	}
+	return current, nil
+}
+
+// iniValue removes quotes, or an inline comment after whitespace from unquoted values
+func iniValue(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	for i := 1; i < len(value); i++ {
+		if (value[i] == ';' || value[i] == '#') && (value[i-1] == ' ' || value[i-1] == '\t') {
+			return strings.TrimSpace(value[:i])
+		}
+	}
+	return value
+}
+
+// parseProperties parses a Java properties file, keys keep their dots
+func parseProperties(data []byte) map[string]interface{} {
+	values := map[string]interface{}{}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// A line ending with an odd number of backslashes continues on the next line
+		for continuesLine(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+
+		end := 0
+		for end < len(line) {
+			c := line[end]
+			if c == '\\' {
+				end += 2
+				continue
+			}
+			if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+				break
+			}
+			end++
+		}
+		end = min(end, len(line))
+		key := line[:end]
+		rest := strings.TrimLeft(line[end:], " \t\f")
+		if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+			rest = strings.TrimLeft(rest[1:], " \t\f")
+		}
+		values[unescapeProperty(key)] = unescapeProperty(rest)
+	}
+	return values
+}
+
+func continuesLine(line string) bool {
+	backslashes := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		backslashes++
+	}
+	return backslashes%2 == 1
+}
+
+func unescapeProperty(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 < len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// parseTOML parses a TOML document, dates and times are kept as strings
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	if !utf8.Valid(data) {
+		return nil, errors.New("invalid UTF-8")
+	}
+	p := &tomlParser{src: strings.ReplaceAll(string(data), "\r\n", "\n"), line: 1}
+	root := map[string]interface{}{}
+	current := root
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return root, nil
+		}
+
+		var err error
+		if p.peek() == '[' {
+			current, err = p.table(root)
+		} else {
+			err = p.keyValue(current)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.line, err)
+		}
+
+		p.skipSpace()
+		p.skipComment()
+		if !p.eof() && p.peek() != '\n' {
+			return nil, fmt.Errorf("line %d: unexpected %q after value", p.line, p.peek())
+		}
+	}
+}
+
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *tomlParser) peek() byte {
+	return p.src[p.pos]
+}
+
+func (p *tomlParser) next() byte {
+	c := p.src[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	if !p.eof() && p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank skips whitespace, newlines and comments
+func (p *tomlParser) skipBlank() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\n', '\r':
+			p.next()
+		case '#':
+			p.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) expect(s string) error {
+	if !strings.HasPrefix(p.src[p.pos:], s) {
+		return fmt.Errorf("expected %q", s)
+	}
+	p.pos += len(s)
+	return nil
+}
+
+// table parses a [table] or [[array of tables]] header and returns the table
+func (p *tomlParser) table(root map[string]interface{}) (map[string]interface{}, error) {
+	array := strings.HasPrefix(p.src[p.pos:], "[[")
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+	p.skipSpace()
+	path, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if err := p.expect(closing); err != nil {
+		return nil, err
+	}
+
+	parent, err := tomlDescend(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	if array {
+		table := map[string]interface{}{}
+		switch existing := parent[last].(type) {
+		case nil:
+			parent[last] = []interface{}{table}
+		case []interface{}:
+			parent[last] = append(existing, table)
+		default:
+			return nil, fmt.Errorf("key %s is not an array of tables", strings.Join(path, "."))
+		}
+		return table, nil
+	}
+	return tomlDescend(parent, []string{last})
+}
+
+// tomlDescend returns the table at the path, creating missing tables, arrays of tables
+// continue in their last table
+func tomlDescend(table map[string]interface{}, path []string) (map[string]interface{}, error) {
+	for _, key := range path {
+		switch child := table[key].(type) {
+		case nil:
+			next := map[string]interface{}{}
+			table[key] = next
+			table = next
+		case map[string]interface{}:
+			table = child
+		case []interface{}:
+			if len(child) == 0 {
+				return nil, fmt.Errorf("key %s is not a table", key)
+			}
+			last, ok := child[len(child)-1].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("key %s is not a table", key)
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("key %s is already defined as a value", key)
+		}
+	}
+	return table, nil
+}
+
+func (p *tomlParser) keyValue(table map[string]interface{}) error {
+	path, err := p.key()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	p.skipSpace()
+	value, err := p.value()
+	if err != nil {
+		return err
+	}
+
+	parent, err := tomlDescend(table, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	last := path[len(path)-1]
+	if _, exists := parent[last]; exists {
+		return fmt.Errorf("duplicate key %s", strings.Join(path, "."))
+	}
+	parent[last] = value
+	return nil
+}
+
+// key parses a dotted key of bare and quoted parts
+func (p *tomlParser) key() ([]string, error) {
+	var path []string
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, errors.New("missing key")
+		}
+		var part string
+		switch c := p.peek(); {
+		case c == '"':
+			s, err := p.basicString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		case c == '\'':
+			s, err := p.literalString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, fmt.Errorf("invalid key character %q", c)
+			}
+			part = p.src[start:p.pos]
+		}
+		path = append(path, part)
+
+		p.skipSpace()
+		if p.eof() || p.peek() != '.' {
+			return path, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *tomlParser) value() (interface{}, error) {
+	if p.eof() {
+		return nil, errors.New("missing value")
+	}
+	rest := p.src[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, `"""`):
+		return p.multilineBasicString()
+	case strings.HasPrefix(rest, "'''"):
+		return p.multilineLiteralString()
+	case rest[0] == '"':
+		return p.basicString()
+	case rest[0] == '\'':
+		return p.literalString()
+	case rest[0] == '[':
+		return p.array()
+	case rest[0] == '{':
+		return p.inlineTable()
+	case strings.HasPrefix(rest, "true") && !p.bareAt(4):
+		p.pos += 4
+		return true, nil
+	case strings.HasPrefix(rest, "false") && !p.bareAt(5):
+		p.pos += 5
+		return false, nil
+	default:
+		return p.scalar()
+	}
+}
+
+// bareAt reports whether the character at the offset continues a bare token
+func (p *tomlParser) bareAt(offset int) bool {
+	i := p.pos + offset
+	return i < len(p.src) && isBareKeyChar(p.src[i])
+}
+
+func (p *tomlParser) basicString() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", errors.New("unterminated string")
+		}
+		c := p.next()
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if err := p.escape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+func (p *tomlParser) multilineBasicString() (string, error) {
+	p.pos += 3
+	// A newline right after the opening quotes is trimmed
+	if !p.eof() && p.peek() == '\n' {
+		p.next()
+	}
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", errors.New("unterminated multi-line string")
+		}
+		if strings.HasPrefix(p.src[p.pos:], `"""`) {
+			// Up to two quotes may end the content right before the closing quotes
+			for strings.HasPrefix(p.src[p.pos+1:], `"""`) {
+				b.WriteByte('"')
+				p.pos++
+			}
+			p.pos += 3
+			return b.String(), nil
+		}
+		c := p.next()
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		// A line ending backslash trims the following whitespace and newlines
+		if i := strings.IndexFunc(p.src[p.pos:], func(r rune) bool { return r != ' ' && r != '\t' }); i >= 0 && p.src[p.pos+i] == '\n' {
+			for !p.eof() && strings.IndexByte(" \t\n", p.peek()) >= 0 {
+				p.next()
+			}
+			continue
+		}
+		if err := p.escape(&b); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (p *tomlParser) escape(b *strings.Builder) error {
+	if p.eof() {
+		return errors.New("unterminated escape")
+	}
+	c := p.next()
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case 'e':
+		b.WriteByte(0x1b)
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.src) {
+			return errors.New("invalid unicode escape")
+		}
+		r, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return errors.New("invalid unicode escape")
+		}
+		b.WriteRune(rune(r))
+		p.pos += size
+	default:
+		return fmt.Errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
+
+func (p *tomlParser) literalString() (string, error) {
+	p.pos++
+	end := strings.IndexAny(p.src[p.pos:], "'\n")
+	if end < 0 || p.src[p.pos+end] != '\'' {
+		return "", errors.New("unterminated string")
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+func (p *tomlParser) multilineLiteralString() (string, error) {
+	p.pos += 3
+	if !p.eof() && p.peek() == '\n' {
+		p.next()
+	}
+	end := strings.Index(p.src[p.pos:], "'''")
+	if end < 0 {
+		return "", errors.New("unterminated multi-line string")
+	}
+	// Up to two quotes may end the content right before the closing quotes
+	for extra := 0; extra < 2 && p.pos+end+3 < len(p.src) && p.src[p.pos+end+3] == '\''; extra++ {
+		end++
+	}
+	s := p.src[p.pos : p.pos+end]
+	for range strings.Count(s, "\n") {
+		p.line++
+	}
+	p.pos += end + 3
+	return s, nil
+}
+
+func (p *tomlParser) array() ([]interface{}, error) {
+	p.pos++
+	values := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil, errors.New("unterminated array")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return values, nil
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		p.skipBlank()
+		if p.eof() {
+			return nil, errors.New("unterminated array")
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, fmt.Errorf("unexpected %q in array", p.peek())
+		}
+	}
+}
+
+func (p *tomlParser) inlineTable() (map[string]interface{}, error) {
+	p.pos++
+	table := map[string]interface{}{}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil, errors.New("unterminated inline table")
+		}
+		if p.peek() == '}' {
+			p.pos++
+			return table, nil
+		}
+		if err := p.keyValue(table); err != nil {
+			return nil, err
+		}
+
+		p.skipBlank()
+		if p.eof() {
+			return nil, errors.New("unterminated inline table")
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+		default:
+			return nil, fmt.Errorf("unexpected %q in inline table", p.peek())
+		}
+	}
+}
+
+// scalar parses numbers, dates and times
+func (p *tomlParser) scalar() (interface{}, error) {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\n\r,]}#", p.peek()) < 0 {
+		p.pos++
+	}
+	// A space may separate the date and the time of a date-time
+	if p.pos-start == 10 && strings.Count(p.src[start:p.pos], "-") == 2 &&
+		p.pos+1 < len(p.src) && p.src[p.pos] == ' ' && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9' {
+		p.pos++
+		for !p.eof() && strings.IndexByte(" \t\n\r,]}#", p.peek()) < 0 {
+			p.pos++
+		}
+	}
+	token := p.src[start:p.pos]
+	if token == "" {
+		return nil, fmt.Errorf("invalid value starting with %q", p.src[start:min(start+1, len(p.src))])
+	}
+
+	switch strings.TrimLeft(token, "+-") {
+	case "inf":
+		if token[0] == '-' {
+			return math.Inf(-1), nil
+		}
+		return math.Inf(1), nil
+	case "nan":
+		return math.NaN(), nil
+	}
+	if isTOMLDateTime(token) {
+		return token, nil
+	}
+
+	digits := strings.ReplaceAll(token, "_", "")
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0o") || strings.HasPrefix(digits, "0b") {
+		if n, err := strconv.ParseInt(digits, 0, 64); err == nil {
+			return n, nil
+		}
+	}
+	if n, err := strconv.ParseInt(digits, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(digits, 64); err == nil && !strings.ContainsAny(digits, "xXpP") {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid value %s", token)
+}
+
+func isTOMLDateTime(token string) bool {
+	dateLike := len(token) >= 10 && token[4] == '-' && token[7] == '-'
+	timeLike := len(token) >= 8 && token[2] == ':' && token[5] == ':'
+	return dateLike || timeLike
+}
@@ -0,0 +1,140 @@
+package vaultino
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	data := `# comment
+title = "app"
+port = 8080
+ratio = 0.5
+enabled = true
+started = 2024-01-02T03:04:05Z
+hosts = ["db1", 'db2']
+path = 'C:\tmp'
+text = """
+line1
+line2"""
+
+[db]
+user = "admin" # inline comment
+"quoted.key" = 1
+inline = { a = 1, b = "x" }
+
+[db.replica]
+host = "r1"
+
+[[servers]]
+name = "a"
+
+[[servers]]
+name = "b"
+`
+	tree, err := parseTOML([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]interface{}{
+		"title":   "app",
+		"port":    int64(8080),
+		"ratio":   0.5,
+		"enabled": true,
+		"started": "2024-01-02T03:04:05Z",
+		"hosts":   []interface{}{"db1", "db2"},
+		"path":    `C:\tmp`,
+		"text":    "line1\nline2",
+		"db": map[string]interface{}{
+			"user":       "admin",
+			"quoted.key": int64(1),
+			"inline":     map[string]interface{}{"a": int64(1), "b": "x"},
+			"replica":    map[string]interface{}{"host": "r1"},
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"name": "a"},
+			map[string]interface{}{"name": "b"},
+		},
+	}
+	if !reflect.DeepEqual(tree, expected) {
+		t.Errorf("expected %#v, got %#v", expected, tree)
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := map[string]string{
+		"duplicate key":     "a = 1\na = 2\n",
+		"missing value":     "a = \n",
+		"unterminated":      "a = \"x\n",
+		"key is not table":  "a = 1\n[a]\n",
+		"garbage after key": "a = 1 2\n",
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseTOML([]byte(data)); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseINI(t *testing.T) {
+	data := `; comment
+top = 1
+[db]
+user = admin ; inline comment
+password = "se;cret"
+skip-networking
+[db.replica]
+host: r1
+`
+	tree, err := parseINI([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]interface{}{
+		"top": "1",
+		"db": map[string]interface{}{
+			"user":            "admin",
+			"password":        "se;cret",
+			"skip-networking": "",
+			"replica":         map[string]interface{}{"host": "r1"},
+		},
+	}
+	if !reflect.DeepEqual(tree, expected) {
+		t.Errorf("expected %v, got %v", expected, tree)
+	}
+
+	for _, invalid := range []string{"[db\n", "[]\n", "= value\n", "db = 1\n[db]\n"} {
+		if _, err := parseINI([]byte(invalid)); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
+
+func TestParseProperties(t *testing.T) {
+	data := "# comment\n! also a comment\ndb.url=jdbc:x\ndb.user : admin\nkey value\nmulti = a,\\\n    b\nescaped\\ key=\\u0041\\tB\n"
+	expected := map[string]interface{}{
+		"db.url":      "jdbc:x",
+		"db.user":     "admin",
+		"key":         "value",
+		"multi":       "a,b",
+		"escaped key": "A\tB",
+	}
+	if tree := parseProperties([]byte(data)); !reflect.DeepEqual(tree, expected) {
+		t.Errorf("expected %v, got %v", expected, tree)
+	}
+}
+
+func TestParseEnvPairs(t *testing.T) {
+	data := "# comment\nA=1\nexport B=\"two\\nlines\"\nC='single'\n\nD = spaced\nnot a pair\n"
+	expected := []envPair{
+		{name: "A", value: "1"},
+		{name: "B", value: "two\nlines"},
+		{name: "C", value: "single"},
+		{name: "D", value: "spaced"},
+	}
+	if pairs := parseEnvPairs([]byte(data)); !reflect.DeepEqual(pairs, expected) {
+		t.Errorf("expected %v, got %v", expected, pairs)
+	}
+}
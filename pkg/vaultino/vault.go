@@ -0,0 +1,404 @@
+// Package vaultino reads and writes Vaultino encrypted files. A vault is sealed with a
+// key derived from a password or with a data key wrapped for age X25519 and SSH ed25519
+// recipients, the keys come from a KeyProvider.
+package vaultino
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Options describe the payload of a new vault
+type Options struct {
+	// FileName of the original file, the header records its base name and extension
+	FileName string
+	Format   Format
+	// Binary keeps the payload as opaque bytes with the original file name, payloads that
+	// are not UTF-8 text always are
+	Binary bool
+}
+
+// Vault is a decrypted vault. Changes are kept in memory until Save seals the payload
+// again with a new salt and nonce, or a new data key for the same recipients.
+type Vault struct {
+	path   string
+	header Header
+	data   []byte
+	keys   KeyProvider
+	// password of password vaults, asked from the keys when a new vault is saved
+	password   []byte
+	recipients []Recipient
+	// identity that opened a recipient vault, it keys the value fingerprints
+	identity       Identity
+	fingerprintKey []byte
+}
+
+// New returns a vault at the path holding the data, it is written by Save. The vault is
+// encrypted for the recipients of the keys, or with their password when there are none.
+func New(path string, data []byte, keys KeyProvider, opts Options) (*Vault, error) {
+	recipients, err := keys.Recipients()
+	if err != nil {
+		return nil, err
+	}
+	mode := ModePassword
+	if len(recipients) > 0 {
+		mode = ModeRecipients
+	}
+
+	var name, fileType string
+	if opts.FileName != "" {
+		ext := filepath.Ext(opts.FileName)
+		name = strings.TrimSuffix(filepath.Base(opts.FileName), ext)
+		fileType = strings.TrimPrefix(ext, ".")
+	}
+	header, err := opts.Format.apply(newHeader(name, fileType, mode))
+	if err != nil {
+		return nil, err
+	}
+	if opts.Binary || !isText(data) {
+		header.Type = TypeBinary
+		if opts.FileName != "" {
+			header.File = filepath.Base(opts.FileName)
+		}
+	}
+	return &Vault{path: path, header: header, data: data, keys: keys, recipients: recipients}, nil
+}
+
+// Open reads and decrypts the vault
+func Open(path string, keys KeyProvider) (*Vault, error) {
+	f, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return unlock(path, f, keys)
+}
+
+// Parse decrypts the vault content, Save needs the path set with SetPath
+func Parse(content []byte, keys KeyProvider) (*Vault, error) {
+	f, err := parseFile(content)
+	if err != nil {
+		return nil, err
+	}
+	return unlock("", f, keys)
+}
+
+// Encrypt seals the data read from r with the keys and returns the vault content
+func Encrypt(r io.Reader, keys KeyProvider) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading data: %w", err)
+	}
+	v, err := New("", data, keys, Options{})
+	if err != nil {
+		return nil, err
+	}
+	return v.Bytes()
+}
+
+// Decrypt returns the payload of the vault content read from r
+func Decrypt(r io.Reader, keys KeyProvider) ([]byte, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading vault: %w", err)
+	}
+	v, err := Parse(content, keys)
+	if err != nil {
+		return nil, err
+	}
+	return v.Data(), nil
+}
+
+func unlock(path string, f *file, keys KeyProvider) (*Vault, error) {
+	v := &Vault{path: path, header: f.hdr, keys: keys}
+
+	if f.hdr.Mode == ModeRecipients {
+		// Recipient vaults are sealed again for the same recipients with a new data key
+		for _, s := range f.stanzas {
+			recipient, err := recipientFromStanza(s)
+			if err != nil {
+				return nil, err
+			}
+			v.recipients = append(v.recipients, recipient)
+		}
+		identities, err := keys.Identities()
+		if err != nil {
+			return nil, err
+		}
+		dataKey, identity, err := unwrapDataKey(f.stanzas, identities)
+		if err != nil {
+			return nil, err
+		}
+		v.identity = identity
+		if v.data, err = f.open(dataKey); err != nil {
+			return nil, errors.New("decryption failed: corrupted data")
+		}
+		return v, nil
+	}
+
+	password, err := keys.Password(path, false)
+	if err != nil {
+		return nil, fmt.Errorf("error reading password: %w", err)
+	}
+	if v.data, err = f.open(deriveKey(password, f.salt, f.hdr.KDF)); err != nil {
+		return nil, ErrDecrypt
+	}
+	v.password = password
+	return v, nil
+}
+
+// Path returns the file the vault is saved to
+func (v *Vault) Path() string {
+	return v.path
+}
+
+// SetPath changes the file the vault is saved to
+func (v *Vault) SetPath(path string) {
+	v.path = path
+}
+
+// Header returns the header the vault was read with, or will be written with when new
+func (v *Vault) Header() Header {
+	return v.header
+}
+
+// Type returns the type of the original file, e.g. yaml, json, env or binary
+func (v *Vault) Type() string {
+	return v.header.Type
+}
+
+// Data returns the payload
+func (v *Vault) Data() []byte {
+	return v.data
+}
+
+// SetData replaces the payload
+func (v *Vault) SetData(data []byte) {
+	v.data = data
+}
+
+// Recipients returns the recipients of recipient vaults
+func (v *Vault) Recipients() []Recipient {
+	return v.recipients
+}
+
+// SetPassword changes the password of a password vault
+func (v *Vault) SetPassword(password []byte) error {
+	if v.header.Mode != ModePassword {
+		return errors.New("the vault is encrypted for recipients, it has no password")
+	}
+	if len(password) == 0 {
+		return errors.New("empty password")
+	}
+	v.password = password
+	v.fingerprintKey = nil
+	return nil
+}
+
+// Bytes seals the payload and returns the vault content
+func (v *Vault) Bytes() ([]byte, error) {
+	if v.header.Mode == ModePassword && v.password == nil {
+		password, err := v.keys.Password(v.path, true)
+		if err != nil {
+			return nil, fmt.Errorf("error reading password: %w", err)
+		}
+		v.password = password
+	}
+	header := v.header.renew()
+	f, err := seal(header, v.data, v.password, v.recipients)
+	if err != nil {
+		return nil, err
+	}
+	v.header = header
+	return f.encode(), nil
+}
+
+// Save seals the payload in a current header and replaces the vault file atomically
+func (v *Vault) Save() error {
+	if v.path == "" {
+		return errors.New("the vault has no path")
+	}
+	content, err := v.Bytes()
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(v.path, content)
+}
+
+// file is a vault as stored: the header line, the wrapped data keys of recipient vaults
+// and the base64 payload of salt (password vaults), nonce and ciphertext
+type file struct {
+	// header is the header line as written, the parsed fields are in hdr
+	header     string
+	hdr        Header
+	stanzas    []stanza
+	salt       []byte
+	nonce      []byte
+	ciphertext []byte
+}
+
+// aad returns the additional data authenticating the header of 2.0 vaults
+func (f *file) aad() []byte {
+	if !f.hdr.Authenticated() {
+		return nil
+	}
+	return []byte(f.header)
+}
+
+// open decrypts the payload with the key, the header of 2.0 vaults has to be intact
+func (f *file) open(key []byte) ([]byte, error) {
+	aead, err := newAEAD(f.hdr.Cipher, key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, f.nonce, f.ciphertext, f.aad())
+}
+
+// seal encrypts the plaintext under the header, with a key derived from the password or
+// with a new data key wrapped for the recipients
+func seal(header Header, plaintext, password []byte, recipients []Recipient) (*file, error) {
+	f := &file{header: header.String(), hdr: header}
+	var key []byte
+	if header.Mode == ModeRecipients {
+		key = make([]byte, dataKeySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("error generating data key: %w", err)
+		}
+		var err error
+		if f.stanzas, err = wrapDataKey(key, recipients); err != nil {
+			return nil, err
+		}
+		if len(f.stanzas) == 0 {
+			return nil, errors.New("no recipients")
+		}
+	} else {
+		f.salt = make([]byte, saltSize)
+		if _, err := rand.Read(f.salt); err != nil {
+			return nil, fmt.Errorf("error generating salt: %w", err)
+		}
+		key = deriveKey(password, f.salt, header.KDF)
+	}
+
+	aead, err := newAEAD(header.Cipher, key)
+	if err != nil {
+		return nil, err
+	}
+	f.nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(f.nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+	f.ciphertext = aead.Seal(nil, f.nonce, plaintext, f.aad())
+	return f, nil
+}
+
+func readFile(path string) (*file, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading vault file: %w", err)
+	}
+	return parseFile(content)
+}
+
+func parseFile(content []byte) (*file, error) {
+	parts := strings.SplitN(string(content), "\n", 2)
+	if len(parts) < 2 {
+		return nil, errors.New("invalid vault format")
+	}
+	hdr, err := parseHeader(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	f := &file{header: strings.TrimRight(parts[0], "\r"), hdr: hdr}
+
+	// Recipient vaults list the wrapped data keys between the header and the payload
+	body := parts[1]
+	for strings.HasPrefix(body, "-> ") {
+		line, rest, _ := strings.Cut(body, "\n")
+		s, err := parseStanza(line)
+		if err != nil {
+			return nil, err
+		}
+		f.stanzas = append(f.stanzas, s)
+		body = rest
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(body))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding base64 content: %w", err)
+	}
+
+	nonceLen := nonceSize(hdr.Cipher)
+	if hdr.Mode == ModeRecipients {
+		if len(f.stanzas) == 0 || len(data) < nonceLen+tagSize {
+			return nil, errors.New("invalid encrypted data")
+		}
+		f.nonce, f.ciphertext = data[:nonceLen], data[nonceLen:]
+		return f, nil
+	}
+
+	if len(data) < saltSize+nonceLen+tagSize {
+		return nil, errors.New("invalid encrypted data")
+	}
+	f.salt = data[:saltSize]
+	f.nonce = data[saltSize : saltSize+nonceLen]
+	f.ciphertext = data[saltSize+nonceLen:]
+	return f, nil
+}
+
+func (f *file) encode() []byte {
+	data := append(append(bytes.Clone(f.salt), f.nonce...), f.ciphertext...)
+
+	var b strings.Builder
+	b.WriteString(f.header + "\n")
+	for _, s := range f.stanzas {
+		b.WriteString(s.String() + "\n")
+	}
+	b.WriteString(base64.StdEncoding.EncodeToString(data))
+	return []byte(b.String())
+}
+
+func (f *file) write(path string) error {
+	return WriteFileAtomic(path, f.encode())
+}
+
+// WriteFileAtomic writes the file with mode 0600 next to the target and renames it over
+// the target, so a crash leaves either the old or the new content. Vaults are saved with
+// it, callers use it for decrypted payloads and backups.
+func WriteFileAtomic(filename string, data []byte) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	tmpName := tmpFile.Name()
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("error replacing file: %w", err)
+	}
+	return nil
+}
+
+// isText reports whether the data is UTF-8 text without NUL bytes
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
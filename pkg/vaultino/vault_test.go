@@ -0,0 +1,518 @@
+package vaultino
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fastFormat keeps argon2id cheap, the default parameters take a noticeable time per vault
+var fastFormat = Format{KDF: KDFParams{Time: 1, Memory: 64, Threads: 1}}
+
+func newTestIdentity(t *testing.T) Identity {
+	t.Helper()
+	secret, _, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	identities, err := ParseIdentities([]byte(secret), nil)
+	if err != nil {
+		t.Fatalf("failed to parse identity: %v", err)
+	}
+	return identities[0]
+}
+
+// createVault writes a vault of the file content to dir and returns its path
+func createVault(t *testing.T, dir, fileName, content string, keys KeyProvider) string {
+	t.Helper()
+	path := filepath.Join(dir, strings.TrimSuffix(fileName, filepath.Ext(fileName))+".vault")
+	v, err := New(path, []byte(content), keys, Options{FileName: fileName, Format: fastFormat})
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatalf("failed to save vault: %v", err)
+	}
+	return path
+}
+
+func TestEncryptDecryptPassword(t *testing.T) {
+	plaintext := []byte("db:\n  password: s3cret\n")
+	content, err := Encrypt(bytes.NewReader(plaintext), Password("pw"))
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	if bytes.Contains(content, []byte("s3cret")) {
+		t.Fatalf("the vault contains the plaintext")
+	}
+
+	decrypted, err := Decrypt(bytes.NewReader(content), Password("pw"))
+	if err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("expected %q, got %q", plaintext, decrypted)
+	}
+
+	if _, err := Decrypt(bytes.NewReader(content), Password("wrong")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt for a wrong password, got %v", err)
+	}
+	if _, err := Encrypt(bytes.NewReader(plaintext), Password(nil)); !errors.Is(err, ErrNoPassword) {
+		t.Errorf("expected ErrNoPassword for an empty password, got %v", err)
+	}
+}
+
+func TestEncryptDecryptRecipients(t *testing.T) {
+	alice, bob := newTestIdentity(t), newTestIdentity(t)
+	plaintext := []byte("TOKEN=abc\n")
+
+	content, err := Encrypt(bytes.NewReader(plaintext), Recipients{alice.Recipient(), bob.Recipient()})
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	for name, identity := range map[string]Identity{"alice": alice, "bob": bob} {
+		decrypted, err := Decrypt(bytes.NewReader(content), Identities{identity})
+		if err != nil {
+			t.Fatalf("%s failed to decrypt: %v", name, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("%s: expected %q, got %q", name, plaintext, decrypted)
+		}
+	}
+
+	if _, err := Decrypt(bytes.NewReader(content), Identities{newTestIdentity(t)}); err == nil {
+		t.Errorf("expected an error for an identity that is no recipient")
+	}
+	if _, err := Decrypt(bytes.NewReader(content), Identities{}); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("expected ErrNoIdentity without identities, got %v", err)
+	}
+	if _, err := Decrypt(bytes.NewReader(content), Password("pw")); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("expected ErrNoIdentity for a password, got %v", err)
+	}
+}
+
+func TestPasswordFunc(t *testing.T) {
+	var asked []bool
+	keys := PasswordFunc(func(path string, confirm bool) ([]byte, error) {
+		asked = append(asked, confirm)
+		return []byte("pw"), nil
+	})
+	path := createVault(t, t.TempDir(), "app.env", "A=1\n", keys)
+	if _, err := Open(path, keys); err != nil {
+		t.Fatalf("failed to open vault: %v", err)
+	}
+	if len(asked) != 2 || !asked[0] || asked[1] {
+		t.Errorf("expected a confirmed password for the new vault and a plain one to open it, got %v", asked)
+	}
+}
+
+func TestHeaderTampering(t *testing.T) {
+	for _, cipherName := range []string{CipherAESGCM, CipherXChaCha20Poly1305} {
+		t.Run(cipherName, func(t *testing.T) {
+			v, err := New("", []byte("A=1\n"), Password("pw"), Options{FileName: "app.env", Format: Format{Cipher: cipherName, KDF: fastFormat.KDF}})
+			if err != nil {
+				t.Fatalf("failed to create vault: %v", err)
+			}
+			content, err := v.Bytes()
+			if err != nil {
+				t.Fatalf("failed to seal vault: %v", err)
+			}
+			if !strings.HasPrefix(string(content), "$VAULTINO;2.0;"+cipherName+";") {
+				t.Fatalf("unexpected header: %s", strings.SplitN(string(content), "\n", 2)[0])
+			}
+			if _, err := Parse(content, Password("pw")); err != nil {
+				t.Fatalf("failed to parse vault: %v", err)
+			}
+
+			tampered := bytes.Replace(content, []byte("type=env"), []byte("type=yml"), 1)
+			if _, err := Parse(tampered, Password("pw")); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("expected ErrDecrypt for a changed header, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenSetSave(t *testing.T) {
+	dir := t.TempDir()
+	path := createVault(t, dir, "app.yaml", "db:\n  # admin account\n  user: admin\n", Password("pw"))
+
+	v, err := Open(path, Password("pw"))
+	if err != nil {
+		t.Fatalf("failed to open vault: %v", err)
+	}
+	if v.Type() != "yaml" || v.Header().FileName() != "app.yaml" {
+		t.Errorf("expected type yaml of app.yaml, got %s of %s", v.Type(), v.Header().FileName())
+	}
+	if err := v.Set("db.password", "s3cret"); err != nil {
+		t.Fatalf("failed to set key: %v", err)
+	}
+	if err := v.SetJSON("db.hosts", `["db1","db2"]`); err != nil {
+		t.Fatalf("failed to set JSON key: %v", err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatalf("failed to save vault: %v", err)
+	}
+
+	// The password is kept, saving does not ask for it again
+	reopened, err := Open(path, Password("pw"))
+	if err != nil {
+		t.Fatalf("failed to reopen vault: %v", err)
+	}
+	for key, expected := range map[string]string{"db.user": "admin", "db.password": "s3cret", "db.hosts.1": "db2"} {
+		value, err := reopened.Get(key)
+		if err != nil {
+			t.Fatalf("failed to get %s: %v", key, err)
+		}
+		if value != expected {
+			t.Errorf("expected %s=%s, got %s", key, expected, value)
+		}
+	}
+	if !strings.Contains(string(reopened.Data()), "# admin account") {
+		t.Errorf("expected the comment to be kept, got:\n%s", reopened.Data())
+	}
+	if _, err := reopened.Get("db.missing"); err == nil {
+		t.Errorf("expected an error for a missing key")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat vault: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestSetPassword(t *testing.T) {
+	path := createVault(t, t.TempDir(), "app.env", "A=1\n", Password("old"))
+	v, err := Open(path, Password("old"))
+	if err != nil {
+		t.Fatalf("failed to open vault: %v", err)
+	}
+	if err := v.SetPassword(nil); err == nil {
+		t.Errorf("expected an error for an empty password")
+	}
+	if err := v.SetPassword([]byte("new")); err != nil {
+		t.Fatalf("failed to set password: %v", err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatalf("failed to save vault: %v", err)
+	}
+
+	if _, err := Open(path, Password("old")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt for the old password, got %v", err)
+	}
+	if _, err := Open(path, Password("new")); err != nil {
+		t.Errorf("failed to open vault with the new password: %v", err)
+	}
+
+	identity := newTestIdentity(t)
+	recipientVault := createVault(t, t.TempDir(), "app.env", "A=1\n", Identities{identity})
+	rv, err := Open(recipientVault, Identities{identity})
+	if err != nil {
+		t.Fatalf("failed to open recipient vault: %v", err)
+	}
+	if err := rv.SetPassword([]byte("pw")); err == nil {
+		t.Errorf("expected an error setting the password of a recipient vault")
+	}
+}
+
+func TestRecipientVaultSave(t *testing.T) {
+	alice, bob := newTestIdentity(t), newTestIdentity(t)
+	path := createVault(t, t.TempDir(), "app.env", "A=1\n", Recipients{alice.Recipient(), bob.Recipient()})
+
+	v, err := Open(path, Identities{alice})
+	if err != nil {
+		t.Fatalf("failed to open vault: %v", err)
+	}
+	if len(v.Recipients()) != 2 {
+		t.Fatalf("expected 2 recipients, got %d", len(v.Recipients()))
+	}
+	if err := v.Set("B", "2"); err != nil {
+		t.Fatalf("failed to set key: %v", err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatalf("failed to save vault: %v", err)
+	}
+
+	// Both recipients keep access with the new data key
+	reopened, err := Open(path, Identities{bob})
+	if err != nil {
+		t.Fatalf("bob failed to open vault: %v", err)
+	}
+	if value, _ := reopened.Get("B"); value != "2" {
+		t.Errorf("expected B=2, got %s", value)
+	}
+}
+
+func TestBinaryVault(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte{0x30, 0x82, 0x00, 0xff, 0xfe}
+	path := filepath.Join(dir, "keystore.vault")
+	v, err := New(path, data, Password("pw"), Options{FileName: "certs/keystore.p12", Format: fastFormat})
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatalf("failed to save vault: %v", err)
+	}
+
+	opened, err := Open(path, Password("pw"))
+	if err != nil {
+		t.Fatalf("failed to open vault: %v", err)
+	}
+	if opened.Type() != TypeBinary {
+		t.Errorf("expected type %s, got %s", TypeBinary, opened.Type())
+	}
+	if name := opened.Header().FileName(); name != "keystore.p12" {
+		t.Errorf("expected file name keystore.p12, got %s", name)
+	}
+	if !bytes.Equal(opened.Data(), data) {
+		t.Errorf("expected %x, got %x", data, opened.Data())
+	}
+	if _, err := opened.Get("key"); err == nil {
+		t.Errorf("expected an error getting a key of a binary vault")
+	}
+
+	// Text is stored as binary on request
+	text, err := New("", []byte("plain"), Password("pw"), Options{FileName: "notes.txt", Binary: true})
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+	if text.Type() != TypeBinary || text.Header().FileName() != "notes.txt" {
+		t.Errorf("expected binary notes.txt, got %s %s", text.Type(), text.Header().FileName())
+	}
+}
+
+func TestVersion1Compatibility(t *testing.T) {
+	header := Header{Version: version1, Cipher: CipherAESGCM, Mode: ModePassword, KDF: DefaultKDF, Name: "app", Type: "env", User: "alice", Time: "2024-01-01T00:00:00Z"}
+	f, err := seal(header, []byte("A=1\n"), []byte("pw"), nil)
+	if err != nil {
+		t.Fatalf("failed to seal vault: %v", err)
+	}
+	content := f.encode()
+	if !strings.HasPrefix(string(content), "$VAULTINO;1.2;AES256-GCM;argon2id;name=app;type=env;") {
+		t.Fatalf("unexpected header: %s", strings.SplitN(string(content), "\n", 2)[0])
+	}
+
+	v, err := Parse(content, Password("pw"))
+	if err != nil {
+		t.Fatalf("failed to parse 1.2 vault: %v", err)
+	}
+	if value, _ := v.Get("A"); value != "1" {
+		t.Errorf("expected A=1, got %s", value)
+	}
+	if v.Header().Authenticated() {
+		t.Errorf("expected the 1.2 header not to be authenticated")
+	}
+
+	// Saving writes the current format
+	resealed, err := v.Bytes()
+	if err != nil {
+		t.Fatalf("failed to seal vault: %v", err)
+	}
+	if !strings.HasPrefix(string(resealed), "$VAULTINO;2.0;") {
+		t.Errorf("expected a 2.0 header, got %s", strings.SplitN(string(resealed), "\n", 2)[0])
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]string{
+		"empty":          "",
+		"no payload":     "$VAULTINO;2.0;AES256-GCM;argon2id;t=1;m=64;p=1;name=a;type=env;user=;time=",
+		"no magic":       "VAULT;2.0;AES256-GCM;argon2id\nAAAA",
+		"version":        "$VAULTINO;3.0;AES256-GCM;argon2id\nAAAA",
+		"cipher":         "$VAULTINO;2.0;DES;argon2id;t=1;m=64;p=1\nAAAA",
+		"mode":           "$VAULTINO;2.0;AES256-GCM;scrypt\nAAAA",
+		"missing kdf":    "$VAULTINO;2.0;AES256-GCM;argon2id;name=a;type=env\nAAAA",
+		"too much kdf":   "$VAULTINO;2.0;AES256-GCM;argon2id;t=1;m=99999999;p=1\nAAAA",
+		"base64":         "$VAULTINO;2.0;AES256-GCM;argon2id;t=1;m=64;p=1\n!!!",
+		"short payload":  "$VAULTINO;2.0;AES256-GCM;argon2id;t=1;m=64;p=1\nAAAA",
+		"no recipients":  "$VAULTINO;2.0;AES256-GCM;recipients;name=a;type=env\nAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+		"invalid stanza": "$VAULTINO;2.0;AES256-GCM;recipients\n-> X25519\nAAAA",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse([]byte(content), Password("pw")); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestSaveWithoutPath(t *testing.T) {
+	v, err := New("", []byte("A=1\n"), Password("pw"), Options{Format: fastFormat})
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+	if err := v.Save(); err == nil {
+		t.Errorf("expected an error saving a vault without a path")
+	}
+	path := filepath.Join(t.TempDir(), "a.vault")
+	v.SetPath(path)
+	if err := v.Save(); err != nil {
+		t.Fatalf("failed to save vault: %v", err)
+	}
+	if v.Path() != path {
+		t.Errorf("expected path %s, got %s", path, v.Path())
+	}
+}
+
+func TestRecipientsManagement(t *testing.T) {
+	alice, bob := newTestIdentity(t), newTestIdentity(t)
+	path := createVault(t, t.TempDir(), "app.env", "A=1\n", Recipients{alice.Recipient()})
+
+	if _, err := Open(path, Identities{bob}); err == nil {
+		t.Fatalf("expected bob to have no access yet")
+	}
+	added, err := AddRecipients(path, []Recipient{bob.Recipient(), alice.Recipient()}, Identities{alice})
+	if err != nil {
+		t.Fatalf("failed to add recipients: %v", err)
+	}
+	if added != 1 {
+		t.Errorf("expected 1 added recipient, got %d", added)
+	}
+	if _, err := Open(path, Identities{bob}); err != nil {
+		t.Errorf("bob failed to open vault: %v", err)
+	}
+
+	recipients, err := ListRecipients(path)
+	if err != nil {
+		t.Fatalf("failed to list recipients: %v", err)
+	}
+	expected := []string{alice.Recipient().String(), bob.Recipient().String()}
+	if strings.Join(recipients, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, recipients)
+	}
+
+	removed, err := RemoveRecipients(path, []string{alice.Recipient().String()})
+	if err != nil {
+		t.Fatalf("failed to remove recipients: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 removed recipient, got %d", removed)
+	}
+	if _, err := Open(path, Identities{alice}); err == nil {
+		t.Errorf("expected alice to lose access")
+	}
+	if _, err := RemoveRecipients(path, []string{bob.Recipient().String()}); err == nil {
+		t.Errorf("expected an error removing the last recipient")
+	}
+
+	passwordVault := createVault(t, t.TempDir(), "app.env", "A=1\n", Password("pw"))
+	if _, err := ListRecipients(passwordVault); err == nil {
+		t.Errorf("expected an error listing recipients of a password vault")
+	}
+}
+
+func TestReadInfo(t *testing.T) {
+	path := createVault(t, t.TempDir(), "app.json", `{"a":"bcd"}`, Password("pw"))
+	info, err := ReadInfo(path)
+	if err != nil {
+		t.Fatalf("failed to read info: %v", err)
+	}
+	if info.Version != version2 || info.Cipher != CipherAESGCM || info.Mode != ModePassword {
+		t.Errorf("unexpected format %s %s %s", info.Version, info.Cipher, info.Mode)
+	}
+	if info.KDF == nil || *info.KDF != fastFormat.KDF {
+		t.Errorf("expected KDF %v, got %v", fastFormat.KDF, info.KDF)
+	}
+	if info.OriginalFile != "app.json" || info.Type != "json" {
+		t.Errorf("expected app.json of type json, got %s of type %s", info.OriginalFile, info.Type)
+	}
+	if info.PayloadBytes != len(`{"a":"bcd"}`) {
+		t.Errorf("expected %d payload bytes, got %d", len(`{"a":"bcd"}`), info.PayloadBytes)
+	}
+	if !info.AuthenticatedHeader {
+		t.Errorf("expected an authenticated header")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	path := createVault(t, t.TempDir(), "app.env", "A=1\n", Password("pw"))
+
+	migrated, err := Migrate(path, Password("pw"), Format{})
+	if err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if migrated {
+		t.Errorf("expected a current vault to be left untouched")
+	}
+
+	format := Format{Cipher: "xchacha20-poly1305", KDF: KDFParams{Memory: 128}}
+	if migrated, err = Migrate(path, Password("pw"), format); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if !migrated {
+		t.Errorf("expected the vault to be migrated")
+	}
+	header, err := ReadHeader(path)
+	if err != nil {
+		t.Fatalf("failed to read header: %v", err)
+	}
+	if header.Cipher != CipherXChaCha20Poly1305 || header.KDF.Memory != 128 || header.KDF.Time != 1 {
+		t.Errorf("unexpected header after migration: %s", header)
+	}
+	v, err := Open(path, Password("pw"))
+	if err != nil {
+		t.Fatalf("failed to open migrated vault: %v", err)
+	}
+	if value, _ := v.Get("A"); value != "1" {
+		t.Errorf("expected A=1, got %s", value)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	identity := newTestIdentity(t)
+	tests := []struct {
+		name   string
+		create KeyProvider
+		open   KeyProvider
+	}{
+		{"password", Password("pw"), Password("pw")},
+		{"recipients", Recipients{identity.Recipient()}, Identities{identity}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := createVault(t, dir, tt.name+".env", "A=1\n", tt.create)
+			v, err := Open(path, tt.open)
+			if err != nil {
+				t.Fatalf("failed to open vault: %v", err)
+			}
+			first, err := v.Fingerprint([]byte("1"))
+			if err != nil {
+				t.Fatalf("failed to fingerprint: %v", err)
+			}
+			if !strings.HasPrefix(first, "hmac:") || strings.Contains(first, "6b86b273") {
+				t.Errorf("expected a keyed fingerprint, got %s", first)
+			}
+			if err := v.Save(); err != nil {
+				t.Fatalf("failed to save vault: %v", err)
+			}
+			reopened, err := Open(path, tt.open)
+			if err != nil {
+				t.Fatalf("failed to reopen vault: %v", err)
+			}
+			if again, _ := reopened.Fingerprint([]byte("1")); again != first {
+				t.Errorf("expected %s after saving, got %s", first, again)
+			}
+			if changed, _ := reopened.Fingerprint([]byte("2")); changed == first {
+				t.Errorf("expected another fingerprint for another value")
+			}
+		})
+	}
+
+	v, err := Open(createVault(t, dir, "other.env", "A=1\n", Password("other")), Password("other"))
+	if err != nil {
+		t.Fatalf("failed to open vault: %v", err)
+	}
+	theirs, _ := v.Fingerprint([]byte("1"))
+	v, _ = Open(filepath.Join(dir, "password.vault"), Password("pw"))
+	if mine, _ := v.Fingerprint([]byte("1")); mine == theirs {
+		t.Errorf("expected fingerprints to depend on the password")
+	}
+}
@@ -0,0 +1,357 @@
+package loadtest
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+)
+
+// DefaultDrainTimeout ends a subscription after the test when no message arrived for this long
+const DefaultDrainTimeout = 500 * time.Millisecond
+
+// ConfirmWaitTimeout limits how long a producer waits for outstanding publisher confirms
+// after it stops publishing
+const ConfirmWaitTimeout = 5 * time.Second
+
+// Message is a message published to or consumed from a destination
+type Message struct {
+	Destination string
+	Body        []byte
+	Headers     map[string]string
+	// ReceivedAt is set by drivers that hand consumed messages over late, e.g. after an
+	// acknowledgement delay, the engine uses the time it got the message otherwise
+	ReceivedAt time.Time
+	// Settlement is how the consumer settled the message with the broker
+	Settlement Settlement
+}
+
+// Settlement of a consumed message
+type Settlement int
+
+const (
+	// SettleAuto is a message acknowledged by the broker on delivery
+	SettleAuto Settlement = iota
+	SettleAcked
+	// SettleRejected is a message rejected without requeueing, it is dropped or dead-lettered
+	SettleRejected
+	// SettleRequeued is a message rejected back to the queue, it is delivered again and not
+	// counted as received
+	SettleRequeued
+)
+
+// Driver connects load test clients to one kind of broker
+type Driver interface {
+	Name() string
+	Connect() (Conn, error)
+}
+
+// Conn is the connection of a single producer or consumer
+type Conn interface {
+	// Declare creates the destinations, brokers without server side state do nothing
+	Declare(destinations []string) error
+	// Delete removes destinations created by Declare
+	Delete(destinations []string) error
+	// Publish returns once the broker accepted the message, when the broker confirms publishes
+	Publish(destination string, msg Message) error
+	// Consume subscribes the destination, the channel is closed with the connection
+	Consume(destination string) (<-chan Message, error)
+	// Headers reports whether messages carry headers, otherwise the trace is sent in the body
+	Headers() bool
+	Close() error
+}
+
+// Confirmer is implemented by connections confirming publishes asynchronously, so a
+// producer keeps publishing while earlier messages are still in flight
+type Confirmer interface {
+	// PublishAsync returns once the message was sent, confirm is called with the answer of
+	// the broker later
+	PublishAsync(destination string, msg Message, confirm func(ack bool)) error
+	// Returns delivers the published messages the broker could not route, it is closed with
+	// the connection
+	Returns() <-chan Message
+}
+
+// Options configures a load test run
+type Options struct {
+	// Destinations are consumed, and published to unless Targets are set
+	Destinations []string
+	// Targets are published to when the broker routes them to the destinations, e.g. through
+	// exchanges
+	Targets []string
+	// Clients is the number of producers and the number of consumers
+	Clients  int
+	Duration time.Duration
+	Rate     RateOptions
+	Size     SizeOptions
+	// Declare creates the destinations before the test and deletes them afterwards unless Keep is set
+	Declare        bool
+	Keep           bool
+	SampleInterval time.Duration
+	DrainTimeout   time.Duration
+}
+
+// Run publishes to random destinations with every producer while the consumers receive from
+// all destinations, and collects the results in stats. Closing stop ends the test early.
+// Publishes are synchronous and a publish accepted by the driver counts as confirmed, unless
+// the connection is a Confirmer.
+func Run(driver Driver, options Options, stats *Stats, stop <-chan struct{}) error {
+	if len(options.Destinations) == 0 {
+		return fmt.Errorf("no destinations to test")
+	}
+	if options.Clients < 1 {
+		return fmt.Errorf("at least one client is required")
+	}
+	if options.DrainTimeout == 0 {
+		options.DrainTimeout = DefaultDrainTimeout
+	}
+	if len(options.Targets) == 0 {
+		options.Targets = options.Destinations
+	}
+
+	// The admin connection fails early with a clear error instead of one per client
+	admin, err := driver.Connect()
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %w", driver.Name(), err)
+	}
+	defer admin.Close()
+
+	if options.Declare {
+		if err := admin.Declare(options.Destinations); err != nil {
+			return fmt.Errorf("error declaring destinations: %w", err)
+		}
+		if options.Keep {
+			logger.Info("keeping destinations, skipping cleanup")
+		} else {
+			defer func() {
+				logger.Info("starting cleanup...")
+				if err := admin.Delete(options.Destinations); err != nil {
+					logger.Errorf("failed to delete destinations: %v", err)
+				}
+			}()
+		}
+	}
+
+	start := time.Now()
+	stopSampling := make(chan struct{})
+	if options.SampleInterval > 0 {
+		go stats.SampleThroughput(start, options.SampleInterval, stopSampling)
+	}
+	defer close(stopSampling)
+
+	done := make(chan struct{})
+	var closeDone sync.Once
+	finish := func() { closeDone.Do(func() { close(done) }) }
+	go func() {
+		select {
+		case <-stop:
+		case <-done:
+		}
+		finish()
+	}()
+
+	var wg, subscribed sync.WaitGroup
+	logger.Info("starting consumers...")
+	assigned := AssignDestinations(options.Destinations, options.Clients)
+	for i := 0; i < options.Clients; i++ {
+		subscribed.Add(1)
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			conn, err := driver.Connect()
+			if err != nil {
+				subscribed.Done()
+				logger.Errorf("failed to connect consumer %d: %v", id, err)
+				return
+			}
+			defer conn.Close()
+
+			logger.Debugf("consumer %d assigned destinations: %v", id, assigned[id])
+			consume(conn, assigned[id], stats, options.DrainTimeout, &subscribed, done)
+			logger.Debugf("consumer %d finished", id)
+		}(i)
+	}
+	subscribed.Wait()
+
+	logger.Info("starting producers...")
+	for i := 0; i < options.Clients; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			conn, err := driver.Connect()
+			if err != nil {
+				logger.Errorf("failed to connect producer %d: %v", id, err)
+				return
+			}
+			defer conn.Close()
+
+			produce(conn, stats.NewProducer(id), stats, options, done)
+			logger.Debugf("producer %d finished", id)
+		}(i)
+	}
+
+	timer := time.AfterFunc(options.Duration, func() {
+		logger.Info("duration expired, stopping load test...")
+		finish()
+	})
+	defer timer.Stop()
+
+	wg.Wait()
+	finish()
+	logger.Info("all producers and consumers stopped")
+	return nil
+}
+
+// AssignDestinations distributes the destinations over the clients round-robin
+func AssignDestinations(destinations []string, clients int) [][]string {
+	assigned := make([][]string, clients)
+	for i, destination := range destinations {
+		assigned[i%clients] = append(assigned[i%clients], destination)
+	}
+	return assigned
+}
+
+// consume subscribes the destinations and records deliveries until stopped, then keeps
+// reading until no message arrived for the drain timeout
+func consume(conn Conn, destinations []string, stats *Stats, drainTimeout time.Duration, subscribed *sync.WaitGroup, stop <-chan struct{}) {
+	subscriptions := make(map[string]<-chan Message, len(destinations))
+	for _, destination := range destinations {
+		messages, err := conn.Consume(destination)
+		if err != nil {
+			logger.Errorf("failed to subscribe to %s: %v", destination, err)
+			continue
+		}
+		subscriptions[destination] = messages
+	}
+	subscribed.Done()
+
+	var wg sync.WaitGroup
+	for destination, messages := range subscriptions {
+		wg.Add(1)
+		go func(destination string, messages <-chan Message) {
+			defer wg.Done()
+			stopped := stop
+			var idle <-chan time.Time
+			for {
+				select {
+				case <-stopped:
+					// Stop only triggers the idle timer once
+					stopped = nil
+					idle = time.After(drainTimeout)
+				case <-idle:
+					logger.Debugf("subscription %s drained", destination)
+					return
+				case msg, ok := <-messages:
+					if !ok {
+						logger.Debugf("subscription %s closed", destination)
+						return
+					}
+					if idle != nil {
+						idle = time.After(drainTimeout)
+					}
+					receivedAt := msg.ReceivedAt
+					if receivedAt.IsZero() {
+						receivedAt = time.Now()
+					}
+					switch msg.Settlement {
+					case SettleAcked:
+						stats.Acked.Add(1)
+					case SettleRejected:
+						stats.Rejected.Add(1)
+					case SettleRequeued:
+						stats.Requeued.Add(1)
+						continue
+					}
+					trace, body, traced := ParseTrace(msg)
+					stats.RecordDelivery(destination, trace, traced, len(body), receivedAt)
+				}
+			}
+		}(destination, messages)
+	}
+	wg.Wait()
+}
+
+// produce publishes to random targets until stopped. Producers of a Confirmer wait for
+// the confirms still in flight before they return.
+func produce(conn Conn, producer *ProducerStats, stats *Stats, options Options, stop <-chan struct{}) {
+	sizer := NewMessageSizer(options.Size)
+	payload := make([]byte, sizer.MaxSize())
+	rand.Read(payload)
+	pace := NewPacer(options.Rate, options.Clients, options.Duration)
+	headers := conn.Headers()
+
+	confirmer, async := conn.(Confirmer)
+	if async {
+		go recordReturns(confirmer.Returns(), producer, stats)
+		defer waitForConfirms(producer)
+	}
+	// pending tags of async publishes, the driver keeps its own delivery tags
+	var tag uint64
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+			if !pace.Wait(stop) {
+				continue
+			}
+			target := options.Targets[rand.Intn(len(options.Targets))]
+			stream := StreamKey(producer.ID, target)
+			seq := producer.NextSeq(stream)
+			body := payload[:sizer.Next()]
+
+			msg := Message{Destination: target, Body: body}
+			NewTrace(stream, seq, time.Now()).Attach(&msg, headers)
+			var err error
+			if async {
+				tag++
+				pending := tag
+				producer.TrackPending(pending, stream, seq)
+				err = confirmer.PublishAsync(target, msg, func(ack bool) {
+					producer.Resolve(pending, ack, stats.Tracker)
+				})
+				if err != nil {
+					producer.ForgetPending(pending)
+				}
+			} else {
+				err = conn.Publish(target, msg)
+			}
+			if err != nil {
+				producer.PublishErrors.Add(1)
+				logger.Errorf("failed to publish message to %s: %v", target, err)
+				continue
+			}
+			producer.Published.Add(1)
+			stats.Sent.Add(1)
+			stats.SentBytes.Add(int64(len(body)))
+			if !async {
+				producer.Confirmed.Add(1)
+				stats.Tracker.MarkConfirmed(stream, seq)
+			}
+		}
+	}
+}
+
+// recordReturns counts the messages the broker could not route
+func recordReturns(returns <-chan Message, producer *ProducerStats, stats *Stats) {
+	for msg := range returns {
+		producer.Returned.Add(1)
+		if trace, _, ok := ParseTrace(msg); ok {
+			stats.Tracker.MarkReturned(trace.Stream, trace.Seq)
+		}
+	}
+}
+
+// waitForConfirms gives the broker a chance to confirm messages still in flight
+func waitForConfirms(producer *ProducerStats) {
+	deadline := time.Now().Add(ConfirmWaitTimeout)
+	for producer.Outstanding() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if outstanding := producer.Outstanding(); outstanding > 0 {
+		logger.Warnf("producer %d stopped with %d unconfirmed messages", producer.ID, outstanding)
+	}
+}
@@ -0,0 +1,390 @@
+package loadtest
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryBroker is an in-memory fake broker delivering every publish to all subscribers of
+// the destination
+type memoryBroker struct {
+	headers bool
+	// dropEvery and duplicateEvery misbehave on every n-th publish when set
+	dropEvery      int
+	duplicateEvery int
+	connectErr     error
+	// settlements are assigned to the deliveries in turn when set
+	settlements []Settlement
+
+	mu        sync.Mutex
+	published int
+	dropped   int
+	declared  map[string]bool
+	deleted   map[string]bool
+	subs      map[string][]chan Message
+}
+
+func newMemoryBroker() *memoryBroker {
+	return &memoryBroker{
+		headers:  true,
+		declared: make(map[string]bool),
+		deleted:  make(map[string]bool),
+		subs:     make(map[string][]chan Message),
+	}
+}
+
+func (b *memoryBroker) Name() string {
+	return "memory"
+}
+
+func (b *memoryBroker) Connect() (Conn, error) {
+	if b.connectErr != nil {
+		return nil, b.connectErr
+	}
+	return &memoryConn{broker: b}, nil
+}
+
+type memoryConn struct {
+	broker *memoryBroker
+	subs   []chan Message
+}
+
+func (c *memoryConn) Declare(destinations []string) error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	for _, destination := range destinations {
+		c.broker.declared[destination] = true
+	}
+	return nil
+}
+
+func (c *memoryConn) Delete(destinations []string) error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	for _, destination := range destinations {
+		c.broker.deleted[destination] = true
+	}
+	return nil
+}
+
+func (c *memoryConn) Publish(destination string, msg Message) error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published++
+	if b.dropEvery > 0 && b.published%b.dropEvery == 0 {
+		b.dropped++
+		return nil
+	}
+	copies := 1
+	if b.duplicateEvery > 0 && b.published%b.duplicateEvery == 0 {
+		copies = 2
+	}
+	if len(b.settlements) > 0 {
+		msg.Settlement = b.settlements[b.published%len(b.settlements)]
+	}
+	for _, sub := range b.subs[destination] {
+		for i := 0; i < copies; i++ {
+			sub <- msg
+		}
+	}
+	return nil
+}
+
+func (c *memoryConn) Consume(destination string) (<-chan Message, error) {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	sub := make(chan Message, 4096)
+	c.broker.subs[destination] = append(c.broker.subs[destination], sub)
+	c.subs = append(c.subs, sub)
+	return sub, nil
+}
+
+func (c *memoryConn) Headers() bool {
+	return c.broker.headers
+}
+
+func (c *memoryConn) Close() error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, sub := range c.subs {
+		for destination, subs := range b.subs {
+			for i, s := range subs {
+				if s == sub {
+					b.subs[destination] = append(subs[:i], subs[i+1:]...)
+					break
+				}
+			}
+		}
+		close(sub)
+	}
+	c.subs = nil
+	return nil
+}
+
+// confirmBroker confirms publishes asynchronously, routes targets to destinations and
+// returns unroutable messages
+type confirmBroker struct {
+	*memoryBroker
+	routes    map[string][]string
+	nackEvery int
+}
+
+func (b *confirmBroker) Connect() (Conn, error) {
+	return &confirmConn{memoryConn: memoryConn{broker: b.memoryBroker}, routes: b, returns: make(chan Message, 4096)}, nil
+}
+
+type confirmConn struct {
+	memoryConn
+	routes  *confirmBroker
+	returns chan Message
+}
+
+func (c *confirmConn) PublishAsync(target string, msg Message, confirm func(ack bool)) error {
+	destinations, ok := c.routes.routes[target]
+	if !ok {
+		c.returns <- msg
+		go confirm(true)
+		return nil
+	}
+	for _, destination := range destinations {
+		if err := c.Publish(destination, msg); err != nil {
+			return err
+		}
+	}
+	b := c.broker
+	b.mu.Lock()
+	nack := c.routes.nackEvery > 0 && b.published%c.routes.nackEvery == 0
+	b.mu.Unlock()
+	go confirm(!nack)
+	return nil
+}
+
+func (c *confirmConn) Returns() <-chan Message {
+	return c.returns
+}
+
+func (c *confirmConn) Close() error {
+	close(c.returns)
+	return c.memoryConn.Close()
+}
+
+func testOptions() Options {
+	return Options{
+		Destinations: []string{"a", "b", "c"},
+		Clients:      2,
+		Duration:     300 * time.Millisecond,
+		Rate:         RateOptions{Rate: 1000, Scope: "global", Profile: "constant"},
+		Size:         SizeOptions{MessageSize: 64, Distribution: "fixed"},
+		Declare:      true,
+		DrainTimeout: 50 * time.Millisecond,
+	}
+}
+
+func TestRun_DeliversAll(t *testing.T) {
+	broker := newMemoryBroker()
+	stats := NewStats()
+	if err := Run(broker, testOptions(), stats, nil); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	summary := stats.Summarize(300 * time.Millisecond)
+	if summary.Messages.Sent == 0 {
+		t.Fatal("no messages sent")
+	}
+	if summary.Messages.Received != summary.Messages.Sent {
+		t.Errorf("received %d, sent %d", summary.Messages.Received, summary.Messages.Sent)
+	}
+	if summary.Integrity.Lost != 0 || summary.Integrity.Duplicated != 0 || summary.Integrity.Untraced != 0 {
+		t.Errorf("unexpected integrity %+v", summary.Integrity)
+	}
+	if summary.Latency.Samples != summary.Messages.Received {
+		t.Errorf("latency samples %d, received %d", summary.Latency.Samples, summary.Messages.Received)
+	}
+	if summary.Confirms.Confirmed != summary.Messages.Sent || len(summary.Producers) != 2 {
+		t.Errorf("unexpected confirms %+v with %d producers", summary.Confirms, len(summary.Producers))
+	}
+	for _, destination := range []string{"a", "b", "c"} {
+		if !broker.declared[destination] || !broker.deleted[destination] {
+			t.Errorf("destination %s was not declared and deleted", destination)
+		}
+	}
+}
+
+func TestRun_RespectsRate(t *testing.T) {
+	stats := NewStats()
+	options := testOptions()
+	options.Rate.Rate = 100
+	options.Duration = 500 * time.Millisecond
+	if err := Run(newMemoryBroker(), options, stats, nil); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// 100 msgs/sec over half a second, shared by both producers
+	if sent := stats.Sent.Load(); sent < 30 || sent > 70 {
+		t.Errorf("sent %d messages, expected about 50", sent)
+	}
+}
+
+func TestRun_TraceInBody(t *testing.T) {
+	broker := newMemoryBroker()
+	broker.headers = false
+	stats := NewStats()
+	if err := Run(broker, testOptions(), stats, nil); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if stats.Tracker.Untraced() != 0 || stats.Latency.Count() != stats.Received.Load() {
+		t.Errorf("%d untraced, %d latency samples of %d messages", stats.Tracker.Untraced(), stats.Latency.Count(), stats.Received.Load())
+	}
+	// The trace prefix does not count as payload
+	if stats.ReceivedBytes.Load() != stats.SentBytes.Load() {
+		t.Errorf("received %d bytes, sent %d", stats.ReceivedBytes.Load(), stats.SentBytes.Load())
+	}
+}
+
+func TestRun_DetectsLossAndDuplicates(t *testing.T) {
+	broker := newMemoryBroker()
+	broker.dropEvery = 10
+	broker.duplicateEvery = 7
+	stats := NewStats()
+	options := testOptions()
+	options.Destinations = []string{"a"}
+	if err := Run(broker, options, stats, nil); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if broker.dropped == 0 {
+		t.Fatal("no message was dropped")
+	}
+	if lost := stats.Tracker.Lost(); lost != int64(broker.dropped) {
+		t.Errorf("lost %d, dropped %d", lost, broker.dropped)
+	}
+	if stats.Tracker.Duplicates() == 0 {
+		t.Error("duplicates were not detected")
+	}
+
+	summary := stats.Summarize(options.Duration)
+	results, passed, err := Thresholds{ZeroLoss: true}.Evaluate(summary.Measurements())
+	if err != nil || passed || len(results) != 1 {
+		t.Errorf("zero loss threshold passed=%t results=%v err=%v", passed, results, err)
+	}
+}
+
+func TestRun_Stop(t *testing.T) {
+	stats := NewStats()
+	options := testOptions()
+	options.Duration = time.Minute
+	stop := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(stop) })
+
+	start := time.Now()
+	if err := Run(newMemoryBroker(), options, stats, stop); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("stop took %s", elapsed)
+	}
+}
+
+func TestRun_ConfirmsAndReturns(t *testing.T) {
+	broker := &confirmBroker{
+		memoryBroker: newMemoryBroker(),
+		routes:       map[string][]string{"all": {"a", "b"}, "a": {"a"}},
+		nackEvery:    5,
+	}
+	stats := NewStats()
+	options := testOptions()
+	options.Destinations = []string{"a", "b"}
+	options.Targets = []string{"all", "a", "nowhere"}
+	if err := Run(broker, options, stats, nil); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	summary := stats.Summarize(options.Duration)
+	confirms := summary.Confirms
+	if confirms.Returned == 0 || confirms.Nacked == 0 {
+		t.Fatalf("expected returned and nacked messages, got %+v", confirms)
+	}
+	if confirms.Confirmed+confirms.Nacked != confirms.Published || confirms.Unconfirmed != 0 {
+		t.Errorf("expected every publish to be confirmed or nacked, got %+v", confirms)
+	}
+	// Nacked messages are delivered by the fake but do not count as lost either way
+	if summary.Integrity.Lost != 0 || summary.Integrity.Duplicated != 0 {
+		t.Errorf("expected no lost or duplicated messages, got %+v", summary.Integrity)
+	}
+}
+
+func TestRun_CountsSettlements(t *testing.T) {
+	broker := newMemoryBroker()
+	broker.settlements = []Settlement{SettleAcked, SettleRejected, SettleRequeued}
+	stats := NewStats()
+	options := testOptions()
+	options.Destinations = []string{"a"}
+	if err := Run(broker, options, stats, nil); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	acked, rejected, requeued := stats.Acked.Load(), stats.Rejected.Load(), stats.Requeued.Load()
+	if acked == 0 || rejected == 0 || requeued == 0 {
+		t.Fatalf("expected all settlements, got %d acked, %d rejected, %d requeued", acked, rejected, requeued)
+	}
+	// Requeued messages come back as a new delivery and are not received yet
+	if received := stats.Received.Load(); received != acked+rejected {
+		t.Errorf("expected %d received, got %d", acked+rejected, received)
+	}
+}
+
+func TestRun_ConnectError(t *testing.T) {
+	broker := newMemoryBroker()
+	broker.connectErr = errors.New("connection refused")
+	if err := Run(broker, testOptions(), NewStats(), nil); err == nil {
+		t.Fatal("expected a connection error")
+	}
+}
+
+func TestThresholds_Evaluate(t *testing.T) {
+	thresholds := Thresholds{MinSendRate: 100, MinReceiveRate: 100, MaxLatencyP99: "10ms"}
+	results, passed, err := thresholds.Evaluate(Measurements{SendRate: 150, ReceiveRate: 90, LatencySamples: 10, LatencyP99: 5})
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if passed || len(results) != 3 || !results[0].Passed || results[1].Passed || !results[2].Passed {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if _, _, err := (Thresholds{MaxLatencyP99: "soon"}).Evaluate(Measurements{}); err == nil {
+		t.Error("expected an invalid latency error")
+	}
+}
+
+func TestParseTrace(t *testing.T) {
+	trace := Trace{Stream: "p1|orders", Seq: 42, SentAt: 1700000000000000000}
+	for _, headers := range []bool{true, false} {
+		msg := Message{Body: []byte("payload")}
+		trace.Attach(&msg, headers)
+		got, body, ok := ParseTrace(msg)
+		if !ok || got != trace || string(body) != "payload" {
+			t.Errorf("headers=%t: got %+v %q %t", headers, got, body, ok)
+		}
+	}
+	if _, _, ok := ParseTrace(Message{Body: []byte("plain body")}); ok {
+		t.Error("untraced message was parsed")
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	h := NewLatencyHistogram()
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	if p50 := h.Percentile(0.5); p50 < 495*time.Millisecond || p50 > 505*time.Millisecond {
+		t.Errorf("p50 = %s", p50)
+	}
+	if p99 := h.Percentile(0.99); p99 < 985*time.Millisecond || p99 > 995*time.Millisecond {
+		t.Errorf("p99 = %s", p99)
+	}
+	if h.Min() != time.Millisecond || h.Max() != time.Second || h.Count() != 1000 {
+		t.Errorf("min %s max %s count %d", h.Min(), h.Max(), h.Count())
+	}
+}
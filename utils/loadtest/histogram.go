@@ -0,0 +1,122 @@
+package loadtest
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyHistogram is a lock-free log-linear histogram of microsecond latencies with
+// roughly 0.2% precision, so percentiles can be computed without keeping every sample
+type LatencyHistogram struct {
+	buckets []uint64
+	count   atomic.Int64
+	sum     atomic.Int64
+	min     atomic.Int64
+	max     atomic.Int64
+}
+
+const (
+	histLinearLimit = 1024
+	histSubBuckets  = 512
+	histMaxShift    = 40
+)
+
+func NewLatencyHistogram() *LatencyHistogram {
+	h := &LatencyHistogram{
+		buckets: make([]uint64, histLinearLimit+histMaxShift*histSubBuckets),
+	}
+	h.min.Store(math.MaxInt64)
+	return h
+}
+
+func histBucketIndex(us int64) int {
+	if us < histLinearLimit {
+		return int(us)
+	}
+	shift := bits.Len64(uint64(us)) - 10
+	return histLinearLimit + (shift-1)*histSubBuckets + int(us>>shift) - histSubBuckets
+}
+
+func histBucketValue(idx int) int64 {
+	if idx < histLinearLimit {
+		return int64(idx)
+	}
+	shift := (idx-histLinearLimit)/histSubBuckets + 1
+	sub := int64((idx-histLinearLimit)%histSubBuckets + histSubBuckets)
+	// Middle of the bucket range
+	return sub<<shift + (int64(1)<<shift)/2
+}
+
+func (h *LatencyHistogram) Record(d time.Duration) {
+	us := d.Microseconds()
+	if us < 0 {
+		us = 0
+	}
+	idx := histBucketIndex(us)
+	if idx >= len(h.buckets) {
+		idx = len(h.buckets) - 1
+	}
+	atomic.AddUint64(&h.buckets[idx], 1)
+	h.count.Add(1)
+	h.sum.Add(us)
+	for {
+		cur := h.min.Load()
+		if us >= cur || h.min.CompareAndSwap(cur, us) {
+			break
+		}
+	}
+	for {
+		cur := h.max.Load()
+		if us <= cur || h.max.CompareAndSwap(cur, us) {
+			break
+		}
+	}
+}
+
+// Count returns the number of recorded samples
+func (h *LatencyHistogram) Count() int64 {
+	return h.count.Load()
+}
+
+// Percentile returns the latency below which the given fraction (0-1) of samples fall
+func (h *LatencyHistogram) Percentile(q float64) time.Duration {
+	total := h.count.Load()
+	if total == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(q * float64(total)))
+	if target < 1 {
+		target = 1
+	}
+	var seen int64
+	for idx := range h.buckets {
+		seen += int64(atomic.LoadUint64(&h.buckets[idx]))
+		if seen >= target {
+			v := histBucketValue(idx)
+			v = min(max(v, h.min.Load()), h.max.Load())
+			return time.Duration(v) * time.Microsecond
+		}
+	}
+	return time.Duration(h.max.Load()) * time.Microsecond
+}
+
+func (h *LatencyHistogram) Mean() time.Duration {
+	count := h.count.Load()
+	if count == 0 {
+		return 0
+	}
+	return time.Duration(h.sum.Load()/count) * time.Microsecond
+}
+
+func (h *LatencyHistogram) Min() time.Duration {
+	if h.count.Load() == 0 {
+		return 0
+	}
+	return time.Duration(h.min.Load()) * time.Microsecond
+}
+
+func (h *LatencyHistogram) Max() time.Duration {
+	return time.Duration(h.max.Load()) * time.Microsecond
+}
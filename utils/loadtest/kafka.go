@@ -0,0 +1,202 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+)
+
+// kafkaFetchWait is how long the consumers wait for new records per fetch
+const kafkaFetchWait = 250 * time.Millisecond
+
+// KafkaOptions configures the connection to the cluster
+type KafkaOptions struct {
+	// Brokers are host:port addresses used to bootstrap the cluster metadata
+	Brokers   []string
+	TLSConfig *tls.Config
+	// User and Password authenticate with SASL PLAIN when set
+	User     string
+	Password string
+	ClientID string
+}
+
+// KafkaDriver produces to single partition topics with acks=all and fetches them from
+// the latest offset at subscription time
+type KafkaDriver struct {
+	Options           KafkaOptions
+	ReplicationFactor int16
+}
+
+func (d *KafkaDriver) Name() string {
+	return "Kafka"
+}
+
+// clientOptions returns the connection options shared by producers and consumers
+func (d *KafkaDriver) clientOptions() []kgo.Opt {
+	options := []kgo.Opt{
+		kgo.SeedBrokers(d.Options.Brokers...),
+		kgo.ClientID(d.Options.ClientID),
+		// Every topic has a single partition, records go to partition 0
+		kgo.RecordPartitioner(kgo.ManualPartitioner()),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+	}
+	if d.Options.TLSConfig != nil {
+		options = append(options, kgo.DialTLSConfig(d.Options.TLSConfig))
+	}
+	if d.Options.User != "" {
+		options = append(options, kgo.SASL(plain.Auth{User: d.Options.User, Pass: d.Options.Password}.AsMechanism()))
+	}
+	return options
+}
+
+func (d *KafkaDriver) Connect() (Conn, error) {
+	client, err := kgo.NewClient(d.clientOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	// The client connects lazily, ping so a wrong address or credentials fail here
+	if err := client.Ping(ctx); err != nil {
+		cancel()
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to %v: %w", d.Options.Brokers, err)
+	}
+	return &kafkaConn{driver: d, client: client, admin: kadm.NewClient(client), ctx: ctx, cancel: cancel}, nil
+}
+
+type kafkaConn struct {
+	driver *KafkaDriver
+	client *kgo.Client
+	admin  *kadm.Client
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	consumers []*kgo.Client
+	closed    bool
+}
+
+// Declare creates the topics with one partition, existing topics are reused
+func (c *kafkaConn) Declare(destinations []string) error {
+	replication := c.driver.ReplicationFactor
+	if replication == 0 {
+		replication = 1
+	}
+	for _, topic := range destinations {
+		_, err := c.admin.CreateTopic(c.ctx, 1, replication, nil, topic)
+		if err != nil && !errors.Is(err, kerr.TopicAlreadyExists) {
+			return fmt.Errorf("failed to create topic %s: %w", topic, err)
+		}
+	}
+	return nil
+}
+
+func (c *kafkaConn) Delete(destinations []string) error {
+	responses, err := c.admin.DeleteTopics(c.ctx, destinations...)
+	if err != nil {
+		return err
+	}
+	return responses.Error()
+}
+
+func (c *kafkaConn) Publish(destination string, msg Message) error {
+	record := &kgo.Record{Topic: destination, Partition: 0, Value: msg.Body, Timestamp: time.Now()}
+	for key, value := range msg.Headers {
+		record.Headers = append(record.Headers, kgo.RecordHeader{Key: key, Value: []byte(value)})
+	}
+	return c.client.ProduceSync(c.ctx, record).FirstErr()
+}
+
+// Consume fetches with a dedicated client, so the long polls of several topics do not
+// block each other
+func (c *kafkaConn) Consume(destination string) (<-chan Message, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("connection closed")
+	}
+	c.mu.Unlock()
+
+	offsets, err := c.admin.ListEndOffsets(c.ctx, destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the latest offset: %w", err)
+	}
+	offset, ok := offsets.Lookup(destination, 0)
+	if !ok {
+		return nil, fmt.Errorf("failed to get the latest offset: topic %s has no partition 0", destination)
+	}
+	if offset.Err != nil {
+		return nil, fmt.Errorf("failed to get the latest offset: %w", offset.Err)
+	}
+
+	options := append(c.driver.clientOptions(),
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{destination: {0: kgo.NewOffset().At(offset.Offset)}}),
+		kgo.FetchMaxWait(kafkaFetchWait),
+		kgo.FetchIsolationLevel(kgo.ReadCommitted()))
+	client, err := kgo.NewClient(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.consumers = append(c.consumers, client)
+	c.mu.Unlock()
+
+	messages := make(chan Message)
+	go func() {
+		defer close(messages)
+		for {
+			fetches := client.PollFetches(c.ctx)
+			if fetches.IsClientClosed() || c.ctx.Err() != nil {
+				return
+			}
+			if err := fetches.Err(); err != nil {
+				logger.Errorf("failed to fetch from %s: %v", destination, err)
+				return
+			}
+			for _, record := range fetches.Records() {
+				msg := Message{Destination: destination, Body: record.Value}
+				if len(record.Headers) > 0 {
+					msg.Headers = make(map[string]string, len(record.Headers))
+					for _, header := range record.Headers {
+						msg.Headers[header.Key] = string(header.Value)
+					}
+				}
+				select {
+				case messages <- msg:
+				case <-c.ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return messages, nil
+}
+
+func (c *kafkaConn) Headers() bool {
+	return true
+}
+
+func (c *kafkaConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.cancel()
+	for _, consumer := range c.consumers {
+		consumer.Close()
+	}
+	c.client.Close()
+	return nil
+}
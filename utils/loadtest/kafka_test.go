@@ -0,0 +1,55 @@
+package loadtest
+
+import (
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+)
+
+// runKafkaCluster starts an in-process single broker cluster and returns its addresses
+func runKafkaCluster(t *testing.T) []string {
+	t.Helper()
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1))
+	if err != nil {
+		t.Fatalf("failed to create Kafka cluster: %v", err)
+	}
+	t.Cleanup(cluster.Close)
+	return cluster.ListenAddrs()
+}
+
+func TestKafkaDriver(t *testing.T) {
+	driver := &KafkaDriver{Options: KafkaOptions{Brokers: runKafkaCluster(t), ClientID: "test"}}
+	testDriverRoundTrip(t, driver, "load-test-0")
+}
+
+func TestKafkaDriver_FetchesFromLatestOffset(t *testing.T) {
+	driver := &KafkaDriver{Options: KafkaOptions{Brokers: runKafkaCluster(t)}}
+	conn, err := driver.Connect()
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.Declare([]string{"load-old"}); err != nil {
+		t.Fatalf("failed to declare: %v", err)
+	}
+	if err := conn.Publish("load-old", Message{Body: []byte("before")}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	messages, err := conn.Consume("load-old")
+	if err != nil {
+		t.Fatalf("failed to consume: %v", err)
+	}
+	if err := conn.Publish("load-old", Message{Body: []byte("after")}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	if msg := receiveMessage(t, messages); string(msg.Body) != "after" {
+		t.Errorf("expected the message produced after subscribing, got %q", msg.Body)
+	}
+}
+
+func TestKafkaDriver_ConnectError(t *testing.T) {
+	driver := &KafkaDriver{Options: KafkaOptions{Brokers: []string{"127.0.0.1:1"}}}
+	if _, err := driver.Connect(); err == nil {
+		t.Error("expected an error connecting to a closed port")
+	}
+}
@@ -0,0 +1,217 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsBuffer is the number of messages buffered per subscription
+const natsBuffer = 1024
+
+// NATSOptions configures the connection to the server
+type NATSOptions struct {
+	// Address is host:port or a nats:// URL of the server
+	Address   string
+	TLSConfig *tls.Config
+	User      string
+	Password  string
+	Token     string
+	// Name identifies the connection in the server monitoring
+	Name string
+}
+
+// NATSDriver publishes to core NATS subjects, or with JetStream to one stream per subject
+// with acknowledged publishes and ordered consumers
+type NATSDriver struct {
+	Options   NATSOptions
+	JetStream bool
+	// Storage of the JetStream streams, file or memory
+	Storage string
+}
+
+func (d *NATSDriver) Name() string {
+	if d.JetStream {
+		return "NATS JetStream"
+	}
+	return "NATS"
+}
+
+func (d *NATSDriver) Connect() (Conn, error) {
+	options := []nats.Option{nats.Name(d.Options.Name), nats.NoReconnect()}
+	if d.Options.TLSConfig != nil {
+		options = append(options, nats.Secure(d.Options.TLSConfig))
+	}
+	if d.Options.User != "" {
+		options = append(options, nats.UserInfo(d.Options.User, d.Options.Password))
+	}
+	if d.Options.Token != "" {
+		options = append(options, nats.Token(d.Options.Token))
+	}
+	conn, err := nats.Connect(d.Options.Address, options...)
+	if err != nil {
+		return nil, err
+	}
+	c := &natsConn{driver: d, conn: conn, done: make(chan struct{})}
+	if d.JetStream {
+		if c.js, err = jetstream.New(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+type natsConn struct {
+	driver *NATSDriver
+	conn   *nats.Conn
+	js     jetstream.JetStream
+	done   chan struct{}
+
+	mu        sync.Mutex
+	consumers []jetstream.MessagesContext
+}
+
+// natsStreamName derives a valid stream name from a subject, stream names must not contain
+// dots, wildcards or path separators
+func natsStreamName(subject string) string {
+	return strings.NewReplacer(".", "_", "*", "_", ">", "_", "/", "_", "\\", "_").Replace(subject)
+}
+
+// Declare creates the streams, core NATS subjects need no declaration
+func (c *natsConn) Declare(destinations []string) error {
+	if !c.driver.JetStream {
+		return nil
+	}
+	storage := jetstream.FileStorage
+	if c.driver.Storage == "memory" {
+		storage = jetstream.MemoryStorage
+	}
+	for _, subject := range destinations {
+		config := jetstream.StreamConfig{Name: natsStreamName(subject), Subjects: []string{subject}, Storage: storage}
+		if _, err := c.js.CreateStream(context.Background(), config); err != nil {
+			return fmt.Errorf("failed to create stream for %s: %w", subject, err)
+		}
+	}
+	return nil
+}
+
+func (c *natsConn) Delete(destinations []string) error {
+	if !c.driver.JetStream {
+		return nil
+	}
+	for _, subject := range destinations {
+		if err := c.js.DeleteStream(context.Background(), natsStreamName(subject)); err != nil {
+			return fmt.Errorf("failed to delete stream for %s: %w", subject, err)
+		}
+	}
+	return nil
+}
+
+// Publish waits for the stream acknowledgement with JetStream, core NATS publishes are
+// fire and forget
+func (c *natsConn) Publish(destination string, msg Message) error {
+	m := &nats.Msg{Subject: destination, Data: msg.Body}
+	if len(msg.Headers) > 0 {
+		m.Header = nats.Header{}
+		for name, value := range msg.Headers {
+			m.Header.Set(name, value)
+		}
+	}
+	if c.driver.JetStream {
+		_, err := c.js.PublishMsg(context.Background(), m)
+		return err
+	}
+	return c.conn.PublishMsg(m)
+}
+
+// Consume subscribes the subject, with JetStream through an ordered consumer delivering
+// the messages stored after the subscription
+func (c *natsConn) Consume(destination string) (<-chan Message, error) {
+	messages := make(chan Message)
+	if c.driver.JetStream {
+		consumer, err := c.js.OrderedConsumer(context.Background(), natsStreamName(destination), jetstream.OrderedConsumerConfig{DeliverPolicy: jetstream.DeliverNewPolicy})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create consumer: %w", err)
+		}
+		iter, err := consumer.Messages()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create consumer: %w", err)
+		}
+		c.mu.Lock()
+		c.consumers = append(c.consumers, iter)
+		c.mu.Unlock()
+
+		go func() {
+			defer close(messages)
+			for {
+				msg, err := iter.Next()
+				if err != nil {
+					return
+				}
+				if !c.deliver(messages, destination, msg.Data(), msg.Headers()) {
+					return
+				}
+			}
+		}()
+		return messages, nil
+	}
+
+	received := make(chan *nats.Msg, natsBuffer)
+	sub, err := c.conn.ChanSubscribe(destination, received)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer close(messages)
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case msg := <-received:
+				if !c.deliver(messages, destination, msg.Data, msg.Header) {
+					return
+				}
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	return messages, nil
+}
+
+// deliver passes the message to the engine, it returns false once the connection is closed
+func (c *natsConn) deliver(messages chan<- Message, destination string, data []byte, header nats.Header) bool {
+	msg := Message{Destination: destination, Body: data}
+	if len(header) > 0 {
+		msg.Headers = make(map[string]string, len(header))
+		for name := range header {
+			msg.Headers[name] = header.Get(name)
+		}
+	}
+	select {
+	case messages <- msg:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+func (c *natsConn) Headers() bool {
+	return c.conn.HeadersSupported()
+}
+
+func (c *natsConn) Close() error {
+	close(c.done)
+	c.mu.Lock()
+	for _, consumer := range c.consumers {
+		consumer.Stop()
+	}
+	c.mu.Unlock()
+	c.conn.Close()
+	return nil
+}
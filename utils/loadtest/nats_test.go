@@ -0,0 +1,124 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// runNATSServer starts an in-process server with JetStream and returns its address
+func runNATSServer(t *testing.T) string {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir(), NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatalf("failed to create NATS server: %v", err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s.Addr().String()
+}
+
+// receiveMessage waits for the next message of the consumer
+func receiveMessage(t *testing.T, messages <-chan Message) Message {
+	t.Helper()
+	select {
+	case msg, ok := <-messages:
+		if !ok {
+			t.Fatal("consumer closed")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	return Message{}
+}
+
+// testDriverRoundTrip publishes to a declared destination and expects the consumer to
+// receive the message with its headers, then deletes it and checks the consumer closes
+func testDriverRoundTrip(t *testing.T, driver Driver, destination string) {
+	t.Helper()
+	conn, err := driver.Connect()
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	if err := conn.Declare([]string{destination}); err != nil {
+		t.Fatalf("failed to declare: %v", err)
+	}
+	// Declaring an existing destination reuses it
+	if err := conn.Declare([]string{destination}); err != nil {
+		t.Fatalf("failed to declare again: %v", err)
+	}
+	messages, err := conn.Consume(destination)
+	if err != nil {
+		t.Fatalf("failed to consume: %v", err)
+	}
+	if !conn.Headers() {
+		t.Fatal("expected header support")
+	}
+
+	if err := conn.Publish(destination, Message{Body: []byte("hello"), Headers: map[string]string{"vpd-trace": "1"}}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	msg := receiveMessage(t, messages)
+	if msg.Destination != destination || string(msg.Body) != "hello" || msg.Headers["vpd-trace"] != "1" {
+		t.Errorf("expected hello with the trace header on %s, got %q with %v on %s", destination, msg.Body, msg.Headers, msg.Destination)
+	}
+
+	if err := conn.Delete([]string{destination}); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	select {
+	case <-messages:
+	case <-time.After(5 * time.Second):
+		t.Error("expected the consumer to close with the connection")
+	}
+}
+
+func TestNATSDriver(t *testing.T) {
+	address := runNATSServer(t)
+	for _, jetStream := range []bool{false, true} {
+		driver := &NATSDriver{Options: NATSOptions{Address: address, Name: "test"}, JetStream: jetStream, Storage: "memory"}
+		t.Run(driver.Name(), func(t *testing.T) {
+			testDriverRoundTrip(t, driver, "load.test.0")
+		})
+	}
+}
+
+func TestNATSDriver_JetStreamDeliversNewMessagesOnly(t *testing.T) {
+	driver := &NATSDriver{Options: NATSOptions{Address: runNATSServer(t)}, JetStream: true, Storage: "memory"}
+	conn, err := driver.Connect()
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.Declare([]string{"load.old"}); err != nil {
+		t.Fatalf("failed to declare: %v", err)
+	}
+	if err := conn.Publish("load.old", Message{Body: []byte("before")}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	messages, err := conn.Consume("load.old")
+	if err != nil {
+		t.Fatalf("failed to consume: %v", err)
+	}
+	if err := conn.Publish("load.old", Message{Body: []byte("after")}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	if msg := receiveMessage(t, messages); string(msg.Body) != "after" {
+		t.Errorf("expected the message published after subscribing, got %q", msg.Body)
+	}
+}
+
+func TestNATSDriver_ConnectError(t *testing.T) {
+	driver := &NATSDriver{Options: NATSOptions{Address: "127.0.0.1:1"}}
+	if _, err := driver.Connect(); err == nil {
+		t.Error("expected an error connecting to a closed port")
+	}
+}
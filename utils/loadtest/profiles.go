@@ -0,0 +1,137 @@
+package loadtest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is a named load shape. Brokers without exchanges only use the queue count, as
+// the number of destinations.
+type Profile struct {
+	Duration        string     `yaml:"duration"`
+	QueueCount      int        `yaml:"queueCount"`
+	ExchangeCount   int        `yaml:"exchangeCount"`
+	RoutingKeyCount int        `yaml:"routingKeyCount"`
+	MessageSize     int        `yaml:"messageSize"`
+	ParallelClients int        `yaml:"parallelClients"`
+	Description     string     `yaml:"description"`
+	Thresholds      Thresholds `yaml:"thresholds"`
+}
+
+var BuiltinProfiles = map[string]Profile{
+	"light": {
+		Duration:        "30s",
+		QueueCount:      5,
+		ExchangeCount:   2,
+		RoutingKeyCount: 3,
+		MessageSize:     1024,
+		ParallelClients: 2,
+		Description:     "Light load - sanity check (2.5K-5K msgs/sec expected)",
+	},
+	"medium": {
+		Duration:        "2m",
+		QueueCount:      20,
+		ExchangeCount:   5,
+		RoutingKeyCount: 10,
+		MessageSize:     4096,
+		ParallelClients: 10,
+		Description:     "Medium load - typical workload (20K-50K msgs/sec expected)",
+	},
+	"heavy": {
+		Duration:        "3m",
+		QueueCount:      50,
+		ExchangeCount:   20,
+		RoutingKeyCount: 30,
+		MessageSize:     8192,
+		ParallelClients: 20,
+		Description:     "Heavy load - stress test (100K+ msgs/sec expected)",
+	},
+	"sustained": {
+		Duration:        "10m",
+		QueueCount:      30,
+		ExchangeCount:   10,
+		RoutingKeyCount: 15,
+		MessageSize:     2048,
+		ParallelClients: 15,
+		Description:     "Sustained load - stability test (long-running)",
+	},
+}
+
+// profilesFile is the YAML document with user defined load profiles
+type profilesFile struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// DefaultProfileFile returns ~/.config/vpd/<name>
+func DefaultProfileFile(name string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "vpd", name)
+}
+
+// LoadProfiles returns the built-in profiles merged with profiles from the given file, or
+// from defaultPath when no file is given. A missing default file is not an error, an
+// explicitly requested one is.
+func LoadProfiles(path, defaultPath string) (map[string]Profile, error) {
+	profiles := make(map[string]Profile, len(BuiltinProfiles))
+	for name, profile := range BuiltinProfiles {
+		profiles[name] = profile
+	}
+
+	explicit := path != ""
+	if !explicit {
+		path = defaultPath
+	}
+	if path == "" {
+		return profiles, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return profiles, nil
+		}
+		return nil, fmt.Errorf("error reading profile file: %w", err)
+	}
+
+	var file profilesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing profile file %s: %w", path, err)
+	}
+	for name, profile := range file.Profiles {
+		if err := profile.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid profile '%s' in %s: %w", name, path, err)
+		}
+		profiles[name] = profile
+	}
+	return profiles, nil
+}
+
+func (p Profile) Validate() error {
+	if p.Duration == "" {
+		return fmt.Errorf("duration is required")
+	}
+	if p.ParallelClients < 1 {
+		return fmt.Errorf("parallelClients must be at least 1")
+	}
+	if p.MessageSize < 0 || p.QueueCount < 0 || p.ExchangeCount < 0 || p.RoutingKeyCount < 0 {
+		return fmt.Errorf("counts and sizes must not be negative")
+	}
+	return nil
+}
+
+func ProfileNames(profiles map[string]Profile) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,297 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	rabbitmqUtils "github.com/VojtechPastyrik/vpd/utils/rabbitmq"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// DefaultPrefetch is the consumer prefetch when the driver sets none
+const DefaultPrefetch = 100
+
+// RabbitMQDriver publishes with publisher confirms, either to durable queues through the
+// default exchange or to the exchanges and routing keys of the targets, and consumes with
+// automatic acknowledgements unless Ack asks for manual ones. Streams are consumed from the
+// next offset with manual acknowledgements, the broker refuses automatic ones.
+type RabbitMQDriver struct {
+	Options rabbitmqUtils.ConnectionOptions
+	// QueueType is classic, quorum or stream, empty uses the server default
+	QueueType string
+	// Streams are queues declared outside the driver that are streams
+	Streams map[string]bool
+	// Targets maps publish targets of the engine to exchanges and routing keys, other targets
+	// are published to the queue of the same name
+	Targets    map[string]RabbitMQTarget
+	Persistent bool
+	// Priority and Expiration in milliseconds of every message, zero values are not set
+	Priority   uint8
+	Expiration int
+	// Prefetch limits the unacknowledged deliveries of a consumer, DefaultPrefetch when zero
+	Prefetch int
+	Ack      AckOptions
+}
+
+// RabbitMQTarget is an exchange and routing key to publish to, the headers are added to every
+// message for headers exchanges
+type RabbitMQTarget struct {
+	Exchange   string
+	RoutingKey string
+	Headers    amqp091.Table
+}
+
+// AckOptions simulate consumers acknowledging manually
+type AckOptions struct {
+	Manual bool
+	// Delay before each acknowledgement, a slow consumer
+	Delay time.Duration
+	// NackPercent of the messages are rejected, requeued when Requeue is set
+	NackPercent float64
+	Requeue     bool
+}
+
+// Enabled reports whether deliveries are acknowledged explicitly
+func (o AckOptions) Enabled() bool {
+	return o.Manual || o.Delay > 0 || o.NackPercent > 0
+}
+
+func (d *RabbitMQDriver) Name() string {
+	return "RabbitMQ"
+}
+
+func (d *RabbitMQDriver) Connect() (Conn, error) {
+	conn, ch, err := rabbitmqUtils.Connect(d.Options)
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	c := &rabbitmqConn{
+		driver:    d,
+		conn:      conn,
+		ch:        ch,
+		done:      make(chan struct{}),
+		confirmed: make(chan struct{}),
+		pending:   make(map[uint64]func(bool)),
+		returns:   make(chan Message, 1024),
+	}
+	go c.resolveConfirms(ch.NotifyPublish(make(chan amqp091.Confirmation, 1024)))
+	go c.forwardReturns(ch.NotifyReturn(make(chan amqp091.Return, 1024)))
+	return c, nil
+}
+
+func (d *RabbitMQDriver) stream(queue string) bool {
+	return d.QueueType == "stream" || d.Streams[queue]
+}
+
+type rabbitmqConn struct {
+	driver *RabbitMQDriver
+	conn   *amqp091.Connection
+	ch     *amqp091.Channel
+	done   chan struct{}
+	// confirmed is closed when the channel stops delivering confirms
+	confirmed chan struct{}
+	returns   chan Message
+
+	publishMu sync.Mutex
+	mu        sync.Mutex
+	pending   map[uint64]func(bool)
+}
+
+func (c *rabbitmqConn) Declare(destinations []string) error {
+	var args amqp091.Table
+	if c.driver.QueueType != "" {
+		args = amqp091.Table{"x-queue-type": c.driver.QueueType}
+	}
+	for _, queue := range destinations {
+		if _, err := c.ch.QueueDeclare(queue, true, false, false, false, args); err != nil {
+			return fmt.Errorf("failed to declare queue %s: %w", queue, err)
+		}
+	}
+	return nil
+}
+
+func (c *rabbitmqConn) Delete(destinations []string) error {
+	for _, queue := range destinations {
+		if _, err := c.ch.QueueDelete(queue, false, false, false); err != nil {
+			return fmt.Errorf("failed to delete queue %s: %w", queue, err)
+		}
+	}
+	return nil
+}
+
+// Publish waits for the publisher confirm, a nack is returned as an error
+func (c *rabbitmqConn) Publish(destination string, msg Message) error {
+	acked := make(chan bool, 1)
+	if err := c.PublishAsync(destination, msg, func(ack bool) { acked <- ack }); err != nil {
+		return err
+	}
+	select {
+	case ack := <-acked:
+		if !ack {
+			return fmt.Errorf("message was nacked by the broker")
+		}
+		return nil
+	case <-c.confirmed:
+		return fmt.Errorf("channel was closed before the message was confirmed")
+	}
+}
+
+// PublishAsync publishes as mandatory, so unroutable messages come back through Returns
+func (c *rabbitmqConn) PublishAsync(destination string, msg Message, confirm func(ack bool)) error {
+	target, ok := c.driver.Targets[destination]
+	if !ok {
+		target = RabbitMQTarget{RoutingKey: destination}
+	}
+	publishing := amqp091.Publishing{Body: msg.Body, Priority: c.driver.Priority, DeliveryMode: amqp091.Transient}
+	if len(msg.Headers) > 0 || len(target.Headers) > 0 {
+		publishing.Headers = make(amqp091.Table, len(msg.Headers)+len(target.Headers))
+		for name, value := range target.Headers {
+			publishing.Headers[name] = value
+		}
+		for name, value := range msg.Headers {
+			publishing.Headers[name] = value
+		}
+	}
+	if c.driver.Persistent {
+		publishing.DeliveryMode = amqp091.Persistent
+	}
+	if c.driver.Expiration > 0 {
+		publishing.Expiration = strconv.Itoa(c.driver.Expiration)
+	}
+
+	// The delivery tag is only known until the next publish on the channel
+	c.publishMu.Lock()
+	defer c.publishMu.Unlock()
+	tag := c.ch.GetNextPublishSeqNo()
+	c.mu.Lock()
+	c.pending[tag] = confirm
+	c.mu.Unlock()
+	if err := c.ch.PublishWithContext(context.Background(), target.Exchange, target.RoutingKey, true, false, publishing); err != nil {
+		c.mu.Lock()
+		delete(c.pending, tag)
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// resolveConfirms calls the confirm callbacks, the client delivers multiple acks one by one
+func (c *rabbitmqConn) resolveConfirms(confirms <-chan amqp091.Confirmation) {
+	defer close(c.confirmed)
+	for confirmation := range confirms {
+		c.mu.Lock()
+		confirm, ok := c.pending[confirmation.DeliveryTag]
+		delete(c.pending, confirmation.DeliveryTag)
+		c.mu.Unlock()
+		if ok {
+			confirm(confirmation.Ack)
+		}
+	}
+}
+
+func (c *rabbitmqConn) forwardReturns(returns <-chan amqp091.Return) {
+	defer close(c.returns)
+	for ret := range returns {
+		select {
+		case c.returns <- Message{Destination: ret.RoutingKey, Body: ret.Body, Headers: stringHeaders(ret.Headers)}:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *rabbitmqConn) Returns() <-chan Message {
+	return c.returns
+}
+
+// Consume subscribes with the prefetch of the driver. Deliveries of streams and of manual
+// acknowledgements are settled before they are handed over.
+func (c *rabbitmqConn) Consume(destination string) (<-chan Message, error) {
+	prefetch := c.driver.Prefetch
+	if prefetch == 0 {
+		prefetch = DefaultPrefetch
+	}
+	if err := c.ch.Qos(prefetch, 0, false); err != nil {
+		return nil, fmt.Errorf("failed to set prefetch: %w", err)
+	}
+	stream := c.driver.stream(destination)
+	var args amqp091.Table
+	if stream {
+		args = amqp091.Table{"x-stream-offset": "next"}
+	}
+	manual := stream || c.driver.Ack.Enabled()
+	deliveries, err := c.ch.Consume(destination, "", !manual, false, false, false, args)
+	if err != nil {
+		return nil, err
+	}
+	messages := make(chan Message)
+	go func() {
+		defer close(messages)
+		for delivery := range deliveries {
+			msg := Message{Destination: destination, Body: delivery.Body, Headers: stringHeaders(delivery.Headers), ReceivedAt: time.Now()}
+			if manual {
+				settlement, err := c.settle(delivery, stream)
+				if err != nil {
+					return
+				}
+				msg.Settlement = settlement
+			}
+			select {
+			case messages <- msg:
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	return messages, nil
+}
+
+// settle applies the acknowledgement delay and acknowledges or rejects the delivery, streams
+// do not support rejecting messages
+func (c *rabbitmqConn) settle(delivery amqp091.Delivery, stream bool) (Settlement, error) {
+	ack := c.driver.Ack
+	if ack.Delay > 0 {
+		time.Sleep(ack.Delay)
+	}
+	if !stream && ack.NackPercent > 0 && rand.Float64()*100 < ack.NackPercent {
+		if err := delivery.Nack(false, ack.Requeue); err != nil {
+			return SettleAuto, err
+		}
+		if ack.Requeue {
+			return SettleRequeued, nil
+		}
+		return SettleRejected, nil
+	}
+	if err := delivery.Ack(false); err != nil {
+		return SettleAuto, err
+	}
+	return SettleAcked, nil
+}
+
+func (c *rabbitmqConn) Headers() bool {
+	return true
+}
+
+func (c *rabbitmqConn) Close() error {
+	close(c.done)
+	return c.conn.Close()
+}
+
+func stringHeaders(table amqp091.Table) map[string]string {
+	if len(table) == 0 {
+		return nil
+	}
+	headers := make(map[string]string, len(table))
+	for name, value := range table {
+		headers[name] = fmt.Sprint(value)
+	}
+	return headers
+}
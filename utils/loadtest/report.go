@@ -0,0 +1,264 @@
+package loadtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/VojtechPastyrik/vpd/pkg/logger"
+)
+
+// Output formats of the report
+const (
+	OutputJSON = "json"
+	OutputCSV  = "csv"
+)
+
+// Report is the result of a load test shared by the load-test commands, the statistics are
+// printed for people and written as JSON or CSV for CI
+type Report struct {
+	Driver           string    `json:"driver"`
+	Profile          string    `json:"profile"`
+	StartTime        time.Time `json:"startTime"`
+	EndTime          time.Time `json:"endTime"`
+	ExpectedDuration float64   `json:"expectedDurationSeconds"`
+	ActualDuration   float64   `json:"actualDurationSeconds"`
+	// Configuration is the command specific configuration written to JSON
+	Configuration interface{} `json:"configuration"`
+	Summary
+	Thresholds []ThresholdResult `json:"thresholds,omitempty"`
+	Passed     bool              `json:"passed"`
+	// Settings are printed as the test configuration
+	Settings []Setting `json:"-"`
+}
+
+// Setting is a line of the test configuration in the statistics
+type Setting struct {
+	Name  string
+	Value string
+}
+
+// NewReport summarizes the statistics of a test that ran from start to end
+func NewReport(driver, profile string, start, end time.Time, expected time.Duration, stats *Stats) *Report {
+	return &Report{
+		Driver:           driver,
+		Profile:          profile,
+		StartTime:        start,
+		EndTime:          end,
+		ExpectedDuration: expected.Seconds(),
+		ActualDuration:   end.Sub(start).Seconds(),
+		Summary:          stats.Summarize(end.Sub(start)),
+		Passed:           true,
+	}
+}
+
+// WorkloadSettings describe the messages, the rate and the clients of the test
+func WorkloadSettings(size SizeOptions, rate RateOptions, clients int) []Setting {
+	settings := make([]Setting, 0, 3)
+	if size.Distribution == "" || size.Distribution == "fixed" {
+		settings = append(settings, Setting{"Message Size", fmt.Sprintf("%d bytes", size.MessageSize)})
+	} else {
+		settings = append(settings, Setting{"Message Size", fmt.Sprintf("%s, mean %d bytes", size.Distribution, size.MessageSize)})
+	}
+	if rate.Rate > 0 {
+		settings = append(settings, Setting{"Target Rate", fmt.Sprintf("%.0f msgs/sec (%s, %s)", rate.Rate, rate.Profile, rate.Scope)})
+	} else {
+		settings = append(settings, Setting{"Target Rate", "unlimited"})
+	}
+	return append(settings, Setting{"Parallel Clients", fmt.Sprintf("%d (consumers + producers)", clients)})
+}
+
+// ValidateOutput checks the output format, empty writes no results
+func ValidateOutput(format string) error {
+	if format != "" && format != OutputJSON && format != OutputCSV {
+		return fmt.Errorf("unsupported output format '%s', use json or csv", format)
+	}
+	return nil
+}
+
+// Evaluate checks the report against the thresholds and sets Passed
+func (r *Report) Evaluate(thresholds Thresholds) error {
+	results, passed, err := thresholds.Evaluate(r.Summary.Measurements())
+	r.Thresholds, r.Passed = results, passed
+	return err
+}
+
+// Finish evaluates the thresholds, prints the statistics unless the results are written to
+// stdout and writes the results in the format to the file
+func (r *Report) Finish(thresholds Thresholds, format, path string) {
+	if err := r.Evaluate(thresholds); err != nil {
+		logger.Errorf("%v", err)
+	}
+	// Keep stdout clean when the results are written there
+	if format == "" || (path != "" && path != "-") {
+		r.Print()
+	}
+	if format == "" {
+		return
+	}
+	if err := r.Write(format, path); err != nil {
+		logger.Errorf("%v", err)
+	} else if path != "" && path != "-" {
+		logger.Successf("results saved to %s", path)
+	}
+}
+
+// Print writes the statistics to stdout
+func (r *Report) Print() {
+	separator := strings.Repeat("=", 70)
+	fmt.Println("\n" + separator)
+	fmt.Println("                    LOAD TEST STATISTICS                         ")
+	fmt.Println(separator)
+	fmt.Printf("\nTest Configuration:\n")
+	fmt.Printf("  - Driver:                %s\n", r.Driver)
+	fmt.Printf("  - Profile:               %s\n", r.Profile)
+	fmt.Printf("  - Expected Duration:     %.2f seconds\n", r.ExpectedDuration)
+	fmt.Printf("  - Actual Duration:       %.2f seconds\n", r.ActualDuration)
+	if r.ExpectedDuration > 0 {
+		fmt.Printf("  - Time Multiplier:       %.2f x\n", r.ActualDuration/r.ExpectedDuration)
+	}
+	for _, setting := range r.Settings {
+		fmt.Printf("  - %-23s%s\n", setting.Name+":", setting.Value)
+	}
+
+	fmt.Printf("\nMessage Statistics:\n")
+	fmt.Printf("  - Messages Sent:         %d\n", r.Messages.Sent)
+	fmt.Printf("  - Messages Received:     %d\n", r.Messages.Received)
+	fmt.Printf("  - Duplication Factor:    %.2f x\n", r.Messages.DuplicationFactor)
+	if r.Messages.Acked+r.Messages.Rejected+r.Messages.Requeued > 0 {
+		fmt.Printf("  - Acked:                 %d\n", r.Messages.Acked)
+		fmt.Printf("  - Rejected (dropped):    %d\n", r.Messages.Rejected)
+		fmt.Printf("  - Rejected (requeued):   %d\n", r.Messages.Requeued)
+	}
+
+	fmt.Printf("\nPublisher Confirms:\n")
+	fmt.Printf("  - Published:             %d\n", r.Confirms.Published)
+	fmt.Printf("  - Confirmed (ack):       %d\n", r.Confirms.Confirmed)
+	fmt.Printf("  - Rejected (nack):       %d\n", r.Confirms.Nacked)
+	fmt.Printf("  - Returned (unroutable): %d\n", r.Confirms.Returned)
+	fmt.Printf("  - Unconfirmed:           %d\n", r.Confirms.Unconfirmed)
+	fmt.Printf("  - Publish Errors:        %d\n", r.Confirms.PublishErrors)
+	for _, p := range r.Producers {
+		fmt.Printf("    producer %-3d published %d, ack %d, nack %d, returned %d, unconfirmed %d\n",
+			p.ID, p.Published, p.Confirmed, p.Nacked, p.Returned, p.Unconfirmed)
+	}
+
+	fmt.Printf("\nEnd-to-End Latency:\n")
+	if r.Latency.Samples == 0 {
+		fmt.Printf("  - No traced messages received\n")
+	} else {
+		fmt.Printf("  - Samples:               %d\n", r.Latency.Samples)
+		fmt.Printf("  - Min:                   %.3f ms\n", r.Latency.Min)
+		fmt.Printf("  - Avg:                   %.3f ms\n", r.Latency.Avg)
+		fmt.Printf("  - p50:                   %.3f ms\n", r.Latency.P50)
+		fmt.Printf("  - p90:                   %.3f ms\n", r.Latency.P90)
+		fmt.Printf("  - p95:                   %.3f ms\n", r.Latency.P95)
+		fmt.Printf("  - p99:                   %.3f ms\n", r.Latency.P99)
+		fmt.Printf("  - p99.9:                 %.3f ms\n", r.Latency.P999)
+		fmt.Printf("  - Max:                   %.3f ms\n", r.Latency.Max)
+	}
+
+	fmt.Printf("\nDelivery Integrity:\n")
+	fmt.Printf("  - Lost Messages:         %d\n", r.Integrity.Lost)
+	fmt.Printf("  - Duplicated Messages:   %d\n", r.Integrity.Duplicated)
+	if r.Integrity.Untraced > 0 {
+		fmt.Printf("  - Untraced Messages:     %d\n", r.Integrity.Untraced)
+	}
+
+	fmt.Printf("\nThroughput:\n")
+	fmt.Printf("  - Send Rate:             %.2f msgs/sec\n", r.Throughput.SendRate)
+	fmt.Printf("  - Receive Rate:          %.2f msgs/sec\n", r.Throughput.ReceiveRate)
+
+	fmt.Printf("\nData Transfer:\n")
+	fmt.Printf("  - Total Data Sent:       %.2f MB\n", r.Throughput.DataSentMB)
+	fmt.Printf("  - Total Data Received:   %.2f MB\n", r.Throughput.DataReceivedMB)
+	fmt.Printf("  - Send Rate:             %.2f MB/s\n", r.Throughput.DataSendRateMB)
+	fmt.Printf("  - Receive Rate:          %.2f MB/s\n", r.Throughput.DataReceiveRateMB)
+
+	if len(r.Thresholds) > 0 {
+		fmt.Printf("\nThresholds:\n")
+		for _, t := range r.Thresholds {
+			status := "PASS"
+			if !t.Passed {
+				status = "FAIL"
+			}
+			fmt.Printf("  - [%s] %-17s expected %s, got %s\n", status, t.Name, t.Expected, t.Actual)
+		}
+	}
+
+	fmt.Println("\n" + separator)
+}
+
+// Marshal returns the report as JSON or CSV, the CSV has the summary followed by the
+// interval samples
+func (r *Report) Marshal(format string) ([]byte, error) {
+	switch format {
+	case OutputJSON:
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("error serializing results: %w", err)
+		}
+		return data, nil
+	case OutputCSV:
+		var buffer bytes.Buffer
+		buffer.WriteString("metric,value\n")
+		rows := [][2]string{
+			{"driver", r.Driver},
+			{"profile", r.Profile},
+			{"start_time", r.StartTime.Format(time.RFC3339)},
+			{"actual_duration_seconds", fmt.Sprintf("%.2f", r.ActualDuration)},
+			{"messages_sent", fmt.Sprintf("%d", r.Messages.Sent)},
+			{"messages_received", fmt.Sprintf("%d", r.Messages.Received)},
+			{"confirmed", fmt.Sprintf("%d", r.Confirms.Confirmed)},
+			{"nacked", fmt.Sprintf("%d", r.Confirms.Nacked)},
+			{"returned", fmt.Sprintf("%d", r.Confirms.Returned)},
+			{"unconfirmed", fmt.Sprintf("%d", r.Confirms.Unconfirmed)},
+			{"lost", fmt.Sprintf("%d", r.Integrity.Lost)},
+			{"duplicated", fmt.Sprintf("%d", r.Integrity.Duplicated)},
+			{"send_rate", fmt.Sprintf("%.2f", r.Throughput.SendRate)},
+			{"receive_rate", fmt.Sprintf("%.2f", r.Throughput.ReceiveRate)},
+			{"latency_p50_ms", fmt.Sprintf("%.3f", r.Latency.P50)},
+			{"latency_p95_ms", fmt.Sprintf("%.3f", r.Latency.P95)},
+			{"latency_p99_ms", fmt.Sprintf("%.3f", r.Latency.P99)},
+			{"latency_max_ms", fmt.Sprintf("%.3f", r.Latency.Max)},
+			{"passed", fmt.Sprintf("%t", r.Passed)},
+		}
+		for _, row := range rows {
+			buffer.WriteString(fmt.Sprintf("%s,%s\n", row[0], csvField(row[1])))
+		}
+
+		buffer.WriteString("\nelapsed_seconds,sent,received,send_rate,receive_rate\n")
+		for _, s := range r.Samples {
+			buffer.WriteString(fmt.Sprintf("%.2f,%d,%d,%.2f,%.2f\n", s.Elapsed, s.Sent, s.Received, s.SendRate, s.ReceiveRate))
+		}
+		return buffer.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unsupported output format '%s', use json or csv", format)
+}
+
+// Write saves the report in the format to the file, "" or "-" for stdout
+func (r *Report) Write(format, path string) error {
+	data, err := r.Marshal(format)
+	if err != nil {
+		return err
+	}
+	if path == "" || path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error saving results to file: %w", err)
+	}
+	return nil
+}
+
+// csvField quotes profile names with commas or quotes
+func csvField(value string) string {
+	if !strings.ContainsAny(value, ",\"\n") {
+		return value
+	}
+	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+}
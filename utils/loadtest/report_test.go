@@ -0,0 +1,83 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReport() *Report {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	stats := NewStats()
+	stats.Sent.Store(100)
+	stats.Received.Store(100)
+	stats.addSample(IntervalSample{Elapsed: 1, Sent: 100, Received: 100, SendRate: 100, ReceiveRate: 100})
+	report := NewReport("memory", "ci, nightly", start, start.Add(time.Second), time.Second, stats)
+	report.Configuration = map[string]int{"destinations": 3}
+	return report
+}
+
+func TestReport_MarshalCSV(t *testing.T) {
+	data, err := testReport().Marshal(OutputCSV)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	csv := string(data)
+	for _, line := range []string{
+		"metric,value\n",
+		"driver,memory\n",
+		"profile,\"ci, nightly\"\n",
+		"start_time,2026-01-02T03:04:05Z\n",
+		"messages_sent,100\n",
+		"passed,true\n",
+		"\nelapsed_seconds,sent,received,send_rate,receive_rate\n1.00,100,100,100.00,100.00\n",
+	} {
+		if !strings.Contains(csv, line) {
+			t.Errorf("expected %q in the CSV, got:\n%s", line, csv)
+		}
+	}
+}
+
+func TestReport_MarshalJSON(t *testing.T) {
+	report := testReport()
+	if err := report.Evaluate(Thresholds{MinSendRate: 1000}); err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	data, err := report.Marshal(OutputJSON)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded["driver"] != "memory" || decoded["passed"] != false {
+		t.Errorf("expected driver memory and passed false, got %v and %v", decoded["driver"], decoded["passed"])
+	}
+	// The summary is embedded, its fields are at the top level
+	if _, ok := decoded["messages"]; !ok {
+		t.Errorf("expected messages at the top level, got %v", decoded)
+	}
+	if _, ok := decoded["Settings"]; ok {
+		t.Errorf("expected settings not to be written, got %v", decoded)
+	}
+}
+
+func TestValidateOutput(t *testing.T) {
+	tests := []struct {
+		format  string
+		invalid bool
+	}{
+		{"", false},
+		{OutputJSON, false},
+		{OutputCSV, false},
+		{"yaml", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateOutput(tt.format); (err != nil) != tt.invalid {
+			t.Errorf("%q: expected invalid %t, got %v", tt.format, tt.invalid, err)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package loadtest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	RateProfiles      = []string{"constant", "ramp", "step"}
+	RateScopes        = []string{"global", "producer"}
+	SizeDistributions = []string{"fixed", "uniform", "normal", "exponential"}
+)
+
+// maxRateCatchUp limits how far a producer may fall behind its schedule before the pacer
+// stops trying to catch up, which would otherwise result in a burst
+const maxRateCatchUp = time.Second
+
+// RateOptions describes the target publish rate over the course of the test
+type RateOptions struct {
+	Rate      float64
+	StartRate float64
+	Scope     string
+	Profile   string
+	Steps     int
+}
+
+// SizeOptions describes the distribution of message sizes
+type SizeOptions struct {
+	MessageSize  int
+	Distribution string
+	MinSize      int
+	MaxSize      int
+	StdDev       int
+}
+
+func (o RateOptions) Validate() error {
+	if o.Rate < 0 || o.StartRate < 0 {
+		return fmt.Errorf("publish rate must not be negative")
+	}
+	if !slices.Contains(RateProfiles, o.Profile) {
+		return fmt.Errorf("invalid rate profile '%s'. Available profiles: %s", o.Profile, strings.Join(RateProfiles, ", "))
+	}
+	if !slices.Contains(RateScopes, o.Scope) {
+		return fmt.Errorf("invalid rate scope '%s'. Available scopes: %s", o.Scope, strings.Join(RateScopes, ", "))
+	}
+	if o.Profile != "constant" && o.Rate == 0 {
+		return fmt.Errorf("rate profile '%s' requires a target rate", o.Profile)
+	}
+	if o.Profile == "step" && o.Steps < 1 {
+		return fmt.Errorf("step rate profile requires at least one step")
+	}
+	return nil
+}
+
+func (o SizeOptions) Validate() error {
+	if !slices.Contains(SizeDistributions, o.Distribution) {
+		return fmt.Errorf("invalid message size distribution '%s'. Available distributions: %s", o.Distribution, strings.Join(SizeDistributions, ", "))
+	}
+	if o.MaxSize > 0 && o.MinSize > o.MaxSize {
+		return fmt.Errorf("minimum message size must not exceed maximum message size")
+	}
+	return nil
+}
+
+// RateAt returns the target rate (msgs/sec) of a single producer at the given elapsed time
+func (o RateOptions) RateAt(elapsed, total time.Duration, producers int) float64 {
+	rate := o.Rate
+	if o.Profile != "constant" && total > 0 {
+		progress := math.Min(1, float64(elapsed)/float64(total))
+		switch o.Profile {
+		case "ramp":
+			rate = o.StartRate + (o.Rate-o.StartRate)*progress
+		case "step":
+			step := math.Min(float64(o.Steps-1), math.Floor(progress*float64(o.Steps)))
+			if o.Steps == 1 {
+				rate = o.Rate
+			} else {
+				rate = o.StartRate + (o.Rate-o.StartRate)*step/float64(o.Steps-1)
+			}
+		}
+	}
+	if o.Scope == "global" && producers > 0 {
+		rate /= float64(producers)
+	}
+	return rate
+}
+
+// Pacer spaces publishes of one producer according to the rate profile
+type Pacer struct {
+	options   RateOptions
+	producers int
+	duration  time.Duration
+	start     time.Time
+	next      time.Time
+}
+
+func NewPacer(options RateOptions, producers int, duration time.Duration) *Pacer {
+	now := time.Now()
+	return &Pacer{options: options, producers: producers, duration: duration, start: now, next: now}
+}
+
+// Wait blocks until the next message may be published. It returns false when the stop
+// channel is closed while waiting.
+func (p *Pacer) Wait(stop <-chan struct{}) bool {
+	if p.options.Rate == 0 && p.options.StartRate == 0 {
+		return true
+	}
+
+	now := time.Now()
+	if now.Sub(p.next) > maxRateCatchUp {
+		p.next = now
+	}
+	if delay := p.next.Sub(now); delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-stop:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+
+	rate := p.options.RateAt(time.Since(p.start), p.duration, p.producers)
+	if rate <= 0 {
+		// Nothing to send at this point of the profile, check again shortly
+		p.next = p.next.Add(100 * time.Millisecond)
+		return p.Wait(stop)
+	}
+	p.next = p.next.Add(time.Duration(float64(time.Second) / rate))
+	return true
+}
+
+// MessageSizer draws message sizes from the configured distribution
+type MessageSizer struct {
+	options SizeOptions
+	rnd     *rand.Rand
+}
+
+func NewMessageSizer(options SizeOptions) *MessageSizer {
+	return &MessageSizer{options: options, rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// MaxSize returns the largest message the sizer can produce
+func (s *MessageSizer) MaxSize() int {
+	if s.options.Distribution == "fixed" {
+		return s.options.MessageSize
+	}
+	if s.options.MaxSize > 0 {
+		return s.options.MaxSize
+	}
+	// Keep the uniform distribution centered on the mean size
+	if s.options.Distribution == "uniform" {
+		return max(2*s.options.MessageSize-s.options.MinSize, s.options.MinSize)
+	}
+	// Unbounded distributions are capped at 4x the mean size
+	return max(s.options.MessageSize*4, s.options.MinSize)
+}
+
+func (s *MessageSizer) Next() int {
+	var size float64
+	switch s.options.Distribution {
+	case "uniform":
+		low, high := s.options.MinSize, s.MaxSize()
+		size = float64(low + s.rnd.Intn(high-low+1))
+	case "normal":
+		stddev := float64(s.options.StdDev)
+		if stddev == 0 {
+			stddev = float64(s.options.MessageSize) / 4
+		}
+		size = s.rnd.NormFloat64()*stddev + float64(s.options.MessageSize)
+	case "exponential":
+		size = s.rnd.ExpFloat64() * float64(s.options.MessageSize)
+	default:
+		return s.options.MessageSize
+	}
+	return int(math.Max(float64(s.options.MinSize), math.Min(float64(s.MaxSize()), math.Round(size))))
+}
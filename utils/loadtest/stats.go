@@ -0,0 +1,172 @@
+package loadtest
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type pendingMessage struct {
+	stream string
+	seq    int64
+}
+
+// ProducerStats tracks publisher confirms, nacks and returns of a single producer
+type ProducerStats struct {
+	ID            int
+	Published     atomic.Int64
+	Confirmed     atomic.Int64
+	Nacked        atomic.Int64
+	Returned      atomic.Int64
+	PublishErrors atomic.Int64
+
+	mu       sync.Mutex
+	pending  map[uint64]pendingMessage
+	sequence map[string]int64
+}
+
+func newProducerStats(id int) *ProducerStats {
+	return &ProducerStats{
+		ID:       id,
+		pending:  make(map[uint64]pendingMessage),
+		sequence: make(map[string]int64),
+	}
+}
+
+// NextSeq returns the next sequence number for the stream
+func (p *ProducerStats) NextSeq(stream string) int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sequence[stream]++
+	return p.sequence[stream]
+}
+
+// TrackPending remembers a published message until the broker confirms its delivery tag
+func (p *ProducerStats) TrackPending(deliveryTag uint64, stream string, seq int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending[deliveryTag] = pendingMessage{stream: stream, seq: seq}
+}
+
+func (p *ProducerStats) ForgetPending(deliveryTag uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, deliveryTag)
+}
+
+func (p *ProducerStats) Outstanding() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pending)
+}
+
+// Resolve handles a broker ack/nack for a previously tracked message
+func (p *ProducerStats) Resolve(deliveryTag uint64, ack bool, tracker *DeliveryTracker) {
+	p.mu.Lock()
+	msg, ok := p.pending[deliveryTag]
+	delete(p.pending, deliveryTag)
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if ack {
+		p.Confirmed.Add(1)
+		tracker.MarkConfirmed(msg.stream, msg.seq)
+	} else {
+		p.Nacked.Add(1)
+	}
+}
+
+// Stats collects the counters, latencies and delivery tracking of a load test
+type Stats struct {
+	Sent          atomic.Int64
+	Received      atomic.Int64
+	SentBytes     atomic.Int64
+	ReceivedBytes atomic.Int64
+	Acked         atomic.Int64
+	Requeued      atomic.Int64
+	Rejected      atomic.Int64
+	Latency       *LatencyHistogram
+	Tracker       *DeliveryTracker
+
+	mu        sync.Mutex
+	producers []*ProducerStats
+	samples   []IntervalSample
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		Latency: NewLatencyHistogram(),
+		Tracker: NewDeliveryTracker(),
+	}
+}
+
+func (s *Stats) NewProducer(id int) *ProducerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := newProducerStats(id)
+	s.producers = append(s.producers, p)
+	return p
+}
+
+// Producers returns the producers ordered by id
+func (s *Stats) Producers() []*ProducerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	producers := append([]*ProducerStats(nil), s.producers...)
+	sort.Slice(producers, func(i, j int) bool { return producers[i].ID < producers[j].ID })
+	return producers
+}
+
+// RecordDelivery counts a consumed message and updates end-to-end latency, sequence
+// tracking and transferred bytes
+func (s *Stats) RecordDelivery(queue string, trace Trace, traced bool, size int, receivedAt time.Time) {
+	s.Received.Add(1)
+	s.ReceivedBytes.Add(int64(size))
+	if !traced {
+		s.Tracker.MarkUntraced()
+		return
+	}
+	s.Latency.Record(s.Tracker.MarkReceived(queue, trace, receivedAt))
+}
+
+func (s *Stats) addSample(sample IntervalSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = append(s.samples, sample)
+}
+
+func (s *Stats) Samples() []IntervalSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]IntervalSample(nil), s.samples...)
+}
+
+// SampleThroughput records sent/received deltas every interval until stop is closed
+func (s *Stats) SampleThroughput(start time.Time, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastSent, lastReceived int64
+	lastTime := start
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			sent := s.Sent.Load()
+			received := s.Received.Load()
+			seconds := now.Sub(lastTime).Seconds()
+			s.addSample(IntervalSample{
+				Elapsed:     now.Sub(start).Seconds(),
+				Sent:        sent - lastSent,
+				Received:    received - lastReceived,
+				SendRate:    float64(sent-lastSent) / seconds,
+				ReceiveRate: float64(received-lastReceived) / seconds,
+			})
+			lastSent, lastReceived, lastTime = sent, received, now
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package loadtest
+
+import "time"
+
+// Summary is the machine-readable result of the statistics after a test
+type Summary struct {
+	Messages   MessageCounts    `json:"messages"`
+	Confirms   ConfirmCounts    `json:"confirms"`
+	Latency    LatencySummary   `json:"latency"`
+	Integrity  IntegrityCounts  `json:"integrity"`
+	Throughput Throughput       `json:"throughput"`
+	Samples    []IntervalSample `json:"samples"`
+	Producers  []ProducerCounts `json:"producers"`
+}
+
+type MessageCounts struct {
+	Sent              int64   `json:"sent"`
+	Received          int64   `json:"received"`
+	DuplicationFactor float64 `json:"duplicationFactor"`
+	Acked             int64   `json:"acked"`
+	Rejected          int64   `json:"rejected"`
+	Requeued          int64   `json:"requeued"`
+}
+
+type ConfirmCounts struct {
+	Published     int64 `json:"published"`
+	Confirmed     int64 `json:"confirmed"`
+	Nacked        int64 `json:"nacked"`
+	Returned      int64 `json:"returned"`
+	Unconfirmed   int64 `json:"unconfirmed"`
+	PublishErrors int64 `json:"publishErrors"`
+}
+
+type ProducerCounts struct {
+	ID          int   `json:"id"`
+	Published   int64 `json:"published"`
+	Confirmed   int64 `json:"confirmed"`
+	Nacked      int64 `json:"nacked"`
+	Returned    int64 `json:"returned"`
+	Unconfirmed int64 `json:"unconfirmed"`
+}
+
+// LatencySummary holds end-to-end latencies in milliseconds
+type LatencySummary struct {
+	Samples int64   `json:"samples"`
+	Min     float64 `json:"minMs"`
+	Avg     float64 `json:"avgMs"`
+	P50     float64 `json:"p50Ms"`
+	P90     float64 `json:"p90Ms"`
+	P95     float64 `json:"p95Ms"`
+	P99     float64 `json:"p99Ms"`
+	P999    float64 `json:"p999Ms"`
+	Max     float64 `json:"maxMs"`
+}
+
+type IntegrityCounts struct {
+	Lost       int64 `json:"lost"`
+	Duplicated int64 `json:"duplicated"`
+	Untraced   int64 `json:"untraced"`
+}
+
+type Throughput struct {
+	SendRate          float64 `json:"sendRate"`
+	ReceiveRate       float64 `json:"receiveRate"`
+	DataSentMB        float64 `json:"dataSentMB"`
+	DataReceivedMB    float64 `json:"dataReceivedMB"`
+	DataSendRateMB    float64 `json:"dataSendRateMBps"`
+	DataReceiveRateMB float64 `json:"dataReceiveRateMBps"`
+}
+
+// IntervalSample is the throughput observed during one sampling interval
+type IntervalSample struct {
+	Elapsed     float64 `json:"elapsedSeconds"`
+	Sent        int64   `json:"sent"`
+	Received    int64   `json:"received"`
+	SendRate    float64 `json:"sendRate"`
+	ReceiveRate float64 `json:"receiveRate"`
+}
+
+// Summarize computes the summary of a test that ran for the given duration
+func (s *Stats) Summarize(duration time.Duration) Summary {
+	durationSeconds := duration.Seconds()
+	sent := s.Sent.Load()
+	received := s.Received.Load()
+
+	summary := Summary{
+		Messages: MessageCounts{
+			Sent:     sent,
+			Received: received,
+			Acked:    s.Acked.Load(),
+			Rejected: s.Rejected.Load(),
+			Requeued: s.Requeued.Load(),
+		},
+		Integrity: IntegrityCounts{
+			Lost:       s.Tracker.Lost(),
+			Duplicated: s.Tracker.Duplicates(),
+			Untraced:   s.Tracker.Untraced(),
+		},
+		Samples: s.Samples(),
+	}
+	if sent > 0 {
+		summary.Messages.DuplicationFactor = float64(received) / float64(sent)
+	}
+
+	for _, p := range s.Producers() {
+		producer := ProducerCounts{
+			ID:          p.ID,
+			Published:   p.Published.Load(),
+			Confirmed:   p.Confirmed.Load(),
+			Nacked:      p.Nacked.Load(),
+			Returned:    p.Returned.Load(),
+			Unconfirmed: int64(p.Outstanding()),
+		}
+		summary.Producers = append(summary.Producers, producer)
+		summary.Confirms.Published += producer.Published
+		summary.Confirms.Confirmed += producer.Confirmed
+		summary.Confirms.Nacked += producer.Nacked
+		summary.Confirms.Returned += producer.Returned
+		summary.Confirms.Unconfirmed += producer.Unconfirmed
+		summary.Confirms.PublishErrors += p.PublishErrors.Load()
+	}
+
+	summary.Latency = LatencySummary{
+		Samples: s.Latency.Count(),
+		Min:     DurationMs(s.Latency.Min()),
+		Avg:     DurationMs(s.Latency.Mean()),
+		P50:     DurationMs(s.Latency.Percentile(0.50)),
+		P90:     DurationMs(s.Latency.Percentile(0.90)),
+		P95:     DurationMs(s.Latency.Percentile(0.95)),
+		P99:     DurationMs(s.Latency.Percentile(0.99)),
+		P999:    DurationMs(s.Latency.Percentile(0.999)),
+		Max:     DurationMs(s.Latency.Max()),
+	}
+
+	if durationSeconds > 0 {
+		sentBytes := float64(s.SentBytes.Load())
+		receivedBytes := float64(s.ReceivedBytes.Load())
+		summary.Throughput = Throughput{
+			SendRate:          float64(sent) / durationSeconds,
+			ReceiveRate:       float64(received) / durationSeconds,
+			DataSentMB:        sentBytes / (1024 * 1024),
+			DataReceivedMB:    receivedBytes / (1024 * 1024),
+			DataSendRateMB:    sentBytes / (1024 * 1024 * durationSeconds),
+			DataReceiveRateMB: receivedBytes / (1024 * 1024 * durationSeconds),
+		}
+	}
+	return summary
+}
+
+// Measurements returns the values checked by thresholds
+func (s Summary) Measurements() Measurements {
+	return Measurements{
+		SendRate:       s.Throughput.SendRate,
+		ReceiveRate:    s.Throughput.ReceiveRate,
+		LatencySamples: s.Latency.Samples,
+		LatencyP99:     s.Latency.P99,
+		Lost:           s.Integrity.Lost,
+		Nacked:         s.Confirms.Nacked,
+		Unconfirmed:    s.Confirms.Unconfirmed,
+	}
+}
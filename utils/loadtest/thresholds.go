@@ -0,0 +1,80 @@
+package loadtest
+
+import (
+	"fmt"
+	"time"
+)
+
+// Thresholds are pass/fail criteria of a load test, zero values are not checked
+type Thresholds struct {
+	MinSendRate    float64 `yaml:"minSendRate"`
+	MinReceiveRate float64 `yaml:"minReceiveRate"`
+	MaxLatencyP99  string  `yaml:"maxLatencyP99"`
+	ZeroLoss       bool    `yaml:"zeroLoss"`
+}
+
+type ThresholdResult struct {
+	Name     string `json:"name"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Passed   bool   `json:"passed"`
+}
+
+// Measurements are the results checked by the thresholds, latencies in milliseconds
+type Measurements struct {
+	SendRate       float64
+	ReceiveRate    float64
+	LatencySamples int64
+	LatencyP99     float64
+	Lost           int64
+	Nacked         int64
+	Unconfirmed    int64
+}
+
+// Validate checks the latency threshold format
+func (t Thresholds) Validate() error {
+	if t.MaxLatencyP99 == "" {
+		return nil
+	}
+	if _, err := time.ParseDuration(t.MaxLatencyP99); err != nil {
+		return fmt.Errorf("invalid latency threshold: %w", err)
+	}
+	return nil
+}
+
+// Evaluate checks the measurements and reports whether all thresholds passed
+func (t Thresholds) Evaluate(m Measurements) ([]ThresholdResult, bool, error) {
+	var results []ThresholdResult
+	passed := true
+	add := func(name, expected, actual string, ok bool) {
+		results = append(results, ThresholdResult{Name: name, Expected: expected, Actual: actual, Passed: ok})
+		passed = passed && ok
+	}
+
+	if t.MinSendRate > 0 {
+		add("min send rate", fmt.Sprintf(">= %.2f msgs/sec", t.MinSendRate),
+			fmt.Sprintf("%.2f msgs/sec", m.SendRate), m.SendRate >= t.MinSendRate)
+	}
+	if t.MinReceiveRate > 0 {
+		add("min receive rate", fmt.Sprintf(">= %.2f msgs/sec", t.MinReceiveRate),
+			fmt.Sprintf("%.2f msgs/sec", m.ReceiveRate), m.ReceiveRate >= t.MinReceiveRate)
+	}
+	if t.MaxLatencyP99 != "" {
+		maxLatency, err := time.ParseDuration(t.MaxLatencyP99)
+		if err != nil {
+			return results, passed, fmt.Errorf("invalid latency threshold: %w", err)
+		}
+		add("max p99 latency", fmt.Sprintf("<= %.2f ms", DurationMs(maxLatency)),
+			fmt.Sprintf("%.2f ms", m.LatencyP99), m.LatencySamples > 0 && m.LatencyP99 <= DurationMs(maxLatency))
+	}
+	if t.ZeroLoss {
+		failures := m.Lost + m.Nacked + m.Unconfirmed
+		add("zero loss", "0 lost, nacked or unconfirmed",
+			fmt.Sprintf("%d lost, %d nacked, %d unconfirmed", m.Lost, m.Nacked, m.Unconfirmed), failures == 0)
+	}
+	return results, passed, nil
+}
+
+func DurationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,74 @@
+package loadtest
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Message headers used to trace every published message through the broker
+const (
+	HeaderStream = "x-vpd-stream"
+	HeaderSeq    = "x-vpd-seq"
+	HeaderSentAt = "x-vpd-sent-at"
+)
+
+// StreamKey identifies a sequence of messages published by one producer to the same
+// publish target. All messages of a stream are routed identically, so every queue that
+// receives one message of a stream is expected to receive all of them.
+func StreamKey(producerID int, target string) string {
+	return fmt.Sprintf("p%d|%s", producerID, target)
+}
+
+// Trace identifies a published message and its send time in nanoseconds
+type Trace struct {
+	Stream string
+	Seq    int64
+	SentAt int64
+}
+
+func NewTrace(stream string, seq int64, sentAt time.Time) Trace {
+	return Trace{Stream: stream, Seq: seq, SentAt: sentAt.UnixNano()}
+}
+
+// Attach adds the trace to the message as headers, or in front of the body as
+// "<stream>\n<seq>\n<sent at>\n" for brokers without headers
+func (t Trace) Attach(msg *Message, headers bool) {
+	if headers {
+		if msg.Headers == nil {
+			msg.Headers = make(map[string]string, 3)
+		}
+		msg.Headers[HeaderStream] = t.Stream
+		msg.Headers[HeaderSeq] = strconv.FormatInt(t.Seq, 10)
+		msg.Headers[HeaderSentAt] = strconv.FormatInt(t.SentAt, 10)
+		return
+	}
+	prefix := fmt.Sprintf("%s\n%d\n%d\n", t.Stream, t.Seq, t.SentAt)
+	msg.Body = append([]byte(prefix), msg.Body...)
+}
+
+// ParseTrace reads the trace from the headers or the body prefix. The returned body has
+// the prefix removed.
+func ParseTrace(msg Message) (Trace, []byte, bool) {
+	body := msg.Body
+	stream, seqText, sentAtText := msg.Headers[HeaderStream], msg.Headers[HeaderSeq], msg.Headers[HeaderSentAt]
+	if stream == "" {
+		parts := bytes.SplitN(msg.Body, []byte("\n"), 4)
+		if len(parts) != 4 {
+			return Trace{}, body, false
+		}
+		stream, seqText, sentAtText = string(parts[0]), string(parts[1]), string(parts[2])
+		body = parts[3]
+	}
+
+	seq, err := strconv.ParseInt(seqText, 10, 64)
+	if err != nil {
+		return Trace{}, msg.Body, false
+	}
+	sentAt, err := strconv.ParseInt(sentAtText, 10, 64)
+	if err != nil {
+		return Trace{}, msg.Body, false
+	}
+	return Trace{Stream: stream, Seq: seq, SentAt: sentAt}, body, true
+}
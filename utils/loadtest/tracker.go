@@ -0,0 +1,161 @@
+package loadtest
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// seqSet is a growable bitset of message sequence numbers (starting at 1)
+type seqSet struct {
+	words []uint64
+}
+
+// add marks seq as present and reports whether it was newly added
+func (s *seqSet) add(seq int64) bool {
+	if seq <= 0 {
+		return false
+	}
+	word, bit := seq/64, uint(seq%64)
+	for int64(len(s.words)) <= word {
+		s.words = append(s.words, 0)
+	}
+	if s.words[word]&(1<<bit) != 0 {
+		return false
+	}
+	s.words[word] |= 1 << bit
+	return true
+}
+
+func (s *seqSet) has(seq int64) bool {
+	word, bit := seq/64, uint(seq%64)
+	if seq <= 0 || int64(len(s.words)) <= word {
+		return false
+	}
+	return s.words[word]&(1<<bit) != 0
+}
+
+// each calls fn for every sequence number present in the set
+func (s *seqSet) each(fn func(seq int64)) {
+	for w, word := range s.words {
+		for word != 0 {
+			bit := bits.TrailingZeros64(word)
+			fn(int64(w)*64 + int64(bit))
+			word &= word - 1
+		}
+	}
+}
+
+// DeliveryTracker records which sequence numbers of which stream arrived on which queue
+// and which were confirmed or returned by the broker
+type DeliveryTracker struct {
+	mu         sync.Mutex
+	received   map[string]map[string]*seqSet // queue -> stream -> seqs
+	confirmed  map[string]*seqSet            // stream -> seqs
+	returned   map[string]*seqSet            // stream -> seqs
+	duplicates atomic.Int64
+	untraced   atomic.Int64
+}
+
+func NewDeliveryTracker() *DeliveryTracker {
+	return &DeliveryTracker{
+		received:  make(map[string]map[string]*seqSet),
+		confirmed: make(map[string]*seqSet),
+		returned:  make(map[string]*seqSet),
+	}
+}
+
+func (t *DeliveryTracker) MarkConfirmed(stream string, seq int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	set, ok := t.confirmed[stream]
+	if !ok {
+		set = &seqSet{}
+		t.confirmed[stream] = set
+	}
+	set.add(seq)
+}
+
+func (t *DeliveryTracker) MarkReturned(stream string, seq int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	set, ok := t.returned[stream]
+	if !ok {
+		set = &seqSet{}
+		t.returned[stream] = set
+	}
+	set.add(seq)
+}
+
+// MarkReceived records a consumed message and returns its end-to-end latency
+func (t *DeliveryTracker) MarkReceived(queue string, trace Trace, now time.Time) time.Duration {
+	t.mu.Lock()
+	streams, ok := t.received[queue]
+	if !ok {
+		streams = make(map[string]*seqSet)
+		t.received[queue] = streams
+	}
+	set, ok := streams[trace.Stream]
+	if !ok {
+		set = &seqSet{}
+		streams[trace.Stream] = set
+	}
+	if !set.add(trace.Seq) {
+		t.duplicates.Add(1)
+	}
+	t.mu.Unlock()
+
+	return now.Sub(time.Unix(0, trace.SentAt))
+}
+
+// MarkUntraced counts a consumed message without trace data
+func (t *DeliveryTracker) MarkUntraced() {
+	t.untraced.Add(1)
+}
+
+func (t *DeliveryTracker) Duplicates() int64 {
+	return t.duplicates.Load()
+}
+
+func (t *DeliveryTracker) Untraced() int64 {
+	return t.untraced.Load()
+}
+
+// Lost counts expected deliveries that never happened: confirmed messages missing on a
+// queue that received other messages of the same stream, and confirmed (not returned)
+// messages of streams that never reached any queue
+func (t *DeliveryTracker) Lost() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var lost int64
+	delivered := make(map[string]bool)
+	for _, streams := range t.received {
+		for stream, got := range streams {
+			delivered[stream] = true
+			confirmed, ok := t.confirmed[stream]
+			if !ok {
+				continue
+			}
+			confirmed.each(func(seq int64) {
+				if !got.has(seq) {
+					lost++
+				}
+			})
+		}
+	}
+
+	for stream, confirmed := range t.confirmed {
+		if delivered[stream] {
+			continue
+		}
+		returned := t.returned[stream]
+		confirmed.each(func(seq int64) {
+			if returned == nil || !returned.has(seq) {
+				lost++
+			}
+		})
+	}
+	return lost
+}
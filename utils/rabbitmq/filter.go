@@ -0,0 +1,89 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/tidwall/gjson"
+)
+
+// MessageFilter selects messages by headers, routing key and values in a JSON body. All
+// conditions must match; a condition without a value only requires the header or path to exist.
+type MessageFilter struct {
+	Headers    map[string]*string
+	RoutingKey string
+	JSONPaths  map[string]*string
+}
+
+// ParseMessageFilter builds a filter from key=value header and JSON path conditions and a
+// routing key glob pattern such as "order.*.failed"
+func ParseMessageFilter(headers []string, routingKey string, jsonPaths []string) (MessageFilter, error) {
+	filter := MessageFilter{RoutingKey: routingKey}
+	if routingKey != "" {
+		if _, err := path.Match(routingKey, ""); err != nil {
+			return filter, fmt.Errorf("invalid routing key pattern '%s': %w", routingKey, err)
+		}
+	}
+
+	var err error
+	if filter.Headers, err = parseConditions(headers); err != nil {
+		return filter, fmt.Errorf("invalid header filter: %w", err)
+	}
+	if filter.JSONPaths, err = parseConditions(jsonPaths); err != nil {
+		return filter, fmt.Errorf("invalid JSON filter: %w", err)
+	}
+	return filter, nil
+}
+
+func parseConditions(conditions []string) (map[string]*string, error) {
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]*string, len(conditions))
+	for _, condition := range conditions {
+		key, value, found := strings.Cut(condition, "=")
+		if key == "" {
+			return nil, fmt.Errorf("empty key in '%s'", condition)
+		}
+		if found {
+			result[key] = &value
+		} else {
+			result[key] = nil
+		}
+	}
+	return result, nil
+}
+
+// Empty reports whether the filter matches every message
+func (f MessageFilter) Empty() bool {
+	return f.RoutingKey == "" && len(f.Headers) == 0 && len(f.JSONPaths) == 0
+}
+
+// Matches reports whether the delivery satisfies all conditions of the filter
+func (f MessageFilter) Matches(d amqp091.Delivery) bool {
+	if f.RoutingKey != "" {
+		if matched, _ := path.Match(f.RoutingKey, d.RoutingKey); !matched {
+			return false
+		}
+	}
+	for key, expected := range f.Headers {
+		value, ok := d.Headers[key]
+		if !ok || (expected != nil && fmt.Sprint(value) != *expected) {
+			return false
+		}
+	}
+	if len(f.JSONPaths) > 0 {
+		if !gjson.ValidBytes(d.Body) {
+			return false
+		}
+		for jsonPath, expected := range f.JSONPaths {
+			result := gjson.GetBytes(d.Body, jsonPath)
+			if !result.Exists() || (expected != nil && result.String() != *expected) {
+				return false
+			}
+		}
+	}
+	return true
+}
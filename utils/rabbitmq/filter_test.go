@@ -0,0 +1,97 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+func TestParseMessageFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    []string
+		routingKey string
+		jsonPaths  []string
+		invalid    bool
+	}{
+		{"empty", nil, "", nil, false},
+		{"conditions", []string{"tenant=eu", "x-retry"}, "order.*", []string{"order.id=1", "customer"}, false},
+		{"value with equals sign", []string{"query=a=b"}, "", nil, false},
+		{"invalid routing key pattern", nil, "order.[", nil, true},
+		{"empty header name", []string{"=eu"}, "", nil, true},
+		{"empty JSON path", nil, "", []string{"=1"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseMessageFilter(tt.headers, tt.routingKey, tt.jsonPaths)
+			if (err != nil) != tt.invalid {
+				t.Fatalf("expected invalid %t, got %v", tt.invalid, err)
+			}
+			if err == nil && filter.Empty() != (tt.name == "empty") {
+				t.Errorf("expected empty %t, got %t", tt.name == "empty", filter.Empty())
+			}
+		})
+	}
+
+	filter, err := ParseMessageFilter([]string{"query=a=b", "x-retry"}, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value := filter.Headers["query"]; value == nil || *value != "a=b" {
+		t.Errorf("expected the value after the first '=', got %v", value)
+	}
+	if value, ok := filter.Headers["x-retry"]; !ok || value != nil {
+		t.Errorf("expected an existence condition for x-retry, got %v", value)
+	}
+}
+
+func TestMessageFilter_Matches(t *testing.T) {
+	delivery := amqp091.Delivery{
+		RoutingKey: "order.eu.failed",
+		Headers:    amqp091.Table{"tenant": "eu", "attempts": int64(3)},
+		Body:       []byte(`{"order":{"id":42,"status":"failed"},"items":[{"sku":"a"}]}`),
+	}
+	tests := []struct {
+		name       string
+		headers    []string
+		routingKey string
+		jsonPaths  []string
+		body       string
+		want       bool
+	}{
+		{"empty filter", nil, "", nil, "", true},
+		{"routing key glob", nil, "order.*.failed", nil, "", true},
+		{"routing key glob mismatch", nil, "order.*.created", nil, "", false},
+		{"star spans dots unlike AMQP topics", nil, "order.*", nil, "", true},
+		{"header value", []string{"tenant=eu"}, "", nil, "", true},
+		{"header value mismatch", []string{"tenant=us"}, "", nil, "", false},
+		{"numeric header value", []string{"attempts=3"}, "", nil, "", true},
+		{"header exists", []string{"attempts"}, "", nil, "", true},
+		{"header missing", []string{"x-retry"}, "", nil, "", false},
+		{"JSON value", nil, "", []string{"order.status=failed"}, "", true},
+		{"JSON number", nil, "", []string{"order.id=42"}, "", true},
+		{"JSON array element", nil, "", []string{"items.0.sku=a"}, "", true},
+		{"JSON value mismatch", nil, "", []string{"order.status=ok"}, "", false},
+		{"JSON path exists", nil, "", []string{"order.id"}, "", true},
+		{"JSON path missing", nil, "", []string{"customer"}, "", false},
+		{"invalid JSON body", nil, "", []string{"order.id"}, `{"order":`, false},
+		{"invalid JSON body without JSON conditions", []string{"tenant=eu"}, "", nil, "not json", true},
+		{"all conditions", []string{"tenant=eu"}, "order.#.failed", []string{"order.id=42"}, "", false},
+		{"all conditions match", []string{"tenant=eu"}, "order.??.failed", []string{"order.id=42"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseMessageFilter(tt.headers, tt.routingKey, tt.jsonPaths)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			d := delivery
+			if tt.body != "" {
+				d.Body = []byte(tt.body)
+			}
+			if got := filter.Matches(d); got != tt.want {
+				t.Errorf("expected %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
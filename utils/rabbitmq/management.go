@@ -0,0 +1,258 @@
+package rabbitmq
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ManagementClient talks to the RabbitMQ management HTTP API
+type ManagementClient struct {
+	BaseURL  string
+	User     string
+	Password string
+	// VirtualHost is the virtual host of the connection options the client was created from
+	VirtualHost string
+	HTTP        *http.Client
+}
+
+// Rate is a message rate reported by the management API
+type Rate struct {
+	Rate float64 `json:"rate"`
+}
+
+// MessageStats holds the message rates of a queue
+type MessageStats struct {
+	PublishDetails    Rate `json:"publish_details"`
+	DeliverGetDetails Rate `json:"deliver_get_details"`
+	AckDetails        Rate `json:"ack_details"`
+}
+
+type Queue struct {
+	Name                   string       `json:"name"`
+	Vhost                  string       `json:"vhost"`
+	Type                   string       `json:"type"`
+	Durable                bool         `json:"durable"`
+	State                  string       `json:"state"`
+	Messages               int          `json:"messages"`
+	MessagesReady          int          `json:"messages_ready"`
+	MessagesUnacknowledged int          `json:"messages_unacknowledged"`
+	Consumers              int          `json:"consumers"`
+	MessageStats           MessageStats `json:"message_stats"`
+}
+
+type Exchange struct {
+	Name       string `json:"name"`
+	Vhost      string `json:"vhost"`
+	Type       string `json:"type"`
+	Durable    bool   `json:"durable"`
+	AutoDelete bool   `json:"auto_delete"`
+	Internal   bool   `json:"internal"`
+}
+
+type Binding struct {
+	Source          string         `json:"source"`
+	Vhost           string         `json:"vhost"`
+	Destination     string         `json:"destination"`
+	DestinationType string         `json:"destination_type"`
+	RoutingKey      string         `json:"routing_key"`
+	Arguments       map[string]any `json:"arguments"`
+}
+
+type ConnectionInfo struct {
+	Name     string `json:"name"`
+	Vhost    string `json:"vhost"`
+	User     string `json:"user"`
+	PeerHost string `json:"peer_host"`
+	PeerPort int    `json:"peer_port"`
+	State    string `json:"state"`
+	Channels int    `json:"channels"`
+	Protocol string `json:"protocol"`
+	SSL      bool   `json:"ssl"`
+}
+
+type ChannelInfo struct {
+	Name                   string `json:"name"`
+	Vhost                  string `json:"vhost"`
+	User                   string `json:"user"`
+	Number                 int    `json:"number"`
+	State                  string `json:"state"`
+	Consumers              int    `json:"consumer_count"`
+	MessagesUnacknowledged int    `json:"messages_unacknowledged"`
+	Prefetch               int    `json:"prefetch_count"`
+	Confirm                bool   `json:"confirm"`
+}
+
+// NewManagementClient creates a client for the management API of the broker in the options.
+// Without baseURL it uses port 15672, or 15671 for TLS connections, on the AMQP host.
+func NewManagementClient(options ConnectionOptions, baseURL string) (*ManagementClient, error) {
+	uri, err := options.uri()
+	if err != nil {
+		return nil, err
+	}
+
+	secure := uri.Scheme == "amqps"
+	if baseURL == "" {
+		scheme, port := "http", 15672
+		if secure {
+			scheme, port = "https", 15671
+		}
+		baseURL = fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(uri.Host, strconv.Itoa(port)))
+	} else {
+		secure = strings.HasPrefix(baseURL, "https://")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if secure {
+		host := uri.Host
+		if parsed, err := url.Parse(baseURL); err == nil {
+			host = parsed.Hostname()
+		}
+		transport.TLSClientConfig, err = newTLSConfig(options.TLS, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create TLS config: %w", err)
+		}
+	}
+
+	return &ManagementClient{
+		BaseURL:     strings.TrimSuffix(baseURL, "/"),
+		User:        uri.Username,
+		Password:    uri.Password,
+		VirtualHost: uri.Vhost,
+		HTTP:        &http.Client{Timeout: 30 * time.Second, Transport: transport},
+	}, nil
+}
+
+// vhostPath returns the API path of a collection, limited to the vhost unless it is empty
+func vhostPath(collection, vhost string) string {
+	if vhost == "" {
+		return "/api/" + collection
+	}
+	return "/api/" + collection + "/" + url.PathEscape(vhost)
+}
+
+func (c *ManagementClient) ListQueues(vhost string) ([]Queue, error) {
+	var queues []Queue
+	return queues, c.getJSON(vhostPath("queues", vhost), &queues)
+}
+
+func (c *ManagementClient) ListExchanges(vhost string) ([]Exchange, error) {
+	var exchanges []Exchange
+	return exchanges, c.getJSON(vhostPath("exchanges", vhost), &exchanges)
+}
+
+func (c *ManagementClient) ListBindings(vhost string) ([]Binding, error) {
+	var bindings []Binding
+	return bindings, c.getJSON(vhostPath("bindings", vhost), &bindings)
+}
+
+func (c *ManagementClient) ListConnections(vhost string) ([]ConnectionInfo, error) {
+	path := "/api/connections"
+	if vhost != "" {
+		path = "/api/vhosts/" + url.PathEscape(vhost) + "/connections"
+	}
+	var connections []ConnectionInfo
+	return connections, c.getJSON(path, &connections)
+}
+
+func (c *ManagementClient) ListChannels(vhost string) ([]ChannelInfo, error) {
+	path := "/api/channels"
+	if vhost != "" {
+		path = "/api/vhosts/" + url.PathEscape(vhost) + "/channels"
+	}
+	var channels []ChannelInfo
+	return channels, c.getJSON(path, &channels)
+}
+
+// ExportDefinitions returns the definitions of the whole broker or a single vhost as JSON
+func (c *ManagementClient) ExportDefinitions(vhost string) ([]byte, error) {
+	return c.do(http.MethodGet, vhostPath("definitions", vhost), nil)
+}
+
+// ImportDefinitions merges the definitions into the broker or a single vhost
+func (c *ManagementClient) ImportDefinitions(vhost string, definitions []byte) error {
+	if !json.Valid(definitions) {
+		return fmt.Errorf("definitions are not valid JSON")
+	}
+	_, err := c.do(http.MethodPost, vhostPath("definitions", vhost), definitions)
+	return err
+}
+
+// PurgeQueue removes all ready messages from the queue
+func (c *ManagementClient) PurgeQueue(vhost, queue string) error {
+	_, err := c.do(http.MethodDelete, vhostPath("queues", vhost)+"/"+url.PathEscape(queue)+"/contents", nil)
+	return err
+}
+
+// DeleteQueue deletes the queue, optionally only when it is empty or has no consumers
+func (c *ManagementClient) DeleteQueue(vhost, queue string, ifEmpty, ifUnused bool) error {
+	query := url.Values{}
+	if ifEmpty {
+		query.Set("if-empty", "true")
+	}
+	if ifUnused {
+		query.Set("if-unused", "true")
+	}
+	path := vhostPath("queues", vhost) + "/" + url.PathEscape(queue)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	_, err := c.do(http.MethodDelete, path, nil)
+	return err
+}
+
+func (c *ManagementClient) getJSON(path string, target any) error {
+	data, err := c.do(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("error parsing response of %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *ManagementClient) do(method, path string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.SetBasicAuth(c.User, c.Password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("management API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error  string `json:"error"`
+			Reason string `json:"reason"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Reason != "" {
+			return nil, fmt.Errorf("management API %s %s returned %d: %s", method, path, resp.StatusCode, apiErr.Reason)
+		}
+		return nil, fmt.Errorf("management API %s %s returned %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
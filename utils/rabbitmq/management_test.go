@@ -0,0 +1,217 @@
+package rabbitmq
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestManagementServer serves canned responses keyed by "METHOD escaped-path?query"
+func newTestManagementServer(t *testing.T, responses map[string]string, requests *[]string) *ManagementClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"error":"not_authorised","reason":"Login failed"}`)
+			return
+		}
+
+		key := r.Method + " " + r.URL.EscapedPath()
+		if r.URL.RawQuery != "" {
+			key += "?" + r.URL.RawQuery
+		}
+		if r.Body != nil {
+			body, _ := io.ReadAll(r.Body)
+			if len(body) > 0 {
+				key += " " + string(body)
+			}
+		}
+		if requests != nil {
+			*requests = append(*requests, key)
+		}
+
+		response, ok := responses[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error":"Object Not Found","reason":"Not Found"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewManagementClient(ConnectionOptions{User: "admin", Password: "secret"}, server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return client
+}
+
+func TestNewManagementClient_DerivesURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		options ConnectionOptions
+		want    string
+	}{
+		{"plain", ConnectionOptions{Host: "rabbit.local"}, "http://rabbit.local:15672"},
+		{"tls", ConnectionOptions{Host: "rabbit.local", SSL: true}, "https://rabbit.local:15671"},
+		{"uri", ConnectionOptions{URI: "amqps://app:pw@broker:5671/prod"}, "https://broker:15671"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewManagementClient(tt.options, "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if client.BaseURL != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, client.BaseURL)
+			}
+		})
+	}
+
+	client, err := NewManagementClient(ConnectionOptions{URI: "amqp://app:pw@broker/prod"}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client.User != "app" || client.Password != "pw" {
+		t.Errorf("expected credentials from URI, got %q/%q", client.User, client.Password)
+	}
+	if client.VirtualHost != "prod" {
+		t.Errorf("expected vhost prod, got %q", client.VirtualHost)
+	}
+}
+
+func TestManagementClient_ListQueues(t *testing.T) {
+	client := newTestManagementServer(t, map[string]string{
+		"GET /api/queues/%2F": `[{"name":"orders","vhost":"/","type":"quorum","durable":true,"state":"running",
+			"messages":12,"messages_ready":10,"messages_unacknowledged":2,"consumers":3,
+			"message_stats":{"publish_details":{"rate":4.5},"deliver_get_details":{"rate":4.0},"ack_details":{"rate":3.5}}}]`,
+		"GET /api/queues": `[{"name":"a","vhost":"/"},{"name":"b","vhost":"prod"}]`,
+	}, nil)
+
+	queues, err := client.ListQueues("/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(queues) != 1 {
+		t.Fatalf("expected 1 queue, got %d", len(queues))
+	}
+	q := queues[0]
+	if q.Name != "orders" || q.Type != "quorum" || q.Messages != 12 || q.MessagesReady != 10 || q.MessagesUnacknowledged != 2 || q.Consumers != 3 {
+		t.Errorf("unexpected queue: %+v", q)
+	}
+	if q.MessageStats.PublishDetails.Rate != 4.5 || q.MessageStats.DeliverGetDetails.Rate != 4.0 {
+		t.Errorf("unexpected rates: %+v", q.MessageStats)
+	}
+
+	all, err := client.ListQueues("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("expected 2 queues across vhosts, got %d", len(all))
+	}
+}
+
+func TestManagementClient_ListResources(t *testing.T) {
+	client := newTestManagementServer(t, map[string]string{
+		"GET /api/exchanges/prod":            `[{"name":"orders","vhost":"prod","type":"topic","durable":true}]`,
+		"GET /api/bindings/prod":             `[{"source":"orders","vhost":"prod","destination":"orders.eu","destination_type":"queue","routing_key":"order.eu.#"}]`,
+		"GET /api/vhosts/prod/connections":   `[{"name":"10.0.0.1:5000 -> 10.0.0.2:5671","vhost":"prod","user":"app","peer_host":"10.0.0.1","peer_port":5000,"state":"running","channels":2,"protocol":"AMQP 0-9-1","ssl":true}]`,
+		"GET /api/channels":                  `[{"name":"10.0.0.1:5000 (1)","vhost":"prod","user":"app","number":1,"state":"running","consumer_count":1,"messages_unacknowledged":5,"prefetch_count":100,"confirm":true}]`,
+		"GET /api/vhosts/prod%2Feu/channels": `[]`,
+	}, nil)
+
+	exchanges, err := client.ListExchanges("prod")
+	if err != nil || len(exchanges) != 1 || exchanges[0].Type != "topic" {
+		t.Errorf("unexpected exchanges %+v, error %v", exchanges, err)
+	}
+
+	bindings, err := client.ListBindings("prod")
+	if err != nil || len(bindings) != 1 || bindings[0].RoutingKey != "order.eu.#" || bindings[0].DestinationType != "queue" {
+		t.Errorf("unexpected bindings %+v, error %v", bindings, err)
+	}
+
+	connections, err := client.ListConnections("prod")
+	if err != nil || len(connections) != 1 || !connections[0].SSL || connections[0].Channels != 2 {
+		t.Errorf("unexpected connections %+v, error %v", connections, err)
+	}
+
+	channels, err := client.ListChannels("")
+	if err != nil || len(channels) != 1 || channels[0].Prefetch != 100 || channels[0].MessagesUnacknowledged != 5 {
+		t.Errorf("unexpected channels %+v, error %v", channels, err)
+	}
+
+	// Vhost names are escaped as a single path segment
+	if _, err := client.ListChannels("prod/eu"); err != nil {
+		t.Errorf("unexpected error for escaped vhost: %v", err)
+	}
+}
+
+func TestManagementClient_Definitions(t *testing.T) {
+	definitions := `{"queues":[{"name":"orders","vhost":"/"}]}`
+	var requests []string
+	client := newTestManagementServer(t, map[string]string{
+		"GET /api/definitions":                     definitions,
+		"POST /api/definitions/%2F " + definitions: ``,
+	}, &requests)
+
+	data, err := client.ExportDefinitions("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != definitions {
+		t.Errorf("expected %s, got %s", definitions, data)
+	}
+
+	if err := client.ImportDefinitions("/", data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := client.ImportDefinitions("/", []byte("{not json")); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+	if len(requests) != 2 {
+		t.Errorf("invalid definitions must not be sent, requests: %v", requests)
+	}
+}
+
+func TestManagementClient_PurgeAndDeleteQueue(t *testing.T) {
+	var requests []string
+	client := newTestManagementServer(t, map[string]string{
+		"DELETE /api/queues/%2F/orders/contents":                         ``,
+		"DELETE /api/queues/%2F/orders":                                  ``,
+		"DELETE /api/queues/%2F/orders.dlq?if-empty=true&if-unused=true": ``,
+	}, &requests)
+
+	if err := client.PurgeQueue("/", "orders"); err != nil {
+		t.Errorf("unexpected purge error: %v", err)
+	}
+	if err := client.DeleteQueue("/", "orders", false, false); err != nil {
+		t.Errorf("unexpected delete error: %v", err)
+	}
+	if err := client.DeleteQueue("/", "orders.dlq", true, true); err != nil {
+		t.Errorf("unexpected conditional delete error: %v", err)
+	}
+	if len(requests) != 3 {
+		t.Errorf("expected 3 requests, got %v", requests)
+	}
+}
+
+func TestManagementClient_Errors(t *testing.T) {
+	client := newTestManagementServer(t, map[string]string{}, nil)
+
+	_, err := client.ListQueues("missing")
+	if err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "Not Found") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	client.Password = "wrong"
+	_, err = client.ListExchanges("")
+	if err == nil || !strings.Contains(err.Error(), "Login failed") {
+		t.Errorf("expected authentication error, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package rabbitmq
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Message is a JSON friendly copy of a delivered message with all its properties
+type Message struct {
+	Queue           string         `json:"queue,omitempty"`
+	Exchange        string         `json:"exchange"`
+	RoutingKey      string         `json:"routingKey"`
+	Redelivered     bool           `json:"redelivered,omitempty"`
+	Headers         map[string]any `json:"headers,omitempty"`
+	ContentType     string         `json:"contentType,omitempty"`
+	ContentEncoding string         `json:"contentEncoding,omitempty"`
+	DeliveryMode    uint8          `json:"deliveryMode,omitempty"`
+	Priority        uint8          `json:"priority,omitempty"`
+	CorrelationId   string         `json:"correlationId,omitempty"`
+	ReplyTo         string         `json:"replyTo,omitempty"`
+	Expiration      string         `json:"expiration,omitempty"`
+	MessageId       string         `json:"messageId,omitempty"`
+	Timestamp       *time.Time     `json:"timestamp,omitempty"`
+	Type            string         `json:"type,omitempty"`
+	UserId          string         `json:"userId,omitempty"`
+	AppId           string         `json:"appId,omitempty"`
+	Body            string         `json:"body"`
+	// BodyEncoding is "base64" when the body is not valid UTF-8
+	BodyEncoding string `json:"bodyEncoding,omitempty"`
+}
+
+// MessageFromDelivery converts a delivery into a Message
+func MessageFromDelivery(queue string, d amqp091.Delivery) Message {
+	msg := Message{
+		Queue:           queue,
+		Exchange:        d.Exchange,
+		RoutingKey:      d.RoutingKey,
+		Redelivered:     d.Redelivered,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    d.DeliveryMode,
+		Priority:        d.Priority,
+		CorrelationId:   d.CorrelationId,
+		ReplyTo:         d.ReplyTo,
+		Expiration:      d.Expiration,
+		MessageId:       d.MessageId,
+		Type:            d.Type,
+		UserId:          d.UserId,
+		AppId:           d.AppId,
+	}
+	if len(d.Headers) > 0 {
+		msg.Headers = tableToMap(d.Headers)
+	}
+	if !d.Timestamp.IsZero() {
+		timestamp := d.Timestamp
+		msg.Timestamp = &timestamp
+	}
+	if utf8.Valid(d.Body) {
+		msg.Body = string(d.Body)
+	} else {
+		msg.Body = base64.StdEncoding.EncodeToString(d.Body)
+		msg.BodyEncoding = "base64"
+	}
+	return msg
+}
+
+// BodyBytes returns the decoded message body
+func (m Message) BodyBytes() ([]byte, error) {
+	if m.BodyEncoding == "base64" {
+		body, err := base64.StdEncoding.DecodeString(m.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding message body: %w", err)
+		}
+		return body, nil
+	}
+	return []byte(m.Body), nil
+}
+
+// Publishing converts the message back into a publishing with the original properties. The
+// user id is only kept on request, the broker rejects it unless it is the connection user.
+func (m Message) Publishing(keepUserId bool) (amqp091.Publishing, error) {
+	body, err := m.BodyBytes()
+	if err != nil {
+		return amqp091.Publishing{}, err
+	}
+	publishing := amqp091.Publishing{
+		Headers:         mapToTable(m.Headers),
+		ContentType:     m.ContentType,
+		ContentEncoding: m.ContentEncoding,
+		DeliveryMode:    m.DeliveryMode,
+		Priority:        m.Priority,
+		CorrelationId:   m.CorrelationId,
+		ReplyTo:         m.ReplyTo,
+		Expiration:      m.Expiration,
+		MessageId:       m.MessageId,
+		Type:            m.Type,
+		AppId:           m.AppId,
+		Body:            body,
+	}
+	if keepUserId {
+		publishing.UserId = m.UserId
+	}
+	if m.Timestamp != nil {
+		publishing.Timestamp = *m.Timestamp
+	}
+	return publishing, nil
+}
+
+// PublishingFromDelivery copies the body and the properties of a delivery into a publishing,
+// the user id only with keepUserId
+func PublishingFromDelivery(d amqp091.Delivery, keepUserId bool) amqp091.Publishing {
+	headers := make(amqp091.Table, len(d.Headers))
+	for key, value := range d.Headers {
+		headers[key] = value
+	}
+	publishing := amqp091.Publishing{
+		Headers:         headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    d.DeliveryMode,
+		Priority:        d.Priority,
+		CorrelationId:   d.CorrelationId,
+		ReplyTo:         d.ReplyTo,
+		Expiration:      d.Expiration,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	}
+	if keepUserId {
+		publishing.UserId = d.UserId
+	}
+	return publishing
+}
+
+// StripDeathHeaders removes the headers RabbitMQ adds when dead-lettering a message
+func StripDeathHeaders(headers amqp091.Table) {
+	for key := range headers {
+		if key == "x-death" || strings.HasPrefix(key, "x-first-death-") || strings.HasPrefix(key, "x-last-death-") {
+			delete(headers, key)
+		}
+	}
+}
+
+// tableToMap converts AMQP field values into JSON serializable values
+func tableToMap(table amqp091.Table) map[string]any {
+	result := make(map[string]any, len(table))
+	for key, value := range table {
+		result[key] = fieldToJSON(value)
+	}
+	return result
+}
+
+func fieldToJSON(value any) any {
+	switch v := value.(type) {
+	case amqp091.Table:
+		return tableToMap(v)
+	case []any:
+		values := make([]any, len(v))
+		for i, item := range v {
+			values[i] = fieldToJSON(item)
+		}
+		return values
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case amqp091.Decimal:
+		return float64(v.Value) / math.Pow10(int(v.Scale))
+	default:
+		return v
+	}
+}
+
+// mapToTable converts decoded JSON values into valid AMQP field values
+func mapToTable(values map[string]any) amqp091.Table {
+	if len(values) == 0 {
+		return nil
+	}
+	table := make(amqp091.Table, len(values))
+	for key, value := range values {
+		table[key] = jsonToField(value)
+	}
+	return table
+}
+
+func jsonToField(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		return mapToTable(v)
+	case []any:
+		values := make([]any, len(v))
+		for i, item := range v {
+			values[i] = jsonToField(item)
+		}
+		return values
+	case float64:
+		// JSON numbers are floats, keep whole numbers as integers
+		if v == math.Trunc(v) && math.Abs(v) < math.MaxInt64 {
+			return int64(v)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,155 @@
+package rabbitmq
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+func testDelivery() amqp091.Delivery {
+	return amqp091.Delivery{
+		Exchange:      "orders",
+		RoutingKey:    "order.created",
+		Headers:       amqp091.Table{"x-death": []any{amqp091.Table{"count": int64(1)}}, "tenant": "eu"},
+		ContentType:   "application/json",
+		DeliveryMode:  amqp091.Persistent,
+		Priority:      3,
+		CorrelationId: "c-1",
+		MessageId:     "m-1",
+		Timestamp:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		UserId:        "orders-app",
+		AppId:         "orders",
+		Body:          []byte(`{"id":1}`),
+	}
+}
+
+func TestPublishingFromDelivery_UserId(t *testing.T) {
+	tests := []struct {
+		name       string
+		keepUserId bool
+		want       string
+	}{
+		{"dropped by default", false, ""},
+		{"kept on request", true, "orders-app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery := testDelivery()
+			publishing := PublishingFromDelivery(delivery, tt.keepUserId)
+			if publishing.UserId != tt.want {
+				t.Errorf("expected user id %q, got %q", tt.want, publishing.UserId)
+			}
+			if publishing.MessageId != "m-1" || publishing.Priority != 3 || publishing.AppId != "orders" || !publishing.Timestamp.Equal(delivery.Timestamp) {
+				t.Errorf("expected the other properties to be copied, got %+v", publishing)
+			}
+
+			// The headers are a copy, stripping them leaves the delivery untouched
+			StripDeathHeaders(publishing.Headers)
+			if _, ok := delivery.Headers["x-death"]; !ok {
+				t.Errorf("expected the delivery headers to be unchanged, got %v", delivery.Headers)
+			}
+		})
+	}
+}
+
+func TestMessagePublishing_UserId(t *testing.T) {
+	msg := MessageFromDelivery("orders.dlq", testDelivery())
+	for _, keepUserId := range []bool{false, true} {
+		publishing, err := msg.Publishing(keepUserId)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := ""
+		if keepUserId {
+			want = "orders-app"
+		}
+		if publishing.UserId != want {
+			t.Errorf("keepUserId %t: expected user id %q, got %q", keepUserId, want, publishing.UserId)
+		}
+	}
+}
+
+func TestMessage_JSONRoundTrip(t *testing.T) {
+	delivery := testDelivery()
+	delivery.Headers = amqp091.Table{
+		"tenant":   "eu",
+		"attempts": int64(3),
+		"small":    int32(7),
+		"ratio":    0.25,
+		"retry":    true,
+		"nested":   amqp091.Table{"reason": "timeout", "count": int64(2)},
+		"list":     []any{"a", int64(1)},
+		"sentAt":   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	delivery.Body = []byte{0xff, 0x00, 0xfe, 'x'}
+
+	data, err := json.Marshal(MessageFromDelivery("orders.dlq", delivery))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded Message
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded.BodyEncoding != "base64" {
+		t.Errorf("expected a base64 body for binary data, got %q", decoded.BodyEncoding)
+	}
+	publishing, err := decoded.Publishing(true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(publishing.Body, delivery.Body) {
+		t.Errorf("expected body %v, got %v", delivery.Body, publishing.Body)
+	}
+	if !publishing.Timestamp.Equal(delivery.Timestamp) {
+		t.Errorf("expected timestamp %s, got %s", delivery.Timestamp, publishing.Timestamp)
+	}
+	if publishing.ContentType != delivery.ContentType || publishing.DeliveryMode != delivery.DeliveryMode || publishing.Priority != delivery.Priority ||
+		publishing.CorrelationId != delivery.CorrelationId || publishing.MessageId != delivery.MessageId || publishing.UserId != delivery.UserId || publishing.AppId != delivery.AppId {
+		t.Errorf("expected the properties of %+v, got %+v", delivery, publishing)
+	}
+
+	// Integers come back as int64 and timestamps in headers as RFC 3339 strings
+	expected := amqp091.Table{
+		"tenant":   "eu",
+		"attempts": int64(3),
+		"small":    int64(7),
+		"ratio":    0.25,
+		"retry":    true,
+		"nested":   amqp091.Table{"reason": "timeout", "count": int64(2)},
+		"list":     []any{"a", int64(1)},
+		"sentAt":   "2026-01-02T03:04:05Z",
+	}
+	if !reflect.DeepEqual(publishing.Headers, expected) {
+		t.Errorf("expected headers %v, got %v", expected, publishing.Headers)
+	}
+	if err := publishing.Headers.Validate(); err != nil {
+		t.Errorf("expected valid AMQP headers, got %v", err)
+	}
+}
+
+func TestMessage_TextBody(t *testing.T) {
+	delivery := testDelivery()
+	delivery.Timestamp = time.Time{}
+	msg := MessageFromDelivery("orders.dlq", delivery)
+	if msg.Body != `{"id":1}` || msg.BodyEncoding != "" || msg.Timestamp != nil {
+		t.Errorf("expected a plain text body without timestamp, got %+v", msg)
+	}
+	publishing, err := msg.Publishing(false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(publishing.Body) != `{"id":1}` || !publishing.Timestamp.IsZero() {
+		t.Errorf("expected the text body without timestamp, got %q at %s", publishing.Body, publishing.Timestamp)
+	}
+
+	invalid := Message{Body: "not base64!", BodyEncoding: "base64"}
+	if _, err := invalid.Publishing(false); err == nil {
+		t.Error("expected an error for an invalid base64 body")
+	}
+}
@@ -0,0 +1,301 @@
+package rabbitmq
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+	pahomqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTT connection settings
+const (
+	mqttTimeout   = 30 * time.Second
+	mqttKeepAlive = 30 * time.Second
+	// mqttBuffer is the number of messages buffered per subscription
+	mqttBuffer = 1024
+)
+
+// mqttSubscription is the topic filter and message channel of a SUBSCRIBE
+type mqttSubscription struct {
+	filter string
+	ch     chan ProtocolMessage
+}
+
+// mqttSubscriptions hands received messages to the channels of the subscriptions. The
+// channels are closed with the client or when the connection is lost.
+type mqttSubscriptions struct {
+	done     chan struct{}
+	stopOnce sync.Once
+
+	mu            sync.RWMutex
+	closed        bool
+	subscriptions map[int]mqttSubscription
+	nextID        int
+}
+
+func newMQTTSubscriptions() *mqttSubscriptions {
+	return &mqttSubscriptions{done: make(chan struct{}), subscriptions: map[int]mqttSubscription{}, nextID: 1}
+}
+
+// add registers a subscription of the topic filter and returns its identifier and channel
+func (s *mqttSubscriptions) add(filter string) (int, chan ProtocolMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ch := s.nextID, make(chan ProtocolMessage, mqttBuffer)
+	s.nextID++
+	if s.closed {
+		close(ch)
+	} else {
+		s.subscriptions[id] = mqttSubscription{filter: filter, ch: ch}
+	}
+	return id, ch
+}
+
+// remove closes the channel of a subscription the broker refused
+func (s *mqttSubscriptions) remove(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sub, ok := s.subscriptions[id]; ok {
+		delete(s.subscriptions, id)
+		close(sub.ch)
+	}
+}
+
+// deliver passes the message to the subscription, it gives up once the client is closing
+func (s *mqttSubscriptions) deliver(id int, msg ProtocolMessage) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if sub, ok := s.subscriptions[id]; ok && !s.closed {
+		s.send(sub.ch, msg)
+	}
+}
+
+// deliverMatching passes the message to every subscription whose filter matches its topic.
+// The broker sends a single copy to a client with overlapping subscriptions.
+func (s *mqttSubscriptions) deliverMatching(msg ProtocolMessage) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	for _, sub := range s.subscriptions {
+		if matchTopic(sub.filter, msg.Destination) {
+			s.send(sub.ch, msg)
+		}
+	}
+}
+
+func (s *mqttSubscriptions) send(ch chan ProtocolMessage, msg ProtocolMessage) {
+	select {
+	case ch <- msg:
+	case <-s.done:
+	}
+}
+
+// stop releases deliveries waiting for a reader
+func (s *mqttSubscriptions) stop() {
+	s.stopOnce.Do(func() { close(s.done) })
+}
+
+// close stops the deliveries and closes all channels
+func (s *mqttSubscriptions) close() {
+	s.stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for _, sub := range s.subscriptions {
+		close(sub.ch)
+	}
+}
+
+// matchTopic reports whether the topic name matches the filter with + and # wildcards
+func matchTopic(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	// Wildcards at the first level do not match topics starting with $
+	if strings.HasPrefix(topic, "$") && (filterLevels[0] == "+" || filterLevels[0] == "#") {
+		return false
+	}
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+// dialMQTT311 connects with the MQTT 3.1.1 client, which carries neither headers nor
+// content types
+func dialMQTT311(address string, tlsConfig *tls.Config, clientID, user, password string) (ProtocolClient, error) {
+	scheme := "tcp"
+	if tlsConfig != nil {
+		scheme = "ssl"
+	}
+	c := &mqtt311Client{subscriptions: newMQTTSubscriptions()}
+	options := pahomqtt.NewClientOptions().
+		AddBroker(scheme + "://" + address).
+		SetProtocolVersion(4).
+		SetClientID(clientID).
+		SetUsername(user).
+		SetPassword(password).
+		SetCleanSession(true).
+		SetKeepAlive(mqttKeepAlive).
+		SetConnectTimeout(mqttTimeout).
+		SetAutoReconnect(false).
+		SetTLSConfig(tlsConfig).
+		SetConnectionLostHandler(func(pahomqtt.Client, error) { c.subscriptions.close() })
+	c.client = pahomqtt.NewClient(options)
+	if err := wait(c.client.Connect()); err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	return c, nil
+}
+
+// wait waits for the token of a MQTT 3.1.1 operation
+func wait(token pahomqtt.Token) error {
+	token.Wait()
+	return token.Error()
+}
+
+type mqtt311Client struct {
+	client        pahomqtt.Client
+	subscriptions *mqttSubscriptions
+}
+
+// Publish sends with QoS 1, the PUBACK acts as the publisher confirm
+func (c *mqtt311Client) Publish(destination string, msg ProtocolMessage) error {
+	return wait(c.client.Publish(destination, 1, false, msg.Body))
+}
+
+func (c *mqtt311Client) Subscribe(source string) (<-chan ProtocolMessage, error) {
+	id, ch := c.subscriptions.add(source)
+	err := wait(c.client.Subscribe(source, 1, func(_ pahomqtt.Client, m pahomqtt.Message) {
+		c.subscriptions.deliver(id, ProtocolMessage{Destination: m.Topic(), Body: m.Payload()})
+	}))
+	if err != nil {
+		c.subscriptions.remove(id)
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (c *mqtt311Client) SupportsHeaders() bool {
+	return false
+}
+
+func (c *mqtt311Client) Close() error {
+	c.subscriptions.stop()
+	c.client.Disconnect(250)
+	c.subscriptions.close()
+	return nil
+}
+
+// dialMQTT5 connects with the MQTT 5 client, headers are sent as user properties
+func dialMQTT5(address string, tlsConfig *tls.Config, clientID, user, password string) (ProtocolClient, error) {
+	dialer := &net.Dialer{Timeout: mqttTimeout}
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+
+	c := &mqtt5Client{subscriptions: newMQTTSubscriptions()}
+	c.client = paho.NewClient(paho.ClientConfig{
+		ClientID:           clientID,
+		Conn:               packets.NewThreadSafeConn(conn),
+		OnPublishReceived:  []func(paho.PublishReceived) (bool, error){c.received},
+		OnClientError:      func(error) { c.subscriptions.close() },
+		OnServerDisconnect: func(*paho.Disconnect) { c.subscriptions.close() },
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), mqttTimeout)
+	defer cancel()
+	_, err = c.client.Connect(ctx, &paho.Connect{
+		ClientID:     clientID,
+		Username:     user,
+		UsernameFlag: user != "",
+		Password:     []byte(password),
+		PasswordFlag: password != "",
+		KeepAlive:    uint16(mqttKeepAlive / time.Second),
+		CleanStart:   true,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	return c, nil
+}
+
+type mqtt5Client struct {
+	client        *paho.Client
+	subscriptions *mqttSubscriptions
+}
+
+// Publish sends with QoS 1, the PUBACK acts as the publisher confirm
+func (c *mqtt5Client) Publish(destination string, msg ProtocolMessage) error {
+	properties := &paho.PublishProperties{ContentType: msg.ContentType}
+	for name, value := range msg.Headers {
+		properties.User = append(properties.User, paho.UserProperty{Key: name, Value: value})
+	}
+	_, err := c.client.Publish(context.Background(), &paho.Publish{Topic: destination, QoS: 1, Payload: msg.Body, Properties: properties})
+	return err
+}
+
+func (c *mqtt5Client) Subscribe(source string) (<-chan ProtocolMessage, error) {
+	id, ch := c.subscriptions.add(source)
+	_, err := c.client.Subscribe(context.Background(), &paho.Subscribe{
+		Subscriptions: []paho.SubscribeOptions{{Topic: source, QoS: 1}},
+	})
+	if err != nil {
+		c.subscriptions.remove(id)
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (c *mqtt5Client) received(received paho.PublishReceived) (bool, error) {
+	p := received.Packet
+	msg := ProtocolMessage{Destination: p.Topic, Body: p.Payload}
+	if p.Properties != nil {
+		msg.ContentType = p.Properties.ContentType
+		if len(p.Properties.User) > 0 {
+			msg.Headers = make(map[string]string, len(p.Properties.User))
+			for _, property := range p.Properties.User {
+				msg.Headers[property.Key] = property.Value
+			}
+		}
+	}
+	c.subscriptions.deliverMatching(msg)
+	return true, nil
+}
+
+func (c *mqtt5Client) SupportsHeaders() bool {
+	return true
+}
+
+func (c *mqtt5Client) Close() error {
+	c.subscriptions.stop()
+	err := c.client.Disconnect(&paho.Disconnect{ReasonCode: 0})
+	c.subscriptions.close()
+	return err
+}
@@ -0,0 +1,163 @@
+package rabbitmq
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	mqttServer "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+// runMQTTBroker starts an in-process MQTT 3.1.1 and 5 broker and returns its address
+func runMQTTBroker(t *testing.T) string {
+	t.Helper()
+	server := mqttServer.New(&mqttServer.Options{InlineClient: false, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	listener := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	if err := server.AddListener(listener); err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatalf("failed to serve: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return listener.Address()
+}
+
+func receiveProtocolMessage(t *testing.T, messages <-chan ProtocolMessage) ProtocolMessage {
+	t.Helper()
+	select {
+	case msg, ok := <-messages:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	return ProtocolMessage{}
+}
+
+func expectClosed(t *testing.T, messages <-chan ProtocolMessage) {
+	t.Helper()
+	select {
+	case _, ok := <-messages:
+		if ok {
+			t.Error("expected no more messages")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the subscription to close with the client")
+	}
+}
+
+func TestMQTT5Client(t *testing.T) {
+	client, err := dialMQTT5(runMQTTBroker(t), nil, "vpd-test", "guest", "guest")
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	if !client.SupportsHeaders() {
+		t.Error("expected MQTT 5 to support headers")
+	}
+	all, err := client.Subscribe("orders/#")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	created, err := client.Subscribe("orders/created")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	msg := ProtocolMessage{Body: []byte(`{"id":1}`), Headers: map[string]string{"tenant": "eu"}, ContentType: "application/json"}
+	if err := client.Publish("orders/created", msg); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	if err := client.Publish("orders/deleted", ProtocolMessage{Body: []byte("gone")}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	// Both subscriptions match the first message, only the wildcard one the second
+	for _, messages := range []<-chan ProtocolMessage{all, created} {
+		received := receiveProtocolMessage(t, messages)
+		if received.Destination != "orders/created" || string(received.Body) != `{"id":1}` || received.Headers["tenant"] != "eu" || received.ContentType != "application/json" {
+			t.Errorf("expected the created order with its properties, got %+v", received)
+		}
+	}
+	if received := receiveProtocolMessage(t, all); received.Destination != "orders/deleted" {
+		t.Errorf("expected orders/deleted, got %s", received.Destination)
+	}
+	select {
+	case received := <-created:
+		t.Errorf("expected no further message for orders/created, got %+v", received)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := client.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectClosed(t, all)
+	expectClosed(t, created)
+}
+
+func TestMQTT311Client(t *testing.T) {
+	client, err := dialMQTT311(runMQTTBroker(t), nil, "vpd-test", "guest", "guest")
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	if client.SupportsHeaders() {
+		t.Error("expected MQTT 3.1.1 not to support headers")
+	}
+	messages, err := client.Subscribe("orders/+")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	body := []byte{0x00, 0xff, '\n'}
+	if err := client.Publish("orders/created", ProtocolMessage{Body: body, Headers: map[string]string{"tenant": "eu"}}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	received := receiveProtocolMessage(t, messages)
+	if received.Destination != "orders/created" || string(received.Body) != string(body) || len(received.Headers) != 0 {
+		t.Errorf("expected the binary body without headers, got %+v", received)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectClosed(t, messages)
+}
+
+func TestMQTTClient_ConnectError(t *testing.T) {
+	if _, err := dialMQTT5("127.0.0.1:1", nil, "vpd-test", "", ""); err == nil {
+		t.Error("expected an error connecting to a closed port")
+	}
+	if _, err := dialMQTT311("127.0.0.1:1", nil, "vpd-test", "", ""); err == nil {
+		t.Error("expected an error connecting to a closed port")
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"#", "a/b", true},
+		{"+/+", "a/b", true},
+		{"a/b", "a/c", false},
+		{"#", "$SYS/uptime", false},
+		{"$SYS/#", "$SYS/uptime", true},
+	}
+	for _, tt := range tests {
+		if got := matchTopic(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("%s on %s: expected %t, got %t", tt.filter, tt.topic, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	vaultinoUtils "github.com/VojtechPastyrik/vpd/utils/vaultino"
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is a named RabbitMQ connection stored in the profiles file
+type Profile struct {
+	URI           string       `yaml:"uri,omitempty"`
+	Host          string       `yaml:"host,omitempty"`
+	Port          int          `yaml:"port,omitempty"`
+	User          string       `yaml:"user,omitempty"`
+	Password      string       `yaml:"password,omitempty"`
+	PasswordEnv   string       `yaml:"passwordEnv,omitempty"`
+	PasswordVault *VaultSecret `yaml:"passwordVault,omitempty"`
+	VirtualHost   string       `yaml:"vhost,omitempty"`
+	SSL           bool         `yaml:"ssl,omitempty"`
+	SSLCA         string       `yaml:"sslCA,omitempty"`
+	SSLServerName string       `yaml:"sslServerName,omitempty"`
+	Insecure      bool         `yaml:"insecure,omitempty"`
+	SSLCert       string       `yaml:"sslCert,omitempty"`
+	SSLKey        string       `yaml:"sslKey,omitempty"`
+	SSLP12        string       `yaml:"sslP12,omitempty"`
+	// SSLP12PasswordEnv names the env var holding the PKCS#12 password
+	SSLP12PasswordEnv string `yaml:"sslP12PasswordEnv,omitempty"`
+	ExternalAuth      bool   `yaml:"externalAuth,omitempty"`
+}
+
+// VaultSecret points to a key in a vaultino encrypted file
+type VaultSecret struct {
+	File string `yaml:"file"`
+	Key  string `yaml:"key"`
+	// PasswordEnv names the env var holding the vault password, prompts when empty
+	PasswordEnv string `yaml:"passwordEnv,omitempty"`
+}
+
+type profilesFile struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// DefaultProfilesPath returns ~/.config/vpd/rabbitmq.yaml
+func DefaultProfilesPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot get home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "vpd", "rabbitmq.yaml"), nil
+}
+
+// LoadProfiles reads connection profiles from the default file, a missing file means no profiles
+func LoadProfiles() (map[string]Profile, error) {
+	path, err := DefaultProfilesPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]Profile{}, nil
+		}
+		return nil, fmt.Errorf("error reading profiles file: %w", err)
+	}
+
+	var file profilesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing profiles file %s: %w", path, err)
+	}
+	if file.Profiles == nil {
+		file.Profiles = map[string]Profile{}
+	}
+	return file.Profiles, nil
+}
+
+// SaveProfiles writes the connection profiles to the default file, readable by the owner only
+func SaveProfiles(profiles map[string]Profile) error {
+	path, err := DefaultProfilesPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("error creating config directory: %w", err)
+	}
+	data, err := yaml.Marshal(profilesFile{Profiles: profiles})
+	if err != nil {
+		return fmt.Errorf("error serializing profiles: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("error writing profiles file: %w", err)
+	}
+	return nil
+}
+
+// GetProfile returns the named connection profile
+func GetProfile(name string) (Profile, error) {
+	profiles, err := LoadProfiles()
+	if err != nil {
+		return Profile{}, err
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("connection profile '%s' not found, available profiles: %v", name, ProfileNames(profiles))
+	}
+	return profile, nil
+}
+
+// ProfileNames returns the sorted profile names
+func ProfileNames(profiles map[string]Profile) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PasswordSource describes where the password of the profile comes from
+func (p Profile) PasswordSource() string {
+	switch {
+	case p.PasswordEnv != "":
+		return "env:" + p.PasswordEnv
+	case p.PasswordVault != nil:
+		return fmt.Sprintf("vaultino:%s#%s", p.PasswordVault.File, p.PasswordVault.Key)
+	case p.Password != "":
+		return "plain text"
+	case p.ExternalAuth:
+		return "client certificate"
+	case p.URI != "":
+		return "uri"
+	default:
+		return "none"
+	}
+}
+
+// Options resolves the profile into connection options, reading the password from its source
+func (p Profile) Options() (ConnectionOptions, error) {
+	password, err := p.password()
+	if err != nil {
+		return ConnectionOptions{}, err
+	}
+	p12Password := ""
+	if p.SSLP12PasswordEnv != "" {
+		p12Password = os.Getenv(p.SSLP12PasswordEnv)
+	}
+	return ConnectionOptions{
+		URI:         p.URI,
+		Host:        p.Host,
+		Port:        p.Port,
+		User:        p.User,
+		Password:    password,
+		VirtualHost: p.VirtualHost,
+		SSL:         p.SSL,
+		TLS: TLSOptions{
+			CACertPath:  p.SSLCA,
+			ServerName:  p.SSLServerName,
+			Insecure:    p.Insecure,
+			CertPath:    p.SSLCert,
+			KeyPath:     p.SSLKey,
+			P12Path:     p.SSLP12,
+			P12Password: p12Password,
+		},
+		ExternalAuth: p.ExternalAuth,
+	}, nil
+}
+
+func (p Profile) password() (string, error) {
+	switch {
+	case p.PasswordEnv != "":
+		password, ok := os.LookupEnv(p.PasswordEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s with the RabbitMQ password is not set", p.PasswordEnv)
+		}
+		return password, nil
+	case p.PasswordVault != nil:
+		vaultPassword := ""
+		if p.PasswordVault.PasswordEnv != "" {
+			vaultPassword = os.Getenv(p.PasswordVault.PasswordEnv)
+		}
+		keys := vaultinoUtils.Keys{Password: vaultinoUtils.PasswordProvider{Value: vaultPassword}, IdentityFiles: vaultinoUtils.DefaultIdentityFiles()}
+		password, err := vaultinoUtils.GetSecretFromVault(p.PasswordVault.File, p.PasswordVault.Key, keys)
+		if err != nil {
+			return "", fmt.Errorf("error reading RabbitMQ password from vault: %w", err)
+		}
+		return password, nil
+	default:
+		return p.Password, nil
+	}
+}
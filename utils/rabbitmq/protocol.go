@@ -0,0 +1,216 @@
+package rabbitmq
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/VojtechPastyrik/vpd/utils/stomp"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Protocols the commands can talk to RabbitMQ with, AMQP 0-9-1 uses the amqp091 client directly,
+// AMQP 1.0 is not supported
+const (
+	ProtocolAMQP  = "amqp"
+	ProtocolMQTT  = "mqtt"
+	ProtocolMQTT5 = "mqtt5"
+	ProtocolSTOMP = "stomp"
+)
+
+// Protocols lists the supported protocols
+var Protocols = []string{ProtocolAMQP, ProtocolMQTT, ProtocolMQTT5, ProtocolSTOMP}
+
+// ProtocolMessage is a message published or received over MQTT or STOMP
+type ProtocolMessage struct {
+	// Destination is the MQTT topic or the STOMP destination
+	Destination string
+	Body        []byte
+	// Headers are STOMP headers or MQTT 5 user properties, MQTT 3.1.1 drops them
+	Headers     map[string]string
+	ContentType string
+}
+
+// Delivery converts the message to an AMQP delivery, so filters and exports work on it
+func (m ProtocolMessage) Delivery() amqp091.Delivery {
+	d := amqp091.Delivery{RoutingKey: m.Destination, Body: m.Body, ContentType: m.ContentType}
+	if len(m.Headers) > 0 {
+		d.Headers = amqp091.Table{}
+		for name, value := range m.Headers {
+			d.Headers[name] = value
+		}
+	}
+	return d
+}
+
+// ProtocolClient publishes and subscribes over a protocol plugin of RabbitMQ
+type ProtocolClient interface {
+	// Publish sends the message and waits until the broker accepted it
+	Publish(destination string, msg ProtocolMessage) error
+	// Subscribe returns the messages of the source, the channel closes with the client
+	Subscribe(source string) (<-chan ProtocolMessage, error)
+	// SupportsHeaders reports whether message headers are transferred
+	SupportsHeaders() bool
+	Close() error
+}
+
+// ValidateProtocol checks that the protocol is supported
+func ValidateProtocol(protocol string) error {
+	for _, p := range Protocols {
+		if p == protocol {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported protocol '%s', use one of %v", protocol, Protocols)
+}
+
+// DefaultProtocolPort returns the default RabbitMQ plugin port of the protocol
+func DefaultProtocolPort(protocol string, ssl bool) int {
+	switch protocol {
+	case ProtocolMQTT, ProtocolMQTT5:
+		if ssl {
+			return 8883
+		}
+		return 1883
+	case ProtocolSTOMP:
+		if ssl {
+			return 61614
+		}
+		return 61613
+	}
+	if ssl {
+		return 5671
+	}
+	return 5672
+}
+
+// DialProtocol connects to the MQTT or STOMP plugin with the credentials, vhost and TLS settings
+// of the options. A zero port selects the default port of the protocol.
+func DialProtocol(options ConnectionOptions, protocol string, port int) (ProtocolClient, error) {
+	uri, err := options.uri()
+	if err != nil {
+		return nil, err
+	}
+	ssl := uri.Scheme == "amqps"
+	if port == 0 {
+		port = DefaultProtocolPort(protocol, ssl)
+	}
+	address := net.JoinHostPort(uri.Host, strconv.Itoa(port))
+
+	tlsConfig, err := protocolTLSConfig(options, uri.Host, ssl)
+	if err != nil {
+		return nil, err
+	}
+	user, password := uri.Username, uri.Password
+	if options.ExternalAuth {
+		// The plugins take the user from the client certificate when no credentials are sent
+		user, password = "", ""
+	}
+
+	switch protocol {
+	case ProtocolMQTT, ProtocolMQTT5:
+		// The MQTT plugin selects the vhost by a "vhost:user" user name
+		if user != "" && uri.Vhost != "/" && uri.Vhost != "" {
+			user = uri.Vhost + ":" + user
+		}
+		clientID := "vpd-" + randomID()
+		if protocol == ProtocolMQTT5 {
+			return dialMQTT5(address, tlsConfig, clientID, user, password)
+		}
+		return dialMQTT311(address, tlsConfig, clientID, user, password)
+	case ProtocolSTOMP:
+		client, err := stomp.Dial(stomp.Options{
+			Address:   address,
+			TLSConfig: tlsConfig,
+			Host:      uri.Vhost,
+			Login:     user,
+			Passcode:  password,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &stompProtocolClient{client: client}, nil
+	}
+	return nil, fmt.Errorf("protocol '%s' has no protocol client", protocol)
+}
+
+func protocolTLSConfig(options ConnectionOptions, host string, ssl bool) (*tls.Config, error) {
+	if !ssl {
+		if options.ExternalAuth {
+			return nil, fmt.Errorf("EXTERNAL authentication requires TLS")
+		}
+		return nil, nil
+	}
+	config, err := newTLSConfig(options.TLS, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TLS config: %w", err)
+	}
+	if options.ExternalAuth && len(config.Certificates) == 0 {
+		return nil, fmt.Errorf("EXTERNAL authentication requires a client certificate")
+	}
+	return config, nil
+}
+
+func randomID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type stompProtocolClient struct {
+	client *stomp.Client
+}
+
+// Publish sends with a receipt, which RabbitMQ returns once the message was routed
+func (c *stompProtocolClient) Publish(destination string, msg ProtocolMessage) error {
+	headers := make(map[string]string, len(msg.Headers)+1)
+	for name, value := range msg.Headers {
+		headers[name] = value
+	}
+	if msg.ContentType != "" {
+		headers["content-type"] = msg.ContentType
+	}
+	return c.client.Send(destination, msg.Body, headers)
+}
+
+func (c *stompProtocolClient) Subscribe(source string) (<-chan ProtocolMessage, error) {
+	sub, err := c.client.Subscribe(source, map[string]string{"prefetch-count": "100"})
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan ProtocolMessage, cap(sub.C))
+	go func() {
+		defer close(out)
+		for msg := range sub.C {
+			out <- ProtocolMessage{Destination: msg.Destination, Body: msg.Body, Headers: userHeaders(msg.Headers), ContentType: msg.Headers["content-type"]}
+		}
+	}()
+	return out, nil
+}
+
+// stompFrameHeaders are set by the broker on every MESSAGE frame
+var stompFrameHeaders = map[string]bool{
+	"subscription": true, "destination": true, "message-id": true, "ack": true,
+	"content-type": true, "content-length": true, "redelivered": true, "persistent": true,
+}
+
+func userHeaders(headers map[string]string) map[string]string {
+	out := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if !stompFrameHeaders[name] {
+			out[name] = value
+		}
+	}
+	return out
+}
+
+func (c *stompProtocolClient) SupportsHeaders() bool {
+	return true
+}
+
+func (c *stompProtocolClient) Close() error {
+	return c.client.Close()
+}
@@ -1,137 +1,125 @@
 package rabbitmq
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/tls"
-	"crypto/x509"
-	"crypto/x509/pkix"
-	"encoding/pem"
 	"fmt"
-	"github.com/rabbitmq/amqp091-go"
-	"math/big"
 	"net"
-	"time"
+
+	"github.com/rabbitmq/amqp091-go"
 )
 
-func ConnectToRabbitMQ(ssl bool, user, password, host string, port int, vhost, certPath, keyPath string) (*amqp091.Connection, *amqp091.Channel, error) {
-	amqpURL := createUrl(ssl, user, password, host, port, vhost)
+// ConnectionOptions describes how to connect to a RabbitMQ server. When URI is set it is
+// used as the base and non-empty fields override its parts.
+type ConnectionOptions struct {
+	URI         string
+	Host        string
+	Port        int
+	User        string
+	Password    string
+	VirtualHost string
+	SSL         bool
+	TLS         TLSOptions
+	// ExternalAuth uses the SASL EXTERNAL mechanism, the user is taken from the client certificate
+	ExternalAuth bool
+	// Dial opens the TCP connection instead of the default dialer, TLS is still added on top
+	Dial func(network, addr string) (net.Conn, error)
+}
 
-	var err error
-	var conn *amqp091.Connection
-	var ch *amqp091.Channel
+// Connect opens a connection and a channel to the RabbitMQ server
+func Connect(options ConnectionOptions) (*amqp091.Connection, *amqp091.Channel, error) {
+	uri, err := options.uri()
+	if err != nil {
+		return nil, nil, err
+	}
 
-	if ssl {
-		tlsConfig, err := newTLSConfig(certPath, keyPath)
-		if err != nil {
-			return nil, nil, fmt.Errorf("Failed to create TLS config: %s", err)
+	config := amqp091.Config{Locale: "en_US", Dial: options.Dial}
+	if uri.Scheme == "amqps" {
+		// TLS settings may also be given as URI query parameters
+		tlsOptions := options.TLS
+		if tlsOptions.CertPath == "" && tlsOptions.KeyPath == "" && tlsOptions.P12Path == "" {
+			tlsOptions.CertPath, tlsOptions.KeyPath = uri.CertFile, uri.KeyFile
 		}
-		conn, err = amqp091.DialTLS(amqpURL, tlsConfig)
-		if err != nil {
-			return nil, nil, fmt.Errorf("Failed to connect to RabbitMQ: %s", err)
+		if tlsOptions.CACertPath == "" {
+			tlsOptions.CACertPath = uri.CACertFile
 		}
-	} else {
-		conn, err = amqp091.Dial(amqpURL)
+		if tlsOptions.ServerName == "" {
+			tlsOptions.ServerName = uri.ServerName
+		}
+
+		config.TLSClientConfig, err = newTLSConfig(tlsOptions, uri.Host)
 		if err != nil {
-			return nil, nil, fmt.Errorf("Failed to connect to RabbitMQ: %s", err)
+			return nil, nil, fmt.Errorf("failed to create TLS config: %w", err)
 		}
+		if options.ExternalAuth {
+			if len(config.TLSClientConfig.Certificates) == 0 {
+				return nil, nil, fmt.Errorf("EXTERNAL authentication requires a client certificate")
+			}
+			config.SASL = []amqp091.Authentication{&amqp091.ExternalAuth{}}
+		}
+	} else if options.ExternalAuth {
+		return nil, nil, fmt.Errorf("EXTERNAL authentication requires TLS")
 	}
 
-	ch, err = conn.Channel()
+	conn, err := amqp091.DialConfig(uri.String(), config)
 	if err != nil {
-		return nil, nil, fmt.Errorf("Failed to open a channel: %s", err)
+		return nil, nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
-	return conn, ch, nil
-
-}
 
-func createUrl(ssl bool, user, password, host string, port int, vhost string) string {
-	if ssl {
-		return fmt.Sprintf("amqps://%s:%s@%s:%d/%s",
-			user,
-			password,
-			host,
-			port,
-			vhost,
-		)
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to open a channel: %w", err)
 	}
-	return fmt.Sprintf("amqp://%s:%s@%s:%d/%s",
-		user,
-		password,
-		host,
-		port,
-		vhost,
-	)
+	return conn, ch, nil
 }
 
-func newTLSConfig(clientCertPath, clientKeyPath string) (*tls.Config, error) {
-	var clientCert tls.Certificate
-	var err error
-
-	if clientCertPath != "" && clientKeyPath != "" {
-		clientCert, err = tls.LoadX509KeyPair(clientCertPath, clientKeyPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load client certificate and key: %v", err)
-		}
-	} else {
-		certOut, keyOut, err := generateSelfSignedCert()
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate self-signed certificate: %v", err)
-		}
-		clientCert, err = tls.X509KeyPair(certOut, keyOut)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create TLS certificate from Let's Encrypt cert: %v", err)
-		}
+// Redacted returns the connection URL with the password masked
+func (o ConnectionOptions) Redacted() string {
+	uri, err := o.uri()
+	if err != nil {
+		return o.URI
 	}
+	if uri.Password != "" {
+		uri.Password = "xxxxx"
+	}
+	return uri.String()
+}
 
-	return &tls.Config{
-		InsecureSkipVerify: true,
-		Certificates:       []tls.Certificate{clientCert},
-	}, nil
+// Validate checks that the options form a valid AMQP URI
+func (o ConnectionOptions) Validate() error {
+	_, err := o.uri()
+	return err
 }
 
-func generateSelfSignedCert() (certOut, keyOut []byte, err error) {
-	// Create private key
-	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	if err != nil {
-		return nil, nil, err
+func (o ConnectionOptions) uri() (amqp091.URI, error) {
+	base := "amqp://localhost"
+	if o.URI != "" {
+		base = o.URI
 	}
-
-	// Set up certificate
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	uri, err := amqp091.ParseURI(base)
 	if err != nil {
-		return nil, nil, err
+		return uri, fmt.Errorf("invalid AMQP URI: %w", err)
 	}
 
-	template := x509.Certificate{
-		SerialNumber: serialNumber,
-		Subject: pkix.Name{
-			Organization: []string{"My Organization"},
-		},
-		NotBefore: time.Now(),
-		NotAfter:  time.Now().Add(365 * 24 * time.Hour),
-
-		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
-		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
-		IsCA:        false,
+	if o.SSL && uri.Scheme != "amqps" {
+		uri.Scheme = "amqps"
+		if o.Port == 0 && uri.Port == 5672 {
+			uri.Port = 5671
+		}
 	}
-
-	// Create certificate
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
-	if err != nil {
-		return nil, nil, err
+	if o.Host != "" {
+		uri.Host = o.Host
 	}
-
-	// Convert certificate to PEM format
-	certOut = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
-	privBytes, err := x509.MarshalECPrivateKey(priv)
-	if err != nil {
-		return nil, nil, err
+	if o.Port != 0 {
+		uri.Port = o.Port
 	}
-	keyOut = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privBytes})
-
-	return certOut, keyOut, nil
+	if o.User != "" {
+		uri.Username = o.User
+	}
+	if o.Password != "" {
+		uri.Password = o.Password
+	}
+	if o.VirtualHost != "" {
+		uri.Vhost = o.VirtualHost
+	}
+	return uri, nil
 }
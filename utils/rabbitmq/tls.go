@@ -0,0 +1,102 @@
+package rabbitmq
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// TLSOptions configures server verification and the client certificate of TLS connections
+type TLSOptions struct {
+	// CACertPath is a PEM bundle trusted in addition to the system roots
+	CACertPath string
+	// ServerName overrides the name verified against the server certificate
+	ServerName string
+	// Insecure disables server certificate verification
+	Insecure bool
+	// CertPath and KeyPath are a PEM encoded client certificate and key
+	CertPath string
+	KeyPath  string
+	// P12Path is a PKCS#12 file with the client certificate, key and chain
+	P12Path     string
+	P12Password string
+}
+
+func newTLSConfig(options TLSOptions, host string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         host,
+		InsecureSkipVerify: options.Insecure,
+	}
+	if options.ServerName != "" {
+		config.ServerName = options.ServerName
+	}
+
+	if options.CACertPath != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		data, err := os.ReadFile(options.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", options.CACertPath)
+		}
+		config.RootCAs = pool
+	}
+
+	switch {
+	case options.P12Path != "" && (options.CertPath != "" || options.KeyPath != ""):
+		return nil, fmt.Errorf("use either a PEM or a PKCS#12 client certificate, not both")
+	case options.P12Path != "":
+		certificate, err := loadP12Certificate(options.P12Path, options.P12Password)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	case options.CertPath != "" || options.KeyPath != "":
+		if options.CertPath == "" || options.KeyPath == "" {
+			return nil, fmt.Errorf("both client certificate and key are required")
+		}
+		certificate, err := tls.LoadX509KeyPair(options.CertPath, options.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate and key: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
+
+func loadP12Certificate(path, password string) (tls.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read PKCS#12 file: %w", err)
+	}
+	privateKey, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to decode PKCS#12 file: %w", err)
+	}
+
+	certificate := tls.Certificate{
+		Certificate: [][]byte{cert.Raw},
+		PrivateKey:  privateKey,
+		Leaf:        cert,
+	}
+	// Send the intermediates so the broker can build the chain
+	for _, caCert := range caCerts {
+		certificate.Certificate = append(certificate.Certificate, caCert.Raw)
+	}
+	return certificate, nil
+}
+
+// Config builds the TLS configuration for a connection to the host, other brokers reuse
+// the RabbitMQ TLS flags through it
+func (o TLSOptions) Config(host string) (*tls.Config, error) {
+	return newTLSConfig(o, host)
+}
@@ -0,0 +1,303 @@
+// Package stomp is a STOMP 1.2 client limited to what the RabbitMQ STOMP plugin adapter
+// needs: CONNECT, SEND with a receipt and SUBSCRIBE with automatic acknowledgements. The
+// text protocol is small enough to speak directly for this subset.
+package stomp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by operations on a closed client
+var ErrClosed = errors.New("stomp: client closed")
+
+// Options configures the connection to the broker
+type Options struct {
+	// Address is host:port of the broker
+	Address   string
+	TLSConfig *tls.Config
+	// Host is the virtual host sent in CONNECT
+	Host     string
+	Login    string
+	Passcode string
+	// Timeout limits dialing and waiting for receipts
+	Timeout time.Duration
+}
+
+// Message is a MESSAGE frame received by a subscription
+type Message struct {
+	Destination string
+	Headers     map[string]string
+	Body        []byte
+}
+
+// Subscription delivers the messages of one SUBSCRIBE
+type Subscription struct {
+	ID string
+	C  <-chan Message
+	ch chan Message
+}
+
+// Client is a STOMP 1.2 client connection
+type Client struct {
+	options Options
+	conn    net.Conn
+	reader  *bufio.Reader
+	// Server is the server header of the CONNECTED frame
+	Server string
+
+	writeMu sync.Mutex
+
+	mu            sync.Mutex
+	nextID        int
+	receipts      map[string]chan *Frame
+	subscriptions map[string]*Subscription
+	closed        bool
+	err           error
+	done          chan struct{}
+}
+
+// Dial connects to the broker and completes the CONNECT handshake
+func Dial(options Options) (*Client, error) {
+	if options.Timeout == 0 {
+		options.Timeout = 30 * time.Second
+	}
+
+	dialer := &net.Dialer{Timeout: options.Timeout}
+	var conn net.Conn
+	var err error
+	if options.TLSConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", options.Address, options.TLSConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", options.Address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to STOMP broker: %w", err)
+	}
+
+	c := &Client{
+		options:       options,
+		conn:          conn,
+		reader:        bufio.NewReader(conn),
+		receipts:      make(map[string]chan *Frame),
+		subscriptions: make(map[string]*Subscription),
+		done:          make(chan struct{}),
+	}
+	if err := c.connect(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *Client) connect() error {
+	frame := NewFrame("CONNECT", "accept-version", "1.2", "heart-beat", "0,0")
+	if c.options.Host != "" {
+		frame.Headers["host"] = c.options.Host
+	}
+	if c.options.Login != "" {
+		frame.Headers["login"] = c.options.Login
+		frame.Headers["passcode"] = c.options.Passcode
+	}
+
+	c.conn.SetDeadline(time.Now().Add(c.options.Timeout))
+	defer c.conn.SetDeadline(time.Time{})
+	if err := c.write(frame); err != nil {
+		return fmt.Errorf("failed to send CONNECT: %w", err)
+	}
+	response, err := readFrame(c.reader)
+	if err != nil {
+		return fmt.Errorf("failed to read CONNECTED: %w", err)
+	}
+	switch response.Command {
+	case "CONNECTED":
+		if version := response.Headers["version"]; version != "1.2" {
+			return fmt.Errorf("broker negotiated STOMP version '%s', 1.2 is required", version)
+		}
+		c.Server = response.Headers["server"]
+		return nil
+	case "ERROR":
+		return fmt.Errorf("connection refused by STOMP broker: %s", frameError(response))
+	default:
+		return fmt.Errorf("expected CONNECTED, got %s", response.Command)
+	}
+}
+
+func frameError(f *Frame) string {
+	message := f.Headers["message"]
+	if len(f.Body) > 0 {
+		if message != "" {
+			message += ": "
+		}
+		message += string(f.Body)
+	}
+	return message
+}
+
+func (c *Client) write(f *Frame) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(f.encode())
+	return err
+}
+
+func (c *Client) closedErr() error {
+	if c.err != nil {
+		return c.err
+	}
+	return ErrClosed
+}
+
+// request sends the frame with a receipt header and waits for the RECEIPT
+func (c *Client) request(f *Frame) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return c.closedErr()
+	}
+	c.nextID++
+	receipt := "r-" + strconv.Itoa(c.nextID)
+	done := make(chan *Frame, 1)
+	c.receipts[receipt] = done
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.receipts, receipt)
+		c.mu.Unlock()
+	}()
+
+	f.Headers["receipt"] = receipt
+	if err := c.write(f); err != nil {
+		return fmt.Errorf("failed to send %s: %w", f.Command, err)
+	}
+	select {
+	case response := <-done:
+		if response.Command == "ERROR" {
+			return fmt.Errorf("%s failed: %s", f.Command, frameError(response))
+		}
+		return nil
+	case <-c.done:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.closedErr()
+	case <-time.After(c.options.Timeout):
+		return fmt.Errorf("no receipt for %s within %s", f.Command, c.options.Timeout)
+	}
+}
+
+// Send publishes the body to the destination and waits for the broker receipt
+func (c *Client) Send(destination string, body []byte, headers map[string]string) error {
+	frame := NewFrame("SEND", "destination", destination)
+	for name, value := range headers {
+		frame.Headers[name] = value
+	}
+	frame.Headers["destination"] = destination
+	frame.Body = body
+	return c.request(frame)
+}
+
+// Subscribe subscribes to the destination, the broker acknowledges the messages on delivery
+func (c *Client) Subscribe(destination string, headers map[string]string) (*Subscription, error) {
+	c.mu.Lock()
+	c.nextID++
+	id := "sub-" + strconv.Itoa(c.nextID)
+	ch := make(chan Message, 1024)
+	sub := &Subscription{ID: id, C: ch, ch: ch}
+	c.subscriptions[id] = sub
+	c.mu.Unlock()
+
+	frame := NewFrame("SUBSCRIBE", "id", id, "destination", destination, "ack", "auto")
+	for name, value := range headers {
+		if _, reserved := frame.Headers[name]; !reserved {
+			frame.Headers[name] = value
+		}
+	}
+	if err := c.request(frame); err != nil {
+		c.mu.Lock()
+		delete(c.subscriptions, id)
+		c.mu.Unlock()
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (c *Client) readLoop() {
+	for {
+		frame, err := readFrame(c.reader)
+		if err != nil {
+			c.shutdown(fmt.Errorf("stomp: connection lost: %w", err))
+			return
+		}
+		switch frame.Command {
+		case "MESSAGE":
+			c.mu.Lock()
+			sub, ok := c.subscriptions[frame.Headers["subscription"]]
+			c.mu.Unlock()
+			if !ok {
+				continue
+			}
+			msg := Message{Destination: frame.Headers["destination"], Headers: frame.Headers, Body: frame.Body}
+			select {
+			case sub.ch <- msg:
+			case <-c.done:
+				return
+			}
+		case "RECEIPT":
+			c.deliverReceipt(frame.Headers["receipt-id"], frame)
+		case "ERROR":
+			// Errors caused by a frame with a receipt carry its id, the broker closes the connection anyway
+			c.deliverReceipt(frame.Headers["receipt-id"], frame)
+			c.shutdown(fmt.Errorf("stomp: broker error: %s", frameError(frame)))
+			return
+		}
+	}
+}
+
+func (c *Client) deliverReceipt(id string, frame *Frame) {
+	c.mu.Lock()
+	done, ok := c.receipts[id]
+	c.mu.Unlock()
+	if ok {
+		done <- frame
+	}
+}
+
+// shutdown closes the connection and all subscription channels once
+func (c *Client) shutdown(err error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	c.err = err
+	close(c.done)
+	subscriptions := c.subscriptions
+	c.subscriptions = nil
+	c.mu.Unlock()
+
+	c.conn.Close()
+	for _, sub := range subscriptions {
+		close(sub.ch)
+	}
+}
+
+// Close sends DISCONNECT, waits for its receipt and closes the connection
+func (c *Client) Close() error {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return nil
+	}
+	err := c.request(NewFrame("DISCONNECT"))
+	c.shutdown(nil)
+	return err
+}
@@ -0,0 +1,242 @@
+package stomp
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubBroker is a minimal in-process STOMP broker delivering sends to subscribers of the same destination
+type stubBroker struct {
+	listener net.Listener
+	login    string
+	passcode string
+
+	mu            sync.Mutex
+	subscriptions map[string]map[net.Conn]string
+	writers       map[net.Conn]*sync.Mutex
+	ackModes      []string
+}
+
+func newStubBroker(t *testing.T, login, passcode string) *stubBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	b := &stubBroker{
+		listener:      listener,
+		login:         login,
+		passcode:      passcode,
+		subscriptions: make(map[string]map[net.Conn]string),
+		writers:       make(map[net.Conn]*sync.Mutex),
+	}
+	t.Cleanup(func() { listener.Close() })
+	go b.serve()
+	return b
+}
+
+func (b *stubBroker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.writers[conn] = &sync.Mutex{}
+		b.mu.Unlock()
+		go b.handle(conn)
+	}
+}
+
+func (b *stubBroker) send(conn net.Conn, f *Frame) {
+	b.mu.Lock()
+	writer := b.writers[conn]
+	b.mu.Unlock()
+	writer.Lock()
+	defer writer.Unlock()
+	conn.Write(f.encode())
+}
+
+func (b *stubBroker) receipt(conn net.Conn, f *Frame) {
+	if id, ok := f.Headers["receipt"]; ok {
+		b.send(conn, NewFrame("RECEIPT", "receipt-id", id))
+	}
+}
+
+func (b *stubBroker) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		f, err := readFrame(reader)
+		if err != nil {
+			return
+		}
+		switch f.Command {
+		case "CONNECT", "STOMP":
+			if f.Headers["login"] != b.login || f.Headers["passcode"] != b.passcode {
+				b.send(conn, NewFrame("ERROR", "message", "Bad CONNECT"))
+				return
+			}
+			b.send(conn, NewFrame("CONNECTED", "version", "1.2", "server", "stub/1.0"))
+		case "SUBSCRIBE":
+			destination := f.Headers["destination"]
+			if strings.HasPrefix(destination, "/forbidden") {
+				refused := NewFrame("ERROR", "message", "access refused", "receipt-id", f.Headers["receipt"])
+				b.send(conn, refused)
+				return
+			}
+			b.mu.Lock()
+			if b.subscriptions[destination] == nil {
+				b.subscriptions[destination] = make(map[net.Conn]string)
+			}
+			b.subscriptions[destination][conn] = f.Headers["id"]
+			b.ackModes = append(b.ackModes, f.Headers["ack"])
+			b.mu.Unlock()
+			b.receipt(conn, f)
+		case "SEND":
+			b.route(f)
+			b.receipt(conn, f)
+		case "DISCONNECT":
+			b.receipt(conn, f)
+			return
+		}
+	}
+}
+
+func (b *stubBroker) route(f *Frame) {
+	destination := f.Headers["destination"]
+	b.mu.Lock()
+	targets := make(map[net.Conn]string)
+	for conn, id := range b.subscriptions[destination] {
+		targets[conn] = id
+	}
+	b.mu.Unlock()
+
+	for conn, id := range targets {
+		msg := NewFrame("MESSAGE", "subscription", id, "message-id", "m-1", "ack", "a-1")
+		for name, value := range f.Headers {
+			if name != "receipt" {
+				msg.Headers[name] = value
+			}
+		}
+		msg.Body = f.Body
+		b.send(conn, msg)
+	}
+}
+
+func (b *stubBroker) dial(t *testing.T) *Client {
+	t.Helper()
+	client, err := Dial(Options{Address: b.listener.Addr().String(), Host: "/", Login: b.login, Passcode: b.passcode, Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatalf("unexpected dial error: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func receive(t *testing.T, messages <-chan Message) Message {
+	t.Helper()
+	select {
+	case msg, ok := <-messages:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+	}
+	return Message{}
+}
+
+func TestClient_SendSubscribe(t *testing.T) {
+	broker := newStubBroker(t, "guest", "secret")
+	client := broker.dial(t)
+	if client.Server != "stub/1.0" {
+		t.Errorf("unexpected server %q", client.Server)
+	}
+
+	sub, err := client.Subscribe("/queue/orders", nil)
+	if err != nil {
+		t.Fatalf("unexpected subscribe error: %v", err)
+	}
+	headers := map[string]string{"content-type": "text/plain", "note": "a:b\nc\\d"}
+	if err := client.Send("/queue/orders", []byte("hello"), headers); err != nil {
+		t.Fatalf("unexpected send error: %v", err)
+	}
+
+	msg := receive(t, sub.C)
+	if msg.Destination != "/queue/orders" || string(msg.Body) != "hello" {
+		t.Errorf("unexpected message %q on %s", msg.Body, msg.Destination)
+	}
+	if msg.Headers["note"] != "a:b\nc\\d" || msg.Headers["content-type"] != "text/plain" {
+		t.Errorf("headers not preserved: %v", msg.Headers)
+	}
+
+	broker.mu.Lock()
+	ackModes := broker.ackModes
+	broker.mu.Unlock()
+	if len(ackModes) != 1 || ackModes[0] != "auto" {
+		t.Errorf("expected an auto acknowledged subscription, got %v", ackModes)
+	}
+}
+
+func TestClient_BinaryBody(t *testing.T) {
+	broker := newStubBroker(t, "", "")
+	client := broker.dial(t)
+
+	sub, err := client.Subscribe("/topic/bin", nil)
+	if err != nil {
+		t.Fatalf("unexpected subscribe error: %v", err)
+	}
+	body := []byte{0x00, 0x01, '\n', 0x00, 0xFF}
+	if err := client.Send("/topic/bin", body, nil); err != nil {
+		t.Fatalf("unexpected send error: %v", err)
+	}
+	if msg := receive(t, sub.C); !bytes.Equal(msg.Body, body) {
+		t.Errorf("expected body %v, got %v", body, msg.Body)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	broker := newStubBroker(t, "guest", "secret")
+
+	_, err := Dial(Options{Address: broker.listener.Addr().String(), Login: "guest", Passcode: "wrong", Timeout: time.Second})
+	if err == nil || !strings.Contains(err.Error(), "Bad CONNECT") {
+		t.Errorf("expected authentication error, got %v", err)
+	}
+
+	client := broker.dial(t)
+	valid, err := client.Subscribe("/queue/valid", nil)
+	if err != nil {
+		t.Fatalf("unexpected subscribe error: %v", err)
+	}
+	if _, err := client.Subscribe("/forbidden/queue", nil); err == nil || !strings.Contains(err.Error(), "access refused") {
+		t.Errorf("expected access refused, got %v", err)
+	}
+	if _, ok := <-valid.C; ok {
+		t.Error("expected subscription channel to be closed after broker error")
+	}
+	if err := client.Send("/queue/valid", nil, nil); err == nil {
+		t.Error("expected error after broker error")
+	}
+}
+
+func TestFrame_Decode(t *testing.T) {
+	raw := "\n\r\nMESSAGE\r\ndestination:/queue/a\\cb\nrepeated:first\nrepeated:second\n\nbody\x00"
+	f, err := readFrame(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Command != "MESSAGE" || f.Headers["destination"] != "/queue/a:b" || f.Headers["repeated"] != "first" || string(f.Body) != "body" {
+		t.Errorf("unexpected frame %+v", f)
+	}
+
+	if _, err := readFrame(bufio.NewReader(strings.NewReader("MESSAGE\ncontent-length:2\n\nabc\x00"))); err == nil {
+		t.Error("expected error for body longer than content-length")
+	}
+}
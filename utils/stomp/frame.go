@@ -0,0 +1,142 @@
+package stomp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Frame is a STOMP frame. Repeated headers keep only the first value, as required by STOMP 1.2.
+type Frame struct {
+	Command string
+	Headers map[string]string
+	Body    []byte
+}
+
+// NewFrame creates a frame with the headers given as name, value pairs
+func NewFrame(command string, headers ...string) *Frame {
+	f := &Frame{Command: command, Headers: make(map[string]string, len(headers)/2)}
+	for i := 0; i+1 < len(headers); i += 2 {
+		f.Headers[headers[i]] = headers[i+1]
+	}
+	return f
+}
+
+// escaped reports whether header values of the command are escaped, CONNECT and
+// CONNECTED frames are not for compatibility with STOMP 1.0
+func escaped(command string) bool {
+	return command != "CONNECT" && command != "CONNECTED"
+}
+
+var (
+	headerEscaper   = strings.NewReplacer("\\", "\\\\", "\r", "\\r", "\n", "\\n", ":", "\\c")
+	headerUnescaper = strings.NewReplacer("\\\\", "\\", "\\r", "\r", "\\n", "\n", "\\c", ":")
+)
+
+// encode serializes the frame, content-length is always set so bodies may contain NUL
+func (f *Frame) encode() []byte {
+	var out bytes.Buffer
+	out.WriteString(f.Command)
+	out.WriteByte('\n')
+
+	names := make([]string, 0, len(f.Headers))
+	for name := range f.Headers {
+		if name != "content-length" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := f.Headers[name]
+		if escaped(f.Command) {
+			name, value = headerEscaper.Replace(name), headerEscaper.Replace(value)
+		}
+		out.WriteString(name)
+		out.WriteByte(':')
+		out.WriteString(value)
+		out.WriteByte('\n')
+	}
+	if len(f.Body) > 0 || f.Command == "SEND" || f.Command == "MESSAGE" {
+		out.WriteString("content-length:")
+		out.WriteString(strconv.Itoa(len(f.Body)))
+		out.WriteByte('\n')
+	}
+	out.WriteByte('\n')
+	out.Write(f.Body)
+	out.WriteByte(0)
+	return out.Bytes()
+}
+
+// readFrame reads the next frame, skipping heart-beat end of lines
+func readFrame(r *bufio.Reader) (*Frame, error) {
+	var command string
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if line != "" {
+			command = line
+			break
+		}
+	}
+
+	f := &Frame{Command: command, Headers: make(map[string]string)}
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("malformed header line '%s'", line)
+		}
+		if escaped(command) {
+			name, value = headerUnescaper.Replace(name), headerUnescaper.Replace(value)
+		}
+		if _, exists := f.Headers[name]; !exists {
+			f.Headers[name] = value
+		}
+	}
+
+	if length, ok := f.Headers["content-length"]; ok {
+		n, err := strconv.Atoi(length)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid content-length '%s'", length)
+		}
+		f.Body = make([]byte, n)
+		if _, err := io.ReadFull(r, f.Body); err != nil {
+			return nil, err
+		}
+		terminator, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if terminator != 0 {
+			return nil, fmt.Errorf("frame body is not terminated by NUL")
+		}
+		return f, nil
+	}
+
+	body, err := r.ReadBytes(0)
+	if err != nil {
+		return nil, err
+	}
+	f.Body = body[:len(body)-1]
+	return f, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
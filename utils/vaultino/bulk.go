@@ -0,0 +1,314 @@
+package vaultino
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+)
+
+// Statuses of bulk results
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// BulkResult is the outcome of a bulk operation on one vault
+type BulkResult struct {
+	File    string   `json:"file"`
+	Status  string   `json:"status"`
+	Detail  string   `json:"detail,omitempty"`
+	Matches []string `json:"matches,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// BulkReport summarizes a bulk operation, the results are sorted by file
+type BulkReport struct {
+	Operation       string       `json:"operation"`
+	Results         []BulkResult `json:"results"`
+	OK              int          `json:"ok"`
+	Failed          int          `json:"failed"`
+	Skipped         int          `json:"skipped"`
+	DurationSeconds float64      `json:"durationSeconds"`
+}
+
+// BulkOptions select the vaults of a bulk operation and its concurrency
+type BulkOptions struct {
+	// Files are processed in addition to the vaults found in Dirs
+	Files []string
+	// Dirs are searched recursively for *.vault files
+	Dirs []string
+	// Workers open vaults concurrently, argon2id makes every password vault take a while
+	Workers int
+}
+
+// FindVaults returns the *.vault files under the directory, hidden directories like .git
+// are skipped
+func FindVaults(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && strings.HasSuffix(d.Name(), ".vault") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error searching %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// RekeyVaults changes the password of all password vaults to the new password, vaults
+// encrypted for recipients are skipped
+func RekeyVaults(opts BulkOptions, keys Keys) (*BulkReport, error) {
+	files, err := opts.files()
+	if err != nil {
+		return nil, err
+	}
+	if keys, err = prepareBulkKeys(files, keys); err != nil {
+		return nil, err
+	}
+
+	newPasswords := keys.NewPassword
+	if newPasswords.Env == "" {
+		newPasswords.Env = NewPasswordEnv
+	}
+	if newPasswords.Prompt == "" {
+		newPasswords.Prompt = "Enter new password for the vaults: "
+	}
+	newPasswords.Keyring = nil
+	newPassword, err := newPasswords.Password(files[0], true)
+	if err != nil {
+		return nil, fmt.Errorf("error reading new password: %w", err)
+	}
+
+	return runBulk("rekey", files, opts.Workers, func(file string) BulkResult {
+		header, err := vaultino.ReadHeader(file)
+		if err != nil {
+			return failed(file, err)
+		}
+		if header.Mode == vaultino.ModeRecipients {
+			return BulkResult{File: file, Status: StatusSkipped, Detail: "encrypted for recipients"}
+		}
+		v, err := vaultino.Open(file, keys.Provider())
+		if err != nil {
+			return failed(file, err)
+		}
+		if err := v.SetPassword(newPassword); err != nil {
+			return failed(file, err)
+		}
+		if err := v.Save(); err != nil {
+			return failed(file, err)
+		}
+		if err := keys.NewPassword.Remember(file, newPassword); err != nil {
+			return BulkResult{File: file, Status: StatusOK, Detail: "rekeyed, keyring not updated: " + err.Error()}
+		}
+		return BulkResult{File: file, Status: StatusOK, Detail: "rekeyed"}
+	}), nil
+}
+
+// VerifyVaults checks that every vault decrypts and its payload parses
+func VerifyVaults(opts BulkOptions, keys Keys) (*BulkReport, error) {
+	files, err := opts.files()
+	if err != nil {
+		return nil, err
+	}
+	if keys, err = prepareBulkKeys(files, keys); err != nil {
+		return nil, err
+	}
+
+	return runBulk("verify", files, opts.Workers, func(file string) BulkResult {
+		v, err := vaultino.Open(file, keys.Provider())
+		if err != nil {
+			return failed(file, err)
+		}
+		header := v.Header()
+		if err := vaultino.Validate(v.Data(), header.Type); err != nil {
+			return failed(file, fmt.Errorf("invalid %s: %w", header.Type, err))
+		}
+		detail := fmt.Sprintf("%s %s, %s", header.Version, header.Type, header.Cipher)
+		if values, err := v.Values(); err == nil {
+			detail += fmt.Sprintf(", %d keys", len(values))
+		}
+		return BulkResult{File: file, Status: StatusOK, Detail: detail}
+	}), nil
+}
+
+// GrepVaults returns the key paths matching the regular expression in every vault
+func GrepVaults(opts BulkOptions, keys Keys, pattern *regexp.Regexp) (*BulkReport, error) {
+	files, err := opts.files()
+	if err != nil {
+		return nil, err
+	}
+	if keys, err = prepareBulkKeys(files, keys); err != nil {
+		return nil, err
+	}
+
+	return runBulk("grep", files, opts.Workers, func(file string) BulkResult {
+		v, err := vaultino.Open(file, keys.Provider())
+		if err != nil {
+			return failed(file, err)
+		}
+		paths, err := v.Keys()
+		if err != nil {
+			return failed(file, err)
+		}
+		result := BulkResult{File: file, Status: StatusOK}
+		for _, path := range paths {
+			if pattern.MatchString(path) {
+				result.Matches = append(result.Matches, path)
+			}
+		}
+		return result
+	}), nil
+}
+
+func (o BulkOptions) files() ([]string, error) {
+	seen := map[string]bool{}
+	var files []string
+	add := func(file string) {
+		clean := filepath.Clean(file)
+		if !seen[clean] {
+			seen[clean] = true
+			files = append(files, clean)
+		}
+	}
+	for _, file := range o.Files {
+		add(file)
+	}
+	for _, dir := range o.Dirs {
+		found, err := FindVaults(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range found {
+			add(file)
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no vault files found")
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// prepareBulkKeys reads the passwords and loads the identities once, the workers must not
+// prompt concurrently. Keyring passwords are looked up per vault like for a single vault,
+// the other password vaults share one password from the remaining sources.
+func prepareBulkKeys(files []string, keys Keys) (Keys, error) {
+	var passwordVaults []string
+	recipientVault := false
+	for _, file := range files {
+		header, err := vaultino.ReadHeader(file)
+		if err != nil {
+			continue
+		}
+		if header.Mode == vaultino.ModeRecipients {
+			recipientVault = true
+		} else {
+			passwordVaults = append(passwordVaults, file)
+		}
+	}
+
+	if len(passwordVaults) > 0 {
+		missing := passwordVaults
+		if keys.Password.Keyring != nil && !keys.Password.explicit() {
+			missing = nil
+			keys.passwords = make(map[string][]byte)
+			for _, file := range passwordVaults {
+				password, err := keys.Password.fromKeyring(file)
+				if errors.Is(err, ErrKeyringNotFound) {
+					missing = append(missing, file)
+					continue
+				}
+				if err != nil {
+					return keys, fmt.Errorf("error reading password of %s: %w", file, err)
+				}
+				keys.passwords[keyringAccount(file)] = password
+			}
+		}
+		if len(missing) > 0 {
+			shared := keys.Password
+			shared.Keyring = nil
+			password, err := shared.Password(missing[0], false)
+			if err != nil {
+				return keys, fmt.Errorf("error reading password: %w", err)
+			}
+			keys.Password = PasswordProvider{Value: string(password)}
+		}
+	}
+	if recipientVault {
+		identities, err := LoadIdentities(keys.IdentityFiles)
+		if err != nil {
+			return keys, err
+		}
+		keys.identities = identities
+	}
+	return keys, nil
+}
+
+// runBulk runs the operation on the files with a pool of workers
+func runBulk(operation string, files []string, workers int, run func(file string) BulkResult) *BulkReport {
+	if workers < 1 {
+		workers = 1
+	}
+	start := time.Now()
+	results := make([]BulkResult, len(files))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(workers, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = run(files[i])
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := &BulkReport{Operation: operation, Results: results, DurationSeconds: time.Since(start).Seconds()}
+	for _, result := range results {
+		switch result.Status {
+		case StatusOK:
+			report.OK++
+		case StatusFailed:
+			report.Failed++
+		case StatusSkipped:
+			report.Skipped++
+		}
+	}
+	return report
+}
+
+func failed(file string, err error) BulkResult {
+	return BulkResult{File: file, Status: StatusFailed, Error: err.Error()}
+}
+
+// DefaultWorkers keeps the memory of concurrent argon2id derivations reasonable
+func DefaultWorkers() int {
+	return min(runtime.NumCPU(), 4)
+}
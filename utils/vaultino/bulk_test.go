@@ -0,0 +1,177 @@
+package vaultino
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+)
+
+// fastFormat keeps argon2id cheap, the default parameters take a noticeable time per vault
+var fastFormat = vaultino.Format{KDF: vaultino.KDFParams{Time: 1, Memory: 64, Threads: 1}}
+
+// createTestVault writes a YAML vault to the path relative to dir, encrypted with the keys
+func createTestVault(t *testing.T, dir, name, content string, keys vaultino.KeyProvider) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	v, err := vaultino.New(path, []byte(content), keys, vaultino.Options{FileName: "secrets.yaml", Format: fastFormat})
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatalf("failed to save vault: %v", err)
+	}
+	return path
+}
+
+// newBulkTree creates a.vault and b.vault with the password "good", sub/c.vault with the
+// password "other" and a vault in a hidden directory that must not be found
+func newBulkTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	createTestVault(t, dir, "a.vault", "db:\n  password: a\n  user: app\n", vaultino.Password("good"))
+	createTestVault(t, dir, "b.vault", "api:\n  token: b\n", vaultino.Password("good"))
+	createTestVault(t, dir, filepath.Join("sub", "c.vault"), "db:\n  password: c\n", vaultino.Password("other"))
+	createTestVault(t, dir, filepath.Join(".git", "d.vault"), "db:\n  password: d\n", vaultino.Password("good"))
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a vault"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return dir
+}
+
+// statuses returns the status of every result by file name relative to dir
+func statuses(t *testing.T, dir string, report *BulkReport) map[string]string {
+	t.Helper()
+	result := make(map[string]string, len(report.Results))
+	for _, r := range report.Results {
+		rel, err := filepath.Rel(dir, r.File)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		result[filepath.ToSlash(rel)] = r.Status
+		if r.Status == StatusFailed && r.Error == "" {
+			t.Errorf("expected an error for the failed vault %s", rel)
+		}
+	}
+	return result
+}
+
+func TestVerifyVaults_WrongPassword(t *testing.T) {
+	dir := newBulkTree(t)
+	report, err := VerifyVaults(BulkOptions{Dirs: []string{dir}, Workers: 2}, Keys{Password: PasswordProvider{Value: "good"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{"a.vault": StatusOK, "b.vault": StatusOK, "sub/c.vault": StatusFailed}
+	got := statuses(t, dir, report)
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for file, status := range expected {
+		if got[file] != status {
+			t.Errorf("%s: expected %s, got %s", file, status, got[file])
+		}
+	}
+	if report.OK != 2 || report.Failed != 1 || report.Skipped != 0 || report.Operation != "verify" {
+		t.Errorf("expected verify with 2 ok and 1 failed, got %s with %d ok, %d failed, %d skipped", report.Operation, report.OK, report.Failed, report.Skipped)
+	}
+	if !strings.HasSuffix(report.Results[0].File, "a.vault") || !strings.HasSuffix(report.Results[2].File, "c.vault") {
+		t.Errorf("expected the results sorted by file, got %v", report.Results)
+	}
+}
+
+func TestGrepVaults(t *testing.T) {
+	dir := newBulkTree(t)
+	report, err := GrepVaults(BulkOptions{Dirs: []string{dir}, Workers: 4}, Keys{Password: PasswordProvider{Value: "good"}}, regexp.MustCompile(`^db\.`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.OK != 2 || report.Failed != 1 {
+		t.Fatalf("expected 2 ok and 1 failed, got %d ok and %d failed", report.OK, report.Failed)
+	}
+	matches := map[string]string{}
+	for _, result := range report.Results {
+		matches[filepath.Base(result.File)] = strings.Join(result.Matches, ",")
+	}
+	if matches["a.vault"] != "db.password,db.user" || matches["b.vault"] != "" {
+		t.Errorf("expected db keys of a.vault only, got %v", matches)
+	}
+}
+
+func TestRekeyVaults(t *testing.T) {
+	dir := newBulkTree(t)
+	secret, public, err := vaultino.GenerateIdentity()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recipient, err := vaultino.ParseRecipient(public)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	createTestVault(t, dir, "r.vault", "key: value\n", vaultino.Recipients{recipient})
+	identityFile := filepath.Join(t.TempDir(), "identity")
+	if err := os.WriteFile(identityFile, []byte(secret+"\n"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	keys := Keys{
+		Password:      PasswordProvider{Value: "good"},
+		NewPassword:   PasswordProvider{Value: "new"},
+		IdentityFiles: []string{identityFile},
+	}
+	report, err := RekeyVaults(BulkOptions{Dirs: []string{dir}, Workers: 2}, keys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{"a.vault": StatusOK, "b.vault": StatusOK, "r.vault": StatusSkipped, "sub/c.vault": StatusFailed}
+	got := statuses(t, dir, report)
+	for file, status := range expected {
+		if got[file] != status {
+			t.Errorf("%s: expected %s, got %s", file, status, got[file])
+		}
+	}
+	if report.OK != 2 || report.Failed != 1 || report.Skipped != 1 {
+		t.Errorf("expected 2 ok, 1 failed and 1 skipped, got %d ok, %d failed, %d skipped", report.OK, report.Failed, report.Skipped)
+	}
+
+	// The rekeyed vaults open with the new password, the failed one keeps its password
+	for file, password := range map[string]string{"a.vault": "new", "b.vault": "new", "sub/c.vault": "other"} {
+		if _, err := vaultino.Open(filepath.Join(dir, file), vaultino.Password(password)); err != nil {
+			t.Errorf("%s: expected to open with %q, got %v", file, password, err)
+		}
+	}
+}
+
+func TestPrepareBulkKeys_KeyringPerVault(t *testing.T) {
+	dir := newBulkTree(t)
+	const env = "VAULTINO_TEST_BULK_PASSWORD"
+	t.Setenv(env, "good")
+	keyring := &memoryKeyring{passwords: map[string]string{
+		keyringAccount(filepath.Join(dir, "sub", "c.vault")): "other",
+	}}
+
+	report, err := VerifyVaults(BulkOptions{Dirs: []string{dir}}, Keys{Password: PasswordProvider{Keyring: keyring, Env: env}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.OK != 3 || report.Failed != 0 {
+		t.Errorf("expected all 3 vaults to open, got %d ok and %d failed: %v", report.OK, report.Failed, report.Results)
+	}
+	// Every password vault is looked up once before the workers start
+	if len(keyring.gets) != 3 {
+		t.Errorf("expected 3 keyring lookups, got %v", keyring.gets)
+	}
+}
+
+func TestBulkOptions_NoVaults(t *testing.T) {
+	if _, err := VerifyVaults(BulkOptions{Dirs: []string{t.TempDir()}}, Keys{}); err == nil {
+		t.Fatal("expected an error without vaults")
+	}
+}
@@ -0,0 +1,212 @@
+package vaultino
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+)
+
+// Kinds of key changes
+const (
+	KeyAdded   = "+"
+	KeyRemoved = "-"
+	KeyChanged = "~"
+)
+
+// KeyChange is an added, removed or changed key between two payloads
+type KeyChange struct {
+	Kind string
+	Key  string
+	Old  string
+	New  string
+}
+
+// DiffVaults decrypts both vaults and returns the changed keys sorted by key
+func DiffVaults(oldVault, newVault string, keys Keys) ([]KeyChange, error) {
+	old, err := vaultValues(oldVault, keys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", oldVault, err)
+	}
+	updated, err := vaultValues(newVault, keys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", newVault, err)
+	}
+	return diffKeys(old, updated), nil
+}
+
+// vaultValues decrypts the vault and returns its values by key path
+func vaultValues(vaultFile string, keys Keys) (map[string]string, error) {
+	v, err := vaultino.Open(vaultFile, keys.Provider())
+	if err != nil {
+		return nil, err
+	}
+	return v.Values()
+}
+
+// Textconv writes the payload of the vault as sorted "key = value" lines for git diff,
+// masked values are replaced by a fingerprint keyed by the vault password or identity so
+// changes stay visible without the values being guessable from the diff.
+// Vaults that cannot be decrypted are written as they are after a comment, git would
+// refuse to show the diff otherwise.
+func Textconv(vaultFile string, keys Keys, mask bool, w io.Writer) error {
+	v, err := vaultino.Open(vaultFile, keys.Provider())
+	if err != nil {
+		raw, readErr := os.ReadFile(vaultFile)
+		if readErr != nil {
+			return readErr
+		}
+		fmt.Fprintf(w, "# vaultino: cannot decrypt: %v\n", err)
+		_, err = w.Write(raw)
+		return err
+	}
+
+	data := v.Data()
+	if v.Type() == vaultino.TypeBinary {
+		content, err := v.Fingerprint(data)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "# vaultino: binary file\nsize = %d\ncontent = %s\n", len(data), content)
+		return err
+	}
+
+	values, err := v.Values()
+	if err != nil {
+		// Other file types and broken payloads are shown as they are
+		if !mask {
+			_, err = w.Write(data)
+			return err
+		}
+		content, err := v.Fingerprint(data)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "content = %s\n", content)
+		return err
+	}
+
+	paths := make([]string, 0, len(values))
+	for path := range values {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		value := values[path]
+		if mask {
+			if value, err = v.Fingerprint([]byte(value)); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s = %s\n", path, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GitSetupOptions control SetupGit
+type GitSetupOptions struct {
+	// Pattern of the vault files in .gitattributes
+	Pattern string
+	// Command runs vaultino textconv, the running executable when empty
+	Command string
+	// Mask shows value fingerprints instead of values in git diff
+	Mask bool
+}
+
+// SetupGit registers the vaultino diff driver in the repository of the directory: the
+// attribute in .gitattributes and the textconv command in the local git config. The
+// textconv cache is disabled so no plaintext is stored in git notes.
+func SetupGit(dir string, opts GitSetupOptions) (string, error) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", fmt.Errorf("error finding git repository: %w", err)
+	}
+	root := strings.TrimSpace(string(out))
+
+	if opts.Pattern == "" {
+		opts.Pattern = "*.vault"
+	}
+	if err := addGitAttribute(filepath.Join(root, ".gitattributes"), opts.Pattern+" diff=vaultino"); err != nil {
+		return "", err
+	}
+
+	command := opts.Command
+	if command == "" {
+		executable, err := os.Executable()
+		if err != nil {
+			return "", fmt.Errorf("error finding vpd executable: %w", err)
+		}
+		command = shellQuote(executable) + " vaultino textconv"
+	}
+	if opts.Mask {
+		command += " --mask-values"
+	}
+	settings := [][2]string{
+		{"diff.vaultino.textconv", command},
+		{"diff.vaultino.cachetextconv", "false"},
+	}
+	for _, setting := range settings {
+		if output, err := exec.Command("git", "-C", root, "config", "--local", setting[0], setting[1]).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("error setting %s: %w: %s", setting[0], err, strings.TrimSpace(string(output)))
+		}
+	}
+	return root, nil
+}
+
+// addGitAttribute appends the line unless .gitattributes already configures the driver
+func addGitAttribute(path, line string) error {
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading .gitattributes: %w", err)
+	}
+	for _, existing := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(existing) == line {
+			return nil
+		}
+	}
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		content = append(content, '\n')
+	}
+	content = append(content, line+"\n"...)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("error writing .gitattributes: %w", err)
+	}
+	return nil
+}
+
+// diffKeys returns the added, removed and changed keys sorted by key
+func diffKeys(old, updated map[string]string) []KeyChange {
+	var keys []string
+	for key := range old {
+		keys = append(keys, key)
+	}
+	for key := range updated {
+		if _, ok := old[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []KeyChange
+	for _, key := range keys {
+		oldValue, inOld := old[key]
+		newValue, inNew := updated[key]
+		switch {
+		case !inOld:
+			changes = append(changes, KeyChange{Kind: KeyAdded, Key: key, New: newValue})
+		case !inNew:
+			changes = append(changes, KeyChange{Kind: KeyRemoved, Key: key, Old: oldValue})
+		case oldValue != newValue:
+			changes = append(changes, KeyChange{Kind: KeyChanged, Key: key, Old: oldValue, New: newValue})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,277 @@
+package vaultino
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+	"golang.org/x/term"
+)
+
+// EditOptions control vaultino edit
+type EditOptions struct {
+	// ChangePassword asks for a new password before saving
+	ChangePassword bool
+	// Backup keeps the previous vault as <vault>.bak
+	Backup bool
+	// Output receives the redacted diff and the prompts, stdout when nil
+	Output io.Writer
+}
+
+// ErrEditAborted is returned when invalid content is not saved on request of the user
+var ErrEditAborted = errors.New("edit aborted, the vault was not changed")
+
+// EditVault decrypts the vault into a private temporary directory, opens it in $EDITOR and
+// seals the edited content again when it changed
+func EditVault(vaultFile string, keys Keys, opts EditOptions) error {
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+
+	header, err := vaultino.ReadHeader(vaultFile)
+	if err != nil {
+		return fmt.Errorf("error reading vault: %w", err)
+	}
+	if header.Type == vaultino.TypeBinary {
+		return errors.New("binary vaults cannot be edited, decrypt the file and create the vault again")
+	}
+	if header.Mode == vaultino.ModeRecipients && opts.ChangePassword {
+		return errors.New("the vault is encrypted for recipients, manage access with vaultino recipients instead")
+	}
+
+	v, err := vaultino.Open(vaultFile, keys.Provider())
+	if err != nil {
+		return err
+	}
+	original := v.Data()
+
+	dir, err := privateTempDir()
+	if err != nil {
+		return err
+	}
+	// The plaintext stays on disk when saving fails, so the edits can be recovered
+	saved := false
+	defer func() {
+		if saved {
+			shredDir(dir)
+		}
+	}()
+
+	tmpFileName := filepath.Join(dir, v.Header().FileName())
+	if err := os.WriteFile(tmpFileName, original, 0600); err != nil {
+		shredDir(dir)
+		return fmt.Errorf("error writing to temp file: %w", err)
+	}
+
+	edited, err := editUntilValid(tmpFileName, v.Type(), out)
+	if err != nil {
+		saved = true
+		return err
+	}
+
+	if bytes.Equal(edited, original) && !opts.ChangePassword {
+		saved = true
+		fmt.Fprintln(out, "No changes, the vault was not rewritten")
+		return nil
+	}
+	before, errBefore := v.Values()
+	v.SetData(edited)
+	after, errAfter := v.Values()
+	if errBefore != nil || errAfter != nil {
+		fmt.Fprintln(out, "Content changed")
+	} else {
+		printRedactedDiff(out, before, after)
+	}
+
+	if err := saveEdited(v, keys, opts); err != nil {
+		return fmt.Errorf("%w, the edited file is kept at %s", err, tmpFileName)
+	}
+	saved = true
+	return nil
+}
+
+func saveEdited(v *vaultino.Vault, keys Keys, opts EditOptions) error {
+	var password []byte
+	if opts.ChangePassword {
+		newPasswords := keys.NewPassword
+		if newPasswords.Env == "" {
+			newPasswords.Env = NewPasswordEnv
+		}
+		if newPasswords.Prompt == "" {
+			newPasswords.Prompt = "Enter new password for the vault: "
+		}
+		// The keyring holds the old password, it is only updated with the new one
+		newPasswords.Keyring = nil
+		var err error
+		if password, err = newPasswords.Password(v.Path(), true); err != nil {
+			return fmt.Errorf("error reading new password: %w", err)
+		}
+		if err := v.SetPassword(password); err != nil {
+			return err
+		}
+	}
+
+	if opts.Backup {
+		original, err := os.ReadFile(v.Path())
+		if err != nil {
+			return fmt.Errorf("error reading vault for backup: %w", err)
+		}
+		if err := vaultino.WriteFileAtomic(v.Path()+".bak", original); err != nil {
+			return fmt.Errorf("error writing backup: %w", err)
+		}
+	}
+	if err := v.Save(); err != nil {
+		return err
+	}
+	if opts.ChangePassword {
+		return keys.NewPassword.Remember(v.Path(), password)
+	}
+	return nil
+}
+
+// editUntilValid runs the editor until the content parses or the user gives up
+func editUntilValid(file, fileType string, out io.Writer) ([]byte, error) {
+	for {
+		if err := runEditor(file); err != nil {
+			return nil, err
+		}
+		edited, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading edited file: %w", err)
+		}
+
+		syntaxErr := vaultino.Validate(edited, fileType)
+		if syntaxErr == nil {
+			return edited, nil
+		}
+		fmt.Fprintf(out, "The edited file is not valid %s: %v\n", strings.ToUpper(fileType), syntaxErr)
+		switch askChoice(out, "[e]dit again, [s]ave anyway or [a]bort? ", "e") {
+		case "e":
+			continue
+		case "s":
+			return edited, nil
+		default:
+			return nil, ErrEditAborted
+		}
+	}
+}
+
+func runEditor(file string) error {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	// EDITOR may carry arguments, e.g. "code --wait"
+	args := strings.Fields(editor)
+
+	cmd := exec.Command(args[0], append(args[1:], file)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error editing vault: %w", err)
+	}
+	return nil
+}
+
+// askChoice reads the first letter of the answer, the default when stdin is no terminal
+// or the answer is empty, and "a" on read errors
+func askChoice(out io.Writer, prompt, defaultChoice string) string {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "a"
+	}
+	fmt.Fprint(out, prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "a"
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer == "" {
+		return defaultChoice
+	}
+	return answer[:1]
+}
+
+// privateTempDir creates a directory only the user can access, on tmpfs when available so
+// the plaintext never reaches the disk
+func privateTempDir() (string, error) {
+	var candidates []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		candidates = append(candidates, dir)
+	}
+	if runtime.GOOS == "linux" {
+		candidates = append(candidates, "/dev/shm")
+	}
+	candidates = append(candidates, os.TempDir())
+
+	var lastErr error
+	for _, base := range candidates {
+		dir, err := os.MkdirTemp(base, "vaultino-edit-*")
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err := os.Chmod(dir, 0700); err != nil && runtime.GOOS != "windows" {
+			os.Remove(dir)
+			lastErr = err
+			continue
+		}
+		return dir, nil
+	}
+	return "", fmt.Errorf("error creating temporary directory: %w", lastErr)
+}
+
+// shredDir overwrites the files of the directory with zeros before removing it, editors
+// may have left swap or backup files next to the edited file
+func shredDir(dir string) {
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			shredFile(filepath.Join(dir, entry.Name()))
+		}
+	}
+	os.RemoveAll(dir)
+}
+
+func shredFile(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err == nil {
+		zeros := make([]byte, 32*1024)
+		for remaining := info.Size(); remaining > 0; {
+			n := min(remaining, int64(len(zeros)))
+			if _, err := file.Write(zeros[:n]); err != nil {
+				break
+			}
+			remaining -= n
+		}
+		file.Sync()
+		file.Close()
+	}
+	os.Remove(path)
+}
+
+// printRedactedDiff lists the added, removed and changed keys without their values
+func printRedactedDiff(out io.Writer, before, after map[string]string) {
+	changes := diffKeys(before, after)
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "No keys changed, only formatting or comments")
+		return
+	}
+	fmt.Fprintln(out, "Changed keys:")
+	for _, change := range changes {
+		fmt.Fprintf(out, "  %s %s\n", change.Kind, change.Key)
+	}
+}
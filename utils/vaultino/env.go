@@ -0,0 +1,146 @@
+package vaultino
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+)
+
+// EnvOptions control how secrets are turned into environment variable names
+type EnvOptions struct {
+	// Prefix is prepended to every name
+	Prefix string
+	// Separator joins the keys of nested YAML and JSON values, "_" when empty
+	Separator string
+	// KeepCase keeps the case of the keys instead of upper casing the names
+	KeepCase bool
+}
+
+// EnvVar is a secret as an environment variable
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// VaultEnv decrypts the vault in memory and returns its secrets as environment variables
+// sorted by name
+func VaultEnv(vaultFile string, keys Keys, opts EnvOptions) ([]EnvVar, error) {
+	v, err := vaultino.Open(vaultFile, keys.Provider())
+	if err != nil {
+		return nil, err
+	}
+	return secretsToEnv(v, opts)
+}
+
+// DecryptVault decrypts the vault in memory and returns the payload with the type of
+// the original file
+func DecryptVault(vaultFile string, keys Keys) ([]byte, string, error) {
+	v, err := vaultino.Open(vaultFile, keys.Provider())
+	if err != nil {
+		return nil, "", err
+	}
+	return v.Data(), v.Type(), nil
+}
+
+// ExecWithSecrets runs the command with the secrets added to the current environment and
+// returns its exit code, signals are forwarded to the child
+func ExecWithSecrets(command []string, secrets []EnvVar) (int, error) {
+	if len(command) == 0 {
+		return 0, errors.New("no command given")
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = os.Environ()
+	for _, secret := range secrets {
+		cmd.Env = append(cmd.Env, secret.Name+"="+secret.Value)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("error starting command: %w", err)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		for sig := range sigChan {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error running command: %w", err)
+	}
+	return 0, nil
+}
+
+// FormatExports returns the variables as export lines for eval in a POSIX shell
+func FormatExports(secrets []EnvVar) string {
+	var b strings.Builder
+	for _, secret := range secrets {
+		fmt.Fprintf(&b, "export %s=%s\n", secret.Name, shellQuote(secret.Value))
+	}
+	return b.String()
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
+// secretsToEnv flattens the payload to environment variables
+func secretsToEnv(v *vaultino.Vault, opts EnvOptions) ([]EnvVar, error) {
+	if opts.Separator == "" {
+		opts.Separator = "_"
+	}
+
+	// Flatten with a separator no key contains, so a nested key and a flat key joining to
+	// the same name stay apart until the collision check
+	values, err := v.Flatten("\x00")
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]EnvVar, 0, len(values))
+	for key, value := range values {
+		name := envName(opts.Prefix + strings.ReplaceAll(key, "\x00", opts.Separator))
+		if !opts.KeepCase {
+			name = strings.ToUpper(name)
+		}
+		secrets = append(secrets, EnvVar{Name: name, Value: value})
+	}
+	sort.Slice(secrets, func(i, j int) bool { return secrets[i].Name < secrets[j].Name })
+	for i := 1; i < len(secrets); i++ {
+		if secrets[i].Name == secrets[i-1].Name {
+			return nil, fmt.Errorf("keys collide on the variable name %s, use another separator", secrets[i].Name)
+		}
+	}
+	return secrets, nil
+}
+
+// envName replaces the characters not allowed in variable names with underscores
+func envName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			name[i] = '_'
+		}
+	}
+	if len(name) > 0 && name[0] >= '0' && name[0] <= '9' {
+		return "_" + string(name)
+	}
+	return string(name)
+}
@@ -0,0 +1,139 @@
+package vaultino
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"DB_PASSWORD", "DB_PASSWORD"},
+		{"db.password", "db_password"},
+		{"api-token", "api_token"},
+		{"my key/with spaces", "my_key_with_spaces"},
+		{"1password", "_1password"},
+		{"heslo-čaj", "heslo___aj"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := envName(tt.key); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.key, tt.want, got)
+		}
+	}
+}
+
+func TestSecretsToEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		opts    EnvOptions
+		want    []EnvVar
+		collide bool
+	}{
+		{
+			name:    "nested keys upper cased and sorted",
+			content: "db:\n  user: app\n  password: secret\napi-token: t\n",
+			want:    []EnvVar{{"API_TOKEN", "t"}, {"DB_PASSWORD", "secret"}, {"DB_USER", "app"}},
+		},
+		{
+			name:    "prefix, separator and case",
+			content: "db:\n  user: app\n",
+			opts:    EnvOptions{Prefix: "app.", Separator: "__", KeepCase: true},
+			want:    []EnvVar{{"app_db__user", "app"}},
+		},
+		{
+			name:    "dash and underscore collide",
+			content: "db-host: a\ndb_host: b\n",
+			collide: true,
+		},
+		{
+			name:    "nested key collides with a flat key",
+			content: "db:\n  host: a\ndb_host: b\n",
+			collide: true,
+		},
+		{
+			name:    "case collides when upper cased",
+			content: "token: a\nTOKEN: b\n",
+			collide: true,
+		},
+		{
+			name:    "case kept avoids the collision",
+			content: "token: a\nTOKEN: b\n",
+			opts:    EnvOptions{KeepCase: true},
+			want:    []EnvVar{{"TOKEN", "b"}, {"token", "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := vaultino.New("secrets.vault", []byte(tt.content), vaultino.Password("secret"), vaultino.Options{FileName: "secrets.yaml", Format: fastFormat})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			secrets, err := secretsToEnv(v, tt.opts)
+			if tt.collide {
+				if err == nil || !strings.Contains(err.Error(), "collide") {
+					t.Fatalf("expected a collision error, got %v and %v", err, secrets)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(secrets, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, secrets)
+			}
+		})
+	}
+}
+
+func TestFormatExports_ShellQuoting(t *testing.T) {
+	values := []string{
+		"plain",
+		"",
+		"it's",
+		`"double" and 'single'`,
+		"$(touch /tmp/vaultino-pwned) `id` $HOME",
+		"line one\nline two\n",
+		`back\slash \n`,
+		"'",
+	}
+	for _, value := range values {
+		exports := FormatExports([]EnvVar{{Name: "SECRET", Value: value}})
+		if !strings.HasPrefix(exports, "export SECRET='") {
+			t.Errorf("expected a single quoted export, got %q", exports)
+		}
+		// The shell must read the value back unchanged, without expanding anything
+		out, err := exec.Command("sh", "-c", exports+`printf %s "$SECRET"`).Output()
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", value, err)
+		}
+		if string(out) != value {
+			t.Errorf("expected %q, got %q", value, out)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"secret", `'secret'`},
+		{"", `''`},
+		{"it's", `'it'"'"'s'`},
+		{"$(id)", `'$(id)'`},
+		{"a\nb", "'a\nb'"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.value); got != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.value, tt.want, got)
+		}
+	}
+}
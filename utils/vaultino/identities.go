@@ -0,0 +1,47 @@
+package vaultino
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+)
+
+// DefaultIdentityFiles returns the identity files used when none are given, in this order:
+// the VAULTINO_IDENTITY variable, ~/.config/vpd/vaultino-identity.txt and ~/.ssh/id_ed25519
+func DefaultIdentityFiles() []string {
+	if env := os.Getenv("VAULTINO_IDENTITY"); env != "" {
+		return filepath.SplitList(env)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, path := range []string{
+		filepath.Join(home, ".config", "vpd", "vaultino-identity.txt"),
+		filepath.Join(home, ".ssh", "id_ed25519"),
+	} {
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// LoadIdentities reads age identity files (AGE-SECRET-KEY-1... lines) and OpenSSH ed25519
+// private keys, passphrase protected SSH keys prompt for the passphrase
+func LoadIdentities(paths []string) ([]vaultino.Identity, error) {
+	var identities []vaultino.Identity
+	for _, path := range paths {
+		loaded, err := vaultino.LoadIdentityFile(path, func() ([]byte, error) {
+			return readPassword(fmt.Sprintf("Enter passphrase for %s: ", path))
+		})
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, loaded...)
+	}
+	return identities, nil
+}
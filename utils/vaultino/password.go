@@ -0,0 +1,283 @@
+package vaultino
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// PasswordEnv is the environment variable read when no other password source is given
+const PasswordEnv = "VAULTINO_PASSWORD"
+
+// NewPasswordEnv holds the new password when changing the password non-interactively
+const NewPasswordEnv = "VAULTINO_NEW_PASSWORD"
+
+// keyringService is the service name vault passwords are stored under
+const keyringService = "vpd-vaultino"
+
+// ErrKeyringNotFound is returned by a keyring without a password for the account
+var ErrKeyringNotFound = errors.New("password not found in keyring")
+
+// Keyring stores vault passwords in an OS credential store, accounts are absolute vault paths
+type Keyring interface {
+	Get(service, account string) (string, error)
+	Set(service, account, password string) error
+}
+
+var (
+	keyringsMu sync.Mutex
+	keyrings   = map[string]Keyring{}
+)
+
+// RegisterKeyring makes a keyring backend available under the name
+func RegisterKeyring(name string, keyring Keyring) {
+	keyringsMu.Lock()
+	defer keyringsMu.Unlock()
+	keyrings[name] = keyring
+}
+
+// GetKeyring returns the registered keyring, "auto" selects the backend of the OS
+func GetKeyring(name string) (Keyring, error) {
+	if name == "auto" {
+		switch runtime.GOOS {
+		case "darwin":
+			name = "macos"
+		default:
+			name = "secret-service"
+		}
+	}
+	keyringsMu.Lock()
+	defer keyringsMu.Unlock()
+	keyring, ok := keyrings[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown keyring '%s', available: auto, %s", name, strings.Join(keyringNames(), ", "))
+	}
+	return keyring, nil
+}
+
+func keyringNames() []string {
+	names := make([]string, 0, len(keyrings))
+	for name := range keyrings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterKeyring("secret-service", &commandKeyring{
+		get: func(service, account string) *exec.Cmd {
+			return exec.Command("secret-tool", "lookup", "service", service, "account", account)
+		},
+		set: func(service, account string) *exec.Cmd {
+			return exec.Command("secret-tool", "store", "--label", service+" "+account, "service", service, "account", account)
+		},
+	})
+	RegisterKeyring("macos", &commandKeyring{
+		get: func(service, account string) *exec.Cmd {
+			return exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+		},
+		set: func(service, account string) *exec.Cmd {
+			// -w without a value reads the password from stdin, -U updates an existing item
+			return exec.Command("security", "add-generic-password", "-U", "-s", service, "-a", account, "-w")
+		},
+	})
+}
+
+// commandKeyring talks to the keyring through its command line tool, so no cgo or
+// D-Bus client is needed
+type commandKeyring struct {
+	get func(service, account string) *exec.Cmd
+	set func(service, account string) *exec.Cmd
+}
+
+func (k *commandKeyring) Get(service, account string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := k.get(service, account)
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", ErrKeyringNotFound
+		}
+		return "", fmt.Errorf("error reading keyring: %w", err)
+	}
+	password := strings.TrimRight(stdout.String(), "\r\n")
+	if password == "" {
+		return "", ErrKeyringNotFound
+	}
+	return password, nil
+}
+
+func (k *commandKeyring) Set(service, account, password string) error {
+	cmd := k.set(service, account)
+	cmd.Stdin = strings.NewReader(password + "\n")
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error saving password to keyring: %w", err)
+	}
+	return nil
+}
+
+// PasswordProvider resolves a vault password from the first configured source: the value,
+// a file ("-" for stdin), a file descriptor, an external command, the keyring, the
+// environment variable and finally an interactive prompt
+type PasswordProvider struct {
+	Value string
+	File  string
+	// FD is an inherited file descriptor, used when greater than zero
+	FD      int
+	Command string
+	Keyring Keyring
+	// Env names the environment variable, PasswordEnv when empty
+	Env string
+	// Prompt is the text of the interactive prompt
+	Prompt string
+}
+
+// Password returns the password of the vault. New passwords from the interactive prompt
+// are asked twice and have to match.
+func (p PasswordProvider) Password(vaultFile string, confirm bool) ([]byte, error) {
+	switch {
+	case p.Value != "":
+		return []byte(p.Value), nil
+	case p.File != "":
+		return p.fromFile()
+	case p.FD > 0:
+		return readFirstLine(os.NewFile(uintptr(p.FD), fmt.Sprintf("fd %d", p.FD)), fmt.Sprintf("file descriptor %d", p.FD))
+	case p.Command != "":
+		return p.fromCommand()
+	}
+
+	if p.Keyring != nil {
+		password, err := p.fromKeyring(vaultFile)
+		if err == nil {
+			return password, nil
+		}
+		if !errors.Is(err, ErrKeyringNotFound) {
+			return nil, err
+		}
+	}
+
+	env := p.Env
+	if env == "" {
+		env = PasswordEnv
+	}
+	if password, ok := os.LookupEnv(env); ok && password != "" {
+		return []byte(password), nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("no password given and stdin is not a terminal, set %s or use --password-file, --password-fd, --password-command or --keyring", env)
+	}
+	prompt := p.Prompt
+	if prompt == "" {
+		prompt = "Enter password: "
+	}
+	password, err := readPassword(prompt)
+	if err != nil || !confirm {
+		return password, err
+	}
+	again, err := readPassword("Confirm password: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(password, again) {
+		return nil, errors.New("passwords do not match")
+	}
+	return password, nil
+}
+
+// explicit reports whether a source preceding the keyring is configured, it gives the
+// password of every vault
+func (p PasswordProvider) explicit() bool {
+	return p.Value != "" || p.File != "" || p.FD > 0 || p.Command != ""
+}
+
+// fromKeyring returns the password stored for the vault, ErrKeyringNotFound without one
+func (p PasswordProvider) fromKeyring(vaultFile string) ([]byte, error) {
+	password, err := p.Keyring.Get(keyringService, keyringAccount(vaultFile))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(password), nil
+}
+
+// Remember stores the password in the keyring, when one is configured
+func (p PasswordProvider) Remember(vaultFile string, password []byte) error {
+	if p.Keyring == nil {
+		return nil
+	}
+	return p.Keyring.Set(keyringService, keyringAccount(vaultFile), string(password))
+}
+
+func (p PasswordProvider) fromFile() ([]byte, error) {
+	if p.File == "-" {
+		return readFirstLine(os.Stdin, "stdin")
+	}
+	file, err := os.Open(p.File)
+	if err != nil {
+		return nil, fmt.Errorf("error opening password file: %w", err)
+	}
+	defer file.Close()
+	return readFirstLine(file, p.File)
+}
+
+func (p PasswordProvider) fromCommand() ([]byte, error) {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	var stdout bytes.Buffer
+	cmd := exec.Command(shell, flag, p.Command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("password command failed: %w", err)
+	}
+	password := strings.TrimRight(stdout.String(), "\r\n")
+	if password == "" {
+		return nil, errors.New("password command returned an empty password")
+	}
+	return []byte(password), nil
+}
+
+// readFirstLine returns the first line without the line ending
+func readFirstLine(r io.Reader, source string) ([]byte, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("error reading password from %s: %w", source, err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty password in %s", source)
+	}
+	return []byte(line), nil
+}
+
+func keyringAccount(vaultFile string) string {
+	if abs, err := filepath.Abs(vaultFile); err == nil {
+		return abs
+	}
+	return vaultFile
+}
+
+// readPassword prompts on stderr, stdout carries the output of env, textconv and others
+func readPassword(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	pass, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return pass, err
+}
@@ -0,0 +1,154 @@
+package vaultino
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// memoryKeyring is a keyring of accounts and passwords, err is returned by every Get when set
+type memoryKeyring struct {
+	passwords map[string]string
+	err       error
+	gets      []string
+}
+
+func (k *memoryKeyring) Get(service, account string) (string, error) {
+	k.gets = append(k.gets, account)
+	if k.err != nil {
+		return "", k.err
+	}
+	password, ok := k.passwords[account]
+	if !ok {
+		return "", ErrKeyringNotFound
+	}
+	return password, nil
+}
+
+func (k *memoryKeyring) Set(service, account, password string) error {
+	if k.passwords == nil {
+		k.passwords = make(map[string]string)
+	}
+	k.passwords[account] = password
+	return nil
+}
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return path
+}
+
+func TestPasswordProvider_Precedence(t *testing.T) {
+	const env = "VAULTINO_TEST_PASSWORD"
+	t.Setenv(env, "from-env")
+	file := writeTestFile(t, "password", "from-file\n")
+	fd, err := os.Open(writeTestFile(t, "fd", "from-fd\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer fd.Close()
+	keyring := &memoryKeyring{passwords: map[string]string{keyringAccount("app.vault"): "from-keyring"}}
+
+	tests := []struct {
+		name     string
+		provider PasswordProvider
+		want     string
+	}{
+		{"value first", PasswordProvider{Value: "from-value", File: file, Command: "echo from-command", Keyring: keyring, Env: env}, "from-value"},
+		{"file before fd and command", PasswordProvider{File: file, FD: int(fd.Fd()), Command: "echo from-command", Keyring: keyring, Env: env}, "from-file"},
+		{"fd before command", PasswordProvider{FD: int(fd.Fd()), Command: "echo from-command", Keyring: keyring, Env: env}, "from-fd"},
+		{"command before keyring", PasswordProvider{Command: "echo from-command", Keyring: keyring, Env: env}, "from-command"},
+		{"keyring before env", PasswordProvider{Keyring: keyring, Env: env}, "from-keyring"},
+		{"env without keyring entry", PasswordProvider{Keyring: &memoryKeyring{}, Env: env}, "from-env"},
+		{"env", PasswordProvider{Env: env}, "from-env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			password, err := tt.provider.Password("app.vault", false)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(password) != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, password)
+			}
+		})
+	}
+}
+
+func TestPasswordProvider_TrimsLineEndings(t *testing.T) {
+	fd, err := os.Open(writeTestFile(t, "fd", "fd-secret\r\nsecond line\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer fd.Close()
+
+	tests := []struct {
+		name     string
+		provider PasswordProvider
+		want     string
+	}{
+		{"file with newline", PasswordProvider{File: writeTestFile(t, "lf", "secret\n")}, "secret"},
+		{"file with crlf and more lines", PasswordProvider{File: writeTestFile(t, "crlf", "secret\r\nsecond line\n")}, "secret"},
+		{"file without newline", PasswordProvider{File: writeTestFile(t, "none", "secret")}, "secret"},
+		{"fd", PasswordProvider{FD: int(fd.Fd())}, "fd-secret"},
+		{"command", PasswordProvider{Command: "printf 'secret\\n\\n'"}, "secret"},
+		{"command keeps inner spaces", PasswordProvider{Command: "printf ' se cret \\r\\n'"}, " se cret "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			password, err := tt.provider.Password("app.vault", false)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(password) != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, password)
+			}
+		})
+	}
+}
+
+func TestPasswordProvider_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider PasswordProvider
+		want     string
+	}{
+		{"command fails", PasswordProvider{Command: "echo secret; exit 3"}, "password command failed"},
+		{"command prints nothing", PasswordProvider{Command: "true"}, "empty password"},
+		{"empty file", PasswordProvider{File: writeTestFile(t, "empty", "\n")}, "empty password"},
+		{"missing file", PasswordProvider{File: filepath.Join(t.TempDir(), "missing")}, "error opening password file"},
+		{"keyring fails", PasswordProvider{Keyring: &memoryKeyring{err: errors.New("dbus unavailable")}}, "dbus unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.provider.Password("app.vault", false)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestPasswordProvider_RememberUsesVaultPath(t *testing.T) {
+	keyring := &memoryKeyring{}
+	provider := PasswordProvider{Keyring: keyring}
+	if err := provider.Remember("app.vault", []byte("secret")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	password, err := provider.Password(filepath.Join(".", "app.vault"), false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(password) != "secret" {
+		t.Errorf("expected %q, got %q", "secret", password)
+	}
+	if !filepath.IsAbs(keyring.gets[0]) {
+		t.Errorf("expected an absolute keyring account, got %q", keyring.gets[0])
+	}
+}
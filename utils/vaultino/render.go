@@ -0,0 +1,228 @@
+package vaultino
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// K8sSecretOptions describe the Kubernetes Secret built from a vault
+type K8sSecretOptions struct {
+	Name      string
+	Namespace string
+	// Type of the Secret, Opaque when empty
+	Type string
+	// Key holds the rendered template in the Secret, the template name without .tmpl when empty
+	Key string
+}
+
+// RenderTemplate evaluates the Go template with the secrets of the vault. Besides the
+// built-in functions the template can use secret, hasSecret, secrets, b64enc, b64dec,
+// toYaml, toJson, quote, indent and nindent.
+func RenderTemplate(templateFile, vaultFile string, keys Keys, w io.Writer) error {
+	text, err := os.ReadFile(templateFile)
+	if err != nil {
+		return fmt.Errorf("error reading template: %w", err)
+	}
+	v, err := vaultino.Open(vaultFile, keys.Provider())
+	if err != nil {
+		return err
+	}
+	tree, err := v.Tree()
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New(filepath.Base(templateFile)).
+		Option("missingkey=error").
+		Funcs(templateFuncs(v, tree)).
+		Parse(string(text))
+	if err != nil {
+		return fmt.Errorf("error parsing template: %w", err)
+	}
+
+	// The output is written only when the whole template rendered
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, tree); err != nil {
+		return fmt.Errorf("error rendering template: %w", err)
+	}
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+// RenderK8sSecret writes a v1.Secret manifest in YAML. Without a template every key of the
+// vault becomes a key of the Secret, with a template the rendered output is stored under a
+// single key.
+func RenderK8sSecret(templateFile, vaultFile string, keys Keys, opts K8sSecretOptions, w io.Writer) error {
+	data := map[string][]byte{}
+	if templateFile != "" {
+		var rendered bytes.Buffer
+		if err := RenderTemplate(templateFile, vaultFile, keys, &rendered); err != nil {
+			return err
+		}
+		key := opts.Key
+		if key == "" {
+			key = strings.TrimSuffix(filepath.Base(templateFile), ".tmpl")
+		}
+		data[key] = rendered.Bytes()
+	} else {
+		v, err := vaultino.Open(vaultFile, keys.Provider())
+		if err != nil {
+			return err
+		}
+		values, err := v.Values()
+		if err != nil {
+			return err
+		}
+		for key, value := range values {
+			data[key] = []byte(value)
+		}
+	}
+
+	for key := range data {
+		if !validSecretKey(key) {
+			return fmt.Errorf("key '%s' is not a valid Secret key, only alphanumerics, '-', '_' and '.' are allowed", key)
+		}
+	}
+
+	secretType := corev1.SecretTypeOpaque
+	if opts.Type != "" {
+		secretType = corev1.SecretType(opts.Type)
+	}
+	secret := corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.Name,
+			Namespace: opts.Namespace,
+		},
+		Type: secretType,
+		Data: data,
+	}
+	manifest, err := toYAML(secret)
+	if err != nil {
+		return fmt.Errorf("error serializing Secret: %w", err)
+	}
+	_, err = io.WriteString(w, manifest)
+	return err
+}
+
+func templateFuncs(v *vaultino.Vault, tree interface{}) template.FuncMap {
+	return template.FuncMap{
+		"secret": func(key string) (interface{}, error) {
+			value, ok, err := v.Lookup(key)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("key %s not found", key)
+			}
+			return value, nil
+		},
+		"hasSecret": func(key string) (bool, error) {
+			_, ok, err := v.Lookup(key)
+			return ok, err
+		},
+		"secrets": func() interface{} {
+			return tree
+		},
+		"b64enc": func(value interface{}) string {
+			return base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(value)))
+		},
+		"b64dec": func(value interface{}) (string, error) {
+			decoded, err := base64.StdEncoding.DecodeString(fmt.Sprint(value))
+			if err != nil {
+				return "", fmt.Errorf("error decoding base64: %w", err)
+			}
+			return string(decoded), nil
+		},
+		"toYaml": func(value interface{}) (string, error) {
+			out, err := toYAML(value)
+			return strings.TrimSuffix(out, "\n"), err
+		},
+		"toJson": func(value interface{}) (string, error) {
+			out, err := json.Marshal(value)
+			return string(out), err
+		},
+		"quote": func(value interface{}) string {
+			return strconv.Quote(fmt.Sprint(value))
+		},
+		"indent": func(spaces int, text string) string {
+			return indentLines(spaces, text)
+		},
+		"nindent": func(spaces int, text string) string {
+			return "\n" + indentLines(spaces, text)
+		},
+	}
+}
+
+// toYAML serializes the value through JSON, so the json tags of the Kubernetes types are
+// used and maps come out with sorted keys
+func toYAML(value interface{}) (string, error) {
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(jsonData, &node); err != nil {
+		return "", err
+	}
+	// Block style instead of the flow style of the JSON input, the encoder still quotes
+	// strings that would read as another type
+	clearStyle(&node)
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	if node.Kind == yaml.ScalarNode && strings.Contains(node.Value, "\n") {
+		node.Style = yaml.LiteralStyle
+	}
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+func indentLines(spaces int, text string) string {
+	pad := strings.Repeat(" ", spaces)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func validSecretKey(key string) bool {
+	if key == "" || len(key) > 253 {
+		return false
+	}
+	for _, c := range key {
+		if !(c == '-' || c == '_' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
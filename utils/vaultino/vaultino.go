@@ -1,392 +1,150 @@
 package vaultino
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
-	"time"
 
-	"github.com/tidwall/gjson"
-	"golang.org/x/crypto/argon2"
-	"golang.org/x/term"
-	"gopkg.in/yaml.v3"
+	"github.com/VojtechPastyrik/vpd/pkg/vaultino"
 )
 
-const (
-	saltSize  = 16
-	nonceSize = 12
-)
-
-type vaultData struct {
-	header     string
-	salt       []byte
-	nonce      []byte
-	ciphertext []byte
+// Keys unlock a vault: password vaults with the password of the provider and recipient
+// vaults with the first matching identity. NewPassword is used when changing the password.
+type Keys struct {
+	Password      PasswordProvider
+	NewPassword   PasswordProvider
+	IdentityFiles []string
+	// identities and the keyring passwords by account are loaded once for bulk operations
+	identities []vaultino.Identity
+	passwords  map[string][]byte
 }
 
-func CreateVault(name string, file string) error {
-	plaintext, err := os.ReadFile(file)
-	if err != nil {
-		return fmt.Errorf("error reading source file: %w", err)
-	}
-
-	password, err := readPassword()
-	if err != nil {
-		return fmt.Errorf("error reading password: %w", err)
-	}
-
-	salt := make([]byte, saltSize)
-	if _, err := rand.Read(salt); err != nil {
-		return fmt.Errorf("error generating salt: %w", err)
-	}
-
-	nonce := make([]byte, nonceSize)
-	if _, err := rand.Read(nonce); err != nil {
-		return fmt.Errorf("error generating nonce: %w", err)
-	}
-
-	ciphertext, err := encrypt(plaintext, password, salt, nonce)
-	if err != nil {
-		return err
-	}
-
-	header := buildHeader(file)
-	vaultFile := fmt.Sprintf("%s.vault", name)
+// Provider returns the keys as a vaultino.KeyProvider, identity files are read when a
+// vault encrypted for recipients is opened
+func (k Keys) Provider() vaultino.KeyProvider {
+	return keyProvider{keys: k}
+}
 
-	return writeVaultFile(vaultFile, header, salt, nonce, ciphertext)
+type keyProvider struct {
+	keys Keys
 }
 
-func DecryptVaultToFile(vaultFile string) error {
-	vd, err := readVaultFile(vaultFile)
-	if err != nil {
-		return err
+func (p keyProvider) Password(path string, confirm bool) ([]byte, error) {
+	if password, ok := p.keys.passwords[keyringAccount(path)]; ok && !confirm {
+		return password, nil
 	}
+	return p.keys.Password.Password(path, confirm)
+}
 
-	password, err := readPassword()
-	if err != nil {
-		return fmt.Errorf("error reading password: %w", err)
+func (p keyProvider) Identities() ([]vaultino.Identity, error) {
+	if p.keys.identities != nil {
+		return p.keys.identities, nil
 	}
-
-	plaintext, err := decrypt(vd.ciphertext, password, vd.salt, vd.nonce)
-	if err != nil {
-		return err
+	if len(p.keys.IdentityFiles) == 0 {
+		return nil, errors.New("the vault is encrypted for recipients, no identity file given (use --identity or VAULTINO_IDENTITY)")
 	}
-
-	fileType := parseHeaderValue(vd.header, "type")
-	name := parseHeaderValue(vd.header, "name")
-	outFile := fmt.Sprintf("%s.%s", name, fileType)
-
-	return os.WriteFile(outFile, plaintext, 0600)
+	return LoadIdentities(p.keys.IdentityFiles)
 }
 
-func EditVault(vaultFile string) error {
-	return editVaultInternal(vaultFile, false)
+func (p keyProvider) Recipients() ([]vaultino.Recipient, error) {
+	return nil, nil
 }
 
-func EditVaultWithPasswordChange(vaultFile string) error {
-	return editVaultInternal(vaultFile, true)
+// CreateOptions select how a new vault is encrypted
+type CreateOptions struct {
+	// Recipients encrypt the vault with a data key wrapped for each of them, the password
+	// is used when there are none
+	Recipients []vaultino.Recipient
+	Passwords  PasswordProvider
+	Format     vaultino.Format
+	// Binary keeps the file as opaque bytes with its original name, files that are not
+	// UTF-8 text are always stored as binary
+	Binary bool
 }
 
-func editVaultInternal(vaultFile string, changePassword bool) error {
-	vd, err := readVaultFile(vaultFile)
-	if err != nil {
-		return fmt.Errorf("error reading vault: %w", err)
-	}
-
-	password, err := readPassword()
-	if err != nil {
-		return fmt.Errorf("error reading password: %w", err)
-	}
-
-	plaintext, err := decrypt(vd.ciphertext, password, vd.salt, vd.nonce)
-	if err != nil {
-		return err
-	}
-
-	tmpFile, err := os.CreateTemp("", "myvault-edit-*.tmp")
-	if err != nil {
-		return fmt.Errorf("error creating temporary file: %w", err)
-	}
-	tmpFileName := tmpFile.Name()
-
-	if _, err := tmpFile.Write(plaintext); err != nil {
-		tmpFile.Close()
-		os.Remove(tmpFileName)
-		return fmt.Errorf("error writing to temp file: %w", err)
-	}
-	tmpFile.Close()
-
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		editor = "vi"
-	}
-
-	cmd := exec.Command(editor, tmpFileName)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		os.Remove(tmpFileName)
-		return fmt.Errorf("error editing vault: %w", err)
-	}
-
-	// Read edited content
-	editedContent, err := os.ReadFile(tmpFileName)
+// CreateVault encrypts the file for the recipients, or with a password when there are none
+func CreateVault(name string, file string, opts CreateOptions) error {
+	plaintext, err := os.ReadFile(file)
 	if err != nil {
-		os.Remove(tmpFileName)
-		return fmt.Errorf("error reading edited file: %w", err)
-	}
-	os.Remove(tmpFileName)
-
-	// Preserve original name and type, update user and time
-	name := parseHeaderValue(vd.header, "name")
-	fileType := parseHeaderValue(vd.header, "type")
-
-	// Create new header with original name and type
-	user := os.Getenv("USER")
-	if user == "" {
-		user = os.Getenv("USERNAME")
+		return fmt.Errorf("error reading source file: %w", err)
 	}
-	timestamp := time.Now().Format(time.RFC3339)
-	header := fmt.Sprintf("$VAULTINO;1.2;AES256-GCM;argon2id;name=%s;type=%s;user=%s;time=%s",
-		name, fileType, user, timestamp)
+	vaultFile := fmt.Sprintf("%s.vault", name)
+	vaultOpts := vaultino.Options{FileName: file, Format: opts.Format, Binary: opts.Binary}
 
-	// Determine which password to use for re-encryption
-	encryptPassword := password
-	if changePassword {
-		fmt.Println("Enter new password for the vault:")
-		newPassword, err := readPassword()
+	if len(opts.Recipients) > 0 {
+		v, err := vaultino.New(vaultFile, plaintext, vaultino.Recipients(opts.Recipients), vaultOpts)
 		if err != nil {
-			return fmt.Errorf("error reading new password: %w", err)
+			return err
 		}
-		encryptPassword = newPassword
-	}
-
-	// Re-encrypt with new salt and nonce
-	salt := make([]byte, saltSize)
-	if _, err := rand.Read(salt); err != nil {
-		return fmt.Errorf("error generating salt: %w", err)
-	}
-
-	nonce := make([]byte, nonceSize)
-	if _, err := rand.Read(nonce); err != nil {
-		return fmt.Errorf("error generating nonce: %w", err)
-	}
-
-	ciphertext, err := encrypt(editedContent, encryptPassword, salt, nonce)
-	if err != nil {
-		return err
-	}
-
-	return writeVaultFile(vaultFile, header, salt, nonce, ciphertext)
-}
-
-func GetSecretFromVault(vaultFile, key, password string) (string, error) {
-	data, err := decryptVaultInMemory(vaultFile, password)
-	if err != nil {
-		return "", err
-	}
-
-	fileType := parseHeaderValueFromFile(vaultFile, "type")
-	return extractValue(data, key, fileType)
-}
-
-func encrypt(plaintext, password, salt, nonce []byte) ([]byte, error) {
-	key := deriveKey(password, salt)
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, fmt.Errorf("error creating cipher: %w", err)
-	}
-
-	aesGCM, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, fmt.Errorf("error creating GCM: %w", err)
-	}
-
-	return aesGCM.Seal(nil, nonce, plaintext, nil), nil
-}
-
-func decrypt(ciphertext, password, salt, nonce []byte) ([]byte, error) {
-	key := deriveKey(password, salt)
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, fmt.Errorf("error creating cipher: %w", err)
-	}
-
-	aesGCM, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, fmt.Errorf("error creating GCM: %w", err)
-	}
-
-	plaintext, err := aesGCM.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return nil, errors.New("decryption failed: incorrect password or corrupted data")
+		return v.Save()
 	}
 
-	return plaintext, nil
-}
-
-func deriveKey(password, salt []byte) []byte {
-	return argon2.IDKey(password, salt, 3, 64*1024, 4, 32)
-}
-
-func readVaultFile(vaultFile string) (*vaultData, error) {
-	content, err := os.ReadFile(vaultFile)
+	password, err := opts.Passwords.Password(vaultFile, true)
 	if err != nil {
-		return nil, fmt.Errorf("error reading vault file: %w", err)
-	}
-
-	parts := strings.SplitN(string(content), "\n", 2)
-	if len(parts) < 2 {
-		return nil, errors.New("invalid vault format")
+		return fmt.Errorf("error reading password: %w", err)
 	}
-
-	data, err := base64.StdEncoding.DecodeString(parts[1])
+	v, err := vaultino.New(vaultFile, plaintext, vaultino.Password(password), vaultOpts)
 	if err != nil {
-		return nil, fmt.Errorf("error decoding base64 content: %w", err)
+		return err
 	}
-
-	if len(data) < saltSize+nonceSize {
-		return nil, errors.New("invalid encrypted data")
+	if err := v.Save(); err != nil {
+		return err
 	}
-
-	return &vaultData{
-		header:     parts[0],
-		salt:       data[:saltSize],
-		nonce:      data[saltSize : saltSize+nonceSize],
-		ciphertext: data[saltSize+nonceSize:],
-	}, nil
+	return opts.Passwords.Remember(vaultFile, password)
 }
 
-func writeVaultFile(filename, header string, salt, nonce, ciphertext []byte) error {
-	data := append(salt, nonce...)
-	data = append(data, ciphertext...)
-	encoded := base64.StdEncoding.EncodeToString(data)
-
-	content := fmt.Sprintf("%s\n%s", header, encoded)
-	return os.WriteFile(filename, []byte(content), 0600)
+// DecryptOptions control where vaultino decrypt writes the payload
+type DecryptOptions struct {
+	// Output is the file or the existing directory to write to, "-" for stdout. The
+	// original file name in the current directory is used when empty.
+	Output string
 }
 
-func decryptVaultInMemory(vaultFile string, providedPassword string) ([]byte, error) {
-	vd, err := readVaultFile(vaultFile)
+// DecryptVaultToFile writes the exact payload of the vault and returns where it was written
+func DecryptVaultToFile(vaultFile string, keys Keys, opts DecryptOptions) (string, error) {
+	v, err := vaultino.Open(vaultFile, keys.Provider())
 	if err != nil {
-		return nil, err
-	}
-
-	password, err := getPassword(providedPassword)
-	if err != nil {
-		return nil, fmt.Errorf("error reading password: %w", err)
-	}
-
-	return decrypt(vd.ciphertext, password, vd.salt, vd.nonce)
-}
-
-func buildHeader(file string) string {
-	user := os.Getenv("USER")
-	if user == "" {
-		user = os.Getenv("USERNAME")
+		return "", err
 	}
-	timestamp := time.Now().Format(time.RFC3339)
-	ext := strings.TrimPrefix(filepath.Ext(file), ".")
-	filename := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
 
-	return fmt.Sprintf("$VAULTINO;1.2;AES256-GCM;argon2id;name=%s;type=%s;user=%s;time=%s",
-		filename, ext, user, timestamp)
-}
-
-func extractValue(data []byte, key, fileType string) (string, error) {
-	switch fileType {
-	case "yaml", "yml":
-		return getFromYAML(data, key)
-	case "json":
-		return getFromJSON(data, key)
-	case "env":
-		return getFromENV(data, key)
+	outFile := opts.Output
+	switch {
+	case outFile == "-":
+		return outFile, WriteOutput(outFile, v.Data())
+	case outFile == "":
+		outFile = v.Header().FileName()
 	default:
-		return "", fmt.Errorf("unsupported file type: %s", fileType)
-	}
-}
-
-func getFromYAML(data []byte, key string) (string, error) {
-	// Convert YAML to JSON first, then use gjson for consistent parsing
-	var yamlData interface{}
-	if err := yaml.Unmarshal(data, &yamlData); err != nil {
-		return "", fmt.Errorf("error parsing YAML: %w", err)
-	}
-
-	jsonData, err := json.Marshal(yamlData)
-	if err != nil {
-		return "", fmt.Errorf("error converting YAML to JSON: %w", err)
-	}
-
-	return getValueUsingGjson(string(jsonData), key)
-}
-
-func getFromJSON(data []byte, key string) (string, error) {
-	return getValueUsingGjson(string(data), key)
-}
-
-func getValueUsingGjson(jsonStr string, key string) (string, error) {
-	result := gjson.Get(jsonStr, key)
-	if !result.Exists() {
-		return "", fmt.Errorf("key %s not found", key)
-	}
-	return result.String(), nil
-}
-
-func getFromENV(data []byte, key string) (string, error) {
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, key+"=") {
-			return strings.TrimPrefix(line, key+"="), nil
+		if info, err := os.Stat(outFile); err == nil && info.IsDir() {
+			outFile = filepath.Join(outFile, v.Header().FileName())
 		}
 	}
-	return "", fmt.Errorf("key %s not found", key)
+	return outFile, vaultino.WriteFileAtomic(outFile, v.Data())
 }
 
-func parseHeaderValue(header, key string) string {
-	parts := strings.Split(header, ";")
-	for _, p := range parts {
-		if strings.HasPrefix(p, key+"=") {
-			return strings.TrimPrefix(p, key+"=")
-		}
-	}
-	return ""
+func GetSecretFromVault(vaultFile, key string, keys Keys) (string, error) {
+	return GetSecretFromVaultAs(vaultFile, key, "", keys)
 }
 
-func parseHeaderValueFromFile(vaultFile, key string) string {
-	content, err := os.ReadFile(vaultFile)
+// GetSecretFromVaultAs parses the payload as fileType, the type of the original file is
+// used when fileType is empty
+func GetSecretFromVaultAs(vaultFile, key, fileType string, keys Keys) (string, error) {
+	data, vaultType, err := DecryptVault(vaultFile, keys)
 	if err != nil {
-		return ""
+		return "", err
 	}
-
-	parts := strings.SplitN(string(content), "\n", 2)
-	if len(parts) < 1 {
-		return ""
+	if fileType == "" {
+		fileType = vaultType
 	}
-
-	return parseHeaderValue(parts[0], key)
+	return vaultino.Extract(data, fileType, key)
 }
 
-func getPassword(providedPassword string) ([]byte, error) {
-	if providedPassword != "" {
-		return []byte(providedPassword), nil
+// WriteOutput writes decrypted data to the file with mode 0600, or to stdout when the
+// path is empty or "-"
+func WriteOutput(path string, data []byte) error {
+	if path == "" || path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
 	}
-	return readPassword()
-}
-
-func readPassword() ([]byte, error) {
-	fmt.Print("Enter password: ")
-	pass, err := term.ReadPassword(int(os.Stdin.Fd()))
-	fmt.Println()
-	return pass, err
+	return vaultino.WriteFileAtomic(path, data)
 }